@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"gpusched/internal/protocol"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+)
+
+// ── attach ──────────────────────────────────────────────────────────────────
+
+// detachKey is Ctrl-], which ends an attach session.
+const detachKey = 0x1d
+
+func attachCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "attach NAME",
+		Short: "Connect your terminal to a process started with run --tty",
+		Long: `Connects your terminal to a process started with run --tty, such as a
+REPL or debugger, the way docker attach does. Press Ctrl-] to detach; the
+process keeps running. Output written while nobody is attached is in its
+logs.`,
+		Example: `  gpusched run --name repl --tty -- python -m pdb train.py
+  gpusched attach repl`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params := protocol.AttachParams{Namespace: namespace, Name: args[0]}
+			fd := os.Stdin.Fd()
+			interactive := term.IsTerminal(fd)
+			if interactive {
+				if cols, rows, err := term.GetSize(fd); err == nil {
+					params.Rows, params.Cols = rows, cols
+				}
+			}
+
+			a, err := newClient().Attach(params)
+			if err != nil {
+				return err
+			}
+			defer a.Close()
+
+			if interactive {
+				state, err := term.MakeRaw(fd)
+				if err != nil {
+					return fmt.Errorf("setting up terminal: %w", err)
+				}
+				defer term.Restore(fd, state)
+				stop := onResize(func() {
+					if cols, rows, err := term.GetSize(fd); err == nil {
+						a.Resize(rows, cols)
+					}
+				})
+				defer stop()
+				fmt.Fprintf(os.Stderr, "Attached to %s; press Ctrl-] to detach.\r\n", args[0])
+			}
+
+			// Input stops at end of file, but output keeps coming until
+			// the session ends; only the detach key ends it early.
+			detached := make(chan struct{})
+			go func() {
+				buf := make([]byte, 4096)
+				for {
+					n, err := os.Stdin.Read(buf)
+					if i := bytes.IndexByte(buf[:n], detachKey); interactive && i >= 0 {
+						a.Write(buf[:i])
+						close(detached)
+						return
+					}
+					if n > 0 {
+						if _, err := a.Write(buf[:n]); err != nil {
+							return
+						}
+					}
+					if err != nil {
+						return
+					}
+				}
+			}()
+			ended := make(chan error, 1)
+			go func() {
+				_, err := io.Copy(os.Stdout, a)
+				ended <- err
+			}()
+
+			select {
+			case <-detached:
+				fmt.Fprintf(os.Stderr, "\r\nDetached from %s; it is still running.\r\n", args[0])
+				return nil
+			case err := <-ended:
+				if err != nil {
+					return err
+				}
+				if a.Detail != "" {
+					fmt.Fprintf(os.Stderr, "\r\n%s: %s\r\n", args[0], a.Detail)
+				}
+				return nil
+			}
+		},
+	}
+}
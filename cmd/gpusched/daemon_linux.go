@@ -0,0 +1,466 @@
+//go:build linux
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpusched/internal/bytesize"
+	"gpusched/internal/daemon"
+	"gpusched/internal/discovery"
+	"gpusched/internal/placement"
+	"gpusched/internal/protocol"
+
+	"github.com/spf13/cobra"
+)
+
+// ── daemon ──────────────────────────────────────────────────────────────────
+
+func daemonCmd() *cobra.Command {
+	var ramBudget string
+	var ramMargin string
+	var gpuPoll, sampleInterval time.Duration
+	var envInherit, envDeny, envSet []string
+	var redact []string
+	var quotaSpecs []string
+	var roleSpecs map[string]string
+	var logDir string
+	var historyPath string
+	var eventLogPath string
+	var eventLogMaxSize string
+	var eventLogKeep int
+	var chainHistory bool
+	var shutdownPolicy string
+	var cgroupRoot string
+	var mpsPipeDir string
+	var specDir string
+	var drainTimeout time.Duration
+	var maxSubDrops int
+	var eventRingSize int
+	var eventTypeLimits map[string]int
+	var ckptBinary string
+	var ckptArgs []string
+	var ckptTimeouts map[string]string
+	var idleAfter time.Duration
+	var idleExempt []string
+	var timeSlice time.Duration
+	var maxAutoFreezes int
+	var metricsAddr string
+	var metricsLabels []string
+	var gpuRates map[string]string
+	var frozenOOM string
+	var swapIn string
+	var logMaxSize string
+	var logKeep int
+	var placementName, placementExec string
+	var advertise string
+	var grpcAddr string
+	var httpAddr string
+	var httpHosts []string
+	var tcpAddr string
+	var tlsCert, tlsKey string
+	var gpuReserve, gpuOvercommit map[string]string
+	var nodeName string
+	var peers map[string]string
+	var advertiseInterval time.Duration
+	var readOnly bool
+	var readOnlyListeners []string
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Start the gpusched daemon (run as root for cuda-checkpoint)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			timeouts, err := parseTimeouts(ckptTimeouts)
+			if err != nil {
+				return err
+			}
+			ramBudgetMB, err := bytesize.ParseMB(ramBudget)
+			if err != nil {
+				return fmt.Errorf("--ram-budget: %w", err)
+			}
+			var marginMB int64
+			var marginPct float64
+			if pct, ok := strings.CutSuffix(ramMargin, "%"); ok {
+				marginPct, err = strconv.ParseFloat(pct, 64)
+				if err != nil || marginPct <= 0 || marginPct >= 100 {
+					return fmt.Errorf("--ram-margin: invalid percentage %q", ramMargin)
+				}
+			} else if marginMB, err = bytesize.ParseMB(ramMargin); err != nil {
+				return fmt.Errorf("--ram-margin: %w", err)
+			}
+			if shutdownPolicy != protocol.ShutdownKill && shutdownPolicy != protocol.ShutdownLeave {
+				return fmt.Errorf("--shutdown-policy must be %q or %q", protocol.ShutdownKill, protocol.ShutdownLeave)
+			}
+			if !daemon.ValidOOMPolicy(frozenOOM) {
+				return fmt.Errorf("--frozen-oom-policy must be %q, %q, or empty", daemon.OOMPolicyProtect, daemon.OOMPolicyPrefer)
+			}
+			swapInMB, err := bytesize.ParseMB(swapIn)
+			if err != nil {
+				return fmt.Errorf("--swap-in-rate: %w", err)
+			}
+			logMaxMB, err := bytesize.ParseMB(logMaxSize)
+			if err != nil {
+				return fmt.Errorf("--log-max-size: %w", err)
+			}
+			eventLogMaxMB, err := bytesize.ParseMB(eventLogMaxSize)
+			if err != nil {
+				return fmt.Errorf("--event-log-max-size: %w", err)
+			}
+			var plugin []string
+			if placementExec != "" {
+				plugin = []string{"/bin/sh", "-c", placementExec}
+			}
+			if _, err := placement.New(placementName, plugin); err != nil {
+				return fmt.Errorf("--placement: %w", err)
+			}
+			rates, err := parseRates(gpuRates)
+			if err != nil {
+				return err
+			}
+			reserve, overcommit, err := parseGPUBudgets(gpuReserve, gpuOvercommit)
+			if err != nil {
+				return err
+			}
+			quotas, err := parseQuotas(quotaSpecs)
+			if err != nil {
+				return err
+			}
+			roles, err := parseRoles(roleSpecs)
+			if err != nil {
+				return err
+			}
+			readOnlyOn := make(map[string]bool)
+			for _, l := range readOnlyListeners {
+				if l != "tcp" && l != "http" && l != "grpc" {
+					return fmt.Errorf("--read-only-listener: unknown listener %q (want tcp, http, or grpc)", l)
+				}
+				readOnlyOn[l] = true
+			}
+			if httpAddr != "" {
+				if err := checkExposed("--http", httpAddr, authToken()); err != nil {
+					return err
+				}
+			}
+			if grpcAddr != "" {
+				if err := checkExposed("--grpc-addr", grpcAddr, authToken()); err != nil {
+					return err
+				}
+			}
+			if tcpAddr != "" {
+				if err := checkExposed("--listen-tcp", strings.TrimPrefix(tcpAddr, protocol.TCPPrefix), authToken()); err != nil {
+					return err
+				}
+			}
+			envVars, err := parseEnvSet("--env-set", envSet)
+			if err != nil {
+				return err
+			}
+			for _, l := range metricsLabels {
+				if !daemon.ValidProcessMetricLabel(l) {
+					return fmt.Errorf("--metrics-process-labels: unknown label %q (allowed: %s)", l, strings.Join(daemon.ProcessMetricLabels, ", "))
+				}
+			}
+			cfg := daemon.Config{
+				RAMBudgetMB:            ramBudgetMB,
+				RAMMarginMB:            marginMB,
+				RAMMarginPct:           marginPct,
+				GPUPollInterval:        gpuPoll,
+				SampleInterval:         sampleInterval,
+				Env:                    protocol.EnvPolicy{Inherit: envInherit, Deny: envDeny, Set: envVars},
+				LogDir:                 logDir,
+				LogMaxMB:               logMaxMB,
+				LogKeep:                logKeep,
+				HistoryPath:            historyPath,
+				EventLogPath:           eventLogPath,
+				EventLogMaxMB:          eventLogMaxMB,
+				EventLogKeep:           eventLogKeep,
+				ChainHistory:           chainHistory,
+				ShutdownPolicy:         shutdownPolicy,
+				DrainTimeout:           drainTimeout,
+				MaxSubscriberDrops:     maxSubDrops,
+				EventRingSize:          eventRingSize,
+				EventTypeLimits:        eventTypeLimits,
+				CUDACheckpointBinary:   ckptBinary,
+				CUDACheckpointArgs:     ckptArgs,
+				CUDACheckpointTimeouts: timeouts,
+				IdleFreezeAfter:        idleAfter,
+				IdleExemptNamespaces:   idleExempt,
+				MaxAutoFreezesPerHour:  maxAutoFreezes,
+				TimeSliceQuantum:       timeSlice,
+				MetricsProcessLabels:   metricsLabels,
+				GPURates:               rates,
+				GPUReserveMB:           reserve,
+				GPUOvercommit:          overcommit,
+				FrozenOOMPolicy:        frozenOOM,
+				SwapInMBps:             swapInMB,
+				PlacementStrategy:      placementName,
+				PlacementExec:          plugin,
+				NodeName:               nodeName,
+				Peers:                  peers,
+				PeerToken:              authToken(),
+				PeerTLS:                tlsConf,
+				RedactPatterns:         redact,
+				Quotas:                 quotas,
+				Roles:                  roles,
+				ReadOnly:               readOnly,
+				CgroupRoot:             cgroupRoot,
+				MPSPipeDir:             mpsPipeDir,
+				SpecDir:                specDir,
+				HTTPHosts:              httpHosts,
+			}
+
+			for name, addr := range peers {
+				if !strings.Contains(addr, "://") && !strings.HasPrefix(addr, "/") {
+					peers[name] = protocol.TCPPrefix + addr
+				}
+			}
+
+			d := daemon.New(cfg)
+			d.Start()
+			if metricsAddr != "" {
+				mux := http.NewServeMux()
+				mux.Handle("/metrics", d.MetricsHandler())
+				go func() {
+					if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+						fmt.Fprintf(os.Stderr, "metrics listener: %v\n", err)
+					}
+				}()
+			}
+			if advertise != "" {
+				go d.Advertise(advertise, advertiseInterval, sockPath)
+			}
+			if httpAddr != "" {
+				go func() {
+					if err := http.ListenAndServe(httpAddr, d.HTTPHandler(authToken(), readOnlyOn["http"])); err != nil {
+						fmt.Fprintf(os.Stderr, "http listener: %v\n", err)
+					}
+				}()
+			}
+			if grpcAddr != "" {
+				ln, err := net.Listen("tcp", grpcAddr)
+				if err != nil {
+					return fmt.Errorf("--grpc-addr: %w", err)
+				}
+				gs := d.GRPCServer(authToken(), readOnlyOn["grpc"])
+				defer gs.Stop()
+				go gs.Serve(ln)
+			}
+			srv := daemon.NewServer(d, sockPath)
+			defer srv.Cleanup()
+			if tcpAddr != "" {
+				auth := daemon.TCPAuth{Token: authToken(), ReadOnly: readOnlyOn["tcp"]}
+				if tlsCert != "" || tlsKey != "" {
+					cert, err := tls.LoadX509KeyPair(tlsCert, tlsKey)
+					if err != nil {
+						return fmt.Errorf("--tls-cert/--tls-key: %w", err)
+					}
+					auth.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+				}
+				if auth.Token != "" && auth.TLS == nil {
+					fmt.Fprintf(os.Stderr, "WARNING: --listen-tcp without --tls-cert — the token crosses the network in the clear\n")
+				}
+				if err := srv.ListenTCP(strings.TrimPrefix(tcpAddr, protocol.TCPPrefix), auth); err != nil {
+					return fmt.Errorf("--listen-tcp: %w", err)
+				}
+			} else if tlsCert != "" {
+				return fmt.Errorf("--tls-cert needs --listen-tcp")
+			}
+
+			fmt.Fprintf(os.Stderr, "gpusched v%s — GPU Process Manager\n", version)
+			fmt.Fprintf(os.Stderr, "listening on %s\n", sockPath)
+			if os.Getuid() != 0 {
+				fmt.Fprintf(os.Stderr, "WARNING: not running as root — cuda-checkpoint may fail\n")
+			}
+
+			return srv.ListenAndServe()
+		},
+	}
+
+	cmd.Flags().StringVar(&ramBudget, "ram-budget", "", "max host RAM for snapshots (e.g. 80G, 80Gi, 85GB, 80000M)")
+	cmd.Flags().StringVar(&ramMargin, "ram-margin", "", "free host RAM freezes must leave, as a size or a percentage of total (e.g. 8G, 5%; default 4G)")
+	cmd.Flags().DurationVar(&gpuPoll, "gpu-poll-interval", 5*time.Second, "how often GPUs are re-enumerated for status and hotplug/MIG changes")
+	cmd.Flags().DurationVar(&sampleInterval, "sample-interval", 2*time.Second, "how often host RAM and per-process GPU memory are sampled for status")
+	cmd.Flags().StringToStringVar(&gpuReserve, "gpu-reserve", nil, "memory kept free per GPU, e.g. 0=2G or all=1G; placement and thaw leave it alone")
+	cmd.Flags().StringToStringVar(&gpuOvercommit, "gpu-overcommit", nil, "cap active+frozen memory per GPU at this multiple of its size, e.g. 0=1.5 or all=2")
+	cmd.Flags().StringArrayVar(&quotaSpecs, "quota", nil, "per-user limits as USER:gpus=N,mem=SIZE,snapshots=SIZE; USER is a name, UID, or * for all but root (repeatable)")
+	cmd.Flags().BoolVar(&readOnly, "read-only", false, "refuse everything but status, logs, events, and other reads, on every listener")
+	cmd.Flags().StringSliceVar(&readOnlyListeners, "read-only-listener", nil, "make only these listeners read-only: tcp, http, grpc (e.g. a fleet-view --listen-tcp)")
+	cmd.Flags().StringToStringVar(&roleSpecs, "role", nil, "caller role by USER (name, UID, or * for everyone else): admin, user (own processes only), or readonly (repeatable)")
+	cmd.Flags().StringVar(&logDir, "log-dir", "/tmp/gpusched/logs", "process log directory")
+	cmd.Flags().StringVar(&logMaxSize, "log-max-size", "100M", "rotate a process log once it grows past this size (0 = never)")
+	cmd.Flags().IntVar(&logKeep, "log-keep", 5, "rotated generations kept per process log, as NAME.log.1 (newest) to NAME.log.N")
+	cmd.Flags().StringVar(&shutdownPolicy, "shutdown-policy", protocol.ShutdownKill, "default for processes on daemon exit: kill, or leave (keep running and reattach on next start)")
+	cmd.Flags().StringVar(&cgroupRoot, "cgroup-root", "/sys/fs/cgroup/gpusched", "cgroup v2 directory holding a cgroup per process, for run --cpus and --host-mem (empty disables)")
+	cmd.Flags().StringVar(&mpsPipeDir, "mps-pipe-dir", "", "run an MPS control daemon with its pipes here, for run --mps (empty = off)")
+	cmd.Flags().StringVar(&specDir, "spec-dir", "", "keep the processes specified by the *.json run specs here running (see apply)")
+	cmd.Flags().DurationVar(&drainTimeout, "drain-timeout", 10*time.Second, "time between SIGTERM and SIGKILL for processes killed on shutdown")
+	cmd.Flags().IntVar(&eventRingSize, "event-ring-size", 1000, "number of recent events kept in memory")
+	cmd.Flags().StringToIntVar(&eventTypeLimits, "event-type-limit", nil, "cap an event type within the ring, e.g. reconcile=50,liveness-failed=100")
+	cmd.Flags().IntVar(&maxSubDrops, "max-subscriber-drops", 0, "disconnect event subscribers after this many dropped events (0 = never)")
+	cmd.Flags().StringVar(&historyPath, "history-file", "", "operation history file (default: ops.jsonl next to --log-dir)")
+	cmd.Flags().StringVar(&eventLogPath, "event-log", "", "file every event is appended to, for 'gpusched events' (default: events.jsonl next to --log-dir)")
+	cmd.Flags().StringVar(&eventLogMaxSize, "event-log-max-size", "100M", "rotate the event log once it grows past this size (0 = never)")
+	cmd.Flags().IntVar(&eventLogKeep, "event-log-keep", 5, "rotated generations of the event log kept, searched by 'gpusched events'")
+	cmd.Flags().BoolVar(&chainHistory, "hash-chain", false, "hash-chain the operation history so edits are detectable (see audit verify)")
+	cmd.Flags().StringArrayVar(&envInherit, "env-inherit", nil, "pass only daemon environment variables matching this glob to processes (repeatable; default all)")
+	cmd.Flags().StringArrayVar(&envDeny, "env-deny", nil, "never pass daemon environment variables matching this glob, e.g. 'AWS_*' (repeatable)")
+	cmd.Flags().StringArrayVar(&envSet, "env-set", nil, "set KEY=VALUE in every process; VALUE may use {{.User}}, {{.Home}}, {{.Name}}, {{.GPU}} (repeatable)")
+	cmd.Flags().StringSliceVar(&redact, "redact", daemon.DefaultRedactPatterns, "globs over variable and flag names whose values are masked in reported commands (empty disables)")
+	cmd.Flags().StringVar(&ckptBinary, "cuda-checkpoint", "", "path to the cuda-checkpoint binary (default: search PATH)")
+	cmd.Flags().StringArrayVar(&ckptArgs, "cuda-checkpoint-arg", nil, "extra argument passed to every cuda-checkpoint call (repeatable)")
+	cmd.Flags().StringToStringVar(&ckptTimeouts, "cuda-checkpoint-timeout", nil, "per-action timeout, e.g. checkpoint=120s,restore=60s")
+	cmd.Flags().DurationVar(&idleAfter, "idle-freeze-after", 0, "freeze processes holding GPU memory with no GPU utilization for this long (0 = never)")
+	cmd.Flags().StringArrayVar(&idleExempt, "idle-exempt-namespace", nil, "namespace never frozen for idleness (repeatable)")
+	cmd.Flags().DurationVar(&timeSlice, "time-slice", 0, "how long each run --time-slice process gets its GPU before the next one's turn (0 = off)")
+	cmd.Flags().IntVar(&maxAutoFreezes, "max-auto-freezes", 0, "cap on idle/liveness freezes per process per hour (0 = unlimited)")
+	cmd.Flags().StringVar(&frozenOOM, "frozen-oom-policy", "", "oom_score_adj for frozen processes: protect (killed last) or prefer (killed first)")
+	cmd.Flags().StringVar(&placementName, "placement", placement.Spread, "GPU placement strategy for --auto-gpu: "+strings.Join(placement.Names, ", "))
+	cmd.Flags().StringVar(&placementExec, "placement-exec", "", "plugin command for --placement exec (GPUs as JSON on stdin, scores on stdout)")
+	cmd.Flags().StringVar(&swapIn, "swap-in-rate", "200M", "assumed swap read rate per second, for thaw penalty estimates")
+	cmd.Flags().StringToStringVar(&gpuRates, "gpu-rate", nil, "cost per GPU-hour by device model substring, e.g. H100=3.50,A100=1.80")
+	cmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "serve Prometheus metrics on this address, e.g. 127.0.0.1:9464")
+	cmd.Flags().StringSliceVar(&metricsLabels, "metrics-process-labels", daemon.ProcessMetricLabels, "labels on per-process series (name,gpu,state,owner; empty disables them)")
+	cmd.Flags().StringVar(&httpAddr, "http", "", "serve the REST API on this address, e.g. 127.0.0.1:8080 (needs --token unless loopback; clients send Authorization: Bearer TOKEN)")
+	cmd.Flags().StringArrayVar(&httpHosts, "http-host", nil, "host name the REST API may be reached by, besides IP addresses, localhost, and --node-name (repeatable)")
+	cmd.Flags().StringVar(&tcpAddr, "listen-tcp", "", "also serve the CLI protocol on this TCP address, e.g. tcp://0.0.0.0:9465, for --host clients; needs --token unless loopback (see --tls-cert)")
+	cmd.Flags().StringVar(&tlsCert, "tls-cert", "", "serve --listen-tcp over TLS with this PEM certificate (clients use tls://HOST:PORT)")
+	cmd.Flags().StringVar(&tlsKey, "tls-key", "", "PEM private key for --tls-cert")
+	cmd.Flags().StringVar(&nodeName, "node-name", "", "this daemon's name in a cluster (default: hostname)")
+	cmd.Flags().StringToStringVar(&peers, "peer", nil, "cluster peer as NAME=ADDR, ADDR being tcp://HOST:PORT of its --listen-tcp (repeatable)")
+	cmd.Flags().StringVar(&grpcAddr, "grpc-addr", "", "serve the gRPC API on this TCP address, e.g. 127.0.0.1:9466 (needs --token unless loopback; clients send authorization: Bearer TOKEN)")
+	cmd.Flags().StringVar(&advertise, "advertise", "", "broadcast UDP beacons for 'nodes discover' to this address, e.g. "+discovery.DefaultAddr)
+	cmd.Flags().DurationVar(&advertiseInterval, "advertise-interval", discovery.DefaultInterval, "time between beacons")
+
+	return cmd
+}
+
+// checkExposed refuses a listener for flag on addr that other machines
+// could reach without a token, since callers there would get the same
+// role as a local one: admin when --role isn't set.
+func checkExposed(flag, addr, token string) error {
+	if token != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", flag, err)
+	}
+	if ip := net.ParseIP(host); host == "localhost" || ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("%s %s can be reached from other machines: set --token or $GPUSCHED_TOKEN, or use a loopback address such as 127.0.0.1", flag, addr)
+}
+
+// parseGPUBudgets reads --gpu-reserve and --gpu-overcommit, whose keys are
+// GPU indices or "all".
+func parseGPUBudgets(reserve, overcommit map[string]string) (map[int]int64, map[int]float64, error) {
+	key := func(flag, k string) (int, error) {
+		if k == "all" {
+			return daemon.AllGPUs, nil
+		}
+		idx, err := strconv.Atoi(k)
+		if err != nil || idx < 0 {
+			return 0, fmt.Errorf("%s: want a GPU index or all, got %q", flag, k)
+		}
+		return idx, nil
+	}
+	reserveMB := make(map[int]int64, len(reserve))
+	for k, v := range reserve {
+		idx, err := key("--gpu-reserve", k)
+		if err != nil {
+			return nil, nil, err
+		}
+		if reserveMB[idx], err = bytesize.ParseMB(v); err != nil {
+			return nil, nil, fmt.Errorf("--gpu-reserve %s: %w", k, err)
+		}
+	}
+	ratios := make(map[int]float64, len(overcommit))
+	for k, v := range overcommit {
+		idx, err := key("--gpu-overcommit", k)
+		if err != nil {
+			return nil, nil, err
+		}
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 1 {
+			return nil, nil, fmt.Errorf("--gpu-overcommit %s: want a ratio of at least 1, got %q", k, v)
+		}
+		ratios[idx] = r
+	}
+	return reserveMB, ratios, nil
+}
+
+// lookupUID resolves a --quota or --role USER: a login name, a UID, or *
+// for AnyUser.
+func lookupUID(flag, who string) (int, error) {
+	if who == "*" {
+		return daemon.AnyUser, nil
+	}
+	if uid, err := strconv.Atoi(who); err == nil {
+		return uid, nil
+	}
+	u, err := user.Lookup(who)
+	if err != nil {
+		return 0, fmt.Errorf("%s: unknown user %q", flag, who)
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// parseRoles reads --role USER=ROLE.
+func parseRoles(specs map[string]string) (map[int]string, error) {
+	roles := make(map[int]string, len(specs))
+	for who, role := range specs {
+		if !daemon.ValidRole(role) {
+			return nil, fmt.Errorf("--role %s: unknown role %q (want admin, user, or readonly)", who, role)
+		}
+		uid, err := lookupUID("--role", who)
+		if err != nil {
+			return nil, err
+		}
+		roles[uid] = role
+	}
+	return roles, nil
+}
+
+// parseQuotas reads --quota USER:gpus=N,mem=SIZE,snapshots=SIZE, USER
+// being a login name, a UID, or * for everyone but root.
+func parseQuotas(specs []string) (map[int]protocol.Quota, error) {
+	quotas := make(map[int]protocol.Quota, len(specs))
+	for _, spec := range specs {
+		who, limits, ok := strings.Cut(spec, ":")
+		if !ok || limits == "" {
+			return nil, fmt.Errorf("--quota: want USER:gpus=N,mem=SIZE,snapshots=SIZE, got %q", spec)
+		}
+		uid, err := lookupUID("--quota", who)
+		if err != nil {
+			return nil, err
+		}
+		var q protocol.Quota
+		for _, kv := range strings.Split(limits, ",") {
+			k, v, _ := strings.Cut(kv, "=")
+			var err error
+			switch k {
+			case "gpus":
+				q.GPUs, err = strconv.Atoi(v)
+			case "mem":
+				q.GPUMemMB, err = bytesize.ParseMB(v)
+			case "snapshots":
+				q.SnapshotMB, err = bytesize.ParseMB(v)
+			default:
+				return nil, fmt.Errorf("--quota %s: unknown limit %q (want gpus, mem, or snapshots)", who, k)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("--quota %s: bad %s %q", who, k, v)
+			}
+		}
+		quotas[uid] = q
+	}
+	return quotas, nil
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// ── daemon ──────────────────────────────────────────────────────────────────
+
+// daemonCmd is a placeholder on platforms without cuda-checkpoint: this
+// build is a client for daemons running elsewhere.
+func daemonCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "daemon",
+		Short: "Start the gpusched daemon (Linux only)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return fmt.Errorf("the daemon runs only on Linux; start it on the GPU host with --listen-tcp and point this client at it with --host HOST:PORT")
+		},
+	}
+}
@@ -3,15 +3,29 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"net/smtp"
 	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"gpusched/internal/audit"
+	"gpusched/internal/bytesize"
+	"gpusched/internal/checkpoint"
 	"gpusched/internal/client"
-	"gpusched/internal/daemon"
+	"gpusched/internal/discovery"
 	"gpusched/internal/protocol"
+	"gpusched/internal/report"
 	"gpusched/internal/tui"
 
 	"github.com/spf13/cobra"
@@ -25,24 +39,64 @@ var (
 
 var sockPath string
 
+// host, token, and tlsCA reach a daemon's TCP listener; see newClient.
+var host, token, tlsCA string
+var tlsConf *tls.Config
+
+// namespace scopes process names; see defaultNamespace.
+var namespace string
+var node string
+
 func main() {
 	root := &cobra.Command{
 		Use:     "gpusched",
 		Short:   "GPU Process Manager — systemd for GPU processes",
 		Version: version,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			tlsConf, err = loadTLSCA(tlsCA)
+			return err
+		},
 	}
 
-	root.PersistentFlags().StringVarP(&sockPath, "socket", "s", daemon.DefaultSocket, "daemon socket path")
+	root.PersistentFlags().StringVarP(&sockPath, "socket", "s", protocol.DefaultSocket, "daemon socket path, or tcp://HOST:PORT for a daemon started with --listen-tcp")
+	root.PersistentFlags().StringVar(&host, "host", os.Getenv("GPUSCHED_HOST"), "remote daemon as HOST:PORT, tcp://HOST:PORT, or tls://HOST:PORT; overrides --socket (default: $GPUSCHED_HOST)")
+	root.PersistentFlags().StringVar(&token, "token", "", "token for a daemon's TCP listener; the daemon's own for --listen-tcp and its peers (default: $GPUSCHED_TOKEN)")
+	root.PersistentFlags().StringVar(&tlsCA, "tls-ca", os.Getenv("GPUSCHED_TLS_CA"), "PEM certificates to trust for tls:// daemons, e.g. a self-signed --tls-cert (default: system roots)")
+	root.PersistentFlags().StringVar(&namespace, "namespace", defaultNamespace(), "process namespace (default: $GPUSCHED_NAMESPACE or the current user)")
+	root.PersistentFlags().StringVar(&node, "node", "", "run the command on this cluster peer of the daemon (see daemon --peer)")
 
 	root.AddCommand(
 		daemonCmd(),
 		runCmd(),
 		freezeCmd(),
 		thawCmd(),
+		parkCmd(),
+		unparkCmd(),
+		recoverCmd(),
 		killCmd(),
+		queueCmd(),
+		groupCmd(),
 		statusCmd(),
+		describeCmd(),
+		annotateCmd(),
+		checkpointedCmd(),
+		adoptCmd(),
 		logsCmd(),
+		attachCmd(),
 		migrateCmd(),
+		planCmd(),
+		opsCmd(),
+		eventsCmd(),
+		auditCmd(),
+		reportCmd(),
+		usageCmd(),
+		gcCmd(),
+		applyCmd(),
+		kernelCmd(),
+		nodesCmd(),
+		selftestCmd(),
+		debugCmd(),
 		dashboardCmd(),
 	)
 
@@ -51,66 +105,264 @@ func main() {
 	}
 }
 
-// ── daemon ──────────────────────────────────────────────────────────────────
-
-func daemonCmd() *cobra.Command {
-	var ramBudget string
-	var logDir string
-
-	cmd := &cobra.Command{
-		Use:   "daemon",
-		Short: "Start the gpusched daemon (run as root for cuda-checkpoint)",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg := daemon.Config{
-				RAMBudgetMB: parseMB(ramBudget),
-				LogDir:      logDir,
-			}
-
-			d := daemon.New(cfg)
-			srv := daemon.NewServer(d, sockPath)
-			defer srv.Cleanup()
-
-			fmt.Fprintf(os.Stderr, "gpusched v%s — GPU Process Manager\n", version)
-			fmt.Fprintf(os.Stderr, "listening on %s\n", sockPath)
-			if os.Getuid() != 0 {
-				fmt.Fprintf(os.Stderr, "WARNING: not running as root — cuda-checkpoint may fail\n")
-			}
+// newClient connects to --host, or else --socket, relaying calls to
+// --node if set.
+func newClient() *client.Client {
+	addr := sockPath
+	if host != "" {
+		addr = host
+		if !strings.Contains(host, "://") {
+			addr = protocol.TCPPrefix + host
+		}
+	}
+	return client.New(addr).OnNode(node).WithToken(authToken()).WithTLS(tlsConf)
+}
 
-			return srv.ListenAndServe()
-		},
+// authToken is --token, else $GPUSCHED_TOKEN (which, unlike the flag,
+// doesn't show up in ps).
+func authToken() string {
+	if token != "" {
+		return token
 	}
+	return os.Getenv("GPUSCHED_TOKEN")
+}
 
-	cmd.Flags().StringVar(&ramBudget, "ram-budget", "", "max host RAM for snapshots (e.g. 80G, 80000M)")
-	cmd.Flags().StringVar(&logDir, "log-dir", "/tmp/gpusched/logs", "process log directory")
+// loadTLSCA returns a TLS configuration trusting the PEM certificates in
+// file, or nil (the system roots) if file is empty.
+func loadTLSCA(file string) (*tls.Config, error) {
+	if file == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("--tls-ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("--tls-ca: no certificates in %s", file)
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
 
-	return cmd
+// defaultNamespace is $GPUSCHED_NAMESPACE, else the current user's name.
+func defaultNamespace() string {
+	if ns := os.Getenv("GPUSCHED_NAMESPACE"); ns != "" {
+		return ns
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
 }
 
 // ── run ─────────────────────────────────────────────────────────────────────
 
 func runCmd() *cobra.Command {
 	var name string
-	var gpuID int
+	var gpuArg string
 	var dir string
+	var shell bool
+	var expandEnv bool
+	var ckptArgs []string
+	var ckptTimeouts map[string]string
+	var readyTCP, readyHTTP, readyExec string
+	var readyTimeout time.Duration
+	var liveTCP, liveHTTP, liveExec, liveAction string
+	var liveGPUMem bool
+	var onShutdown string
+	var restart string
+	var wakePaths, wakeFIFOs []string
+	var maxAutoFreezes int
+	var livePeriod time.Duration
+	var liveFailures int
+	var serverKind, serverURL string
+	var drainTimeout time.Duration
+	var autoGPU bool
+	var exclusive bool
+	var group string
+	var envInherit, envDeny, envSet []string
+	var input, output string
+	var tty bool
+	var queue bool
+	var timeSlice, mps bool
+	var maxRuntime, ttlAfterExit time.Duration
+	var maxRuntimeAction string
+	var mem string
+	var cpus float64
+	var gpuMemLimit, gpuMemLimitAction, gpuMemLimitSignal string
+	var nice, oomScoreAdj int
+	var ioClass string
+	var hostMem string
+	var drainStop, drainResume string
 
 	cmd := &cobra.Command{
 		Use:   "run [flags] -- COMMAND [ARGS...]",
 		Short: "Spawn a managed GPU process",
 		Example: `  gpusched run --name train -- python train.py
-  gpusched run --name eval --gpu 1 -- python eval.py`,
+  gpusched run --name eval --gpu 1 -- python eval.py
+  gpusched run --name bench --gpu auto --exclusive -- python bench.py
+  gpusched run --name sweep --shell -- 'python sweep.py | tee sweep.out'
+  gpusched run --name ft --expand-env -- python ft.py --out '$HOME/ckpt'
+  gpusched run --name batch --input prompts.jsonl --output answers.jsonl -- python infer.py
+  gpusched run --name sweep-3 --queue --mem 40G -- python sweep.py --trial 3
+  gpusched run --name prep --cpus 8 --host-mem 64G -- python preprocess.py
+  gpusched run --name exp-a --time-slice -- python train.py
+  gpusched run --name sweep-7 --max-runtime 6h --ttl-after-exit 1h -- python sweep.py
+  gpusched run --name tenant-b --gpu-mem-limit 20G --gpu-mem-limit-action freeze -- python serve.py
+  gpusched run --name sweep-4 --nice 19 --ionice-class idle --oom-score-adj 500 -- python sweep.py
+  gpusched run --name train --restart on-failure:5 -- python train.py --resume
+  gpusched run --name repl --tty -- python -m pdb train.py
+  gpusched run --name worker --wake-on ./inbox --wake-fifo ./jobs -- python worker.py
+  gpusched run --name llama --server vllm -- vllm serve meta-llama/Llama-3.1-8B`,
 		Args:               cobra.MinimumNArgs(1),
 		DisableFlagParsing: false,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if name == "" {
 				name = args[0]
 			}
+			if shell && expandEnv {
+				return fmt.Errorf("--expand-env can't be combined with --shell, which expands $VAR itself")
+			}
+			timeouts, err := parseTimeouts(ckptTimeouts)
+			if err != nil {
+				return err
+			}
+			timeoutsMs := make(map[string]int64, len(timeouts))
+			for action, d := range timeouts {
+				timeoutsMs[action] = d.Milliseconds()
+			}
+			readiness, err := buildProbe(readyTCP, readyHTTP, readyExec, false)
+			if err != nil {
+				return err
+			}
+			if readiness != nil {
+				readiness.TimeoutMs = readyTimeout.Milliseconds()
+			}
+			liveness, err := buildProbe(liveTCP, liveHTTP, liveExec, liveGPUMem)
+			if err != nil {
+				return err
+			}
+			if liveness != nil {
+				liveness.PeriodMs = livePeriod.Milliseconds()
+				liveness.FailureThreshold = liveFailures
+			}
+			var server *protocol.InferenceServer
+			if serverKind != "" {
+				server = &protocol.InferenceServer{
+					Kind:           serverKind,
+					URL:            serverURL,
+					DrainTimeoutMs: drainTimeout.Milliseconds(),
+				}
+			} else if serverURL != "" {
+				return fmt.Errorf("--server-url requires --server")
+			}
+			var hooks *protocol.DrainHooks
+			if drainStop != "" || drainResume != "" {
+				hooks = &protocol.DrainHooks{}
+				if drainStop != "" {
+					hooks.Stop = []string{"/bin/sh", "-c", drainStop}
+				}
+				if drainResume != "" {
+					hooks.Resume = []string{"/bin/sh", "-c", drainResume}
+				}
+			}
+
+			gpuID, auto, err := parseGPUArg(gpuArg)
+			if err != nil {
+				return err
+			}
+			restartPolicy, restartMax, err := parseRestartArg(restart)
+			if err != nil {
+				return err
+			}
+			var env *protocol.EnvPolicy
+			if len(envInherit)+len(envDeny)+len(envSet) > 0 {
+				vars, err := parseEnvSet("--env", envSet)
+				if err != nil {
+					return err
+				}
+				env = &protocol.EnvPolicy{Inherit: envInherit, Deny: envDeny, Set: vars}
+			}
+			memMB, err := bytesize.ParseMB(mem)
+			if err != nil {
+				return fmt.Errorf("--mem: %w", err)
+			}
+			if queue && memMB <= 0 {
+				return fmt.Errorf("--queue needs --mem, the GPU memory the job will use")
+			}
+			hostMemMB, err := bytesize.ParseMB(hostMem)
+			if err != nil {
+				return fmt.Errorf("--host-mem: %w", err)
+			}
+			gpuMemLimitMB, err := bytesize.ParseMB(gpuMemLimit)
+			if err != nil {
+				return fmt.Errorf("--gpu-mem-limit: %w", err)
+			}
+			// Without --dir the daemon's working directory is not ours, so
+			// pin relative paths to where the command was typed.
+			paths := []*string{&input, &output}
+			for i := range wakePaths {
+				paths = append(paths, &wakePaths[i])
+			}
+			for i := range wakeFIFOs {
+				paths = append(paths, &wakeFIFOs[i])
+			}
+			for _, path := range paths {
+				if *path != "" && dir == "" {
+					if *path, err = filepath.Abs(*path); err != nil {
+						return err
+					}
+				}
+			}
+			var wake *protocol.WakeTriggers
+			if len(wakePaths)+len(wakeFIFOs) > 0 {
+				wake = &protocol.WakeTriggers{Paths: wakePaths, FIFOs: wakeFIFOs}
+			}
 
-			c := client.New(sockPath)
+			c := newClient()
 			resp, err := c.Call("run", protocol.RunParams{
-				Name: name,
-				Cmd:  args,
-				Dir:  dir,
-				GPU:  gpuID,
+				Namespace:          namespace,
+				Name:               name,
+				Cmd:                args,
+				Dir:                dir,
+				GPU:                gpuID,
+				AutoGPU:            auto || autoGPU,
+				Exclusive:          exclusive,
+				Group:              group,
+				Env:                env,
+				Input:              input,
+				Output:             output,
+				TTY:                tty,
+				Queue:              queue,
+				TimeSlice:          timeSlice,
+				MPS:                mps,
+				MaxRuntimeMs:       maxRuntime.Milliseconds(),
+				MaxRuntimeAction:   maxRuntimeAction,
+				TTLAfterExitMs:     ttlAfterExit.Milliseconds(),
+				MemMB:              memMB,
+				GPUMemLimitMB:      gpuMemLimitMB,
+				GPUMemLimitAction:  gpuMemLimitAction,
+				GPUMemLimitSignal:  gpuMemLimitSignal,
+				Nice:               nice,
+				IOClass:            ioClass,
+				OOMScoreAdj:        oomScoreAdj,
+				CPUs:               cpus,
+				HostMemMB:          hostMemMB,
+				Shell:              shell,
+				ExpandEnv:          expandEnv,
+				CheckpointArgs:     ckptArgs,
+				CheckpointTimeouts: timeoutsMs,
+				Readiness:          readiness,
+				Liveness:           liveness,
+				LivenessAction:     liveAction,
+				OnShutdown:         onShutdown,
+				Wake:               wake,
+				Restart:            restartPolicy,
+				RestartMax:         restartMax,
+
+				MaxAutoFreezesPerHour: maxAutoFreezes,
+				Inference:             server,
+				DrainHooks:            hooks,
 			})
 			if err != nil {
 				return err
@@ -121,28 +373,128 @@ func runCmd() *cobra.Command {
 
 			var result protocol.RunResult
 			json.Unmarshal(resp.Result, &result)
-			fmt.Printf("Started %s (pid=%d)\n", result.Name, result.PID)
+			if result.Queued {
+				fmt.Printf("Queued %s (position %d); it starts when %s is free\n", result.Name, result.Position, mem)
+				return nil
+			}
+			fmt.Printf("Started %s (pid=%d, gpu=%d)\n", result.Name, result.PID, result.GPU)
+			if tty {
+				fmt.Printf("Attach with 'gpusched attach %s'\n", name)
+			}
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVarP(&name, "name", "n", "", "process name (default: command name)")
-	cmd.Flags().IntVarP(&gpuID, "gpu", "g", 0, "GPU device index")
+	cmd.Flags().StringVarP(&gpuArg, "gpu", "g", "auto", "GPU device index, or auto to let the daemon's placement strategy pick")
+	cmd.Flags().BoolVar(&autoGPU, "auto-gpu", false, "same as --gpu auto")
+	cmd.Flags().MarkDeprecated("auto-gpu", "use --gpu auto")
+	cmd.Flags().BoolVar(&exclusive, "exclusive", false, "keep other managed processes off the GPU while this one is active")
+	cmd.Flags().StringVar(&group, "group", "", "add the process to a group that 'gpusched group' freezes, thaws, migrates, and kills together")
+	cmd.Flags().StringArrayVar(&envSet, "env", nil, "set KEY=VALUE in the process environment (repeatable)")
+	cmd.Flags().StringArrayVar(&envInherit, "env-inherit", nil, "pass only daemon environment variables matching this glob (repeatable; replaces the daemon's list; admins only)")
+	cmd.Flags().StringArrayVar(&envDeny, "env-deny", nil, "don't pass daemon environment variables matching this glob (repeatable)")
 	cmd.Flags().StringVarP(&dir, "dir", "d", "", "working directory")
+	cmd.Flags().BoolVar(&queue, "queue", false, "if the GPU lacks --mem free, wait in the daemon's queue instead of starting now")
+	cmd.Flags().StringVar(&mem, "mem", "", "GPU memory the job needs, e.g. 40G (required with --queue)")
+	cmd.Flags().DurationVar(&maxRuntime, "max-runtime", 0, "stop the process once it has been active this long, not counting time frozen (0 = no limit)")
+	cmd.Flags().StringVar(&maxRuntimeAction, "max-runtime-action", "", "what --max-runtime does: kill or freeze (default kill)")
+	cmd.Flags().DurationVar(&ttlAfterExit, "ttl-after-exit", 0, "remove the process from status this long after it exits (0 = keep)")
+	cmd.Flags().BoolVar(&timeSlice, "time-slice", false, "take turns on the GPU with its other --time-slice processes (needs daemon --time-slice)")
+	cmd.Flags().BoolVar(&mps, "mps", false, "share the GPU's compute with other clients of the daemon's MPS server; can't be frozen (needs daemon --mps-pipe-dir)")
+	cmd.Flags().StringVar(&gpuMemLimit, "gpu-mem-limit", "", "most GPU memory the process may use, e.g. 20G")
+	cmd.Flags().StringVar(&gpuMemLimitAction, "gpu-mem-limit-action", "", "when over --gpu-mem-limit: kill, freeze, or signal (default kill)")
+	cmd.Flags().StringVar(&gpuMemLimitSignal, "gpu-mem-limit-signal", "", "signal sent by --gpu-mem-limit-action signal, e.g. USR1 (default TERM)")
+	cmd.Flags().IntVar(&nice, "nice", 0, "CPU niceness, -20 (favored) to 19 (background)")
+	cmd.Flags().StringVar(&ioClass, "ionice-class", "", "disk I/O scheduling class: idle, best-effort, or realtime")
+	cmd.Flags().IntVar(&oomScoreAdj, "oom-score-adj", 0, "oom_score_adj, -1000 (never OOM-killed) to 1000 (killed first)")
+	cmd.Flags().Float64Var(&cpus, "cpus", 0, "limit the process to this many CPUs, e.g. 8 or 0.5 (needs cgroup v2)")
+	cmd.Flags().StringVar(&hostMem, "host-mem", "", "limit the process's host memory, e.g. 64G (needs cgroup v2)")
+	cmd.Flags().StringVar(&input, "input", "", "file or named pipe to read stdin from (default /dev/null; admins only)")
+	cmd.Flags().StringVar(&output, "output", "", "file or named pipe to write stdout to instead of the log (stderr stays in the log; admins only)")
+	cmd.Flags().BoolVarP(&tty, "tty", "t", false, "run on a terminal that 'gpusched attach' can connect to, for REPLs and debuggers")
+	cmd.Flags().BoolVar(&shell, "shell", false, "run the command via /bin/sh -c instead of exec'ing it directly")
+	cmd.Flags().BoolVar(&expandEnv, "expand-env", false, "expand $VAR references in arguments from the process environment (not with --shell)")
+	cmd.Flags().StringArrayVar(&ckptArgs, "checkpoint-arg", nil, "extra cuda-checkpoint argument for this process (repeatable)")
+	cmd.Flags().StringToStringVar(&ckptTimeouts, "checkpoint-timeout", nil, "per-action cuda-checkpoint timeout, e.g. checkpoint=300s")
+	cmd.Flags().StringVar(&readyTCP, "ready-tcp", "", "readiness probe after thaw: TCP address to connect to")
+	cmd.Flags().StringVar(&readyHTTP, "ready-http", "", "readiness probe after thaw: URL that must return 2xx/3xx")
+	cmd.Flags().StringVar(&readyExec, "ready-exec", "", "readiness probe after thaw: shell command that must exit 0 (admins only)")
+	cmd.Flags().DurationVar(&readyTimeout, "ready-timeout", 0, "how long thaw waits for readiness (default 60s)")
+	cmd.Flags().StringVar(&liveTCP, "live-tcp", "", "liveness probe: TCP address to connect to")
+	cmd.Flags().StringVar(&liveHTTP, "live-http", "", "liveness probe: URL that must return 2xx/3xx")
+	cmd.Flags().StringVar(&liveExec, "live-exec", "", "liveness probe: shell command that must exit 0 (admins only)")
+	cmd.Flags().BoolVar(&liveGPUMem, "live-gpu-mem", false, "liveness probe: process must still hold GPU memory")
+	cmd.Flags().DurationVar(&livePeriod, "live-period", 0, "interval between liveness checks (default 1s)")
+	cmd.Flags().IntVar(&liveFailures, "live-failures", 0, "consecutive failures before acting (default 3)")
+	cmd.Flags().StringVar(&onShutdown, "on-shutdown", "", "when the daemon exits: kill, or leave running to reattach (default: daemon policy)")
+	cmd.Flags().StringArrayVar(&wakePaths, "wake-on", nil, "thaw when frozen and this file, or a file in this directory, changes (repeatable; admins only)")
+	cmd.Flags().StringArrayVar(&wakeFIFOs, "wake-fifo", nil, "thaw when frozen and data is written to this named pipe, created if missing (repeatable; admins only)")
+	cmd.Flags().StringVar(&restart, "restart", "", "relaunch the command when it exits: always, or on-failure[:MAX] (default no)")
+	cmd.Flags().IntVar(&maxAutoFreezes, "max-auto-freezes", 0, "cap on idle/liveness freezes of this process per hour (default: daemon setting)")
+	cmd.Flags().StringVar(&liveAction, "live-action", "", "on liveness failure: alert, freeze, or restart (default alert)")
+	cmd.Flags().StringVar(&serverKind, "server", "", "inference server profile: vllm or tgi (health probes, drain before freeze, request-aware idle)")
+	cmd.Flags().StringVar(&serverURL, "server-url", "", "inference server base URL (default: the server's usual local port)")
+	cmd.Flags().DurationVar(&drainTimeout, "drain-timeout", 0, "how long a freeze waits for in-flight requests (default 30s)")
+	cmd.Flags().StringVar(&drainStop, "drain-stop", "", "shell command that stops the process taking new work, run by freeze --drain (admins only)")
+	cmd.Flags().StringVar(&drainResume, "drain-resume", "", "shell command that undoes --drain-stop, run after thaw (admins only)")
 
 	return cmd
 }
 
+// parseGPUArg reads run's --gpu: a device index, or "auto".
+func parseGPUArg(arg string) (gpu int, auto bool, err error) {
+	if arg == "auto" {
+		return 0, true, nil
+	}
+	gpu, err = strconv.Atoi(arg)
+	if err != nil || gpu < 0 {
+		return 0, false, fmt.Errorf("--gpu: want a device index or auto, got %q", arg)
+	}
+	return gpu, false, nil
+}
+
+// parseRestartArg reads run's --restart: no, always, or on-failure with an
+// optional cap on relaunches, as in on-failure:5.
+func parseRestartArg(arg string) (policy string, max int, err error) {
+	policy, n, capped := strings.Cut(arg, ":")
+	switch policy {
+	case "", protocol.RestartNo, protocol.RestartAlways:
+		if !capped {
+			return policy, 0, nil
+		}
+	case protocol.RestartOnFailure:
+		if !capped {
+			return policy, 0, nil
+		}
+		if max, err = strconv.Atoi(n); err == nil && max > 0 {
+			return policy, max, nil
+		}
+	}
+	return "", 0, fmt.Errorf("--restart: want no, always, or on-failure[:MAX], got %q", arg)
+}
+
 // ── freeze ──────────────────────────────────────────────────────────────────
 
 func freezeCmd() *cobra.Command {
-	return &cobra.Command{
+	var drain bool
+	var drainTimeout time.Duration
+
+	cmd := &cobra.Command{
 		Use:   "freeze NAME",
 		Short: "Checkpoint a process to host RAM (frees GPU)",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c := client.New(sockPath)
-			resp, err := c.Call("freeze", protocol.NameParams{Name: args[0]})
+			if drainTimeout != 0 && !drain {
+				return fmt.Errorf("--drain-timeout requires --drain")
+			}
+			c := newClient()
+			resp, err := c.Call("freeze", protocol.FreezeParams{
+				Namespace:      namespace,
+				Name:           args[0],
+				Drain:          drain,
+				DrainTimeoutMs: drainTimeout.Milliseconds(),
+			})
 			if err != nil {
 				return err
 			}
@@ -152,10 +504,33 @@ func freezeCmd() *cobra.Command {
 
 			var result protocol.FreezeResult
 			json.Unmarshal(resp.Result, &result)
-			fmt.Printf("Frozen %s → ram (%d ms)\n", result.Name, result.DurationMs)
+			if result.DrainMs > 0 {
+				fmt.Printf("Drained %s (%d ms)\n", result.Name, result.DrainMs)
+			}
+			fmt.Printf("Frozen %s → ram (%d ms)%s\n", result.Name, result.DurationMs, formatPhases(result.Phases))
+			if g := result.GPUFree; g != nil {
+				printGPUFree(*g)
+				if result.MemMB > 0 && g.DeltaMB() < result.MemMB/2 {
+					fmt.Printf("WARNING: GPU %d gained %s free, but %s held %s\n",
+						g.GPU, bytesize.FormatMB(g.DeltaMB()), result.Name, bytesize.FormatMB(result.MemMB))
+				}
+			}
 			return nil
 		},
 	}
+	cmd.Flags().BoolVar(&drain, "drain", false, "stop new work (--drain-stop hook) and wait for in-flight requests before freezing; refuse if they don't finish")
+	cmd.Flags().DurationVar(&drainTimeout, "drain-timeout", 0, "how long --drain waits (default: the process's drain timeout, or 30s)")
+	return cmd
+}
+
+// printGPUFree shows how a freeze or thaw moved its GPU's free memory.
+func printGPUFree(g protocol.GPUMemDelta) {
+	sign := ""
+	if g.DeltaMB() >= 0 {
+		sign = "+"
+	}
+	fmt.Printf("GPU %d free: %s → %s (%s%s)\n", g.GPU,
+		bytesize.FormatMB(g.BeforeMB), bytesize.FormatMB(g.AfterMB), sign, bytesize.FormatMB(g.DeltaMB()))
 }
 
 // ── thaw ────────────────────────────────────────────────────────────────────
@@ -166,8 +541,72 @@ func thawCmd() *cobra.Command {
 		Short: "Restore a frozen process (reclaims GPU)",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c := client.New(sockPath)
-			resp, err := c.Call("thaw", protocol.NameParams{Name: args[0]})
+			c := newClient()
+			resp, err := c.Call("thaw", protocol.NameParams{Namespace: namespace, Name: args[0]})
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+
+			var result protocol.ThawResult
+			json.Unmarshal(resp.Result, &result)
+			fmt.Printf("Thawed %s ← ram (%d ms)%s\n", result.Name, result.DurationMs, formatPhases(result.Phases))
+			if result.GPUFree != nil {
+				printGPUFree(*result.GPUFree)
+			}
+			if result.Ready != nil {
+				if *result.Ready {
+					fmt.Printf("Ready %s (%d ms after restore)\n", result.Name, result.ReadyMs)
+				} else {
+					fmt.Printf("WARNING: %s restored but not ready: %s\n", result.Name, result.ReadyError)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// ── park ────────────────────────────────────────────────────────────────────
+
+func parkCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "park NAME",
+		Short: "Free a process's GPU memory but keep it running on CPU",
+		Long: `Checkpoints the process's GPU state to host RAM like freeze, but doesn't
+stop it, so it can keep doing CPU work such as data preprocessing. Its
+CUDA calls block until "gpusched unpark" (or thaw) restores the GPU state.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := newClient()
+			resp, err := c.Call("park", protocol.NameParams{Namespace: namespace, Name: args[0]})
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+
+			var result protocol.FreezeResult
+			json.Unmarshal(resp.Result, &result)
+			fmt.Printf("Parked %s → ram (%d ms), still running on CPU%s\n", result.Name, result.DurationMs, formatPhases(result.Phases))
+			if result.GPUFree != nil {
+				printGPUFree(*result.GPUFree)
+			}
+			return nil
+		},
+	}
+}
+
+func unparkCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unpark NAME",
+		Short: "Restore a parked process's GPU state",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := newClient()
+			resp, err := c.Call("unpark", protocol.NameParams{Namespace: namespace, Name: args[0]})
 			if err != nil {
 				return err
 			}
@@ -177,7 +616,35 @@ func thawCmd() *cobra.Command {
 
 			var result protocol.ThawResult
 			json.Unmarshal(resp.Result, &result)
-			fmt.Printf("Thawed %s ← ram (%d ms)\n", result.Name, result.DurationMs)
+			fmt.Printf("Unparked %s ← ram (%d ms)%s\n", result.Name, result.DurationMs, formatPhases(result.Phases))
+			if result.GPUFree != nil {
+				printGPUFree(*result.GPUFree)
+			}
+			return nil
+		},
+	}
+}
+
+// ── recover ─────────────────────────────────────────────────────────────────
+
+func recoverCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "recover NAME",
+		Short: "Return a degraded process (failed freeze or thaw) to active",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := newClient()
+			resp, err := c.Call("recover", protocol.NameParams{Namespace: namespace, Name: args[0]})
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+
+			var result protocol.RecoverResult
+			json.Unmarshal(resp.Result, &result)
+			fmt.Printf("Recovered %s (%d ms)\n", result.Name, result.DurationMs)
 			return nil
 		},
 	}
@@ -191,8 +658,8 @@ func killCmd() *cobra.Command {
 		Short: "Terminate a managed process",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c := client.New(sockPath)
-			resp, err := c.Call("kill", protocol.NameParams{Name: args[0]})
+			c := newClient()
+			resp, err := c.Call("kill", protocol.NameParams{Namespace: namespace, Name: args[0]})
 			if err != nil {
 				return err
 			}
@@ -205,17 +672,202 @@ func killCmd() *cobra.Command {
 	}
 }
 
+// ── group ───────────────────────────────────────────────────────────────────
+
+func groupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "group",
+		Short: "Freeze, thaw, migrate, or kill a group of processes (see run --group)",
+		Long: `Acts on every process started with the same run --group, or on none:
+if one member fails, those already done are put back.`,
+		Example: `  gpusched group freeze exp42
+  gpusched group migrate exp42 --gpu 1`,
+	}
+
+	call := func(method, verb, done string, params protocol.GroupParams) error {
+		params.Namespace = namespace
+		resp, err := newClient().Call(method, params)
+		if err != nil {
+			return err
+		}
+		var result protocol.GroupResult
+		json.Unmarshal(resp.Result, &result)
+		if len(result.Processes) == 0 && resp.OK {
+			fmt.Printf("Nothing in %s to %s\n", result.Group, verb)
+		}
+		for _, name := range result.Processes {
+			fmt.Printf("%s %s\n", done, name)
+		}
+		if !resp.OK {
+			return fmt.Errorf("%s", resp.Error)
+		}
+		return nil
+	}
+	for _, op := range []struct{ verb, done, short string }{
+		{"freeze", "Frozen", "Freeze the group's active processes"},
+		{"thaw", "Thawed", "Thaw the group's frozen processes"},
+		{"kill", "Killed", "Terminate every process in the group"},
+	} {
+		cmd.AddCommand(&cobra.Command{
+			Use:   op.verb + " GROUP",
+			Short: op.short,
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return call("group_"+op.verb, op.verb, op.done, protocol.GroupParams{Group: args[0]})
+			},
+		})
+	}
+
+	var gpuID int
+	migrate := &cobra.Command{
+		Use:   "migrate GROUP --gpu N",
+		Short: "Move the group's processes to another GPU",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return call("group_migrate", "migrate", fmt.Sprintf("Migrated to GPU %d:", gpuID), protocol.GroupParams{Group: args[0], GPU: gpuID})
+		},
+	}
+	migrate.Flags().IntVar(&gpuID, "gpu", 0, "target GPU index")
+	migrate.MarkFlagRequired("gpu")
+	cmd.AddCommand(migrate)
+	return cmd
+}
+
+// ── queue ───────────────────────────────────────────────────────────────────
+
+func queueCmd() *cobra.Command {
+	var allNamespaces bool
+
+	cmd := &cobra.Command{
+		Use:   "queue",
+		Short: "List runs waiting for GPU memory (see run --queue)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params := protocol.QueueParams{Namespace: namespace}
+			if allNamespaces {
+				params.Namespace = ""
+			}
+			resp, err := newClient().Call("queue", params)
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			var result protocol.QueueResult
+			json.Unmarshal(resp.Result, &result)
+			if len(result.Runs) == 0 {
+				fmt.Println("(queue is empty)")
+				return nil
+			}
+			printQueue(result.Runs)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "show runs queued in every namespace")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "move NAME POSITION",
+		Short: "Move a queued run to POSITION (1 is next to start)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pos, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("position must be a number, got %q", args[1])
+			}
+			resp, err := newClient().Call("queue_move", protocol.QueueMoveParams{Namespace: namespace, Name: args[0], Position: pos})
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			fmt.Printf("Moved %s to position %d\n", args[0], pos)
+			return nil
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "remove NAME",
+		Short: "Drop a queued run without starting it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resp, err := newClient().Call("queue_remove", protocol.NameParams{Namespace: namespace, Name: args[0]})
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			fmt.Printf("Removed %s from the queue\n", args[0])
+			return nil
+		},
+	})
+	return cmd
+}
+
+func printQueue(runs []protocol.QueuedRun) {
+	for _, q := range runs {
+		where := "any GPU"
+		if !q.AutoGPU {
+			where = fmt.Sprintf("GPU %d", q.GPU)
+		}
+		name := q.Name
+		if q.Namespace != namespace {
+			name = protocol.QualifiedName(q.Namespace, q.Name)
+		}
+		fmt.Printf("  %2d. %-16s %10s on %-8s waiting %s\n", q.Position, name,
+			bytesize.FormatMB(q.MemMB), where, time.Since(q.Enqueued).Round(time.Second))
+	}
+}
+
+// hasQuotas reports whether any user has a quota, which is when per-user
+// usage is worth showing.
+func hasQuotas(users []protocol.UserUsage) bool {
+	for _, u := range users {
+		if u.Quota != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// userUsageNote summarizes a user's GPUs, GPU memory, and snapshots with
+// their quota limits.
+func userUsageNote(u protocol.UserUsage) string {
+	var q protocol.Quota
+	if u.Quota != nil {
+		q = *u.Quota
+	}
+	limit := func(n int64, format func(int64) string) string {
+		if n == 0 {
+			return ""
+		}
+		return " / " + format(n)
+	}
+	count := func(n int64) string { return strconv.FormatInt(n, 10) }
+	return fmt.Sprintf("%d%s GPUs  %s%s GPU mem  %s%s snapshots",
+		len(u.GPUs), limit(int64(q.GPUs), count),
+		bytesize.FormatMB(u.GPUMemMB), limit(q.GPUMemMB, bytesize.FormatMB),
+		bytesize.FormatMB(u.SnapshotMB), limit(q.SnapshotMB, bytesize.FormatMB))
+}
+
 // ── status ──────────────────────────────────────────────────────────────────
 
 func statusCmd() *cobra.Command {
 	var jsonOut bool
+	var allNamespaces bool
+	var cluster bool
 
 	cmd := &cobra.Command{
 		Use:   "status",
-		Short: "Show all processes, GPU usage, and snapshots",
+		Short: "Show processes, GPU usage, and snapshots in the current namespace",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c := client.New(sockPath)
-			resp, err := c.Call("status", nil)
+			params := protocol.StatusParams{Namespace: namespace, Cluster: cluster}
+			if allNamespaces {
+				params.Namespace = ""
+			}
+			c := newClient()
+			resp, err := c.Call("status", params)
 			if err != nil {
 				return err
 			}
@@ -236,36 +888,94 @@ func statusCmd() *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&jsonOut, "json", false, "output as JSON")
+	cmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "show processes in every namespace")
+	cmd.Flags().BoolVar(&cluster, "cluster", false, "include GPUs and processes of the daemon's cluster peers")
 	return cmd
 }
 
 func printStatus(s protocol.StatusResult) {
+	for _, n := range s.Nodes {
+		if n.Error != "" {
+			fmt.Printf("node %s unreachable: %s\n", n.Name, n.Error)
+		}
+	}
 	for _, g := range s.GPUs {
 		pct := float64(g.MemUsed) / float64(g.MemTotal) * 100
-		fmt.Printf("GPU %d: %s (%d / %d MB, %.0f%%)\n", g.Index, g.Name, g.MemUsed, g.MemTotal, pct)
+		fmt.Printf("GPU %s: %s (%s / %s, %.0f%%)%s\n", gpuLabel(g), g.Name,
+			bytesize.FormatMB(g.MemUsed), bytesize.FormatMB(g.MemTotal), pct, clockNote(g))
+		if g.ReservedBy != "" {
+			fmt.Printf("       reserved by %s (exclusive)\n", g.ReservedBy)
+		}
+		if g.MPS {
+			fmt.Println("       MPS server running")
+		}
+		if note := budgetNote(g); note != "" {
+			fmt.Printf("       %s\n", note)
+		}
+		if g.Node != "" && g.Node != s.Nodes[0].Name {
+			continue // GPUCaps only covers the local daemon's GPUs
+		}
+		if note := gpuCapsNote(s.GPUCaps, g.Index); note != "" {
+			fmt.Printf("       %s\n", note)
+		}
 	}
 
-	var active, frozen []protocol.ProcessInfo
+	var active, frozen, degraded []protocol.ProcessInfo
 	for _, p := range s.Processes {
 		switch p.State {
 		case protocol.StateActive:
 			active = append(active, p)
 		case protocol.StateFrozen:
 			frozen = append(frozen, p)
+		case protocol.StateDegraded:
+			degraded = append(degraded, p)
 		}
 	}
 
-	if len(active) > 0 {
+	if len(active)+len(degraded) > 0 {
 		fmt.Println()
-		for _, p := range active {
-			fmt.Printf("  ● %-16s active      %6d MB  %s\n", p.Name, p.MemMB, p.Age)
-		}
+		printGrouped(append(active, degraded...), func(indent string, width int, p protocol.ProcessInfo) {
+			if p.State == protocol.StateDegraded {
+				fmt.Printf("%s! %-*s degraded  %10s  %5.0f%% cpu  %10s rss  %s  (run 'gpusched recover %s')\n",
+					indent, width, displayName(p), bytesize.FormatMB(p.MemMB), p.CPUPercent, bytesize.FormatMB(p.RSSMB), p.Age, p.Name)
+				return
+			}
+			icon, state := "●", "active"
+			if p.Unhealthy() {
+				icon, state = "!", "unhealthy"
+			}
+			fmt.Printf("%s%s %-*s %-9s %10s  %5.0f%% cpu  %10s rss  %s%s\n",
+				indent, icon, width, displayName(p), state, bytesize.FormatMB(p.MemMB), p.CPUPercent, bytesize.FormatMB(p.RSSMB), p.Age, healthNote(p))
+		})
 	}
 
 	if len(frozen) > 0 {
-		fmt.Printf("\nSnapshots (host RAM: %d / %d MB):\n", s.Memory.SnapshotsMB, s.Memory.HostRAMBudgetMB)
-		for _, p := range frozen {
-			fmt.Printf("  ○ %-16s frozen      %6d MB  %s\n", p.Name, p.MemMB, p.Age)
+		limit := ""
+		if s.Memory.MarginBound {
+			limit = fmt.Sprintf(", limited by the %s free-RAM margin", bytesize.FormatMB(s.Memory.RAMMarginMB))
+		}
+		fmt.Printf("\nSnapshots (host RAM: %s / %s, %s headroom%s):\n",
+			bytesize.FormatMB(s.Memory.SnapshotsMB), bytesize.FormatMB(s.Memory.HostRAMBudgetMB),
+			bytesize.FormatMB(s.Memory.HeadroomMB), limit)
+		printGrouped(frozen, func(indent string, width int, p protocol.ProcessInfo) {
+			state := "frozen"
+			if p.Parked {
+				state = "parked"
+			}
+			fmt.Printf("%s○ %-*s %-9s %10s  %5.0f%% cpu  %10s rss  %s%s\n",
+				indent, width, displayName(p), state, bytesize.FormatMB(p.MemMB), p.CPUPercent, bytesize.FormatMB(p.RSSMB), p.Age, swapNote(p)+thawNote(p))
+		})
+	}
+
+	if len(s.Queued) > 0 {
+		fmt.Println("\nQueued:")
+		printQueue(s.Queued)
+	}
+
+	if hasQuotas(s.Users) {
+		fmt.Println("\nUsers:")
+		for _, u := range s.Users {
+			fmt.Printf("  %-16s %s\n", u.User, userUsageNote(u))
 		}
 	}
 
@@ -277,62 +987,663 @@ func printStatus(s protocol.StatusResult) {
 	if m.Requests > 0 {
 		fmt.Printf("\nMetrics: %d req | %d freezes | %d thaws | avg freeze %dms | avg thaw %dms\n",
 			m.Requests, m.Freezes, m.Thaws, m.AvgFreezeMs, m.AvgThawMs)
+		if m.EventsDropped > 0 {
+			fmt.Printf("Events dropped for slow subscribers: %d\n", m.EventsDropped)
+		}
 	}
 
 	fmt.Printf("\nCapabilities: cuda-checkpoint=%v  driver=%s\n",
 		s.Caps.CUDACheckpoint, s.Caps.DriverVersion)
 }
 
-// ── logs ────────────────────────────────────────────────────────────────────
+// printGrouped prints each of ps with row, those in no group first and
+// then each group's members nested under its name. row gets the indent
+// and name width that keep the columns lined up.
+func printGrouped(ps []protocol.ProcessInfo, row func(indent string, width int, p protocol.ProcessInfo)) {
+	groups := make(map[string][]protocol.ProcessInfo)
+	for _, p := range ps {
+		if p.Group == "" {
+			row("  ", 16, p)
+			continue
+		}
+		g := displayName(protocol.ProcessInfo{Node: p.Node, Namespace: p.Namespace, Name: p.Group})
+		groups[g] = append(groups[g], p)
+	}
+	names := make([]string, 0, len(groups))
+	for g := range groups {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+	for _, g := range names {
+		fmt.Printf("  ▾ %s (group)\n", g)
+		for _, p := range groups[g] {
+			row("    ", 14, p)
+		}
+	}
+}
 
-func logsCmd() *cobra.Command {
-	var lines int
+// displayName qualifies p's name when it is outside the current namespace,
+// and prefixes its node in cluster status.
+func displayName(p protocol.ProcessInfo) string {
+	name := p.Name
+	if p.Namespace != namespace {
+		name = protocol.QualifiedName(p.Namespace, p.Name)
+	}
+	if p.Node != "" {
+		name = p.Node + ":" + name
+	}
+	return name
+}
 
-	cmd := &cobra.Command{
-		Use:   "logs NAME",
-		Short: "View process stdout/stderr",
-		Args:  cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			c := client.New(sockPath)
-			resp, err := c.Call("logs", protocol.LogsParams{
-				Name:  args[0],
-				Lines: lines,
-			})
-			if err != nil {
-				return err
-			}
-			if !resp.OK {
-				return fmt.Errorf("%s", resp.Error)
-			}
+// budgetNote describes a GPU's reserve and overcommit budget, if any.
+func budgetNote(g protocol.GPUInfo) string {
+	var parts []string
+	if g.ReserveMB > 0 {
+		parts = append(parts, bytesize.FormatMB(g.ReserveMB)+" kept free")
+	}
+	if g.BudgetMB > 0 {
+		parts = append(parts, fmt.Sprintf("%s of %s budget committed",
+			bytesize.FormatMB(g.CommittedMB), bytesize.FormatMB(g.BudgetMB)))
+	}
+	return strings.Join(parts, ", ")
+}
 
-			var result protocol.LogsResult
+// gpuLabel is the GPU's index, prefixed with its node in cluster status.
+func gpuLabel(g protocol.GPUInfo) string {
+	if g.Node != "" {
+		return g.Node + ":" + strconv.Itoa(g.Index)
+	}
+	return strconv.Itoa(g.Index)
+}
+
+// clockNote renders current clocks and any throttling other than idle.
+func clockNote(g protocol.GPUInfo) string {
+	if g.SMClockMHz == 0 {
+		return ""
+	}
+	note := fmt.Sprintf("  sm %d MHz, mem %d MHz", g.SMClockMHz, g.MemClockMHz)
+	if reasons := activeThrottles(g); len(reasons) > 0 {
+		note += "  throttled: " + strings.Join(reasons, ",")
+	}
+	return note
+}
+
+// gpuCapsNote summarizes what a GPU can't do, or shows MIG mode, so odd
+// devices stand out. Fully capable GPUs print nothing.
+func gpuCapsNote(caps map[int]protocol.GPUCapabilities, index int) string {
+	c, ok := caps[index]
+	if !ok {
+		return ""
+	}
+	var notes []string
+	if c.MIG {
+		notes = append(notes, "MIG enabled")
+	}
+	if !c.Checkpoint {
+		notes = append(notes, "no freeze/thaw")
+	}
+	if !c.Reset {
+		notes = append(notes, "no reset")
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	note := strings.Join(notes, ", ")
+	if len(c.Notes) > 0 {
+		note += " (" + strings.Join(c.Notes, "; ") + ")"
+	}
+	return note
+}
+
+func activeThrottles(g protocol.GPUInfo) []string {
+	var reasons []string
+	for _, r := range g.ThrottleReasons {
+		if r != "idle" {
+			reasons = append(reasons, r)
+		}
+	}
+	return reasons
+}
+
+func healthNote(p protocol.ProcessInfo) string {
+	var notes []string
+	if p.Liveness != nil && !p.Liveness.OK {
+		notes = append(notes, "liveness failing: "+p.Liveness.Error)
+	}
+	if p.Restarts > 0 {
+		notes = append(notes, fmt.Sprintf("%d restarts", p.Restarts))
+	}
+	if t := p.NextRestart; t != nil {
+		notes = append(notes, fmt.Sprintf("restarting in %s", time.Until(*t).Round(time.Second)))
+	}
+	if p.WaitingForSlice() {
+		notes = append(notes, "waiting for its time slice")
+	}
+	if c := p.AppCheckpoint; c != nil {
+		notes = append(notes, fmt.Sprintf("ckpt %s ago", time.Since(c.Time).Round(time.Second)))
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return "  (" + strings.Join(notes, ", ") + ")"
+}
+
+// appCheckpointLabel is a reported checkpoint's step and detail.
+func appCheckpointLabel(c *protocol.AppCheckpoint) string {
+	switch {
+	case c.Step != 0 && c.Detail != "":
+		return fmt.Sprintf("step %d, %s", c.Step, c.Detail)
+	case c.Step != 0:
+		return fmt.Sprintf("step %d", c.Step)
+	}
+	return c.Detail
+}
+
+// swapNote flags frozen processes whose snapshot has been swapped out.
+func swapNote(p protocol.ProcessInfo) string {
+	if p.Tier != protocol.TierRAMSwapped {
+		return ""
+	}
+	return fmt.Sprintf("  (%s swapped, thaw +%s)", bytesize.FormatMB(p.SwapMB),
+		(time.Duration(p.ThawPenaltyMs) * time.Millisecond).Round(time.Second))
+}
+
+// thawNote is a frozen process's expected thaw time, when there is one.
+func thawNote(p protocol.ProcessInfo) string {
+	if p.ThawEstimate == nil || p.ThawEstimate.Ms == 0 {
+		return ""
+	}
+	return fmt.Sprintf("  thaw ~%s", (time.Duration(p.ThawEstimate.Ms) * time.Millisecond).Round(100*time.Millisecond))
+}
+
+// ── describe ────────────────────────────────────────────────────────────────
+
+func describeCmd() *cobra.Command {
+	var jsonOut bool
+
+	cmd := &cobra.Command{
+		Use:   "describe NAME",
+		Short: "Show everything gpusched knows about a process",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := newClient()
+			resp, err := c.Call("describe", protocol.NameParams{Namespace: namespace, Name: args[0]})
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			if jsonOut {
+				fmt.Println(string(resp.Result))
+				return nil
+			}
+
+			var p protocol.ProcessInfo
+			json.Unmarshal(resp.Result, &p)
+			printDescribe(p)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "output as JSON")
+	return cmd
+}
+
+func printDescribe(p protocol.ProcessInfo) {
+	fmt.Printf("Name:      %s\n", p.Name)
+	if p.Namespace != "" {
+		fmt.Printf("Namespace: %s\n", p.Namespace)
+	}
+	if p.Group != "" {
+		fmt.Printf("Group:     %s\n", p.Group)
+	}
+	fmt.Printf("PID:       %d\n", p.PID)
+	if p.Parked {
+		fmt.Printf("State:     %s (%s), parked: still running on CPU\n", p.State, p.Tier)
+	} else {
+		fmt.Printf("State:     %s (%s)\n", p.State, p.Tier)
+	}
+	if p.Exclusive {
+		fmt.Printf("GPU:       %d (exclusive)\n", p.GPU)
+	} else {
+		fmt.Printf("GPU:       %d\n", p.GPU)
+	}
+	fmt.Printf("Memory:    %s GPU, %s host RSS\n", bytesize.FormatMB(p.MemMB), bytesize.FormatMB(p.RSSMB))
+	if p.SwapMB > 0 {
+		fmt.Printf("Swap:      %s (est. thaw penalty %s)\n", bytesize.FormatMB(p.SwapMB),
+			(time.Duration(p.ThawPenaltyMs) * time.Millisecond).Round(100*time.Millisecond))
+	}
+	if p.ThawEstimate != nil {
+		fmt.Printf("Thaw:      %s\n", estimateNote(*p.ThawEstimate))
+	}
+	if len(p.RecentThawMs) > 0 {
+		recent := make([]string, len(p.RecentThawMs))
+		for i, ms := range p.RecentThawMs {
+			recent[i] = (time.Duration(ms) * time.Millisecond).Round(100 * time.Millisecond).String()
+		}
+		fmt.Printf("Thaws:     %s (oldest first)\n", strings.Join(recent, ", "))
+	}
+	fmt.Printf("CPU:       %.1f%%\n", p.CPUPercent)
+	fmt.Printf("Started:   %s (%s ago)\n", p.Started.Format("2006-01-02 15:04:05"), p.Age)
+	fmt.Printf("Command:   %s\n", p.Command)
+	if p.TTY {
+		fmt.Printf("Terminal:  yes (gpusched attach %s)\n", p.Name)
+	}
+	if w := p.Wake; w != nil {
+		triggers := append([]string(nil), w.Paths...)
+		for _, f := range w.FIFOs {
+			triggers = append(triggers, "fifo "+f)
+		}
+		fmt.Printf("Wake on:   %s\n", strings.Join(triggers, ", "))
+	}
+	fmt.Printf("Shutdown:  %s\n", p.OnShutdown)
+	if p.GPUMemLimitMB > 0 {
+		action := p.GPUMemLimitAction
+		if action == "" {
+			action = protocol.LimitKill
+		}
+		fmt.Printf("GPU limit: %s (%s when over)\n", bytesize.FormatMB(p.GPUMemLimitMB), action)
+	}
+	if p.MaxRuntimeMs > 0 || p.TTLAfterExitMs > 0 {
+		var limits []string
+		if p.MaxRuntimeMs > 0 {
+			action := p.MaxRuntimeAction
+			if action == "" {
+				action = protocol.DeadlineKill
+			}
+			limits = append(limits, fmt.Sprintf("%s after %s active", action, time.Duration(p.MaxRuntimeMs)*time.Millisecond))
+		}
+		if p.TTLAfterExitMs > 0 {
+			limits = append(limits, fmt.Sprintf("removed %s after exit", time.Duration(p.TTLAfterExitMs)*time.Millisecond))
+		}
+		note := strings.Join(limits, ", ")
+		if t := p.Deadline; t != nil {
+			note += fmt.Sprintf(" (due in %s)", time.Until(*t).Round(time.Second))
+		}
+		fmt.Printf("Deadline:  %s\n", note)
+	}
+	if p.TimeSlice {
+		note := fmt.Sprintf("takes turns on GPU %d", p.GPU)
+		if p.WaitingForSlice() {
+			note += ", waiting for its turn"
+		}
+		fmt.Printf("Slicing:   %s\n", note)
+	}
+	if p.MPS {
+		fmt.Printf("MPS:       client of the MPS server on GPU %d (can't be frozen)\n", p.GPU)
+	}
+	if p.Nice != 0 || p.IOClass != "" || p.OOMScoreAdj != 0 {
+		var prio []string
+		if p.Nice != 0 {
+			prio = append(prio, fmt.Sprintf("nice %d", p.Nice))
+		}
+		if p.IOClass != "" {
+			prio = append(prio, "I/O "+p.IOClass)
+		}
+		if p.OOMScoreAdj != 0 {
+			prio = append(prio, fmt.Sprintf("oom_score_adj %d", p.OOMScoreAdj))
+		}
+		fmt.Printf("Priority:  %s\n", strings.Join(prio, ", "))
+	}
+	if p.Cgroup != "" {
+		var limits []string
+		if p.CPUs > 0 {
+			limits = append(limits, fmt.Sprintf("%g CPUs", p.CPUs))
+		}
+		if p.HostMemMB > 0 {
+			limits = append(limits, bytesize.FormatMB(p.HostMemMB)+" host memory")
+		}
+		note := "no limits"
+		if len(limits) > 0 {
+			note = strings.Join(limits, ", ")
+		}
+		fmt.Printf("Cgroup:    %s (%s)\n", p.Cgroup, note)
+	}
+	if p.Restart != "" {
+		policy := p.Restart
+		if t := p.NextRestart; t != nil {
+			policy += fmt.Sprintf(" (relaunching in %s)", time.Until(*t).Round(time.Second))
+		}
+		fmt.Printf("Restart:   %s\n", policy)
+	}
+	if s := p.Inference; s != nil {
+		fmt.Printf("Server:    %s at %s\n", s.Kind, s.URL)
+	}
+	if r := p.Rendezvous; r != nil {
+		fmt.Printf("Torchrun:  %s\n", rendezvousNote(r))
+	}
+	if p.Restarts > 0 {
+		fmt.Printf("Restarts:  %d\n", p.Restarts)
+	}
+	if p.Freezes > 0 {
+		fmt.Printf("Frozen:    %d times, %s suspended in total\n", p.Freezes,
+			(time.Duration(p.SuspendedMs) * time.Millisecond).Round(time.Second))
+	}
+	if t := p.LastChange; t != nil {
+		fmt.Printf("Changed:   %s at %s by %s\n", t.State, t.Time.Format("2006-01-02 15:04:05"), transitionCause(t))
+	}
+	if l := p.Liveness; l != nil {
+		result := "ok"
+		if !l.OK {
+			result = fmt.Sprintf("failing (%d in a row): %s", l.ConsecutiveFailures, l.Error)
+		}
+		fmt.Printf("Liveness:  %s at %s\n", result, l.LastCheck.Format("15:04:05"))
+	}
+	if c := p.AppCheckpoint; c != nil {
+		fmt.Printf("App ckpt:  %s at %s (%s ago)\n", appCheckpointLabel(c), c.Time.Format("2006-01-02 15:04:05"),
+			time.Since(c.Time).Round(time.Second))
+	}
+	if len(p.Notes) > 0 {
+		fmt.Println("Notes:")
+		for _, n := range p.Notes {
+			fmt.Printf("  %s  %s\n", n.Time.Format("2006-01-02 15:04"), n.Text)
+		}
+	}
+}
+
+// transitionCause describes who or what caused a state change.
+func transitionCause(t *protocol.Transition) string {
+	s := t.Cause
+	if t.Detail != "" {
+		s += " (" + t.Detail + ")"
+	}
+	if t.RequestID != "" {
+		s += ", request " + t.RequestID
+	}
+	return s
+}
+
+// rendezvousNote summarizes a torchrun configuration for describe.
+func rendezvousNote(r *protocol.Rendezvous) string {
+	var parts []string
+	if r.Standalone {
+		parts = append(parts, "standalone")
+	}
+	for _, kv := range [][2]string{
+		{"nnodes", r.Nnodes}, {"nproc_per_node", r.NprocPerNode},
+		{"rdzv", r.Backend}, {"endpoint", r.Endpoint}, {"id", r.ID},
+	} {
+		if kv[1] != "" {
+			parts = append(parts, kv[0]+"="+kv[1])
+		}
+	}
+	if len(parts) == 0 {
+		return "defaults"
+	}
+	return strings.Join(parts, " ")
+}
+
+// ── annotate ────────────────────────────────────────────────────────────────
+
+func annotateCmd() *cobra.Command {
+	var clear bool
+
+	cmd := &cobra.Command{
+		Use:   "annotate NAME [NOTE]",
+		Short: "Attach a free-form note to a process",
+		Example: `  gpusched annotate train "restarted after lr fix"
+  gpusched annotate train --clear`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params := protocol.AnnotateParams{Namespace: namespace, Name: args[0], Clear: clear}
+			if len(args) == 2 {
+				params.Note = args[1]
+			} else if !clear {
+				return fmt.Errorf("a note is required unless --clear is set")
+			}
+
+			c := newClient()
+			resp, err := c.Call("annotate", params)
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			if clear {
+				fmt.Printf("Cleared notes on %s\n", args[0])
+			} else {
+				fmt.Printf("Annotated %s\n", args[0])
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&clear, "clear", false, "remove all notes")
+	return cmd
+}
+
+// ── checkpointed ────────────────────────────────────────────────────────────
+
+func checkpointedCmd() *cobra.Command {
+	var name string
+	var step int64
+
+	cmd := &cobra.Command{
+		Use:   "checkpointed [DETAIL]",
+		Short: "Report that a process saved a checkpoint of its own",
+		Long: `Records the last application checkpoint of a managed process, shown by
+status and describe so you can tell how much work killing it would lose.
+Meant to be run by the process itself or its checkpoint hook, which gets
+its name from $GPUSCHED_NAME.`,
+		Example: `  gpusched checkpointed --step 12000
+  gpusched checkpointed --step 12000 "saved to s3://ckpt/run7/12000"`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if name == "" {
+				return fmt.Errorf("--name is required outside a managed process")
+			}
+			params := protocol.AppCheckpointParams{Name: name, Step: step}
+			if !strings.Contains(name, "/") {
+				params.Namespace = namespace
+			}
+			if len(args) == 1 {
+				params.Detail = args[0]
+			}
+
+			c := newClient()
+			resp, err := c.Call("app_checkpoint", params)
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", os.Getenv("GPUSCHED_NAME"), "process that saved the checkpoint (default: $GPUSCHED_NAME)")
+	cmd.Flags().Int64Var(&step, "step", 0, "training step or other progress counter the checkpoint holds")
+	return cmd
+}
+
+// ── adopt ───────────────────────────────────────────────────────────────────
+
+func adoptCmd() *cobra.Command {
+	var all, jsonOut bool
+	var ownerMap map[string]string
+
+	cmd := &cobra.Command{
+		Use:   "adopt [PID...]",
+		Short: "Bring running CUDA processes gpusched didn't start under management",
+		Long: `Adopt registers CUDA compute processes that are already running, so an
+existing GPU server can be moved under gpusched without restarting its jobs.
+Each is named from its command line (the script for python and similar) and
+placed in its owner's namespace: the user name it runs as, or the namespace
+--owner-map gives for that user name or uid. Adopted processes are left
+running when the daemon exits.`,
+		Example: `  gpusched adopt --all
+  gpusched adopt --all --owner-map alice=vision,bob=vision,1005=nlp
+  gpusched adopt 48213 48377`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) > 0) {
+				return fmt.Errorf("pass either PIDs or --all")
+			}
+			params := protocol.AdoptParams{All: all, OwnerMap: ownerMap}
+			for _, a := range args {
+				pid, err := strconv.Atoi(a)
+				if err != nil {
+					return fmt.Errorf("invalid PID %q", a)
+				}
+				params.PIDs = append(params.PIDs, pid)
+			}
+
+			c := newClient()
+			resp, err := c.Call("adopt", params)
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			if jsonOut {
+				fmt.Println(string(resp.Result))
+				return nil
+			}
+
+			var result protocol.AdoptResult
 			json.Unmarshal(resp.Result, &result)
-			for _, line := range result.Lines {
-				fmt.Println(line)
+			for _, p := range result.Adopted {
+				fmt.Printf("Adopted %s/%s (pid %d, GPU %d, %d MB, %s)\n", p.Namespace, p.Name, p.PID, p.GPU, p.MemMB, p.State)
+			}
+			for _, sk := range result.Skipped {
+				fmt.Printf("Skipped pid %d: %s\n", sk.PID, sk.Reason)
+			}
+			if len(result.Adopted) == 0 && len(result.Skipped) == 0 {
+				fmt.Println("(no CUDA compute processes found)")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "adopt every CUDA compute process on the host")
+	cmd.Flags().StringToStringVar(&ownerMap, "owner-map", nil, "namespace per user name or uid, e.g. alice=vision,1005=nlp")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "output as JSON")
+	return cmd
+}
+
+// ── logs ────────────────────────────────────────────────────────────────────
+
+func logsCmd() *cobra.Command {
+	var lines int
+	var grep, highlight string
+	var invert, follow bool
+
+	cmd := &cobra.Command{
+		Use:   "logs NAME",
+		Short: "View process stdout/stderr",
+		Long: `View a process's stdout/stderr.
+
+--grep filters on the daemon, so only matching lines cross the socket;
+-n then counts matching lines. Matches are highlighted when stdout is a
+terminal. -f keeps printing new output until the process exits.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := newClient()
+			params := protocol.LogsParams{
+				Namespace: namespace,
+				Name:      args[0],
+				Lines:     lines,
+				Grep:      grep,
+				Invert:    invert,
+				Highlight: highlight,
+			}
+			color := isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+			if follow {
+				ch, _, err := c.FollowLogs(params)
+				if err != nil {
+					return err
+				}
+				for result := range ch {
+					printLogLines(result, color)
+				}
+				return nil
+			}
+
+			resp, err := c.Call("logs", params)
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
 			}
+
+			var result protocol.LogsResult
+			json.Unmarshal(resp.Result, &result)
+			printLogLines(result, color)
 			return nil
 		},
 	}
 
 	cmd.Flags().IntVarP(&lines, "lines", "n", 50, "number of lines")
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "keep printing new output until the process exits")
+	cmd.Flags().StringVar(&grep, "grep", "", "only show lines matching this regular expression")
+	cmd.Flags().BoolVarP(&invert, "invert", "v", false, "only show lines not matching --grep")
+	cmd.Flags().StringVar(&highlight, "highlight", "", "regular expression to highlight (default: --grep)")
 	return cmd
 }
 
+// printLogLines prints logs output, highlighting matches if color is set.
+func printLogLines(result protocol.LogsResult, color bool) {
+	for i, line := range result.Lines {
+		if color && i < len(result.Matches) {
+			line = highlightSpans(line, result.Matches[i])
+		}
+		fmt.Println(line)
+	}
+}
+
+// highlightSpans wraps each [start, end) byte span of line in bold red.
+func highlightSpans(line string, spans [][2]int) string {
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s[0] < last || s[1] > len(line) || s[0] >= s[1] {
+			continue
+		}
+		b.WriteString(line[last:s[0]])
+		b.WriteString("\x1b[1;31m" + line[s[0]:s[1]] + "\x1b[0m")
+		last = s[1]
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
 // ── migrate ─────────────────────────────────────────────────────────────────
 
 func migrateCmd() *cobra.Command {
 	var gpuID int
+	var auto bool
+	var dryRun bool
+	var maxDowntime time.Duration
 
 	cmd := &cobra.Command{
-		Use:     "migrate NAME",
-		Short:   "Move a process to a different GPU",
-		Example: "  gpusched migrate train --to 1",
-		Args:    cobra.ExactArgs(1),
+		Use:   "migrate NAME",
+		Short: "Move a process to a different GPU",
+		Example: `  gpusched migrate train --to 1
+  gpusched migrate train --auto
+  gpusched migrate train --to 1 --dry-run --max-downtime 30s`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c := client.New(sockPath)
+			if auto == cmd.Flags().Changed("to") {
+				return fmt.Errorf("exactly one of --to or --auto is required")
+			}
+			c := newClient()
 			resp, err := c.Call("migrate", protocol.MigrateParams{
-				Name: args[0],
-				GPU:  gpuID,
+				Namespace: namespace,
+				Name:      args[0],
+				GPU:       gpuID,
+				AutoGPU:   auto,
+
+				DryRun:        dryRun,
+				MaxDowntimeMs: maxDowntime.Milliseconds(),
 			})
 			if err != nil {
 				return err
@@ -343,49 +1654,967 @@ func migrateCmd() *cobra.Command {
 
 			var result protocol.MigrateResult
 			json.Unmarshal(resp.Result, &result)
+			if result.DryRun {
+				fmt.Printf("Would migrate %s: GPU %d → GPU %d\n", result.Name, result.FromGPU, result.ToGPU)
+				fmt.Printf("Estimated downtime: %s\n", estimateNote(result.Estimate))
+				if result.Warning != "" {
+					fmt.Printf("WARNING: %s\n", result.Warning)
+				}
+				return nil
+			}
 			fmt.Printf("Migrated %s: GPU %d → GPU %d\n", result.Name, result.FromGPU, result.ToGPU)
 			return nil
 		},
 	}
 
 	cmd.Flags().IntVar(&gpuID, "to", 0, "target GPU device index")
-	cmd.MarkFlagRequired("to")
+	cmd.Flags().BoolVar(&auto, "auto", false, "let the daemon's placement strategy pick the target GPU")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only show the target and estimated downtime")
+	cmd.Flags().DurationVar(&maxDowntime, "max-downtime", 0, "refuse (or, with --dry-run, warn) if the estimated downtime is longer")
 
 	return cmd
 }
 
-// ── dashboard ───────────────────────────────────────────────────────────────
+// ── plan ────────────────────────────────────────────────────────────────────
 
-func dashboardCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:     "dashboard",
-		Aliases: []string{"dash", "tui"},
-		Short:   "Interactive terminal dashboard",
+func planCmd() *cobra.Command {
+	var gpuID int
+	var add string
+	var jsonOut bool
+
+	cmd := &cobra.Command{
+		Use:     "plan",
+		Short:   "Show what would be frozen or migrated to fit a new job (dry run)",
+		Example: "  gpusched plan --gpu 0 --add 30G",
+		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c := client.New(sockPath)
-			return tui.Run(c)
+			addMB, err := bytesize.ParseMB(add)
+			if err != nil {
+				return fmt.Errorf("--add: %w", err)
+			}
+			c := newClient()
+			resp, err := c.Call("plan", protocol.PlanParams{GPU: gpuID, AddMB: addMB})
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			if jsonOut {
+				fmt.Println(string(resp.Result))
+				return nil
+			}
+
+			var plan protocol.PlanResult
+			json.Unmarshal(resp.Result, &plan)
+			fmt.Printf("GPU %d: %s free, %s requested\n", plan.GPU,
+				bytesize.FormatMB(plan.FreeMB), bytesize.FormatMB(plan.AddMB))
+			if len(plan.Steps) == 0 && plan.Fits {
+				fmt.Println("  fits without changes")
+			}
+			for i, s := range plan.Steps {
+				switch s.Action {
+				case "migrate":
+					fmt.Printf("  %d. migrate %s (%s) → GPU %d%s\n", i+1, s.Process, bytesize.FormatMB(s.MemMB), s.ToGPU, stepEstimate(s))
+				default:
+					fmt.Printf("  %d. freeze %s (%s) to host RAM%s\n", i+1, s.Process, bytesize.FormatMB(s.MemMB), stepEstimate(s))
+				}
+			}
+			if !plan.Fits {
+				fmt.Printf("Does not fit: %s short after all possible moves\n", bytesize.FormatMB(plan.ShortMB))
+			}
+			fmt.Println("(dry run — nothing was changed)")
+			return nil
 		},
 	}
-}
 
-// ── Helpers ─────────────────────────────────────────────────────────────────
+	cmd.Flags().IntVar(&gpuID, "gpu", 0, "GPU device index")
+	cmd.Flags().StringVar(&add, "add", "", "GPU memory the new job needs (e.g. 30G)")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "output as JSON")
+	cmd.MarkFlagRequired("add")
 
-// parseMB converts strings like "80G", "80000M", "80000" to MB.
-func parseMB(s string) int64 {
-	if s == "" {
-		return 0
-	}
-	s = strings.TrimSpace(s)
-	multiplier := int64(1)
-	if strings.HasSuffix(s, "G") || strings.HasSuffix(s, "g") {
-		multiplier = 1024
-		s = s[:len(s)-1]
-	} else if strings.HasSuffix(s, "M") || strings.HasSuffix(s, "m") {
-		s = s[:len(s)-1]
-	} else if strings.HasSuffix(s, "T") || strings.HasSuffix(s, "t") {
-		multiplier = 1024 * 1024
-		s = s[:len(s)-1]
-	}
-	v, _ := strconv.ParseInt(s, 10, 64)
-	return v * multiplier
+	return cmd
+}
+
+// estimateNote formats a downtime estimate with its basis.
+func estimateNote(e protocol.Estimate) string {
+	if e.Ms == 0 {
+		return "unknown (" + e.Basis + ")"
+	}
+	return fmt.Sprintf("~%s (%s)", (time.Duration(e.Ms) * time.Millisecond).Round(100*time.Millisecond), e.Basis)
+}
+
+func stepEstimate(s protocol.PlanStep) string {
+	if s.EstimatedMs == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", ~%s", (time.Duration(s.EstimatedMs) * time.Millisecond).Round(100*time.Millisecond))
+}
+
+// ── ops ─────────────────────────────────────────────────────────────────────
+
+func opsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ops",
+		Short: "Inspect checkpoint operations",
+	}
+	cmd.AddCommand(opsHistoryCmd())
+	return cmd
+}
+
+func opsHistoryCmd() *cobra.Command {
+	var process, op string
+	var limit int
+	var jsonOut bool
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show past freeze/thaw/migrate operations and throughput",
+		Example: `  gpusched ops history
+  gpusched ops history --process train --op freeze -n 50`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if process != "" {
+				process = protocol.QualifiedName(namespace, process)
+			}
+			c := newClient()
+			resp, err := c.Call("ops_history", protocol.OpsHistoryParams{
+				Process: process,
+				Op:      op,
+				Limit:   limit,
+			})
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			if jsonOut {
+				fmt.Println(string(resp.Result))
+				return nil
+			}
+
+			var result protocol.OpsHistoryResult
+			json.Unmarshal(resp.Result, &result)
+			if len(result.Records) == 0 {
+				fmt.Println("(no operations recorded)")
+				return nil
+			}
+			for _, r := range result.Records {
+				fmt.Printf("%s  %-8s %-16s gpu%-2d %-4s %8d MB %7d ms %9.1f MB/s  %s\n",
+					r.Time.Format("2006-01-02 15:04:05"), r.Op, r.Process, r.GPU, r.Tier,
+					r.MemMB, r.DurationMs, r.MBps, r.Cause)
+			}
+			fmt.Println()
+			for _, st := range result.Stats {
+				fmt.Printf("%-8s %-4s  %5d ops  %10d MB  avg %6d ms  avg %8.1f MB/s  peak %8.1f MB/s\n",
+					st.Op, st.Tier, st.Count, st.TotalMB, st.AvgMs, st.AvgMBps, st.PeakMBps)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&process, "process", "", "only show this process")
+	cmd.Flags().StringVar(&op, "op", "", "only show this operation (freeze, thaw, migrate)")
+	cmd.Flags().IntVarP(&limit, "lines", "n", 20, "number of records (stats cover all matches)")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "output as JSON")
+	return cmd
+}
+
+// ── events ──────────────────────────────────────────────────────────────────
+
+func eventsCmd() *cobra.Command {
+	var since, process, typ string
+	var limit int
+	var jsonOut, allNamespaces bool
+
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Show past events from the daemon's event log",
+		Long: `Show events from the daemon's persistent event log (events.jsonl next
+to --log-dir), which unlike the dashboard's recent events survives restarts.`,
+		Example: `  gpusched events --since 1h
+  gpusched events --process train --type freeze -n 100`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params := protocol.EventsParams{Namespace: namespace, Type: typ, Limit: limit}
+			if allNamespaces {
+				params.Namespace = ""
+			}
+			if process != "" {
+				params.Process = protocol.QualifiedName(namespace, process)
+			}
+			if since != "" {
+				window, err := parseSince(since)
+				if err != nil {
+					return fmt.Errorf("--since: %w", err)
+				}
+				params.Since = time.Now().Add(-window)
+			}
+			c := newClient()
+			resp, err := c.Call("events", params)
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			if jsonOut {
+				fmt.Println(string(resp.Result))
+				return nil
+			}
+
+			var result protocol.EventsResult
+			json.Unmarshal(resp.Result, &result)
+			if len(result.Events) == 0 {
+				fmt.Println("(no events)")
+				return nil
+			}
+			for _, e := range result.Events {
+				fmt.Println(formatEvent(e))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "", "only events this recent, e.g. 1h or 7d")
+	cmd.Flags().StringVar(&process, "process", "", "only events for this process")
+	cmd.Flags().StringVar(&typ, "type", "", "only events of this type, e.g. freeze or degraded")
+	cmd.Flags().IntVarP(&limit, "lines", "n", 50, "number of events (the most recent)")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "output as JSON")
+	cmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "show events in every namespace")
+	return cmd
+}
+
+// formatEvent renders an event as one line: time, type, process, detail,
+// and who asked for it.
+func formatEvent(e protocol.Event) string {
+	s := fmt.Sprintf("%s  %-16s %-20s", e.Time.Format("2006-01-02 15:04:05"), e.Type, e.Process)
+	if e.Detail != "" {
+		s += " " + e.Detail
+	}
+	var notes []string
+	if e.Duration > 0 {
+		notes = append(notes, fmt.Sprintf("%d ms", e.Duration))
+	}
+	if e.Cause != "" {
+		notes = append(notes, e.Cause)
+	}
+	if e.User != "" {
+		notes = append(notes, "by "+e.User)
+	}
+	if len(notes) > 0 {
+		s += " [" + strings.Join(notes, ", ") + "]"
+	}
+	return strings.TrimRight(s, " ")
+}
+
+// ── audit ───────────────────────────────────────────────────────────────────
+
+func auditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Check hash-chained logs for tampering",
+	}
+	cmd.AddCommand(auditVerifyCmd())
+	return cmd
+}
+
+func auditVerifyCmd() *cobra.Command {
+	var path, head string
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the operation history's hash chain (daemon --hash-chain)",
+		Example: `  gpusched audit verify
+  gpusched audit verify --file /var/lib/gpusched/ops.jsonl --head 3f9c...`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := audit.VerifyFile(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			if res.Chained == 0 {
+				return fmt.Errorf("%s: no chained entries (is the daemon running with --hash-chain?)", path)
+			}
+			if head != "" && head != res.Head {
+				return fmt.Errorf("%s: head is %s, expected %s (entries removed from the end?)", path, res.Head, head)
+			}
+			fmt.Printf("ok: %d chained entries", res.Chained)
+			if res.Unchained > 0 {
+				fmt.Printf(", %d earlier unchained", res.Unchained)
+			}
+			fmt.Printf("\nhead: %s\n", res.Head)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&path, "file", "/tmp/gpusched/ops.jsonl", "history file to verify")
+	cmd.Flags().StringVar(&head, "head", "", "expected head hash from an earlier verify")
+	return cmd
+}
+
+// ── report ──────────────────────────────────────────────────────────────────
+
+func reportCmd() *cobra.Command {
+	var since time.Duration
+	var outPath, smtpAddr, from string
+	var to []string
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Summarize GPU usage and operations for a period (run from cron for digests)",
+		Example: `  gpusched report --since 24h
+  gpusched report --since 168h --out /var/log/gpusched/weekly.txt
+  gpusched report --smtp mail:25 --from gpusched@lab --to admin@lab`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if smtpAddr != "" && (from == "" || len(to) == 0) {
+				return fmt.Errorf("--smtp requires --from and --to")
+			}
+			now := time.Now()
+			in := report.Input{Since: now.Add(-since), Until: now}
+			in.Host, _ = os.Hostname()
+
+			c := newClient()
+			resp, err := c.Call("status", protocol.StatusParams{})
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			json.Unmarshal(resp.Result, &in.Status)
+
+			resp, err = c.Call("ops_history", protocol.OpsHistoryParams{Since: in.Since})
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			json.Unmarshal(resp.Result, &in.History)
+
+			var body strings.Builder
+			report.Write(&body, in)
+
+			if smtpAddr != "" {
+				return sendReport(smtpAddr, from, to, report.Subject(in), body.String())
+			}
+			if outPath == "" || outPath == "-" {
+				fmt.Print(body.String())
+				return nil
+			}
+			return os.WriteFile(outPath, []byte(body.String()), 0o644)
+		},
+	}
+
+	cmd.Flags().DurationVar(&since, "since", 24*time.Hour, "period to cover, ending now")
+	cmd.Flags().StringVar(&outPath, "out", "", "write the report to this file instead of stdout")
+	cmd.Flags().StringVar(&smtpAddr, "smtp", "", "send the report via this SMTP server (host:port)")
+	cmd.Flags().StringVar(&from, "from", "", "sender address for --smtp")
+	cmd.Flags().StringArrayVar(&to, "to", nil, "recipient address for --smtp (repeatable)")
+	return cmd
+}
+
+// sendReport mails body. SMTP credentials, if needed, come from
+// GPUSCHED_SMTP_USER and GPUSCHED_SMTP_PASSWORD.
+func sendReport(addr, from string, to []string, subject, body string) error {
+	var auth smtp.Auth
+	if user := os.Getenv("GPUSCHED_SMTP_USER"); user != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		auth = smtp.PlainAuth("", user, os.Getenv("GPUSCHED_SMTP_PASSWORD"), host)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		from, strings.Join(to, ", "), subject, strings.ReplaceAll(body, "\n", "\r\n"))
+	if err := smtp.SendMail(addr, auth, from, to, []byte(msg)); err != nil {
+		return fmt.Errorf("sending report: %w", err)
+	}
+	return nil
+}
+
+// ── usage ───────────────────────────────────────────────────────────────────
+
+func usageCmd() *cobra.Command {
+	var by, since string
+	var jsonOut bool
+
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Show GPU-hours (active time only) and cost",
+		Example: `  gpusched usage --by owner --since 30d
+  gpusched usage --by model --since 7d --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			window, err := parseSince(since)
+			if err != nil {
+				return fmt.Errorf("--since: %w", err)
+			}
+			c := newClient()
+			resp, err := c.Call("usage", protocol.UsageParams{By: by, Since: time.Now().Add(-window)})
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			if jsonOut {
+				fmt.Println(string(resp.Result))
+				return nil
+			}
+
+			var result protocol.UsageResult
+			json.Unmarshal(resp.Result, &result)
+			if len(result.Rows) == 0 {
+				fmt.Println("(no GPU usage recorded)")
+				return nil
+			}
+			fmt.Printf("%-32s %10s %10s\n", strings.ToUpper(result.By), "GPU-HOURS", "COST")
+			for _, r := range result.Rows {
+				fmt.Printf("%-32s %10.2f %10.2f\n", r.Key, r.GPUHours, r.Cost)
+			}
+			fmt.Printf("%-32s %10.2f %10.2f\n", "total", result.GPUHours, result.Cost)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&by, "by", "process", "group by process, owner, gpu, or model")
+	cmd.Flags().StringVar(&since, "since", "30d", "period to cover, ending now (e.g. 12h, 7d)")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "output as JSON")
+	return cmd
+}
+
+// ── gc ──────────────────────────────────────────────────────────────────────
+
+func gcCmd() *cobra.Command {
+	var apply, jsonOut bool
+	var retention time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Report, and with --apply remove, stale logs and exited processes",
+		Long: `Lists what the daemon can clean up: logs, rotated ones included, of
+processes it no longer has that haven't been written for --retention, and
+processes that exited longer ago than that and won't be restarted. Nothing
+is removed without --apply.`,
+		Example: `  gpusched gc
+  gpusched gc --retention 168h --apply`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := newClient()
+			resp, err := c.Call("gc", protocol.GCParams{Apply: apply, RetentionMs: retention.Milliseconds()})
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			if jsonOut {
+				fmt.Println(string(resp.Result))
+				return nil
+			}
+
+			var result protocol.GCResult
+			json.Unmarshal(resp.Result, &result)
+			var total int64
+			for _, cat := range result.Categories {
+				fmt.Printf("%s: %d", cat.Name, len(cat.Items))
+				if cat.Bytes > 0 {
+					fmt.Printf(", %s", bytesize.Format(cat.Bytes))
+				}
+				fmt.Println()
+				for _, item := range cat.Items {
+					line := "  " + item.Name
+					if item.Bytes > 0 {
+						line += "  " + bytesize.Format(item.Bytes)
+					}
+					if item.Error != "" {
+						line += "  (" + item.Error + ")"
+					}
+					fmt.Println(line)
+				}
+				total += cat.Bytes
+			}
+			if result.Applied {
+				fmt.Printf("Reclaimed %s.\n", bytesize.Format(total))
+			} else {
+				fmt.Printf("Would reclaim %s. Run with --apply to remove.\n", bytesize.Format(total))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&apply, "apply", false, "remove what is found instead of only reporting it")
+	cmd.Flags().DurationVar(&retention, "retention", 24*time.Hour, "leave exited processes and logs younger than this")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "output as JSON")
+	return cmd
+}
+
+// ── apply ───────────────────────────────────────────────────────────────────
+
+func applyCmd() *cobra.Command {
+	var files, deletes []string
+	var jsonOut bool
+
+	cmd := &cobra.Command{
+		Use:   "apply -f FILE... [--delete NAME]",
+		Short: "Push process specs for the daemon to keep running",
+		Long: `Adds each spec to the daemon's desired state, or replaces the one of the
+same name. A spec is a JSON file with the fields of a run, named after the
+file unless it gives a name. The daemon starts specs that have no process,
+replaces a process when its spec changes, and kills it when its spec is
+deleted. Pushed specs last until the daemon restarts; for specs that
+outlive it, use daemon --spec-dir.`,
+		Example: `  gpusched apply -f trainer.json -f eval.json
+  gpusched apply --delete eval`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(files) == 0 && len(deletes) == 0 {
+				return fmt.Errorf("nothing to apply: give -f FILE or --delete NAME")
+			}
+			params := protocol.ApplyParams{Namespace: namespace, Delete: deletes}
+			for _, path := range files {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				var spec protocol.RunParams
+				if err := json.Unmarshal(data, &spec); err != nil {
+					return fmt.Errorf("%s: %w", path, err)
+				}
+				if spec.Name == "" {
+					spec.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+				}
+				params.Specs = append(params.Specs, spec)
+			}
+
+			c := newClient()
+			resp, err := c.Call("apply", params)
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			if jsonOut {
+				fmt.Println(string(resp.Result))
+				return nil
+			}
+
+			var result protocol.ApplyResult
+			json.Unmarshal(resp.Result, &result)
+			if len(result.Actions) == 0 {
+				fmt.Println("Nothing to change.")
+			}
+			failed := 0
+			for _, a := range result.Actions {
+				if a.Error != "" {
+					fmt.Printf("%s: %s failed: %s\n", a.Name, a.Action, a.Error)
+					failed++
+					continue
+				}
+				fmt.Printf("%s: %s\n", a.Name, a.Action)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d actions failed; the daemon retries them", failed, len(result.Actions))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&files, "file", "f", nil, "spec file to push (repeatable)")
+	cmd.Flags().StringArrayVar(&deletes, "delete", nil, "pushed spec to delete, killing its process (repeatable)")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "output as JSON")
+	return cmd
+}
+
+// ── kernel ──────────────────────────────────────────────────────────────────
+
+func kernelCmd() *cobra.Command {
+	var name string
+	var gpuID int
+
+	cmd := &cobra.Command{
+		Use:   "kernel [flags] -- KERNEL_COMMAND [ARGS...]",
+		Short: "Run a Jupyter kernel as a managed process (for use in kernel.json)",
+		Long: `Runs a Jupyter kernel under gpusched and stays in the foreground as its
+stand-in, so Jupyter can interrupt, restart, and shut it down as usual.
+The process is named after the notebook (JPY_SESSION_NAME) and is subject
+to idle freezing like any other. Use "gpusched kernel install" to create
+a kernelspec.`,
+		Example: `  gpusched kernel -- /usr/bin/python3 -m ipykernel_launcher -f {connection_file}`,
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if name == "" {
+				name = kernelName(os.Getenv("JPY_SESSION_NAME"), args)
+			}
+			dir, _ := os.Getwd()
+			if nb := os.Getenv("JPY_SESSION_NAME"); filepath.IsAbs(nb) {
+				dir = filepath.Dir(nb)
+			}
+			return runKernel(name, dir, gpuID, args)
+		},
+	}
+
+	cmd.Flags().StringVarP(&name, "name", "n", "", "process name (default: from the notebook path)")
+	cmd.Flags().IntVarP(&gpuID, "gpu", "g", 0, "GPU device index")
+	cmd.AddCommand(kernelInstallCmd())
+	return cmd
+}
+
+func kernelInstallCmd() *cobra.Command {
+	var python, displayName, kernelDir string
+	var gpuID int
+
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install a Jupyter kernelspec that launches kernels through gpusched",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			self, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			if python, err = exec.LookPath(python); err != nil {
+				return fmt.Errorf("--python: %w", err)
+			}
+			if kernelDir == "" {
+				home, err := os.UserHomeDir()
+				if err != nil {
+					return err
+				}
+				kernelDir = filepath.Join(home, ".local", "share", "jupyter", "kernels", fmt.Sprintf("gpusched-gpu%d", gpuID))
+			}
+			spec := map[string]any{
+				"argv": []string{
+					self, "kernel", "--gpu", strconv.Itoa(gpuID), "--",
+					python, "-m", "ipykernel_launcher", "-f", "{connection_file}",
+				},
+				"display_name":   displayName,
+				"language":       "python",
+				"interrupt_mode": "signal",
+			}
+			data, _ := json.MarshalIndent(spec, "", "  ")
+			if err := os.MkdirAll(kernelDir, 0o755); err != nil {
+				return err
+			}
+			path := filepath.Join(kernelDir, "kernel.json")
+			if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+				return err
+			}
+			fmt.Printf("Installed kernelspec %s\n", path)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&python, "python", "python3", "Python interpreter with ipykernel installed")
+	cmd.Flags().StringVar(&displayName, "display-name", "Python 3 (gpusched)", "name shown in Jupyter's kernel picker")
+	cmd.Flags().StringVar(&kernelDir, "dir", "", "kernelspec directory (default: ~/.local/share/jupyter/kernels/gpusched-gpuN)")
+	cmd.Flags().IntVarP(&gpuID, "gpu", "g", 0, "GPU device index")
+	return cmd
+}
+
+// kernelName derives a process name from the notebook path, falling back
+// to the kernel ID in the connection file name (kernel-<id>.json).
+func kernelName(notebook string, argv []string) string {
+	if notebook != "" {
+		stem := strings.TrimSuffix(filepath.Base(notebook), filepath.Ext(notebook))
+		if n := sanitizeName(stem); n != "" {
+			return n
+		}
+	}
+	for _, a := range argv {
+		base := filepath.Base(a)
+		if id, ok := strings.CutPrefix(base, "kernel-"); ok && strings.HasSuffix(id, ".json") {
+			id = strings.TrimSuffix(id, ".json")
+			if len(id) > 8 {
+				id = id[:8]
+			}
+			return "kernel-" + id
+		}
+	}
+	return "kernel"
+}
+
+// sanitizeName maps s to letters, digits, '-', '_', and '.'.
+func sanitizeName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+	return strings.Trim(b.String(), "-.")
+}
+
+// runKernel starts the kernel and mirrors its lifetime: SIGINT is passed
+// through as a kernel interrupt, SIGTERM/SIGHUP kill it, and the shim
+// exits once the kernel is gone.
+func runKernel(name, dir string, gpuID int, argv []string) error {
+	c := newClient()
+	params := protocol.RunParams{Namespace: namespace, Name: name, Cmd: argv, Dir: dir, GPU: gpuID}
+	resp, err := c.Call("run", params)
+	if err == nil && !resp.OK && strings.Contains(resp.Error, "already exists") {
+		// Another kernel for a notebook of the same name; disambiguate.
+		params.Name = fmt.Sprintf("%s-%d", name, os.Getpid())
+		resp, err = c.Call("run", params)
+	}
+	if err != nil {
+		return err
+	}
+	if !resp.OK {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	var started protocol.RunResult
+	json.Unmarshal(resp.Result, &started)
+	fmt.Fprintf(os.Stderr, "gpusched: kernel running as %s (pid=%d)\n", params.Name, started.PID)
+
+	target := protocol.NameParams{Namespace: namespace, Name: params.Name}
+	sigs := make(chan os.Signal, 4)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case sig := <-sigs:
+			if sig == syscall.SIGINT {
+				if err := interrupt(started.PID); err != nil {
+					fmt.Fprintf(os.Stderr, "gpusched: interrupt %s: %v\n", params.Name, err)
+				}
+				continue
+			}
+			c.Call("kill", target)
+			return nil
+		case <-ticker.C:
+			resp, err := c.Call("describe", target)
+			if err != nil {
+				continue // daemon restarting; keep waiting
+			}
+			if !resp.OK {
+				return nil // killed elsewhere
+			}
+			var p protocol.ProcessInfo
+			json.Unmarshal(resp.Result, &p)
+			if p.State == protocol.StateDead {
+				c.Call("kill", target)
+				return nil
+			}
+			started.PID = p.PID
+		}
+	}
+}
+
+// ── nodes ───────────────────────────────────────────────────────────────────
+
+func nodesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "nodes",
+		Short: "Find gpusched daemons on the local network",
+	}
+	cmd.AddCommand(nodesDiscoverCmd())
+	return cmd
+}
+
+func nodesDiscoverCmd() *cobra.Command {
+	var listen string
+	var timeout time.Duration
+	var jsonOut bool
+
+	cmd := &cobra.Command{
+		Use:   "discover",
+		Short: "Listen for beacons from daemons started with --advertise",
+		Example: `  gpusched nodes discover
+  gpusched nodes discover --timeout 30s --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			beacons, err := discovery.Listen(listen, timeout)
+			if err != nil {
+				return fmt.Errorf("listening for beacons on %s: %w", listen, err)
+			}
+			if jsonOut {
+				data, _ := json.MarshalIndent(beacons, "", "  ")
+				fmt.Println(string(data))
+				return nil
+			}
+			if len(beacons) == 0 {
+				fmt.Printf("(no daemons heard in %s)\n", timeout)
+				return nil
+			}
+			for _, b := range beacons {
+				fmt.Printf("%-20s %-15s %-24s %3d procs  %s\n",
+					b.Host, b.From, b.Socket, b.Processes, gpuInventory(b.GPUs))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&listen, "listen", fmt.Sprintf(":%d", discovery.DefaultPort), "UDP address to listen on")
+	cmd.Flags().DurationVar(&timeout, "timeout", 2*discovery.DefaultInterval, "how long to listen")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "output as JSON")
+	return cmd
+}
+
+// gpuInventory summarizes GPUs as "2× H100 (150G free)", grouping by model.
+func gpuInventory(gpus []protocol.GPUInfo) string {
+	if len(gpus) == 0 {
+		return "no GPUs"
+	}
+	var order []string
+	count := make(map[string]int)
+	var free int64
+	for _, g := range gpus {
+		if count[g.Name] == 0 {
+			order = append(order, g.Name)
+		}
+		count[g.Name]++
+		free += g.MemFree
+	}
+	parts := make([]string, len(order))
+	for i, name := range order {
+		parts[i] = fmt.Sprintf("%d× %s", count[name], name)
+	}
+	return fmt.Sprintf("%s (%s free)", strings.Join(parts, ", "), bytesize.FormatMB(free))
+}
+
+// ── debug ───────────────────────────────────────────────────────────────────
+
+func debugCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "debug",
+		Short:  "Dump daemon internals (event ring, subscribers) as JSON",
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := newClient()
+			resp, err := c.Call("debug", nil)
+			if err != nil {
+				return err
+			}
+			if !resp.OK {
+				return fmt.Errorf("%s", resp.Error)
+			}
+			var info protocol.DebugInfo
+			json.Unmarshal(resp.Result, &info)
+			out, _ := json.MarshalIndent(info, "", "  ")
+			fmt.Println(string(out))
+			return nil
+		},
+	}
+}
+
+// ── dashboard ───────────────────────────────────────────────────────────────
+
+func dashboardCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "dashboard",
+		Aliases: []string{"dash", "tui"},
+		Short:   "Interactive terminal dashboard",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c := newClient()
+			return tui.Run(c, namespace)
+		},
+	}
+}
+
+// ── Helpers ─────────────────────────────────────────────────────────────────
+
+// formatPhases renders a phase breakdown like "  [lock 12ms · checkpoint 590ms]".
+func formatPhases(phases []protocol.Phase) string {
+	if len(phases) == 0 {
+		return ""
+	}
+	parts := make([]string, len(phases))
+	for i, p := range phases {
+		parts[i] = fmt.Sprintf("%s %dms", p.Name, p.DurationMs)
+	}
+	return "  [" + strings.Join(parts, " · ") + "]"
+}
+
+// parseSince parses a duration that may also be given in days, e.g. "30d".
+func parseSince(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parseEnvSet turns repeated KEY=VALUE flags into a map.
+func parseEnvSet(flag string, pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	vars := make(map[string]string, len(pairs))
+	for _, kv := range pairs {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("%s: want KEY=VALUE, got %q", flag, kv)
+		}
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+// parseRates turns --gpu-rate model=price pairs into floats.
+func parseRates(raw map[string]string) (map[string]float64, error) {
+	rates := make(map[string]float64, len(raw))
+	for model, v := range raw {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 {
+			return nil, fmt.Errorf("--gpu-rate %s: invalid price %q", model, v)
+		}
+		rates[model] = r
+	}
+	return rates, nil
+}
+
+// buildProbe turns the --*-tcp/--*-http/--*-exec/--*-gpu-mem flags into a Probe.
+func buildProbe(tcp, httpURL, execCmd string, gpuMem bool) (*protocol.Probe, error) {
+	set := 0
+	for _, v := range []string{tcp, httpURL, execCmd} {
+		if v != "" {
+			set++
+		}
+	}
+	if gpuMem {
+		set++
+	}
+	if set == 0 {
+		return nil, nil
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("only one of tcp, http, exec, or gpu-mem probe may be set")
+	}
+	p := &protocol.Probe{TCP: tcp, HTTP: httpURL, GPUMem: gpuMem}
+	if execCmd != "" {
+		p.Exec = []string{"/bin/sh", "-c", execCmd}
+	}
+	return p, nil
+}
+
+// parseTimeouts converts action=duration pairs into cuda-checkpoint timeouts.
+func parseTimeouts(m map[string]string) (map[string]time.Duration, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]time.Duration, len(m))
+	for action, v := range m {
+		if !checkpoint.ValidAction(action) {
+			return nil, fmt.Errorf("unknown cuda-checkpoint action %q (want one of %s)",
+				action, strings.Join(checkpoint.Actions, ", "))
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("timeout for %s: %w", action, err)
+		}
+		out[action] = d
+	}
+	return out, nil
 }
@@ -0,0 +1,207 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpusched/internal/bytesize"
+	"gpusched/internal/client"
+	"gpusched/internal/protocol"
+
+	"github.com/spf13/cobra"
+)
+
+// ── selftest ────────────────────────────────────────────────────────────────
+
+// selftestProgram is the allocator selftest runs; see selftest.py.
+//
+//go:embed selftest.py
+var selftestProgram string
+
+// selftestTimeout bounds each wait for the allocator's output.
+const selftestTimeout = 60 * time.Second
+
+func selftestCmd() *cobra.Command {
+	var gpuIdx int
+	var size string
+	var python string
+
+	cmd := &cobra.Command{
+		Use:   "selftest",
+		Short: "Check freeze, thaw, and migrate end to end on a test allocation",
+		Long: `Runs a small CUDA program under the daemon that fills GPU memory with
+random bytes and keeps checksumming it, then freezes, thaws, and (with more
+than one GPU) migrates it, checking after each step that the memory came back
+intact. Needs python3 and the NVIDIA driver on the daemon's host.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mb, err := bytesize.ParseMB(size)
+			if err != nil || mb <= 0 {
+				return fmt.Errorf("--size: want a size such as 64M")
+			}
+			c := newClient()
+			var status protocol.StatusResult
+			if err := callInto(c, "status", protocol.StatusParams{Namespace: namespace}, &status); err != nil {
+				return err
+			}
+			if !status.Caps.CUDACheckpoint {
+				return fmt.Errorf("the daemon has no cuda-checkpoint; nothing to test")
+			}
+
+			t := &selftest{c: c, name: fmt.Sprintf("selftest-%d", os.Getpid())}
+			defer c.Call("kill", protocol.NameParams{Namespace: namespace, Name: t.name})
+
+			t.stage("start", func() (string, error) {
+				var res protocol.RunResult
+				err := callInto(c, "run", protocol.RunParams{
+					Namespace: namespace,
+					Name:      t.name,
+					Cmd:       []string{python, "-c", selftestProgram, strconv.FormatInt(mb, 10)},
+					GPU:       gpuIdx,
+				}, &res)
+				if err != nil {
+					return "", err
+				}
+				if err := t.verify(); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%s on GPU %d, checksum %s", bytesize.FormatMB(mb), res.GPU, t.want), nil
+			})
+			t.stage("freeze", func() (string, error) {
+				var res protocol.FreezeResult
+				if err := callInto(c, "freeze", protocol.FreezeParams{Namespace: namespace, Name: t.name}, &res); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%d ms", res.DurationMs), nil
+			})
+			t.stage("thaw", func() (string, error) {
+				var res protocol.ThawResult
+				if err := callInto(c, "thaw", protocol.NameParams{Namespace: namespace, Name: t.name}, &res); err != nil {
+					return "", err
+				}
+				if err := t.verify(); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%d ms, memory intact", res.DurationMs), nil
+			})
+
+			to := -1
+			for _, g := range status.GPUs {
+				if g.Index != gpuIdx {
+					to = g.Index
+					break
+				}
+			}
+			if to < 0 {
+				fmt.Printf("SKIP  %-8s only one GPU\n", "migrate")
+			} else {
+				t.stage("migrate", func() (string, error) {
+					start := time.Now()
+					var res protocol.MigrateResult
+					if err := callInto(c, "migrate", protocol.MigrateParams{Namespace: namespace, Name: t.name, GPU: to}, &res); err != nil {
+						return "", err
+					}
+					took := time.Since(start)
+					if err := t.verify(); err != nil {
+						return "", err
+					}
+					return fmt.Sprintf("GPU %d → GPU %d in %d ms, memory intact", res.FromGPU, res.ToGPU, took.Milliseconds()), nil
+				})
+			}
+
+			if t.failed {
+				return fmt.Errorf("selftest failed")
+			}
+			fmt.Println("All stages passed.")
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&gpuIdx, "gpu", 0, "GPU to start the test allocation on")
+	cmd.Flags().StringVar(&size, "size", "64M", "GPU memory to allocate and check")
+	cmd.Flags().StringVar(&python, "python", "python3", "Python interpreter on the daemon's host")
+	return cmd
+}
+
+// selftest tracks a selftest run: the allocator's process name, the
+// checksum its memory must keep, and the last check seen.
+type selftest struct {
+	c      *client.Client
+	name   string
+	want   string
+	seen   int
+	failed bool
+}
+
+// stage runs one step unless an earlier one failed, and prints its
+// outcome.
+func (t *selftest) stage(name string, run func() (string, error)) {
+	if t.failed {
+		fmt.Printf("SKIP  %-8s an earlier stage failed\n", name)
+		return
+	}
+	detail, err := run()
+	if err != nil {
+		t.failed = true
+		fmt.Printf("FAIL  %-8s %v\n", name, err)
+		return
+	}
+	fmt.Printf("PASS  %-8s %s\n", name, detail)
+}
+
+// verify waits for the allocator to check its memory again, after every
+// check already in its log, and compares the checksum with the one it
+// started with.
+func (t *selftest) verify() error {
+	deadline := time.Now().Add(selftestTimeout)
+	after := -1
+	for time.Now().Before(deadline) {
+		var logs protocol.LogsResult
+		err := callInto(t.c, "logs", protocol.LogsParams{
+			Namespace: namespace, Name: t.name, Lines: 20, Grep: `^(ready|verify|error) `,
+		}, &logs)
+		if err != nil {
+			return err
+		}
+		last, sum := 0, ""
+		for _, line := range logs.Lines {
+			f := strings.Fields(line)
+			switch {
+			case f[0] == "error":
+				return fmt.Errorf("allocator: %s", line)
+			case f[0] == "ready" && len(f) == 3 && t.want == "":
+				t.want = f[2]
+			case f[0] == "verify" && len(f) == 4:
+				last, _ = strconv.Atoi(f[1])
+				sum = f[3]
+			}
+		}
+		if after < 0 {
+			after = max(last, t.seen)
+		} else if last > after {
+			t.seen = last
+			if sum != t.want {
+				return fmt.Errorf("memory changed: checksum %s, want %s", sum, t.want)
+			}
+			return nil
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	return fmt.Errorf("no memory check from the allocator within %s", selftestTimeout)
+}
+
+// callInto calls method and decodes a successful result into out.
+func callInto(c *client.Client, method string, params, out any) error {
+	resp, err := c.Call(method, params)
+	if err != nil {
+		return err
+	}
+	if !resp.OK {
+		return fmt.Errorf("%s", resp.Error)
+	}
+	return json.Unmarshal(resp.Result, out)
+}
@@ -0,0 +1,44 @@
+"""Allocator for `gpusched selftest`.
+
+Fills a GPU buffer with random bytes through the CUDA driver API, prints
+their checksum, and then reads the buffer back every second, printing the
+checksum again, so freezes, thaws, and migrations can be checked for
+intact memory. Needs only the NVIDIA driver, not the CUDA toolkit.
+
+Usage: python3 selftest.py [MB]
+"""
+import ctypes
+import hashlib
+import os
+import sys
+import time
+
+size = (int(sys.argv[1]) if len(sys.argv) > 1 else 64) << 20
+cuda = ctypes.CDLL("libcuda.so.1")
+
+
+def check(rc, call):
+    if rc != 0:
+        print(f"error {call} returned {rc}", flush=True)
+        sys.exit(1)
+
+
+check(cuda.cuInit(0), "cuInit")
+dev = ctypes.c_int()
+check(cuda.cuDeviceGet(ctypes.byref(dev), 0), "cuDeviceGet")
+ctx = ctypes.c_void_p()
+check(cuda.cuCtxCreate_v2(ctypes.byref(ctx), 0, dev), "cuCtxCreate")
+buf = ctypes.c_uint64()
+check(cuda.cuMemAlloc_v2(ctypes.byref(buf), ctypes.c_size_t(size)), "cuMemAlloc")
+
+host = ctypes.create_string_buffer(os.urandom(size), size)
+check(cuda.cuMemcpyHtoD_v2(buf, host, ctypes.c_size_t(size)), "cuMemcpyHtoD")
+print(f"ready checksum {hashlib.sha256(host.raw).hexdigest()[:16]}", flush=True)
+
+n = 0
+while True:
+    time.sleep(1)
+    ctypes.memset(host, 0, size)
+    check(cuda.cuMemcpyDtoH_v2(host, buf, ctypes.c_size_t(size)), "cuMemcpyDtoH")
+    n += 1
+    print(f"verify {n} checksum {hashlib.sha256(host.raw).hexdigest()[:16]}", flush=True)
@@ -0,0 +1,17 @@
+//go:build windows
+
+package main
+
+import "errors"
+
+// interrupt is unsupported on Windows, which has no SIGINT to send to
+// another process; kernels run on the daemon's Linux host anyway.
+func interrupt(pid int) error {
+	return errors.New("interrupting a process is not supported on Windows")
+}
+
+// onResize does nothing on Windows, which has no SIGWINCH; an attached
+// process keeps the size the terminal had when attach started.
+func onResize(fn func()) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func chain(t *testing.T, records ...string) []string {
+	t.Helper()
+	var lines []string
+	prev := ""
+	for _, r := range records {
+		line, hash := Seal(prev, []byte(r))
+		lines = append(lines, string(line))
+		prev = hash
+	}
+	return lines
+}
+
+func TestSealVerify(t *testing.T) {
+	lines := chain(t, `{"op":"freeze","mem_mb":100}`, `{"op":"thaw","mem_mb":100}`, `{"op":"migrate"}`)
+
+	// Sealed lines are still valid JSON with the original fields.
+	var rec map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil || rec["op"] != "freeze" || rec["hash"] == nil {
+		t.Fatalf("sealed line %s: %v", lines[0], err)
+	}
+
+	all := `{"op":"legacy"}` + "\n" + strings.Join(lines, "\n") + "\n"
+	res, err := Verify(strings.NewReader(all))
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if res.Unchained != 1 || res.Chained != 3 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if got := Head([]byte(all)); got != res.Head {
+		t.Fatalf("Head = %s, Verify head = %s", got, res.Head)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	lines := chain(t, `{"op":"freeze","mem_mb":100}`, `{"op":"thaw","mem_mb":100}`, `{"op":"migrate"}`)
+	cases := map[string][]string{
+		"edited":    {strings.Replace(lines[0], "100", "999", 1), lines[1], lines[2]},
+		"removed":   {lines[0], lines[2]},
+		"reordered": {lines[1], lines[0], lines[2]},
+		"unsealed":  {lines[0], `{"op":"kill"}`, lines[1]},
+	}
+	for name, tampered := range cases {
+		_, err := Verify(bytes.NewBufferString(strings.Join(tampered, "\n")))
+		if err == nil {
+			t.Errorf("%s: tampering not detected", name)
+		}
+	}
+}
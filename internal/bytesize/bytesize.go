@@ -0,0 +1,141 @@
+// Package bytesize parses and formats memory sizes.
+//
+// Accepted suffixes (case-insensitive):
+//
+//	Ki, KiB, Mi, MiB, Gi, GiB, Ti, TiB   binary (powers of 1024)
+//	KB, MB, GB, TB                       decimal (powers of 1000)
+//	K, M, G, T                           binary, for compatibility with
+//	                                     earlier --ram-budget values
+//
+// A bare number is taken as MiB. Fractions are allowed ("1.5G").
+package bytesize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	KiB int64 = 1 << 10
+	MiB int64 = 1 << 20
+	GiB int64 = 1 << 30
+	TiB int64 = 1 << 40
+)
+
+var units = map[string]int64{
+	"":    MiB,
+	"b":   1,
+	"k":   KiB,
+	"ki":  KiB,
+	"kib": KiB,
+	"kb":  1000,
+	"m":   MiB,
+	"mi":  MiB,
+	"mib": MiB,
+	"mb":  1000 * 1000,
+	"g":   GiB,
+	"gi":  GiB,
+	"gib": GiB,
+	"gb":  1000 * 1000 * 1000,
+	"t":   TiB,
+	"ti":  TiB,
+	"tib": TiB,
+	"tb":  1000 * 1000 * 1000 * 1000,
+}
+
+// Parse converts a size string to bytes.
+func Parse(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
+	}
+	num, unit := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+	if num == "" {
+		return 0, fmt.Errorf("invalid size %q: missing number", s)
+	}
+	mult, ok := units[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, s[i:])
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	bytes := v * float64(mult)
+	if bytes > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return int64(bytes), nil
+}
+
+// ParseMB converts a size string to whole MiB, rounding down. An empty
+// string yields 0 with no error so optional flags can pass through.
+func ParseMB(s string) (int64, error) {
+	if strings.TrimSpace(s) == "" {
+		return 0, nil
+	}
+	b, err := Parse(s)
+	if err != nil {
+		return 0, err
+	}
+	return b / MiB, nil
+}
+
+// FormatMB renders a MiB count with the largest binary unit that keeps the
+// value at or above 1, e.g. 512 → "512 MiB", 1536 → "1.5 GiB".
+func FormatMB(mb int64) string {
+	neg := mb < 0
+	if neg {
+		mb = -mb
+	}
+	var out string
+	switch {
+	case mb >= 1024*1024:
+		out = trimFloat(float64(mb)/(1024*1024)) + " TiB"
+	case mb >= 1024:
+		out = trimFloat(float64(mb)/1024) + " GiB"
+	default:
+		out = strconv.FormatInt(mb, 10) + " MiB"
+	}
+	if neg {
+		return "-" + out
+	}
+	return out
+}
+
+// Format renders a byte count the same way, down to bytes, e.g. 900 →
+// "900 B", 1536 → "1.5 KiB".
+func Format(b int64) string {
+	neg := b < 0
+	if neg {
+		b = -b
+	}
+	var out string
+	switch {
+	case b >= TiB:
+		out = trimFloat(float64(b)/float64(TiB)) + " TiB"
+	case b >= GiB:
+		out = trimFloat(float64(b)/float64(GiB)) + " GiB"
+	case b >= MiB:
+		out = trimFloat(float64(b)/float64(MiB)) + " MiB"
+	case b >= KiB:
+		out = trimFloat(float64(b)/float64(KiB)) + " KiB"
+	default:
+		out = strconv.FormatInt(b, 10) + " B"
+	}
+	if neg {
+		return "-" + out
+	}
+	return out
+}
+
+func trimFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 1, 64)
+	return strings.TrimSuffix(s, ".0")
+}
@@ -0,0 +1,70 @@
+package bytesize
+
+import "testing"
+
+func TestParseMB(t *testing.T) {
+	cases := map[string]int64{
+		"":       0,
+		"80000":  80000,
+		"80G":    80 * 1024,
+		"80g":    80 * 1024,
+		"80Gi":   80 * 1024,
+		"80GiB":  80 * 1024,
+		"1.5G":   1536,
+		"2T":     2 * 1024 * 1024,
+		"512M":   512,
+		"1000MB": 953,
+		"1GB":    953,
+		"2048Ki": 2,
+	}
+	for in, want := range cases {
+		got, err := ParseMB(in)
+		if err != nil {
+			t.Fatalf("ParseMB(%q): %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("ParseMB(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{"G", "80X", "eighty", "1.2.3G", "-5G"} {
+		if _, err := ParseMB(in); err == nil {
+			t.Fatalf("ParseMB(%q): expected error", in)
+		}
+	}
+}
+
+func TestFormatMB(t *testing.T) {
+	cases := map[int64]string{
+		0:       "0 MiB",
+		512:     "512 MiB",
+		1024:    "1 GiB",
+		1536:    "1.5 GiB",
+		81559:   "79.6 GiB",
+		2097152: "2 TiB",
+		-2048:   "-2 GiB",
+	}
+	for in, want := range cases {
+		if got := FormatMB(in); got != want {
+			t.Fatalf("FormatMB(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	cases := map[int64]string{
+		0:          "0 B",
+		900:        "900 B",
+		1536:       "1.5 KiB",
+		5 << 20:    "5 MiB",
+		3 << 30:    "3 GiB",
+		-(2 << 40): "-2 TiB",
+	}
+	for in, want := range cases {
+		if got := Format(in); got != want {
+			t.Fatalf("Format(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
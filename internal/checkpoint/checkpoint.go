@@ -2,6 +2,8 @@
 package checkpoint
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,9 +12,65 @@ import (
 	"time"
 )
 
+// Actions lists the cuda-checkpoint actions gpusched invokes, and
+// get-state, the state query rollback relies on.
+var Actions = []string{"lock", "checkpoint", "restore", "unlock", "get-state"}
+
+// RecoverAttempts bounds the actions Recover takes before giving up.
+const RecoverAttempts = 3
+
+// ErrUnrecovered marks a failed freeze or thaw that left the process in a
+// CUDA state Recover could not bring it back from.
+var ErrUnrecovered = errors.New("CUDA state not recovered")
+
+// ValidAction reports whether action is one of Actions.
+func ValidAction(action string) bool {
+	for _, a := range Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
 type CUDA struct {
 	Binary    string
 	Available bool
+
+	// ExtraArgs are appended to every cuda-checkpoint invocation.
+	ExtraArgs []string
+	// Timeouts bounds each action; zero or missing means no timeout.
+	Timeouts map[string]time.Duration
+}
+
+// NewCUDAAt uses the given cuda-checkpoint binary, falling back to
+// NewCUDA's lookup when binary is empty.
+func NewCUDAAt(binary string) *CUDA {
+	if binary == "" {
+		return NewCUDA()
+	}
+	if _, err := os.Stat(binary); err != nil {
+		return &CUDA{Binary: binary, Available: false}
+	}
+	return &CUDA{Binary: binary, Available: true}
+}
+
+// With returns a copy of c with extra args appended and timeouts overridden
+// per action. c itself is left untouched.
+func (c *CUDA) With(extraArgs []string, timeouts map[string]time.Duration) *CUDA {
+	if len(extraArgs) == 0 && len(timeouts) == 0 {
+		return c
+	}
+	cp := *c
+	cp.ExtraArgs = append(append([]string(nil), c.ExtraArgs...), extraArgs...)
+	cp.Timeouts = make(map[string]time.Duration, len(c.Timeouts)+len(timeouts))
+	for k, v := range c.Timeouts {
+		cp.Timeouts[k] = v
+	}
+	for k, v := range timeouts {
+		cp.Timeouts[k] = v
+	}
+	return &cp
 }
 
 func NewCUDA() *CUDA {
@@ -48,40 +106,137 @@ func (c *CUDA) Unlock(pid int) (time.Duration, error) {
 	return c.run("unlock", pid)
 }
 
-func (c *CUDA) RestoreOnDevice(pid, device int) (time.Duration, error) {
+// State returns pid's CUDA checkpoint state as cuda-checkpoint reports it:
+// "running", "locked", "checkpointed", or "failed".
+func (c *CUDA) State(pid int) (string, error) {
 	if !c.Available {
-		return 0, fmt.Errorf("cuda-checkpoint not available")
+		return "", fmt.Errorf("cuda-checkpoint not available")
+	}
+	out, _, err := c.command("get-state", pid, []string{"--get-state", "--pid", strconv.Itoa(pid)})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Phase is the wall-clock time spent in one step of a freeze or thaw.
+type Phase struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Phases is an ordered list of steps.
+type Phases []Phase
+
+func (ps Phases) Total() time.Duration {
+	var total time.Duration
+	for _, p := range ps {
+		total += p.Duration
 	}
-	return c.exec("restore", pid, "--device", strconv.Itoa(device))
+	return total
 }
 
-// Freeze performs the full lock→checkpoint sequence.
-func (c *CUDA) Freeze(pid int) (time.Duration, error) {
+// Recover brings pid back to "running" after a failed freeze or thaw,
+// re-reading its state before each step: a checkpoint is restored and a
+// lock released. It gives up after attempts steps and returns the last
+// state seen.
+func (c *CUDA) Recover(pid, attempts int) (string, error) {
+	var state string
+	var err error
+	for i := 0; ; i++ {
+		var serr error
+		if state, serr = c.State(pid); serr != nil {
+			err = serr
+		} else if state == "running" {
+			return state, nil
+		}
+		if i == attempts {
+			break
+		}
+		switch state {
+		case "locked":
+			_, err = c.Unlock(pid)
+		case "checkpointed":
+			_, err = c.Restore(pid)
+		case "":
+		default:
+			return state, fmt.Errorf("process is %s", state)
+		}
+	}
+	if state == "" {
+		return "", fmt.Errorf("state unknown after %d attempts: %w", attempts, err)
+	}
+	return state, fmt.Errorf("still %s after %d attempts: %v", state, attempts, err)
+}
+
+// recovered runs Recover after step failed with err. If the process is
+// back to running, err is returned as is; otherwise it is wrapped with
+// ErrUnrecovered. The time spent is added to phases.
+func (c *CUDA) recovered(pid int, phases *Phases, step string, err error) error {
+	start := time.Now()
+	_, rerr := c.Recover(pid, RecoverAttempts)
+	*phases = append(*phases, Phase{"recover", time.Since(start)})
+	if rerr != nil {
+		return fmt.Errorf("%s: %w (recovery: %v): %w", step, err, rerr, ErrUnrecovered)
+	}
+	return fmt.Errorf("%s: %w", step, err)
+}
+
+// Freeze performs the full lock→checkpoint sequence. If a step fails, the
+// process is rolled back to running; the error wraps ErrUnrecovered when
+// that fails too.
+func (c *CUDA) Freeze(pid int) (Phases, error) {
 	lockDur, err := c.Lock(pid)
+	phases := Phases{{"lock", lockDur}}
 	if err != nil {
-		return lockDur, fmt.Errorf("lock: %w", err)
+		return phases, c.recovered(pid, &phases, "lock", err)
 	}
 	ckptDur, err := c.Checkpoint(pid)
-	total := lockDur + ckptDur
+	phases = append(phases, Phase{"checkpoint", ckptDur})
 	if err != nil {
-		c.Unlock(pid) //nolint:errcheck
-		return total, fmt.Errorf("checkpoint: %w", err)
+		return phases, c.recovered(pid, &phases, "checkpoint", err)
 	}
-	return total, nil
+	return phases, nil
+}
+
+// Thaw performs the full restore→unlock sequence. A failed restore that
+// leaves the process checkpointed is an ordinary error, so the thaw can be
+// retried. Otherwise the process is driven on to running, and Thaw
+// succeeds if it gets there; the error wraps ErrUnrecovered if not.
+func (c *CUDA) Thaw(pid int) (Phases, error) {
+	return c.thaw(pid)
 }
 
-// Thaw performs the full restore→unlock sequence.
-func (c *CUDA) Thaw(pid int) (time.Duration, error) {
-	restDur, err := c.Restore(pid)
+// ThawOnDevice is Thaw restoring onto GPU device instead of the one pid
+// was checkpointed from.
+func (c *CUDA) ThawOnDevice(pid, device int) (Phases, error) {
+	return c.thaw(pid, "--device", strconv.Itoa(device))
+}
+
+// thaw is Thaw with extra arguments to the restore step.
+func (c *CUDA) thaw(pid int, extra ...string) (Phases, error) {
+	if !c.Available {
+		return nil, fmt.Errorf("cuda-checkpoint not available")
+	}
+	restDur, err := c.exec("restore", pid, extra...)
+	phases := Phases{{"restore", restDur}}
 	if err != nil {
-		return restDur, fmt.Errorf("restore: %w", err)
+		if state, serr := c.State(pid); serr == nil && state == "checkpointed" {
+			return phases, fmt.Errorf("restore: %w", err)
+		}
+		if err := c.recovered(pid, &phases, "restore", err); errors.Is(err, ErrUnrecovered) {
+			return phases, err
+		}
+		return phases, nil
 	}
 	unlDur, err := c.Unlock(pid)
-	total := restDur + unlDur
+	phases = append(phases, Phase{"unlock", unlDur})
 	if err != nil {
-		return total, fmt.Errorf("unlock: %w", err)
+		if err := c.recovered(pid, &phases, "unlock", err); errors.Is(err, ErrUnrecovered) {
+			return phases, err
+		}
 	}
-	return total, nil
+	return phases, nil
 }
 
 func (c *CUDA) run(action string, pid int) (time.Duration, error) {
@@ -93,15 +248,34 @@ func (c *CUDA) run(action string, pid int) (time.Duration, error) {
 
 func (c *CUDA) exec(action string, pid int, extra ...string) (time.Duration, error) {
 	args := []string{"--action", action, "--pid", strconv.Itoa(pid)}
-	args = append(args, extra...)
+	_, elapsed, err := c.command(action, pid, append(args, extra...))
+	return elapsed, err
+}
+
+// command runs cuda-checkpoint with args and c.ExtraArgs, bounded by
+// action's timeout, and returns its output.
+func (c *CUDA) command(action string, pid int, args []string) ([]byte, time.Duration, error) {
+	args = append(args, c.ExtraArgs...)
+
+	ctx := context.Background()
+	timeout := c.Timeouts[action]
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	start := time.Now()
-	cmd := exec.Command(c.Binary, args...)
+	cmd := exec.CommandContext(ctx, c.Binary, args...)
+	cmd.WaitDelay = time.Second
 	out, err := cmd.CombinedOutput()
 	elapsed := time.Since(start)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return out, elapsed, fmt.Errorf("cuda-checkpoint --%s pid=%d: timed out after %s", action, pid, timeout)
+	}
 	if err != nil {
-		return elapsed, fmt.Errorf("cuda-checkpoint --%s pid=%d: %s (%w)",
+		return out, elapsed, fmt.Errorf("cuda-checkpoint --%s pid=%d: %s (%w)",
 			action, pid, strings.TrimSpace(string(out)), err)
 	}
-	return elapsed, nil
+	return out, elapsed, nil
 }
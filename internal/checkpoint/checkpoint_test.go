@@ -1,7 +1,12 @@
 package checkpoint
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewCUDA(t *testing.T) {
@@ -27,3 +32,120 @@ func TestCUDAThawRequiresAvailable(t *testing.T) {
 		t.Fatal("expected error for unavailable cuda-checkpoint")
 	}
 }
+
+func fakeBinary(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cuda-checkpoint")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCUDAActionTimeout(t *testing.T) {
+	c := NewCUDAAt(fakeBinary(t, "sleep 5"))
+	c.Timeouts = map[string]time.Duration{"lock": 50 * time.Millisecond}
+	_, err := c.Lock(1)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	// The state query Recover runs is bounded the same way.
+	c.Timeouts["get-state"] = 50 * time.Millisecond
+	if _, err := c.State(1); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected get-state timeout error, got %v", err)
+	}
+}
+
+func TestCUDAExtraArgs(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "args")
+	base := NewCUDAAt(fakeBinary(t, `echo "$@" > `+out))
+	base.ExtraArgs = []string{"--verbose"}
+	c := base.With([]string{"--foo"}, nil)
+	if _, err := c.Unlock(42); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	if got := strings.TrimSpace(string(data)); got != "--action unlock --pid 42 --verbose --foo" {
+		t.Fatalf("unexpected args: %q", got)
+	}
+	if len(base.ExtraArgs) != 1 {
+		t.Fatal("With mutated the base CUDA")
+	}
+	if _, err := c.State(42); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(out)
+	if got := strings.TrimSpace(string(data)); got != "--get-state --pid 42 --verbose --foo" {
+		t.Fatalf("unexpected get-state args: %q", got)
+	}
+}
+
+func TestCUDAFreezePhases(t *testing.T) {
+	c := NewCUDAAt(fakeBinary(t, "exit 0"))
+	phases, err := c.Freeze(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(phases) != 2 || phases[0].Name != "lock" || phases[1].Name != "checkpoint" {
+		t.Fatalf("unexpected phases: %+v", phases)
+	}
+	if phases.Total() != phases[0].Duration+phases[1].Duration {
+		t.Fatal("Total does not sum phases")
+	}
+}
+
+// statefulBinary fakes cuda-checkpoint with its state kept in a file;
+// actions listed in fail exit 1 without changing it.
+func statefulBinary(t *testing.T, fail string) string {
+	t.Helper()
+	state := filepath.Join(t.TempDir(), "state")
+	os.WriteFile(state, []byte("running\n"), 0o644)
+	return fakeBinary(t, `
+st=`+state+`
+[ "$1" = --get-state ] && exec cat $st
+case " `+fail+` " in *" $2 "*) exit 1 ;; esac
+case "$2" in
+lock|restore) echo locked > $st ;;
+checkpoint) echo checkpointed > $st ;;
+unlock) echo running > $st ;;
+esac`)
+}
+
+func TestCUDAFreezeRollsBack(t *testing.T) {
+	c := NewCUDAAt(statefulBinary(t, "checkpoint"))
+	phases, err := c.Freeze(1)
+	if err == nil || errors.Is(err, ErrUnrecovered) {
+		t.Fatalf("want a recovered checkpoint error, got %v", err)
+	}
+	if last := phases[len(phases)-1]; last.Name != "recover" {
+		t.Fatalf("expected a recover phase, got %+v", phases)
+	}
+	if state, _ := c.State(1); state != "running" {
+		t.Fatalf("state = %q after rollback", state)
+	}
+}
+
+func TestCUDAFreezeUnrecovered(t *testing.T) {
+	c := NewCUDAAt(statefulBinary(t, "checkpoint unlock"))
+	if _, err := c.Freeze(1); !errors.Is(err, ErrUnrecovered) {
+		t.Fatalf("want ErrUnrecovered, got %v", err)
+	}
+	state, err := c.Recover(1, RecoverAttempts)
+	if err == nil || state != "locked" {
+		t.Fatalf("Recover = %q, %v; want locked and an error", state, err)
+	}
+}
+
+func TestCUDAThawKeepsCheckpoint(t *testing.T) {
+	c := NewCUDAAt(statefulBinary(t, "restore"))
+	if _, err := c.Freeze(1); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.Thaw(1)
+	if err == nil || errors.Is(err, ErrUnrecovered) {
+		t.Fatalf("want a retryable restore error, got %v", err)
+	}
+	if state, _ := c.State(1); state != "checkpointed" {
+		t.Fatalf("state = %q; a failed restore should leave the checkpoint", state)
+	}
+}
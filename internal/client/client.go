@@ -1,29 +1,83 @@
-// Package client connects to the gpusched daemon via Unix socket.
+// Package client connects to the gpusched daemon via Unix socket, or over
+// TCP when the address starts with tcp:// (tls:// for TLS).
 package client
 
 import (
-	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
+	"strings"
+	"time"
 
-	"gpusched/internal/daemon"
 	"gpusched/internal/protocol"
 )
 
 type Client struct {
 	sockPath string
+	node     string
+	token    string
+	tls      *tls.Config
 }
 
 func New(sockPath string) *Client {
 	if sockPath == "" {
-		sockPath = daemon.DefaultSocket
+		sockPath = protocol.DefaultSocket
 	}
 	return &Client{sockPath: sockPath}
 }
 
+// OnNode has Call run requests on the named cluster peer of the daemon
+// instead of the daemon itself. Streams are not relayed.
+func (c *Client) OnNode(node string) *Client {
+	c.node = node
+	return c
+}
+
+// WithToken sends token with every request, for a daemon whose TCP
+// listener requires one.
+func (c *Client) WithToken(token string) *Client {
+	c.token = token
+	return c
+}
+
+// WithTLS sets the TLS configuration for tls:// addresses, e.g. to trust
+// a daemon's self-signed certificate. By default the system roots are.
+func (c *Client) WithTLS(cfg *tls.Config) *Client {
+	c.tls = cfg
+	return c
+}
+
+func (c *Client) dial() (net.Conn, error) {
+	if addr, ok := strings.CutPrefix(c.sockPath, protocol.TCPPrefix); ok {
+		return net.DialTimeout("tcp", addr, 10*time.Second)
+	}
+	if addr, ok := strings.CutPrefix(c.sockPath, protocol.TLSPrefix); ok {
+		return tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", addr, c.tls)
+	}
+	return net.Dial("unix", c.sockPath)
+}
+
 func (c *Client) Call(method string, params interface{}) (protocol.Response, error) {
-	conn, err := net.Dial("unix", c.sockPath)
+	req, err := newRequest(method, params)
+	if err != nil {
+		return protocol.Response{}, err
+	}
+	req.Node = c.node
+	resp, err := c.Do(req)
+	if err != nil {
+		return protocol.Response{}, err
+	}
+	return tagError(resp), nil
+}
+
+// Do sends an already built request and returns the daemon's response
+// as is. The daemon uses it to relay requests to cluster peers.
+func (c *Client) Do(req protocol.Request) (protocol.Response, error) {
+	conn, err := c.dial()
 	if err != nil {
 		return protocol.Response{}, fmt.Errorf(
 			"cannot connect to daemon at %s — is 'gpusched daemon' running?\n  error: %w",
@@ -32,61 +86,103 @@ func (c *Client) Call(method string, params interface{}) (protocol.Response, err
 	}
 	defer conn.Close()
 
-	var rawParams json.RawMessage
-	if params != nil {
-		rawParams, err = json.Marshal(params)
-		if err != nil {
-			return protocol.Response{}, fmt.Errorf("marshaling params: %w", err)
-		}
-	}
-
-	req := protocol.Request{Method: method, Params: rawParams}
-	data, _ := json.Marshal(req)
-	data = append(data, '\n')
-	if _, err := conn.Write(data); err != nil {
+	req.Framing = protocol.FramingLength
+	req.Token = c.token
+	if err := protocol.WriteMessage(conn, req, false); err != nil {
 		return protocol.Response{}, fmt.Errorf("writing request: %w", err)
 	}
 
-	scanner := bufio.NewScanner(conn)
-	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
-	if !scanner.Scan() {
+	data, err := protocol.NewDecoder(conn).Next()
+	if err != nil {
 		return protocol.Response{}, fmt.Errorf("no response from daemon")
 	}
 
 	var resp protocol.Response
-	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+	if err := json.Unmarshal(data, &resp); err != nil {
 		return protocol.Response{}, fmt.Errorf("decoding response: %w", err)
 	}
 	return resp, nil
 }
 
+// tagError appends the request ID to a failed response's error so it can be
+// matched against the daemon log.
+func tagError(resp protocol.Response) protocol.Response {
+	if !resp.OK && resp.ID != "" {
+		resp.Error = fmt.Sprintf("%s (request %s)", resp.Error, resp.ID)
+	}
+	return resp
+}
+
+func newRequestID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newRequest(method string, params interface{}) (protocol.Request, error) {
+	var rawParams json.RawMessage
+	if params != nil {
+		var err error
+		rawParams, err = json.Marshal(params)
+		if err != nil {
+			return protocol.Request{}, fmt.Errorf("marshaling params: %w", err)
+		}
+	}
+	return protocol.Request{
+		ID:      newRequestID(),
+		Method:  method,
+		Params:  rawParams,
+		Framing: protocol.FramingLength,
+	}, nil
+}
+
+// send writes a request as a JSON line, asking for length-prefixed replies.
+func send(conn net.Conn, token, method string, params interface{}) error {
+	req, err := newRequest(method, params)
+	if err != nil {
+		return err
+	}
+	req.Token = token
+	if err := protocol.WriteMessage(conn, req, false); err != nil {
+		return fmt.Errorf("writing request: %w", err)
+	}
+	return nil
+}
+
 // Subscribe opens a persistent connection for event streaming.
 func (c *Client) Subscribe() (protocol.StatusResult, <-chan protocol.Event, func(), error) {
-	conn, err := net.Dial("unix", c.sockPath)
+	return c.subscribe(nil)
+}
+
+// SubscribeDeltas is Subscribe with "status-delta" events every interval
+// in which status changed; apply each event's Delta to the returned status
+// to keep it current.
+func (c *Client) SubscribeDeltas(interval time.Duration) (protocol.StatusResult, <-chan protocol.Event, func(), error) {
+	return c.subscribe(protocol.SubscribeParams{StatusDeltas: true, IntervalMs: interval.Milliseconds()})
+}
+
+func (c *Client) subscribe(params interface{}) (protocol.StatusResult, <-chan protocol.Event, func(), error) {
+	conn, err := c.dial()
 	if err != nil {
 		return protocol.StatusResult{}, nil, nil, fmt.Errorf(
 			"cannot connect to daemon at %s: %w", c.sockPath, err,
 		)
 	}
 
-	req := protocol.Request{Method: "subscribe"}
-	data, _ := json.Marshal(req)
-	data = append(data, '\n')
-	if _, err := conn.Write(data); err != nil {
+	if err := send(conn, c.token, "subscribe", params); err != nil {
 		conn.Close()
 		return protocol.StatusResult{}, nil, nil, fmt.Errorf("sending subscribe: %w", err)
 	}
 
-	scanner := bufio.NewScanner(conn)
-	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
-
-	if !scanner.Scan() {
+	dec := protocol.NewDecoder(conn)
+	data, err := dec.Next()
+	if err != nil {
 		conn.Close()
 		return protocol.StatusResult{}, nil, nil, fmt.Errorf("no initial status")
 	}
 
 	var initResp protocol.Response
-	if err := json.Unmarshal(scanner.Bytes(), &initResp); err != nil {
+	if err := json.Unmarshal(data, &initResp); err != nil {
 		conn.Close()
 		return protocol.StatusResult{}, nil, nil, fmt.Errorf("decoding initial status: %w", err)
 	}
@@ -100,9 +196,13 @@ func (c *Client) Subscribe() (protocol.StatusResult, <-chan protocol.Event, func
 	go func() {
 		defer close(ch)
 		defer conn.Close()
-		for scanner.Scan() {
+		for {
+			data, err := dec.Next()
+			if err != nil {
+				return
+			}
 			var event protocol.Event
-			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			if err := json.Unmarshal(data, &event); err != nil {
 				continue
 			}
 			ch <- event
@@ -113,43 +213,261 @@ func (c *Client) Subscribe() (protocol.StatusResult, <-chan protocol.Event, func
 	return status, ch, cancel, nil
 }
 
+// WatchStatus opens a read-only stream of status snapshots pushed every
+// interval. The channel closes when the connection ends.
+func (c *Client) WatchStatus(interval time.Duration, namespace string) (<-chan protocol.StatusResult, func(), error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot connect to daemon at %s: %w", c.sockPath, err)
+	}
+	params := protocol.StatusStreamParams{IntervalMs: interval.Milliseconds(), Namespace: namespace}
+	if err := send(conn, c.token, "status_stream", params); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("sending status_stream: %w", err)
+	}
+
+	ch := make(chan protocol.StatusResult, 1)
+	go func() {
+		defer close(ch)
+		defer conn.Close()
+		dec := protocol.NewDecoder(conn)
+		for {
+			data, err := dec.Next()
+			if err != nil {
+				return
+			}
+			var resp protocol.Response
+			if err := json.Unmarshal(data, &resp); err != nil || !resp.OK {
+				return
+			}
+			var status protocol.StatusResult
+			if err := json.Unmarshal(resp.Result, &status); err != nil {
+				continue
+			}
+			ch <- status
+		}
+	}()
+
+	cancel := func() { conn.Close() }
+	return ch, cancel, nil
+}
+
+// FollowLogs streams a process's log: the last lines first, as Call("logs")
+// returns them, then new lines as they are written. The channel closes
+// when the process has exited and its output is drained, or the
+// connection ends.
+func (c *Client) FollowLogs(params protocol.LogsParams) (<-chan protocol.LogsResult, func(), error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot connect to daemon at %s: %w", c.sockPath, err)
+	}
+	params.Follow = true
+	if err := send(conn, c.token, "logs", params); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("sending logs: %w", err)
+	}
+
+	dec := protocol.NewDecoder(conn)
+	next := func() (protocol.LogsResult, error) {
+		data, err := dec.Next()
+		if err != nil {
+			return protocol.LogsResult{}, err
+		}
+		var resp protocol.Response
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return protocol.LogsResult{}, err
+		}
+		if !resp.OK {
+			return protocol.LogsResult{}, fmt.Errorf("%s", resp.Error)
+		}
+		var res protocol.LogsResult
+		err = json.Unmarshal(resp.Result, &res)
+		return res, err
+	}
+	first, err := next()
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	ch := make(chan protocol.LogsResult, 16)
+	ch <- first
+	go func() {
+		defer close(ch)
+		defer conn.Close()
+		for {
+			res, err := next()
+			if err != nil {
+				return
+			}
+			ch <- res
+		}
+	}()
+
+	cancel := func() { conn.Close() }
+	return ch, cancel, nil
+}
+
+// Attachment is a terminal session with a process run with TTY. Read
+// returns the process's output and Write sends it input; Close detaches,
+// leaving the process running.
+type Attachment struct {
+	conn net.Conn
+	dec  *protocol.Decoder
+	buf  []byte
+
+	// Detail is why the daemon ended the session, once Read has returned
+	// io.EOF.
+	Detail string
+}
+
+// Attach opens a terminal session with a process run with TTY.
+func (c *Client) Attach(params protocol.AttachParams) (*Attachment, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to daemon at %s: %w", c.sockPath, err)
+	}
+	if err := send(conn, c.token, "attach", params); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("sending attach: %w", err)
+	}
+
+	dec := protocol.NewDecoder(conn)
+	data, err := dec.Next()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	var resp protocol.Response
+	if err := json.Unmarshal(data, &resp); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	if !resp.OK {
+		conn.Close()
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+	return &Attachment{conn: conn, dec: dec}, nil
+}
+
+func (a *Attachment) Read(p []byte) (int, error) {
+	for len(a.buf) == 0 {
+		data, err := a.dec.Next()
+		if err != nil {
+			return 0, err
+		}
+		var out protocol.AttachOutput
+		if err := json.Unmarshal(data, &out); err != nil {
+			return 0, err
+		}
+		if out.Closed {
+			a.Detail = out.Detail
+			return 0, io.EOF
+		}
+		a.buf = out.Data
+	}
+	n := copy(p, a.buf)
+	a.buf = a.buf[n:]
+	return n, nil
+}
+
+func (a *Attachment) Write(p []byte) (int, error) {
+	if err := protocol.WriteMessage(a.conn, protocol.AttachInput{Data: p}, false); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Resize tells the process its terminal is now rows by cols.
+func (a *Attachment) Resize(rows, cols int) error {
+	return protocol.WriteMessage(a.conn, protocol.AttachInput{Rows: rows, Cols: cols}, false)
+}
+
+func (a *Attachment) Close() error {
+	return a.conn.Close()
+}
+
 // Command holds a persistent connection for sending multiple requests.
 type Command struct {
-	conn    net.Conn
-	scanner *bufio.Scanner
+	conn  net.Conn
+	dec   *protocol.Decoder
+	token string
+
+	// After Notify, a reader goroutine owns dec and splits what the daemon
+	// sends into replies and notifications.
+	replies chan protocol.Response
+	notes   chan protocol.Event
 }
 
 func (c *Client) OpenCommand() (*Command, error) {
-	conn, err := net.Dial("unix", c.sockPath)
+	conn, err := c.dial()
 	if err != nil {
 		return nil, fmt.Errorf("cannot connect to daemon at %s: %w", c.sockPath, err)
 	}
-	scanner := bufio.NewScanner(conn)
-	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
-	return &Command{conn: conn, scanner: scanner}, nil
+	return &Command{conn: conn, dec: protocol.NewDecoder(conn), token: c.token}, nil
 }
 
 func (cmd *Command) Call(method string, params interface{}) (protocol.Response, error) {
-	var rawParams json.RawMessage
-	if params != nil {
-		var err error
-		rawParams, err = json.Marshal(params)
-		if err != nil {
-			return protocol.Response{}, err
-		}
-	}
-	req := protocol.Request{Method: method, Params: rawParams}
-	data, _ := json.Marshal(req)
-	data = append(data, '\n')
-	if _, err := cmd.conn.Write(data); err != nil {
+	if err := send(cmd.conn, cmd.token, method, params); err != nil {
 		return protocol.Response{}, err
 	}
-	if !cmd.scanner.Scan() {
+	if cmd.replies != nil {
+		resp, ok := <-cmd.replies
+		if !ok {
+			return protocol.Response{}, fmt.Errorf("connection closed")
+		}
+		return tagError(resp), nil
+	}
+	data, err := cmd.dec.Next()
+	if err != nil {
 		return protocol.Response{}, fmt.Errorf("connection closed")
 	}
 	var resp protocol.Response
-	json.Unmarshal(cmd.scanner.Bytes(), &resp)
-	return resp, nil
+	json.Unmarshal(data, &resp)
+	return tagError(resp), nil
+}
+
+// Notify asks the daemon to push events of the given types (default
+// protocol.NotifyEventTypes) on this connection and returns the channel
+// they arrive on. Calls keep working as before. The channel closes when
+// the connection does; notifications that aren't read in time are dropped.
+func (cmd *Command) Notify(namespace string, types ...string) (<-chan protocol.Event, error) {
+	if cmd.notes != nil {
+		return cmd.notes, nil
+	}
+	resp, err := cmd.Call("notify", protocol.NotifyParams{Types: types, Namespace: namespace})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.OK {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	cmd.replies = make(chan protocol.Response)
+	cmd.notes = make(chan protocol.Event, 16)
+	go func() {
+		defer close(cmd.replies)
+		defer close(cmd.notes)
+		for {
+			data, err := cmd.dec.Next()
+			if err != nil {
+				return
+			}
+			var resp protocol.Response
+			if err := json.Unmarshal(data, &resp); err != nil {
+				continue
+			}
+			if resp.Notification == nil {
+				cmd.replies <- resp
+				continue
+			}
+			select {
+			case cmd.notes <- *resp.Notification:
+			default:
+			}
+		}
+	}()
+	return cmd.notes, nil
 }
 
 func (cmd *Command) Close() {
@@ -0,0 +1,155 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gpusched/internal/gpu"
+	"gpusched/internal/procfs"
+	"gpusched/internal/protocol"
+)
+
+// Adopt takes over CUDA processes gpusched didn't start, so an existing
+// busy server can be brought under management without restarting its
+// jobs. Adopted processes are left running when the daemon exits, since
+// it never owned them.
+func (d *Daemon) Adopt(params protocol.AdoptParams) (protocol.AdoptResult, error) {
+	if !params.All && len(params.PIDs) == 0 {
+		return protocol.AdoptResult{}, fmt.Errorf("no processes to adopt: pass PIDs or all")
+	}
+	apps, err := gpu.ComputeApps()
+	if err != nil {
+		return protocol.AdoptResult{}, err
+	}
+	gpus, err := gpu.ComputeAppGPUs()
+	if err != nil {
+		return protocol.AdoptResult{}, err
+	}
+	return d.adopt(params, apps, gpus), nil
+}
+
+// adopt registers the processes params selects from apps (GPU memory by
+// PID) and gpus (GPU index by PID).
+func (d *Daemon) adopt(params protocol.AdoptParams, apps map[int]int64, gpus map[int]int) protocol.AdoptResult {
+	pids := params.PIDs
+	if params.All {
+		pids = pids[:0:0]
+		for pid := range apps {
+			pids = append(pids, pid)
+		}
+		slices.Sort(pids)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	res := protocol.AdoptResult{Adopted: []protocol.ProcessInfo{}}
+	skip := func(pid int, reason string) {
+		res.Skipped = append(res.Skipped, protocol.AdoptSkip{PID: pid, Reason: reason})
+	}
+	known := d.knownPIDs()
+	for _, pid := range pids {
+		memMB, isApp := apps[pid]
+		switch {
+		case known[pid]:
+			skip(pid, "already managed")
+			continue
+		case pid == os.Getpid():
+			skip(pid, "the daemon itself")
+			continue
+		case !isApp:
+			skip(pid, "not a CUDA compute process")
+			continue
+		}
+		if env, err := procfs.Environ(pid); err == nil && env["GPUSCHED_MANAGED"] == "1" {
+			skip(pid, "started by gpusched; a restarted daemon recovers it")
+			continue
+		}
+		argv, err := procfs.Cmdline(pid)
+		if err != nil || len(argv) == 0 || argv[0] == "" {
+			skip(pid, "exited")
+			continue
+		}
+		if filepath.Base(argv[0]) == gpu.MPSServerName {
+			skip(pid, "an MPS server, not a job")
+			continue
+		}
+		uid, err := procfs.UID(pid)
+		if err != nil {
+			skip(pid, "exited")
+			continue
+		}
+
+		name := protocol.QualifiedName(ownerNamespace(uid, params.OwnerMap), commandName(argv))
+		if _, exists := d.procs[name]; exists {
+			name = fmt.Sprintf("%s-%d", name, pid)
+		}
+		p := d.adoptPID(pid, name, gpus[pid], argv, memMB, "", protocol.ShutdownLeave)
+		p.params.UID = &uid
+
+		d.emit(protocol.Event{Type: "adopt", Process: name, Detail: fmt.Sprintf("pid=%d gpu=%d %s", pid, p.GPU, p.State)})
+		d.log.Printf("ADOPT %s pid=%d gpu=%d %s", name, pid, p.GPU, p.State)
+		res.Adopted = append(res.Adopted, d.processInfo(p))
+	}
+	return res
+}
+
+// ownerNamespace is the namespace for a process run by uid: ownerMap's
+// entry for the user name or numeric uid, else the user name.
+func ownerNamespace(uid int, ownerMap map[string]string) string {
+	id := strconv.Itoa(uid)
+	owner := id
+	if u, err := user.LookupId(id); err == nil {
+		owner = u.Username
+	}
+	if ns, ok := ownerMap[owner]; ok {
+		return ns
+	}
+	if ns, ok := ownerMap[id]; ok {
+		return ns
+	}
+	return owner
+}
+
+// interpreters are binaries whose process is better named after the
+// script they run.
+var interpreters = []string{"python", "python3", "ipython", "torchrun", "accelerate", "deepspeed", "bash", "sh"}
+
+// commandName names an adopted process after what it runs:
+// "python train.py" is "train", "python -m vllm.entrypoints.api_server" is
+// "api_server", and anything else is its binary's name.
+func commandName(argv []string) string {
+	name := filepath.Base(argv[0])
+	if slices.Contains(interpreters, strings.TrimRight(name, "0123456789.")) {
+	args:
+		for i := 1; i < len(argv); i++ {
+			switch a := argv[i]; {
+			case a == "-c":
+				break args
+			case a == "-m" && i+1 < len(argv):
+				mod := argv[i+1]
+				name = mod[strings.LastIndexByte(mod, '.')+1:]
+				break args
+			case strings.HasSuffix(a, ".py") || strings.HasSuffix(a, ".sh"):
+				name = strings.TrimSuffix(filepath.Base(a), filepath.Ext(a))
+				break args
+			}
+		}
+	}
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '-'
+	}, name)
+	if name == "" {
+		return "proc"
+	}
+	return name
+}
@@ -0,0 +1,238 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"gpusched/internal/protocol"
+)
+
+// Roles a caller may have (see Config.Roles).
+const (
+	RoleAdmin    = "admin"    // anything, on any process
+	RoleUser     = "user"     // run, and act on their own processes
+	RoleReadOnly = "readonly" // status, logs, and other reads
+)
+
+// ValidRole reports whether role is one of the roles above.
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleUser, RoleReadOnly:
+		return true
+	}
+	return false
+}
+
+// readMethods only look at the daemon's state.
+var readMethods = map[string]bool{
+	"status":        true,
+	"describe":      true,
+	"logs":          true,
+	"queue":         true,
+	"plan":          true,
+	"usage":         true,
+	"ops_history":   true,
+	"events":        true,
+	"subscribe":     true,
+	"status_stream": true,
+	"notify":        true,
+}
+
+// checkReadOnly refuses method unless it only reads, for a read-only
+// daemon or listener.
+func checkReadOnly(method string) error {
+	if readMethods[method] {
+		return nil
+	}
+	return errorf(protocol.CodePermissionDenied, "permission denied: %s is refused here, which is read-only", method)
+}
+
+// ownedMethods act on the process (or queued run) named in their params,
+// which a user may do to their own.
+var ownedMethods = map[string]bool{
+	"freeze":         true,
+	"thaw":           true,
+	"park":           true,
+	"unpark":         true,
+	"recover":        true,
+	"kill":           true,
+	"migrate":        true,
+	"annotate":       true,
+	"attach":         true,
+	"app_checkpoint": true,
+	"queue_move":     true,
+	"queue_remove":   true,
+}
+
+// groupMethods act on every process of the group named in their params,
+// which a user may do when they own them all.
+var groupMethods = map[string]bool{
+	"group_freeze":  true,
+	"group_thaw":    true,
+	"group_migrate": true,
+	"group_kill":    true,
+}
+
+// roleOf returns the role of the caller uid (nil if unknown). Without
+// configured roles everyone is an admin; root and the daemon's own user
+// always are. Unlisted users are users, and unknown callers read-only,
+// unless AnyUser gives a role.
+func (d *Daemon) roleOf(uid *int) string {
+	if len(d.cfg.Roles) == 0 {
+		return RoleAdmin
+	}
+	if uid != nil {
+		if *uid == 0 || *uid == os.Getuid() {
+			return RoleAdmin
+		}
+		if role, ok := d.cfg.Roles[*uid]; ok {
+			return role
+		}
+	}
+	if role, ok := d.cfg.Roles[AnyUser]; ok {
+		return role
+	}
+	if uid == nil {
+		return RoleReadOnly
+	}
+	return RoleUser
+}
+
+// runAs decides who a run for the caller uid runs as: the daemon's user
+// for admins, else the caller, who must be known.
+func (d *Daemon) runAs(uid *int) (*int, error) {
+	if d.roleOf(uid) == RoleAdmin {
+		return nil, nil
+	}
+	if uid == nil {
+		return nil, errorf(protocol.CodePermissionDenied, "permission denied: %s can't run processes", caller(uid))
+	}
+	return uid, nil
+}
+
+// credential is what a process started as uid runs with: the user's
+// primary and supplementary groups.
+func credential(uid int) (*syscall.Credential, error) {
+	u, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		return nil, fmt.Errorf("running as uid %d: %w", uid, err)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return nil, fmt.Errorf("running as %s: bad gid %q", u.Username, u.Gid)
+	}
+	cred := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	ids, _ := u.GroupIds()
+	for _, id := range ids {
+		if g, err := strconv.Atoi(id); err == nil {
+			cred.Groups = append(cred.Groups, uint32(g))
+		}
+	}
+	return cred, nil
+}
+
+// authorize refuses req unless the caller uid's role allows it.
+func (d *Daemon) authorize(req protocol.Request, uid *int) error {
+	role := d.roleOf(uid)
+	switch {
+	case role == RoleAdmin, readMethods[req.Method]:
+		return nil
+	case role == RoleReadOnly:
+		return errorf(protocol.CodePermissionDenied, "permission denied: %s is read-only", caller(uid))
+	case req.Method == "run":
+		var p protocol.RunParams
+		json.Unmarshal(req.Params, &p)
+		return checkUserRun(p)
+	case groupMethods[req.Method]:
+		var target protocol.GroupParams
+		json.Unmarshal(req.Params, &target)
+		for _, m := range d.groupMembers(target.Namespace, target.Group) {
+			if owner := d.ownerOf(m.name); uid == nil || owner == nil || *owner != *uid {
+				return errorf(protocol.CodePermissionDenied, "permission denied: %q in group %q is not owned by %s", m.name, target.Group, caller(uid))
+			}
+		}
+		return nil
+	case !ownedMethods[req.Method]:
+		return errorf(protocol.CodePermissionDenied, "permission denied: %s is for admins", req.Method)
+	}
+
+	var target protocol.NameParams
+	json.Unmarshal(req.Params, &target)
+	name := protocol.QualifiedName(target.Namespace, target.Name)
+	if owner := d.ownerOf(name); uid == nil || owner == nil || *owner != *uid {
+		return errorf(protocol.CodePermissionDenied, "permission denied: %q is not owned by %s", name, caller(uid))
+	}
+	if req.Method == "queue_move" {
+		// Only admins may jump the queue; a user may let others go first.
+		var move protocol.QueueMoveParams
+		json.Unmarshal(req.Params, &move)
+		d.mu.RLock()
+		i := d.queued(name)
+		d.mu.RUnlock()
+		if i >= 0 && move.Position < i+1 {
+			return errorf(protocol.CodePermissionDenied, "permission denied: only admins may move %q ahead in the queue", name)
+		}
+	}
+	return nil
+}
+
+// checkUserRun refuses the parts of a run only admins may set: extra
+// cuda-checkpoint arguments and timeouts, which the daemon passes as root,
+// an env inherit list, which would replace the daemon's, exec probes and
+// drain hooks, which the daemon runs as itself, and input and output
+// files and wake paths, which it opens, creates, and watches as itself.
+func checkUserRun(p protocol.RunParams) error {
+	if len(p.CheckpointArgs) > 0 || len(p.CheckpointTimeouts) > 0 {
+		return errorf(protocol.CodePermissionDenied, "permission denied: checkpoint_args and checkpoint_timeouts_ms are for admins")
+	}
+	if p.Env != nil && len(p.Env.Inherit) > 0 {
+		return errorf(protocol.CodePermissionDenied, "permission denied: env inherit is for admins")
+	}
+	if (p.Readiness != nil && len(p.Readiness.Exec) > 0) || (p.Liveness != nil && len(p.Liveness.Exec) > 0) {
+		return errorf(protocol.CodePermissionDenied, "permission denied: exec probes are for admins")
+	}
+	if p.DrainHooks != nil && (len(p.DrainHooks.Stop) > 0 || len(p.DrainHooks.Resume) > 0) {
+		return errorf(protocol.CodePermissionDenied, "permission denied: drain hooks are for admins")
+	}
+	if p.Input != "" || p.Output != "" {
+		return errorf(protocol.CodePermissionDenied, "permission denied: input and output files are for admins")
+	}
+	if p.Wake != nil && (len(p.Wake.Paths) > 0 || len(p.Wake.FIFOs) > 0) {
+		return errorf(protocol.CodePermissionDenied, "permission denied: wake paths and FIFOs are for admins")
+	}
+	return nil
+}
+
+// ownerOf returns the UID that started name, whether running or queued,
+// or nil if no one is known to have.
+func (d *Daemon) ownerOf(name string) *int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if p, ok := d.procs[name]; ok {
+		return p.params.UID
+	}
+	if i := d.queued(name); i >= 0 {
+		return d.queue[i].params.UID
+	}
+	return nil
+}
+
+// callerName is uid's login name, or empty if unknown.
+func callerName(uid *int) string {
+	if uid == nil {
+		return ""
+	}
+	return userName(*uid)
+}
+
+// caller names uid for error messages.
+func caller(uid *int) string {
+	if uid == nil {
+		return "an unidentified caller"
+	}
+	return userName(*uid)
+}
@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"os"
+	"time"
+
+	"gpusched/internal/discovery"
+	"gpusched/internal/protocol"
+)
+
+// Advertise broadcasts a beacon describing this daemon to addr every
+// interval until Shutdown, so "gpusched nodes discover" can find it.
+// socket is the path clients on this host connect to.
+func (d *Daemon) Advertise(addr string, interval time.Duration, socket string) {
+	if interval <= 0 {
+		interval = discovery.DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	d.log.Printf("advertising on %s every %s", addr, interval)
+	var lastErr string
+	for {
+		err := discovery.Send(addr, d.beacon(socket))
+		switch {
+		case err != nil && err.Error() != lastErr:
+			d.log.Printf("WARN: beacon to %s: %v", addr, err)
+			lastErr = err.Error()
+		case err == nil && lastErr != "":
+			d.log.Printf("beacon to %s: recovered", addr)
+			lastErr = ""
+		}
+
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Daemon) beacon(socket string) protocol.Beacon {
+	host, _ := os.Hostname()
+	gpus, _, _ := d.cachedGPUs()
+
+	d.mu.RLock()
+	live := 0
+	for _, p := range d.procs {
+		if p.State != protocol.StateDead {
+			live++
+		}
+	}
+	d.mu.RUnlock()
+
+	return protocol.Beacon{
+		Host:      host,
+		Socket:    socket,
+		Time:      time.Now(),
+		GPUs:      gpus,
+		Processes: live,
+	}
+}
@@ -0,0 +1,148 @@
+package daemon
+
+import (
+	"fmt"
+
+	"gpusched/internal/bytesize"
+	"gpusched/internal/gpu"
+	"gpusched/internal/protocol"
+)
+
+// AllGPUs keys the GPUReserveMB and GPUOvercommit entry that applies to
+// GPUs without one of their own.
+const AllGPUs = -1
+
+// gpuReserveMB is the memory kept free on GPU idx, e.g. for a display
+// server.
+func (d *Daemon) gpuReserveMB(idx int) int64 {
+	if mb, ok := d.cfg.GPUReserveMB[idx]; ok {
+		return mb
+	}
+	return d.cfg.GPUReserveMB[AllGPUs]
+}
+
+// gpuOvercommit is how many times GPU idx's usable memory may be committed
+// to active and frozen processes together; 0 means no limit.
+func (d *Daemon) gpuOvercommit(idx int) float64 {
+	if r, ok := d.cfg.GPUOvercommit[idx]; ok {
+		return r
+	}
+	return d.cfg.GPUOvercommit[AllGPUs]
+}
+
+// budgetMB is the overcommit limit on memory assigned to g, or 0 if there
+// is none.
+func (d *Daemon) budgetMB(g protocol.GPUInfo) int64 {
+	ratio := d.gpuOvercommit(g.Index)
+	if ratio <= 0 {
+		return 0
+	}
+	return int64(ratio * float64(g.MemTotal-d.gpuReserveMB(g.Index)))
+}
+
+// committedMB sums the memory of live processes assigned to GPU idx,
+// frozen ones included since they come back on thaw. Caller must hold
+// d.mu.
+func (d *Daemon) committedMB(idx int, self *Proc) int64 {
+	var mb int64
+	for _, p := range d.procs {
+		if p != self && p.GPU == idx && p.State != protocol.StateDead {
+			mb += p.MemMB
+			// Older GPUs report an MPS client's memory against its MPS
+			// server, so until some is seen the client counts what it
+			// asked for.
+			if p.params.MPS && p.MemMB == 0 {
+				mb += p.params.MemMB
+			}
+		}
+	}
+	return mb
+}
+
+// withReserve returns gpus with each GPU's reserve taken out of its free
+// memory, which is how placement and plans should see them.
+func (d *Daemon) withReserve(gpus []protocol.GPUInfo) []protocol.GPUInfo {
+	out := make([]protocol.GPUInfo, len(gpus))
+	for i, g := range gpus {
+		g.MemFree = max(g.MemFree-d.gpuReserveMB(g.Index), 0)
+		out[i] = g
+	}
+	return out
+}
+
+// withinBudget drops GPUs where adding needMB for self would commit more
+// than the overcommit budget. Caller must hold d.mu.
+func (d *Daemon) withinBudget(gpus []protocol.GPUInfo, needMB int64, self *Proc) []protocol.GPUInfo {
+	var ok []protocol.GPUInfo
+	for _, g := range gpus {
+		if budget := d.budgetMB(g); budget == 0 || d.committedMB(g.Index, self)+needMB <= budget {
+			ok = append(ok, g)
+		}
+	}
+	return ok
+}
+
+// checkThawReserve refuses a thaw that would eat into its GPU's reserve.
+// It queries nvidia-smi afresh, since a freeze just before commonly made
+// the room, and only when the GPU has a reserve. Caller must hold d.mu.
+func (d *Daemon) checkThawReserve(p *Proc) error {
+	reserve := d.gpuReserveMB(p.GPU)
+	if reserve <= 0 || p.MemMB <= 0 {
+		return nil
+	}
+	gpus, err := gpu.QueryGPUs()
+	if err != nil {
+		return nil
+	}
+	return d.checkReserve(gpus, p.GPU, p.MemMB, p.Name)
+}
+
+// checkReserve refuses needMB for name on GPU idx if it would eat into the
+// GPU's reserve. Caller must hold d.mu.
+func (d *Daemon) checkReserve(gpus []protocol.GPUInfo, idx int, needMB int64, name string) error {
+	reserve := d.gpuReserveMB(idx)
+	if reserve <= 0 || needMB <= 0 {
+		return nil
+	}
+	for _, g := range gpus {
+		if g.Index == idx && g.MemFree-reserve < needMB {
+			return fmt.Errorf("GPU %d has %s free, %s of it reserved; %s needs %s",
+				g.Index, bytesize.FormatMB(g.MemFree), bytesize.FormatMB(reserve), name, bytesize.FormatMB(needMB))
+		}
+	}
+	return nil
+}
+
+// checkGPUBudget refuses a run or migrate that puts needMB for self (nil
+// for a new run) on a GPU chosen by the caller, rather than by placement,
+// when placement wouldn't have: it would eat into the GPU's reserve or go
+// over its overcommit budget. nvidia-smi is only queried when the GPU has
+// either. Caller must hold d.mu.
+func (d *Daemon) checkGPUBudget(idx int, needMB int64, self *Proc, name string) error {
+	if d.gpuReserveMB(idx) <= 0 && d.gpuOvercommit(idx) <= 0 {
+		return nil
+	}
+	gpus, err := gpu.QueryGPUs()
+	if err != nil {
+		return nil
+	}
+	return d.checkBudgetOn(gpus, idx, needMB, self, name)
+}
+
+// checkBudgetOn is checkGPUBudget with GPUs as gpus reports them. Caller
+// must hold d.mu.
+func (d *Daemon) checkBudgetOn(gpus []protocol.GPUInfo, idx int, needMB int64, self *Proc, name string) error {
+	if err := d.checkReserve(gpus, idx, needMB, name); err != nil {
+		return err
+	}
+	for _, g := range gpus {
+		if g.Index != idx {
+			continue
+		}
+		if budget, committed := d.budgetMB(g), d.committedMB(idx, self); budget > 0 && committed+needMB > budget {
+			return fmt.Errorf("GPU %d is at its memory overcommit budget: %s of %s committed; %s needs %s",
+				idx, bytesize.FormatMB(committed), bytesize.FormatMB(budget), name, bytesize.FormatMB(needMB))
+		}
+	}
+	return nil
+}
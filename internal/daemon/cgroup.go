@@ -0,0 +1,184 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gpusched/internal/procfs"
+	"gpusched/internal/protocol"
+)
+
+// cpuMaxPeriod is the cpu.max period, in microseconds, that CPU limits are
+// expressed against.
+const cpuMaxPeriod = 100000
+
+// initCgroups prepares root for per-process cgroups. Its parent must be in
+// a cgroup v2 hierarchy; the cpu and memory controllers are enabled down
+// to root's children. It returns root, or "" to run processes without
+// cgroups.
+func (d *Daemon) initCgroups(root string) string {
+	if root == "" {
+		return ""
+	}
+	parent := filepath.Dir(root)
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.controllers")); err != nil {
+		d.log.Printf("WARN: %s is not a cgroup v2 hierarchy; running processes without cgroups", parent)
+		return ""
+	}
+	err := enableControllers(parent)
+	if err == nil {
+		err = os.MkdirAll(root, 0o755)
+	}
+	if err == nil {
+		err = enableControllers(root)
+	}
+	if err != nil {
+		d.log.Printf("WARN: setting up cgroups under %s: %v; running processes without cgroups", root, err)
+		return ""
+	}
+	d.log.Printf("cgroups: %s", root)
+	return root
+}
+
+// enableControllers lets dir's children use the cpu and memory controllers.
+func enableControllers(dir string) error {
+	return os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+cpu +memory"), 0o644)
+}
+
+// cgroupPath is where name's cgroup goes: a scope under the cgroup root,
+// inside a slice for its namespace if it has one. The suffixes keep a
+// namespace and a process of the same name apart. It is "" if the daemon
+// runs processes without cgroups.
+func (d *Daemon) cgroupPath(name string) string {
+	if d.cgroups == "" {
+		return ""
+	}
+	namespace, base := protocol.SplitQualifiedName(name)
+	if namespace == "" {
+		return filepath.Join(d.cgroups, base+".scope")
+	}
+	return filepath.Join(d.cgroups, namespace+".slice", base+".scope")
+}
+
+// makeCgroup creates name's cgroup with the CPU and host memory limits of
+// params, and returns it opened for the child to start in. It returns nil
+// if the daemon runs processes without cgroups, which is an error if
+// params asks for limits.
+func (d *Daemon) makeCgroup(name string, params protocol.RunParams) (*os.File, error) {
+	dir := d.cgroupPath(name)
+	if dir == "" {
+		if params.CPUs > 0 || params.HostMemMB > 0 {
+			return nil, fmt.Errorf("cpu and host memory limits need cgroup v2 (daemon --cgroup-root)")
+		}
+		return nil, nil
+	}
+	if parent := filepath.Dir(dir); parent != d.cgroups {
+		if err := os.MkdirAll(parent, 0o755); err != nil {
+			return nil, fmt.Errorf("creating cgroup: %w", err)
+		}
+		if err := enableControllers(parent); err != nil {
+			return nil, fmt.Errorf("creating cgroup: %w", err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cgroup: %w", err)
+	}
+	// A restart reuses the cgroup, so limits are always written.
+	if err := writeCgroup(dir, "cpu.max", cpuMax(params.CPUs)); err != nil {
+		return nil, err
+	}
+	mem := "max"
+	if params.HostMemMB > 0 {
+		mem = strconv.FormatInt(params.HostMemMB<<20, 10)
+	}
+	if err := writeCgroup(dir, "memory.max", mem); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, fmt.Errorf("opening cgroup: %w", err)
+	}
+	return f, nil
+}
+
+func writeCgroup(dir, file, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0o644); err != nil {
+		return fmt.Errorf("setting %s: %w", file, err)
+	}
+	return nil
+}
+
+// cpuMax is the cpu.max value allowing cpus CPUs, or no limit for 0.
+func cpuMax(cpus float64) string {
+	if cpus <= 0 {
+		return fmt.Sprintf("max %d", cpuMaxPeriod)
+	}
+	return fmt.Sprintf("%d %d", int64(cpus*cpuMaxPeriod), cpuMaxPeriod)
+}
+
+// removeCgroup removes name's cgroup once nothing runs in it. It fails
+// quietly while something does, such as a restarted instance or a child
+// the process left behind.
+func (d *Daemon) removeCgroup(name string) {
+	if dir := d.cgroupPath(name); dir != "" {
+		os.Remove(dir)
+	}
+}
+
+// existingCgroup is name's cgroup if pid, a process started by an earlier
+// daemon, runs in it, else "".
+func (d *Daemon) existingCgroup(name string, pid int) string {
+	dir := d.cgroupPath(name)
+	if dir == "" {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return ""
+	}
+	if slices.Contains(strings.Fields(string(data)), strconv.Itoa(pid)) {
+		return dir
+	}
+	return ""
+}
+
+// cgroupMemMB is the host memory charged to the cgroup at dir, or 0 if it
+// can't be read.
+func cgroupMemMB(dir string) int64 {
+	data, err := os.ReadFile(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return 0
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n >> 20
+}
+
+// hostMemMB is the host memory p holds: what its cgroup is charged, which
+// covers its children, or else its RSS.
+func hostMemMB(p *Proc) int64 {
+	if p.cgroup != "" {
+		if mb := cgroupMemMB(p.cgroup); mb > 0 {
+			return mb
+		}
+	}
+	return procfs.RSSMB(p.PID)
+}
+
+// snapshotMB is the host RAM p holds while frozen. In a cgroup that is
+// what the cgroup is charged, the parked GPU state included; otherwise it
+// is taken to be the GPU memory p held.
+func snapshotMB(p *Proc) int64 {
+	if p.cgroup != "" {
+		if mb := cgroupMemMB(p.cgroup); mb > 0 {
+			return mb
+		}
+	}
+	return p.MemMB
+}
@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"gpusched/internal/client"
+	"gpusched/internal/protocol"
+)
+
+// peerTimeout bounds how long a cluster status waits for each peer.
+const peerTimeout = 5 * time.Second
+
+// relay runs req on the peer it names and returns the peer's response.
+// The node is cleared on the way out, so a peer that knows this daemon
+// under another name can't bounce the request back.
+func (d *Daemon) relay(req protocol.Request) protocol.Response {
+	addr, ok := d.cfg.Peers[req.Node]
+	if !ok {
+		return protocol.ErrResponse(fmt.Sprintf("unknown node %q (peers: %s)", req.Node, d.peerNames()))
+	}
+	node := req.Node
+	req.Node = ""
+	resp, err := d.peerClient(addr).Do(req)
+	if err != nil {
+		return protocol.ErrResponse(fmt.Sprintf("node %s: %v", node, err))
+	}
+	return resp
+}
+
+// peerClient connects to the peer at addr.
+func (d *Daemon) peerClient(addr string) *client.Client {
+	return client.New(addr).WithToken(d.cfg.PeerToken).WithTLS(d.cfg.PeerTLS)
+}
+
+func (d *Daemon) peerNames() string {
+	if len(d.cfg.Peers) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(d.cfg.Peers))
+	for name := range d.cfg.Peers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprint(names)
+}
+
+// ClusterStatus is Status with the GPUs and processes of every peer
+// appended, all tagged with their node. Peers are asked in parallel; one
+// that errors or takes longer than peerTimeout is reported in Nodes and
+// otherwise left out.
+func (d *Daemon) ClusterStatus(p protocol.StatusParams) protocol.StatusResult {
+	res := d.Status(p)
+	tagNode(&res, d.cfg.NodeName)
+	res.Nodes = []protocol.NodeStatus{{Name: d.cfg.NodeName}}
+
+	type peerResult struct {
+		status protocol.StatusResult
+		err    error
+	}
+	names := make([]string, 0, len(d.cfg.Peers))
+	for name := range d.cfg.Peers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]chan peerResult, len(names))
+	for i, name := range names {
+		results[i] = make(chan peerResult, 1)
+		go func(addr string, out chan<- peerResult) {
+			s, err := d.peerStatus(addr, p.Namespace)
+			out <- peerResult{s, err}
+		}(d.cfg.Peers[name], results[i])
+	}
+
+	deadline := time.After(peerTimeout)
+	for i, name := range names {
+		node := protocol.NodeStatus{Name: name, Addr: d.cfg.Peers[name]}
+		select {
+		case r := <-results[i]:
+			if r.err != nil {
+				node.Error = r.err.Error()
+				break
+			}
+			tagNode(&r.status, name)
+			res.GPUs = append(res.GPUs, r.status.GPUs...)
+			res.Processes = append(res.Processes, r.status.Processes...)
+		case <-deadline:
+			node.Error = "timed out"
+		}
+		res.Nodes = append(res.Nodes, node)
+	}
+	return res
+}
+
+func (d *Daemon) peerStatus(addr, namespace string) (protocol.StatusResult, error) {
+	resp, err := d.peerClient(addr).Call("status", protocol.StatusParams{Namespace: namespace})
+	if err != nil {
+		return protocol.StatusResult{}, err
+	}
+	if !resp.OK {
+		return protocol.StatusResult{}, fmt.Errorf("%s", resp.Error)
+	}
+	var s protocol.StatusResult
+	if err := json.Unmarshal(resp.Result, &s); err != nil {
+		return protocol.StatusResult{}, fmt.Errorf("decoding status: %w", err)
+	}
+	return s, nil
+}
+
+// tagNode stamps every GPU and process in s with node. Peers are tagged
+// with the name this daemon knows them by, not their own NodeName.
+func tagNode(s *protocol.StatusResult, node string) {
+	for i := range s.GPUs {
+		s.GPUs[i].Node = node
+	}
+	for i := range s.Processes {
+		s.Processes[i].Node = node
+	}
+}
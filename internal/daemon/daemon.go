@@ -2,8 +2,13 @@
 package daemon
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -14,8 +19,12 @@ import (
 	"syscall"
 	"time"
 
+	"gpusched/internal/bytesize"
 	"gpusched/internal/checkpoint"
 	"gpusched/internal/gpu"
+	"gpusched/internal/placement"
+	"gpusched/internal/probe"
+	"gpusched/internal/procfs"
 	"gpusched/internal/protocol"
 )
 
@@ -26,14 +35,260 @@ type Proc struct {
 	GPU     int
 	MemMB   int64
 	Started time.Time
+	Argv    []string
+	Shell   bool
+	// command is Argv as reported, with secrets redacted.
+	command string
 	Cmd     *exec.Cmd
 	LogPath string
 	logFile *os.File
+	// tty is the process's terminal when it runs with TTY; its pump owns
+	// the log file, so logFile is nil.
+	tty *terminal
+	// cgroup is the process's own cgroup directory, if it has one.
+	cgroup string
+
+	// cuda carries per-process cuda-checkpoint overrides; nil means the
+	// daemon default.
+	cuda *checkpoint.CUDA
+
+	Readiness *protocol.Probe
+	Restarts  int
+	Notes     []protocol.Note
+	// AppCheckpoint is the last checkpoint the process reported saving.
+	AppCheckpoint *protocol.AppCheckpoint
+
+	// restartStreak counts relaunches under the restart policy since a run
+	// last lasted restartResetAfter, for the backoff; nextRestart is when
+	// the pending one is due.
+	restartStreak int
+	nextRestart   time.Time
+
+	// Freezes counts every freeze; frozenTotal is the suspended time of
+	// completed freeze/thaw cycles and frozenAt the start of the current
+	// one. autoFreezes holds recent daemon-initiated freezes for the
+	// per-hour cap.
+	Freezes     int
+	frozenTotal time.Duration
+	frozenAt    time.Time
+	autoFreezes []time.Time
+	// parked means the process was frozen without being stopped, and is
+	// still running on the CPU.
+	parked bool
+	// thaws are the most recent thaw times, for per-process estimates.
+	thaws []thawSample
+
+	// params are the original run parameters, kept for restarts.
+	params protocol.RunParams
+
+	liveness protocol.ProbeStatus
+
+	// exited is closed once the process has been reaped.
+	exited chan struct{}
+
+	lastGPUSeen   time.Time
+	lastBusy      time.Time
+	served        float64
+	gpuLeakWarned bool
+	zeroMemWarned bool
+	// overGPUMemLimit is set once the process has been acted on for going
+	// over its GPU memory limit, until it is back under.
+	overGPUMemLimit bool
+	// deadlineHit is set once the process has been acted on for reaching
+	// its max runtime; exitedAt is when it exited, for its TTL.
+	deadlineHit bool
+	exitedAt    time.Time
+
+	// workers are the torchrun workers stopped by the current freeze.
+	workers []int
+
+	// lastChange is the most recent freeze, thaw, or migration.
+	lastChange *protocol.Transition
+
+	// activeSince starts the open usage interval; zero while not active.
+	// activeTotal is the time spent active in earlier intervals.
+	activeSince time.Time
+	activeTotal time.Duration
+
+	// oomAdjOrig is the oom_score_adj to restore on thaw, if the frozen
+	// OOM policy changed it.
+	oomAdjOrig *int
+
+	// drained is set while frozen by a draining freeze, until the thaw
+	// runs the Resume hook.
+	drained bool
 }
 
 type Config struct {
 	RAMBudgetMB int64
-	LogDir      string
+	// RAMMarginMB and RAMMarginPct (of total host RAM) are the free host
+	// RAM a freeze must leave; the larger applies. Both zero means
+	// defaultRAMMarginMB.
+	RAMMarginMB  int64
+	RAMMarginPct float64
+	LogDir       string
+	// LogMaxMB rotates a process log once it grows past this size (0 =
+	// never), keeping LogKeep older generations as name.log.1 (newest)
+	// through name.log.N.
+	LogMaxMB int64
+	LogKeep  int
+	// HistoryPath is the operation history file; defaults to ops.jsonl next
+	// to LogDir. ChainHistory hash-chains its lines so tampering can be
+	// caught with `gpusched audit verify`.
+	HistoryPath  string
+	ChainHistory bool
+	// EventLogPath keeps every event; defaults to events.jsonl next to
+	// LogDir. Like a process log, it is rotated past EventLogMaxMB (0 =
+	// never), keeping EventLogKeep older generations.
+	EventLogPath  string
+	EventLogMaxMB int64
+	EventLogKeep  int
+	// UsagePath is the GPU-hours ledger; defaults to usage.jsonl next to
+	// LogDir. GPURates are $/GPU-hour keyed by a substring of the device
+	// model, e.g. "H100".
+	UsagePath string
+	GPURates  map[string]float64
+
+	// CUDACheckpointBinary overrides the cuda-checkpoint lookup.
+	CUDACheckpointBinary string
+	// CUDACheckpointArgs are appended to every cuda-checkpoint call.
+	CUDACheckpointArgs []string
+	// CUDACheckpointTimeouts bounds each cuda-checkpoint action.
+	CUDACheckpointTimeouts map[string]time.Duration
+
+	// ReconcileInterval is how often nvidia-smi is cross-checked against
+	// the process table. ZeroMemGrace is how long an active process may
+	// hold no GPU memory before it is reported.
+	ReconcileInterval time.Duration
+	ZeroMemGrace      time.Duration
+
+	// Env is the environment policy for managed processes; runs can
+	// adjust it with RunParams.Env.
+	Env protocol.EnvPolicy
+
+	// GPUPollInterval is how often GPUs are re-enumerated for status and
+	// to detect hotplug and MIG changes.
+	GPUPollInterval time.Duration
+	// SampleInterval is how often the host RAM and per-process GPU memory
+	// shown in status are sampled.
+	SampleInterval time.Duration
+
+	// IdleFreezeAfter freezes active processes that hold GPU memory but
+	// have had no SM utilization for this long. Zero disables it.
+	// Processes in IdleExemptNamespaces are never frozen for idleness.
+	IdleFreezeAfter      time.Duration
+	IdleExemptNamespaces []string
+
+	// MaxAutoFreezesPerHour caps freezes the daemon itself initiates (idle
+	// and liveness) per process, unless the process sets its own cap.
+	// Zero is unlimited.
+	MaxAutoFreezesPerHour int
+
+	// TimeSliceQuantum is how long each time-sliced process on a GPU runs
+	// before the next gets its turn. Zero turns time slicing off.
+	TimeSliceQuantum time.Duration
+
+	// EventRingSize is how many recent events are kept in memory.
+	// EventTypeLimits caps individual event types within the ring so noisy
+	// types (e.g. "reconcile") can't push out freezes and thaws.
+	EventRingSize   int
+	EventTypeLimits map[string]int
+
+	// PlacementStrategy picks GPUs for runs and migrations with AutoGPU
+	// and for dry-run plans (see placement.Names; default spread).
+	// PlacementExec is the plugin command for the exec strategy.
+	PlacementStrategy string
+	PlacementExec     []string
+
+	// SwapInMBps is the assumed rate at which swapped snapshot memory is
+	// faulted back in, used to estimate thaw penalties.
+	SwapInMBps int64
+
+	// FrozenOOMPolicy adjusts oom_score_adj of frozen processes, whose
+	// snapshots occupy host RAM: "protect", "prefer", or "" to leave it.
+	FrozenOOMPolicy string
+
+	// MetricsProcessLabels are the labels on per-process metric series
+	// (see ProcessMetricLabels). Empty disables per-process series.
+	MetricsProcessLabels []string
+
+	// MaxSubscriberDrops disconnects a subscriber once it has missed this
+	// many events because its buffer was full. Zero never disconnects.
+	MaxSubscriberDrops int
+
+	// ShutdownPolicy is the default on_shutdown behaviour ("kill" or
+	// "leave"). DrainTimeout is how long killed processes get between
+	// SIGTERM and SIGKILL. StatePath records processes left running so the
+	// next daemon can reattach them.
+	ShutdownPolicy string
+	DrainTimeout   time.Duration
+	StatePath      string
+
+	// GPUReserveMB is memory kept free on each GPU, keyed by index, with
+	// AllGPUs as the default; placement and thaw leave it alone.
+	// GPUOvercommit caps, per GPU, the memory of its active and frozen
+	// processes at this multiple of its usable memory (0 = no cap).
+	GPUReserveMB  map[int]int64
+	GPUOvercommit map[int]float64
+
+	// NodeName identifies this daemon in a cluster; defaults to the
+	// hostname. Peers maps the other nodes' names to their addresses
+	// (tcp://host:port, tls://host:port, or a socket path), for cluster
+	// status and for requests relayed with Request.Node. PeerToken and
+	// PeerTLS are what the peers' TCP listeners expect.
+	NodeName  string
+	Peers     map[string]string
+	PeerToken string
+	PeerTLS   *tls.Config
+
+	// HTTPHosts are the host names the REST API answers to besides IP
+	// addresses, localhost, and NodeName. Requests naming any other Host
+	// are refused, so a DNS name rebound to the daemon's address can't
+	// reach it.
+	HTTPHosts []string
+
+	// RedactPatterns are globs over variable and flag names whose values
+	// are masked in reported commands; nil means DefaultRedactPatterns.
+	// Processes still restart with the real values.
+	RedactPatterns []string
+
+	// Quotas limit each user's processes, keyed by UID, with AnyUser as
+	// the default for users not listed. Users are known only on the unix
+	// socket, so runs over TCP, HTTP, or gRPC are not limited.
+	Quotas map[int]protocol.Quota
+
+	// Roles gives callers on the unix socket a role (RoleAdmin, RoleUser,
+	// or RoleReadOnly) by UID, with AnyUser for everyone else, including
+	// callers over TCP, HTTP, and gRPC. Without AnyUser, unlisted users
+	// default to RoleUser and callers with no UID to RoleReadOnly. Root
+	// and the daemon's own user are always admins. Empty Roles makes
+	// everyone an admin. Processes run by anyone but admins run as the
+	// caller, so callers with no UID must be admins to run them.
+	Roles map[int]string
+	// ReadOnly refuses everything but reads, from every caller on every
+	// listener, admins included. See also TCPAuth.ReadOnly and the
+	// readOnly arguments of HTTPHandler and GRPCServer, which restrict a
+	// single listener.
+	ReadOnly bool
+
+	// CgroupRoot is a cgroup v2 directory under which each process gets a
+	// cgroup of its own, for the CPU and host memory limits of RunParams
+	// and for accounting its host memory. Empty, or a parent outside a
+	// cgroup v2 hierarchy, runs processes in the daemon's cgroup and
+	// refuses limits.
+	CgroupRoot string
+
+	// MPSPipeDir is where the MPS control daemon for RunParams.MPS
+	// processes keeps its pipes. The daemon starts one there unless one
+	// is already running, and stops it on shutdown if it started it.
+	// Empty refuses MPS runs.
+	MPSPipeDir string
+
+	// SpecDir holds the desired state: one RunParams JSON file per
+	// process, named after it unless it gives a Name. The daemon keeps
+	// them running alongside specs pushed with "apply". Empty means only
+	// pushed specs.
+	SpecDir string
 }
 
 type Daemon struct {
@@ -42,12 +297,58 @@ type Daemon struct {
 	events  []protocol.Event
 	metrics protocol.Metrics
 
-	cuda *checkpoint.CUDA
-	cfg  Config
-	log  *log.Logger
-
-	subs  []chan protocol.Event
-	subMu sync.Mutex
+	// requests maps a process to the request currently acting on it, so
+	// the events that request causes carry its ID and caller.
+	requests map[string]activeRequest
+
+	cuda     *checkpoint.CUDA
+	cfg      Config
+	log      *log.Logger
+	history  *opHistory
+	eventLog *eventLog
+	usage    *usageLedger
+	cpu      *procfs.CPUSampler
+	placer   placement.Strategy
+	// cgroups is CgroupRoot if processes get cgroups, else "".
+	cgroups string
+	// mps is MPSPipeDir if MPS runs are possible, else "". mpsStarted
+	// means the daemon started the control daemon there.
+	mps        string
+	mpsStarted bool
+
+	// gpuModels caches device names by index for usage records.
+	gpuModels map[int]string
+	inv       gpuInventory
+	smp       hostSample
+
+	// oomKills is the last seen kernel OOM kill count.
+	oomKills int64
+
+	subs      []*subscriber
+	subMu     sync.Mutex
+	nextSubID int
+	dropped   int
+
+	done     chan struct{}
+	doneOnce sync.Once
+
+	// queue holds runs waiting for GPU memory; queueKick wakes queueLoop
+	// when memory may have been released.
+	queue     []*queuedRun
+	queueKick chan struct{}
+
+	// desiredMu serializes reconcile passes and guards the desired state.
+	// applied holds pushed specs and specKeys the spec each process was
+	// last started from, both by qualified name. specFiles is the last
+	// good spec read from each spec file. specErrors and fileErrors are
+	// the last failure reported for each spec and spec file, so that a
+	// failure is reported once.
+	desiredMu  sync.Mutex
+	applied    map[string]desiredSpec
+	specKeys   map[string]string
+	specFiles  map[string]desiredSpec
+	specErrors map[string]string
+	fileErrors map[string]string
 
 	freezeTotalMs int64
 	thawTotalMs   int64
@@ -66,74 +367,454 @@ func New(cfg Config) *Daemon {
 		}
 	}
 
+	if cfg.RAMMarginMB == 0 && cfg.RAMMarginPct == 0 {
+		cfg.RAMMarginMB = defaultRAMMarginMB
+	}
+
+	if cfg.ReconcileInterval == 0 {
+		cfg.ReconcileInterval = defaultReconcileInterval
+	}
+	if cfg.ZeroMemGrace == 0 {
+		cfg.ZeroMemGrace = defaultZeroMemGrace
+	}
+	if cfg.GPUPollInterval == 0 {
+		cfg.GPUPollInterval = defaultGPUPollInterval
+	}
+	if cfg.SampleInterval == 0 {
+		cfg.SampleInterval = defaultSampleInterval
+	}
+	if cfg.EventRingSize <= 0 {
+		cfg.EventRingSize = defaultEventRingSize
+	}
+	if cfg.SwapInMBps <= 0 {
+		cfg.SwapInMBps = defaultSwapInMBps
+	}
+	if cfg.DrainTimeout == 0 {
+		cfg.DrainTimeout = defaultDrainTimeout
+	}
+	if cfg.StatePath == "" {
+		cfg.StatePath = filepath.Join(filepath.Dir(cfg.LogDir), "state.json")
+	}
+	if cfg.HistoryPath == "" {
+		cfg.HistoryPath = filepath.Join(filepath.Dir(cfg.LogDir), "ops.jsonl")
+	}
+	if cfg.EventLogPath == "" {
+		cfg.EventLogPath = filepath.Join(filepath.Dir(cfg.LogDir), "events.jsonl")
+	}
+	if cfg.NodeName == "" {
+		cfg.NodeName, _ = os.Hostname()
+	}
+	if cfg.UsagePath == "" {
+		cfg.UsagePath = filepath.Join(filepath.Dir(cfg.LogDir), "usage.jsonl")
+	}
+
 	os.MkdirAll(cfg.LogDir, 0o755)
 
-	cuda := checkpoint.NewCUDA()
+	cuda := checkpoint.NewCUDAAt(cfg.CUDACheckpointBinary)
+	cuda.ExtraArgs = cfg.CUDACheckpointArgs
+	cuda.Timeouts = cfg.CUDACheckpointTimeouts
 
 	d := &Daemon{
-		procs: make(map[string]*Proc),
-		cuda:  cuda,
-		cfg:   cfg,
-		log:   log.New(os.Stderr, "[gpusched] ", log.LstdFlags|log.Lmsgprefix),
+		procs:     make(map[string]*Proc),
+		requests:  make(map[string]activeRequest),
+		cuda:      cuda,
+		cfg:       cfg,
+		log:       log.New(os.Stderr, "[gpusched] ", log.LstdFlags|log.Lmsgprefix),
+		history:   openHistory(cfg.HistoryPath, cfg.ChainHistory),
+		usage:     openUsage(cfg.UsagePath),
+		cpu:       procfs.NewCPUSampler(),
+		done:      make(chan struct{}),
+		queueKick: make(chan struct{}, 1),
+
+		applied:    make(map[string]desiredSpec),
+		specKeys:   make(map[string]string),
+		specFiles:  make(map[string]desiredSpec),
+		specErrors: make(map[string]string),
+		fileErrors: make(map[string]string),
+	}
+
+	d.oomKills, _ = procfs.OOMKills()
+
+	// Events are logged from the start, whether or not the daemon is
+	// started.
+	d.eventLog = newEventLog(cfg.EventLogPath, cfg.EventLogMaxMB, cfg.EventLogKeep, d.log)
+	go d.eventLog.writeLoop(d.done)
+
+	placer, err := placement.New(cfg.PlacementStrategy, cfg.PlacementExec)
+	if err != nil {
+		d.log.Printf("WARN: %v; using %s placement", err, placement.Spread)
+		placer, _ = placement.New(placement.Spread, nil)
 	}
+	d.placer = placer
+	d.cgroups = d.initCgroups(cfg.CgroupRoot)
 
-	d.log.Printf("capabilities: cuda-checkpoint=%v", cuda.Available)
+	d.log.Printf("capabilities: cuda-checkpoint=%v binary=%s", cuda.Available, cuda.Binary)
 	d.log.Printf("config: ram_budget=%dMB", cfg.RAMBudgetMB)
 
 	return d
 }
 
+// Start reattaches processes left running by a previous daemon, whether it
+// shut down cleanly or crashed, and launches the background loops. The loops stop on Shutdown.
+func (d *Daemon) Start() {
+	d.mps = d.initMPS(d.cfg.MPSPipeDir)
+	d.refreshGPUs()
+	d.reattach()
+	d.recoverOrphans()
+	go d.reconcileLoop()
+	go d.gpuLoop()
+	go d.sampleLoop()
+	go d.queueLoop()
+	go d.deadlineLoop()
+	go d.desiredLoop()
+	if d.cfg.LogMaxMB > 0 {
+		go d.logLoop()
+	}
+	if d.cfg.IdleFreezeAfter > 0 {
+		go d.idleLoop()
+	}
+	if d.cfg.TimeSliceQuantum > 0 {
+		go d.timeSliceLoop()
+	}
+}
+
 func (d *Daemon) Run(params protocol.RunParams) (protocol.RunResult, error) {
+	if params.Queue {
+		return d.runOrQueue(params)
+	}
+	name := protocol.QualifiedName(params.Namespace, params.Name)
+
+	// Placement may run a plugin, so it happens before taking the lock.
+	var placed *protocol.Placement
+	if params.AutoGPU {
+		pl, err := d.place(placement.Request{Process: name, Exclude: -1}, params.Exclusive)
+		if err != nil {
+			return protocol.RunResult{}, err
+		}
+		params.GPU = pl.GPU
+		placed = &pl
+	}
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	if _, exists := d.procs[params.Name]; exists {
-		return protocol.RunResult{}, fmt.Errorf("process %q already exists", params.Name)
+	if _, exists := d.procs[name]; exists {
+		return protocol.RunResult{}, errorf(protocol.CodeAlreadyExists, "process %q already exists", name)
+	}
+	if d.queued(name) >= 0 {
+		return protocol.RunResult{}, fmt.Errorf("process %q is already queued", name)
+	}
+	if err := d.checkExclusive(params.GPU, params.Exclusive, nil); err != nil {
+		return protocol.RunResult{}, err
+	}
+	if err := d.checkQuota(params.UID, params.GPU, params.MemMB); err != nil {
+		return protocol.RunResult{}, err
+	}
+	if !params.AutoGPU {
+		if err := d.checkGPUBudget(params.GPU, params.MemMB, nil, name); err != nil {
+			return protocol.RunResult{}, err
+		}
+	}
+
+	p, err := d.spawn(params, false)
+	if err != nil {
+		return protocol.RunResult{}, err
+	}
+	d.metrics.ColdStarts++
+
+	detail := fmt.Sprintf("pid=%d gpu=%d cmd=%s", p.PID, params.GPU, p.command)
+	if placed != nil {
+		detail += " (" + placement.Explain(*placed) + ")"
+	}
+	d.emit(protocol.Event{
+		Type:      "run",
+		Process:   name,
+		Detail:    detail,
+		Placement: placed,
+	})
+
+	d.log.Printf("RUN %s %s", name, detail)
+	return protocol.RunResult{Name: params.Name, PID: p.PID, GPU: params.GPU}, nil
+}
+
+// place picks a GPU for req with the configured strategy.
+func (d *Daemon) place(req placement.Request, exclusive bool) (protocol.Placement, error) {
+	gpus, err := d.refreshGPUs()
+	if err != nil {
+		return protocol.Placement{}, fmt.Errorf("query gpus: %w", err)
+	}
+	d.mu.RLock()
+	self := d.procs[req.Process]
+	open := withoutCordoned(gpus, d.cordonedGPUs(exclusive, self))
+	if len(open) == 0 && len(gpus) > 0 {
+		d.mu.RUnlock()
+		return protocol.Placement{}, fmt.Errorf("no GPU available: every GPU is reserved or in use")
+	}
+	open = d.withinBudget(d.withReserve(open), req.NeedMB, self)
+	d.mu.RUnlock()
+	if len(open) == 0 && len(gpus) > 0 {
+		return protocol.Placement{}, fmt.Errorf("no GPU available: every GPU is at its memory overcommit budget")
+	}
+	return placement.Choose(d.placer, req, open)
+}
+
+// validNames checks that params' name, namespace, and group are each a
+// single path element, since logs and cgroups are kept under them.
+func validNames(params protocol.RunParams) error {
+	for _, f := range []struct{ what, s string }{
+		{"name", params.Name},
+		{"namespace", params.Namespace},
+		{"group", params.Group},
+	} {
+		if strings.Contains(f.s, "/") {
+			return fmt.Errorf("%s must not contain '/'", f.what)
+		}
+		if f.s == "." || f.s == ".." {
+			return fmt.Errorf("%s must not be %q", f.what, f.s)
+		}
+	}
+	return nil
+}
+
+// validateRunParams checks params for anything spawn would refuse, so a
+// queued run is refused when it is submitted rather than when it starts.
+func (d *Daemon) validateRunParams(params protocol.RunParams) error {
+	if params.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if err := validNames(params); err != nil {
+		return err
 	}
 	if len(params.Cmd) == 0 {
-		return protocol.RunResult{}, fmt.Errorf("empty command")
+		return fmt.Errorf("empty command")
+	}
+	if !validLivenessAction(params.LivenessAction) {
+		return fmt.Errorf("unknown liveness action %q", params.LivenessAction)
+	}
+	if !validShutdownPolicy(params.OnShutdown) {
+		return fmt.Errorf("unknown shutdown policy %q", params.OnShutdown)
+	}
+	if err := validGPUMemLimit(params); err != nil {
+		return err
+	}
+	if err := validPriority(params); err != nil {
+		return err
+	}
+	if err := validDeadline(params); err != nil {
+		return err
+	}
+	if params.TimeSlice && d.cfg.TimeSliceQuantum <= 0 {
+		return fmt.Errorf("time slicing is off (daemon --time-slice)")
+	}
+	if params.MPS && d.mps == "" {
+		return fmt.Errorf("MPS is off (daemon --mps-pipe-dir)")
+	}
+	if params.MPS && params.TimeSlice {
+		return fmt.Errorf("an MPS client can't be time-sliced: it can't be frozen")
+	}
+	if params.CPUs < 0 || params.HostMemMB < 0 {
+		return fmt.Errorf("cpu and host memory limits must not be negative")
+	}
+	if !validRestartPolicy(params.Restart) {
+		return fmt.Errorf("unknown restart policy %q", params.Restart)
+	}
+	if params.RestartMax < 0 {
+		return fmt.Errorf("restart max must not be negative")
+	}
+	if params.Shell && params.ExpandEnv {
+		return fmt.Errorf("expand-env can't be combined with shell, which expands $VAR itself")
+	}
+	if params.TTY && (params.Input != "" || params.Output != "") {
+		return fmt.Errorf("a tty can't be combined with input or output")
+	}
+	if params.TTY && params.OnShutdown == protocol.ShutdownLeave {
+		return fmt.Errorf("a tty process can't be left running: its terminal closes with the daemon")
+	}
+	// params is a copy, so the profile is only checked here.
+	if err := applyInferenceProfile(&params); err != nil {
+		return err
+	}
+	for action := range params.CheckpointTimeouts {
+		if !checkpoint.ValidAction(action) {
+			return fmt.Errorf("unknown cuda-checkpoint action %q", action)
+		}
+	}
+	return nil
+}
+
+// spawn starts params as a managed process and registers it under its
+// qualified name, replacing any existing entry. appendLog keeps the existing
+// log file (used on restart). Caller must hold d.mu.
+func (d *Daemon) spawn(params protocol.RunParams, appendLog bool) (*Proc, error) {
+	if err := d.validateRunParams(params); err != nil {
+		return nil, err
+	}
+	if err := applyInferenceProfile(&params); err != nil {
+		return nil, err
+	}
+	timeouts := make(map[string]time.Duration, len(params.CheckpointTimeouts))
+	for action, ms := range params.CheckpointTimeouts {
+		timeouts[action] = time.Duration(ms) * time.Millisecond
+	}
+
+	var cred *syscall.Credential
+	if params.RunAs != nil {
+		c, err := credential(*params.RunAs)
+		if err != nil {
+			return nil, err
+		}
+		cred = c
+	}
+
+	name := protocol.QualifiedName(params.Namespace, params.Name)
+	env, err := processEnv(os.Environ(), d.cfg.Env, params.Env, params, name)
+	if err != nil {
+		return nil, err
+	}
+	if params.MPS {
+		env = append(env, mpsClientEnv(d.mps, params)...)
 	}
 
-	logPath := filepath.Join(d.cfg.LogDir, params.Name+".log")
-	logFile, err := os.Create(logPath)
+	logPath := filepath.Join(d.cfg.LogDir, name+".log")
+	if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
+		return nil, fmt.Errorf("creating log dir: %w", err)
+	}
+	// The log is always appended to, so the process keeps writing at the
+	// end after rotation truncates it.
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND | os.O_TRUNC
+	if appendLog {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	} else {
+		removeRotatedLogs(logPath)
+	}
+	// Wake FIFOs are created before the process starts, so it can open
+	// them.
+	wake, err := openWakeWatch(params.Wake, params.Dir)
+	if err != nil {
+		return nil, err
+	}
+	cgroup, err := d.makeCgroup(name, params)
+	if err != nil {
+		if wake != nil {
+			wake.f.Close()
+		}
+		return nil, err
+	}
+	closeWake := func() {
+		if wake != nil {
+			wake.f.Close()
+		}
+	}
+	// The child starts in the cgroup, so the directory is only needed
+	// until then.
+	if cgroup != nil {
+		defer cgroup.Close()
+	}
+	stdin, stdout, err := openStdio(params, appendLog)
 	if err != nil {
-		return protocol.RunResult{}, fmt.Errorf("creating log: %w", err)
+		closeWake()
+		return nil, err
+	}
+	logFile, err := os.OpenFile(logPath, flags, 0o644)
+	if err != nil {
+		closeAll(stdin, stdout)
+		closeWake()
+		return nil, fmt.Errorf("creating log: %w", err)
 	}
 
-	cmd := exec.Command(params.Cmd[0], params.Cmd[1:]...)
+	argv := buildArgv(params, env)
+
+	cmd := exec.Command(argv[0], argv[1:]...)
 	cmd.Stdout = logFile
 	cmd.Stderr = logFile
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
 	cmd.Dir = params.Dir
+	cmd.Env = env
 
-	env := os.Environ()
-	env = append(env,
-		"GPUSCHED_MANAGED=1",
-		fmt.Sprintf("CUDA_VISIBLE_DEVICES=%d", params.GPU),
-	)
-	if os.Getuid() == 0 {
-		env = appendPythonPath(env)
+	// A tty process gets the slave side of a new terminal for all of its
+	// stdio, as its controlling terminal in a session of its own.
+	var tty *terminal
+	if params.TTY {
+		tty, stdin, err = openTerminal()
+		if err != nil {
+			logFile.Close()
+			closeWake()
+			return nil, err
+		}
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdin, stdin
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	}
+	if cgroup != nil {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = int(cgroup.Fd())
+	}
+	if cred != nil {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.Credential = cred
 	}
-	cmd.Env = env
 
-	if err := cmd.Start(); err != nil {
+	err = cmd.Start()
+	// The child has its own copies of stdin and stdout now.
+	closeAll(stdin, stdout)
+	if err != nil {
+		logFile.Close()
+		closeWake()
+		if tty != nil {
+			tty.master.Close()
+		}
+		return nil, fmt.Errorf("starting process: %w", err)
+	}
+	if err := applyPriority(cmd.Process.Pid, params); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
 		logFile.Close()
-		return protocol.RunResult{}, fmt.Errorf("starting process: %w", err)
+		closeWake()
+		if tty != nil {
+			tty.master.Close()
+		}
+		return nil, err
+	}
+	if tty != nil {
+		// The pump writes the log and closes it once it has drained the
+		// terminal, which can be after the process exits.
+		go tty.pump(logFile)
+		logFile = nil
 	}
 
 	p := &Proc{
-		Name:    params.Name,
+		Name:    name,
 		PID:     cmd.Process.Pid,
 		State:   protocol.StateActive,
 		GPU:     params.GPU,
 		Started: time.Now(),
+		Argv:    argv,
+		Shell:   params.Shell,
+		command: d.displayCommand(argv, env),
 		Cmd:     cmd,
 		LogPath: logPath,
 		logFile: logFile,
+		tty:     tty,
+		cgroup:  d.cgroupPath(name),
+		cuda:    d.cuda.With(params.CheckpointArgs, timeouts),
+		params:  params,
+		exited:  make(chan struct{}),
+
+		Readiness: params.Readiness,
 	}
-	d.procs[params.Name] = p
-	d.metrics.ColdStarts++
+	d.procs[name] = p
+	d.startActive(p, p.Started)
 
-	go d.monitorProcess(params.Name, cmd)
+	go d.monitorProcess(name, cmd, p.exited)
 
 	go func() {
 		for i := 0; i < 12; i++ {
@@ -152,23 +833,95 @@ func (d *Daemon) Run(params protocol.RunParams) (protocol.RunResult, error) {
 		}
 	}()
 
+	if params.Liveness != nil {
+		go d.livenessLoop(p)
+	}
+	if wake != nil {
+		go d.wakeLoop(p, wake)
+	}
+
+	return p, nil
+}
+
+// restart kills the current instance of name and spawns it again from its
+// original run parameters, appending to the same log. Caller must hold d.mu.
+func (d *Daemon) restart(name, reason string) error {
+	p, ok := d.procs[name]
+	if !ok {
+		return errorf(protocol.CodeNotFound, "process %q not found", name)
+	}
+	if p.State != protocol.StateDead {
+		if p.State == protocol.StateFrozen {
+			syscall.Kill(p.PID, syscall.SIGCONT)
+		}
+		syscall.Kill(p.PID, syscall.SIGKILL)
+		for _, w := range p.workers {
+			syscall.Kill(w, syscall.SIGKILL)
+		}
+	}
+	d.endActive(p, time.Now())
+	p.State = protocol.StateDead
+	p.exitedAt = time.Now()
+	if p.logFile != nil {
+		p.logFile.Close()
+	}
+
+	np, err := d.spawn(p.params, true)
+	if err != nil {
+		d.emit(protocol.Event{Type: "restart-failed", Process: name, Detail: err.Error()})
+		d.log.Printf("RESTART %s failed: %v", name, err)
+		return err
+	}
+	np.Restarts = p.Restarts + 1
+	np.restartStreak = p.restartStreak
+	np.Notes = p.Notes
+	np.AppCheckpoint = p.AppCheckpoint
+	np.Freezes = p.Freezes
+	np.frozenTotal = p.suspended(time.Now())
+	np.autoFreezes = p.autoFreezes
+
 	d.emit(protocol.Event{
-		Type:    "run",
-		Process: params.Name,
-		Detail:  fmt.Sprintf("pid=%d gpu=%d cmd=%v", p.PID, params.GPU, params.Cmd),
+		Type:    "restart",
+		Process: name,
+		Detail:  fmt.Sprintf("pid=%d (%s)", np.PID, reason),
 	})
-
-	d.log.Printf("RUN %s pid=%d gpu=%d cmd=%v", params.Name, p.PID, params.GPU, params.Cmd)
-	return protocol.RunResult{Name: params.Name, PID: p.PID}, nil
+	d.log.Printf("RESTART %s pid=%d → %d: %s", name, p.PID, np.PID, reason)
+	return nil
 }
 
+// Freeze checkpoints a process to host RAM at the user's request.
 func (d *Daemon) Freeze(name string) (protocol.FreezeResult, error) {
+	return d.freeze(name, protocol.CauseUser, "")
+}
+
+// Park checkpoints a process's GPU state to host RAM at the user's
+// request but leaves it running, so it can get on with CPU work until
+// Unpark or Thaw. Its CUDA calls block until then.
+func (d *Daemon) Park(name string) (protocol.FreezeResult, error) {
+	return d.suspend(name, protocol.CauseUser, "", true)
+}
+
+// freeze checkpoints name to host RAM, recording cause and detail as the
+// reason on the event, the history record, and the process.
+func (d *Daemon) freeze(name, cause, detail string) (protocol.FreezeResult, error) {
+	return d.suspend(name, cause, detail, false)
+}
+
+// suspend freezes name, or with park only checkpoints it and leaves it
+// running.
+func (d *Daemon) suspend(name, cause, detail string, park bool) (protocol.FreezeResult, error) {
+	op := "freeze"
+	if park {
+		op = "park"
+	}
+	d.drainInference(name)
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	p, ok := d.procs[name]
 	if !ok {
-		return protocol.FreezeResult{}, fmt.Errorf("process %q not found", name)
+		return protocol.FreezeResult{}, errorf(protocol.CodeNotFound, "process %q not found", name)
 	}
 	if p.State != protocol.StateActive {
 		return protocol.FreezeResult{}, fmt.Errorf("process %q is %s, not active", name, p.State)
@@ -176,78 +929,312 @@ func (d *Daemon) Freeze(name string) (protocol.FreezeResult, error) {
 	if !d.cuda.Available {
 		return protocol.FreezeResult{}, fmt.Errorf("cuda-checkpoint not available")
 	}
-
-	if mem := gpu.ProcessGPUMem(p.PID); mem > 0 {
+	if p.params.MPS {
+		return protocol.FreezeResult{}, fmt.Errorf("process %q is an MPS client, which cuda-checkpoint can't checkpoint", name)
+	}
+	rdzv := detectTorchrun(p.params.Cmd)
+	if park && rdzv != nil {
+		return protocol.FreezeResult{}, fmt.Errorf("process %q is a torchrun job, which can only be frozen with its agent stopped", name)
+	}
+	if mem := procGPUMem(p); mem > 0 {
 		p.MemMB = mem
 	}
-
-	dur, err := d.cuda.Freeze(p.PID)
-	if err != nil {
-		return protocol.FreezeResult{}, fmt.Errorf("cuda freeze: %w", err)
+	if err := d.checkRAM(name, p.MemMB); err != nil {
+		return protocol.FreezeResult{}, err
+	}
+	if err := d.checkSnapshotQuota(p); err != nil {
+		return protocol.FreezeResult{}, err
 	}
 
-	syscall.Kill(p.PID, syscall.SIGSTOP)
+	freeBefore, freeErr := gpu.FreeMB(p.GPU)
+	var phases checkpoint.Phases
+	if rdzv != nil {
+		var err error
+		if phases, err = d.freezeElastic(p, rdzv); err != nil {
+			return protocol.FreezeResult{}, fmt.Errorf("cuda freeze: %w", err)
+		}
+	} else {
+		var err error
+		if phases, err = d.cudaFor(p).Freeze(p.PID); err != nil {
+			d.degradeIf(p, op, cause, err)
+			return protocol.FreezeResult{}, fmt.Errorf("cuda %s: %w", op, err)
+		}
+		if !park {
+			stopStart := time.Now()
+			syscall.Kill(p.PID, syscall.SIGSTOP)
+			phases = append(phases, checkpoint.Phase{Name: "sigstop", Duration: time.Since(stopStart)})
+		}
+	}
+	dur := phases.Total()
+	gpuFree := gpuFreeDelta(p.GPU, freeBefore, freeErr)
 
+	d.endActive(p, time.Now())
 	p.State = protocol.StateFrozen
+	p.parked = park
+	d.applyFrozenOOM(p)
+	p.Freezes++
+	p.frozenAt = time.Now()
 
 	d.metrics.Freezes++
 	d.freezeTotalMs += dur.Milliseconds()
 	d.metrics.AvgFreezeMs = d.freezeTotalMs / int64(d.metrics.Freezes)
+	d.metrics.FreezeLatency.Observe(dur.Milliseconds())
+	if cause != protocol.CauseUser {
+		d.metrics.Evictions++
+	}
+
+	d.setTransition(p, cause, detail)
 
+	still := ""
+	if park {
+		still = ", still running on CPU"
+	}
 	d.emit(protocol.Event{
-		Type:     "freeze",
+		Type:     op,
 		Process:  name,
 		Duration: dur.Milliseconds(),
-		Detail:   fmt.Sprintf("→ RAM (%d MB)", p.MemMB),
+		Detail:   fmt.Sprintf("→ RAM (%d MB)%s%s", p.MemMB, gpuFreeNote(gpuFree), still),
+		Cause:    cause,
+	})
+
+	d.recordOp(protocol.OpRecord{
+		Op: op, Process: name, GPU: p.GPU, Tier: protocol.TierRAM,
+		MemMB: p.MemMB, DurationMs: dur.Milliseconds(), Cause: cause,
 	})
 
-	d.log.Printf("FREEZE %s pid=%d %dms %dMB → RAM", name, p.PID, dur.Milliseconds(), p.MemMB)
+	d.log.Printf("%s %s pid=%d %dms %dMB → RAM%s [%s] (%s)", strings.ToUpper(op), name, p.PID, dur.Milliseconds(), p.MemMB, gpuFreeNote(gpuFree), formatPhases(phases), causeNote(cause, detail))
 	return protocol.FreezeResult{
 		Name:       name,
 		DurationMs: dur.Milliseconds(),
 		MemMB:      p.MemMB,
+		Phases:     toProtocolPhases(phases),
+		GPUFree:    gpuFree,
 	}, nil
 }
 
+// autoFreeze freezes name on the daemon's own initiative (idleness,
+// liveness) unless it has hit its automatic freeze cap for the last hour.
+func (d *Daemon) autoFreeze(name, cause, reason string) error {
+	d.mu.Lock()
+	p, ok := d.procs[name]
+	if !ok {
+		d.mu.Unlock()
+		return errorf(protocol.CodeNotFound, "process %q not found", name)
+	}
+	now := time.Now()
+	limit := d.autoFreezeLimit(p)
+	recent := p.autoFreezes[:0]
+	for _, t := range p.autoFreezes {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	p.autoFreezes = recent
+	if limit > 0 && len(recent) >= limit {
+		detail := fmt.Sprintf("%s; not freezing: %d automatic freezes in the last hour (max %d)", reason, len(recent), limit)
+		d.emit(protocol.Event{Type: "freeze-capped", Process: name, Detail: detail})
+		d.log.Printf("AUTO-FREEZE %s: %s", name, detail)
+		d.mu.Unlock()
+		return nil
+	}
+	d.mu.Unlock()
+
+	if _, err := d.freeze(name, cause, reason); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	p.autoFreezes = append(p.autoFreezes, now)
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *Daemon) autoFreezeLimit(p *Proc) int {
+	if p.params.MaxAutoFreezesPerHour > 0 {
+		return p.params.MaxAutoFreezesPerHour
+	}
+	return d.cfg.MaxAutoFreezesPerHour
+}
+
+// suspended is the total time p has spent frozen, including the current
+// freeze.
+func (p *Proc) suspended(now time.Time) time.Duration {
+	total := p.frozenTotal
+	if p.State == protocol.StateFrozen && !p.frozenAt.IsZero() {
+		total += now.Sub(p.frozenAt)
+	}
+	return total
+}
+
+// resumed records p running on its GPU again after a thaw or migrate, and
+// puts back its OOM score if it was frozen. Caller must hold d.mu.
+func (d *Daemon) resumed(p *Proc, now time.Time) {
+	if p.State == protocol.StateFrozen && !p.frozenAt.IsZero() {
+		p.frozenTotal += now.Sub(p.frozenAt)
+	}
+	p.frozenAt = time.Time{}
+	p.State = protocol.StateActive
+	p.parked = false
+	d.restoreOOM(p)
+	d.startActive(p, now)
+}
+
+// Thaw restores a frozen process and, if it has a readiness probe, waits
+// for the probe to pass before returning. The wait happens without holding
+// the daemon lock.
 func (d *Daemon) Thaw(name string) (protocol.ThawResult, error) {
+	return d.thawReady(name, protocol.CauseUser, "")
+}
+
+// Unpark restores the GPU state of a parked process. Thaw does the same,
+// and also thaws frozen ones.
+func (d *Daemon) Unpark(name string) (protocol.ThawResult, error) {
+	d.mu.RLock()
+	p, ok := d.procs[name]
+	var err error
+	if ok && !p.parked {
+		err = fmt.Errorf("process %q is %s, not parked", name, p.State)
+	}
+	d.mu.RUnlock()
+	if err != nil {
+		return protocol.ThawResult{}, err
+	}
+	return d.thawReady(name, protocol.CauseUser, "")
+}
+
+// thawReady is Thaw with cause and detail recorded as the reason.
+func (d *Daemon) thawReady(name, cause, detail string) (protocol.ThawResult, error) {
+	res, readiness, err := d.thaw(name, cause, detail)
+	if err != nil {
+		return res, err
+	}
+	defer d.resumeDrained(name)
+	if readiness == nil {
+		return res, nil
+	}
+
+	readyDur, perr := probe.Wait(context.Background(), *readiness)
+	ready := perr == nil
+	res.Ready = &ready
+	res.ReadyMs = readyDur.Milliseconds()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if ready {
+		d.emit(protocol.Event{
+			Type:     "ready",
+			Process:  name,
+			Duration: res.ReadyMs,
+			Detail:   probe.Describe(*readiness),
+		})
+		d.log.Printf("READY %s %dms after restore (%s)", name, res.ReadyMs, probe.Describe(*readiness))
+	} else {
+		res.ReadyError = perr.Error()
+		d.emit(protocol.Event{Type: "not-ready", Process: name, Detail: perr.Error()})
+		d.log.Printf("NOT READY %s: %v", name, perr)
+	}
+	return res, nil
+}
+
+func (d *Daemon) thaw(name, cause, detail string) (protocol.ThawResult, *protocol.Probe, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	p, ok := d.procs[name]
 	if !ok {
-		return protocol.ThawResult{}, fmt.Errorf("process %q not found", name)
+		return protocol.ThawResult{}, nil, errorf(protocol.CodeNotFound, "process %q not found", name)
 	}
 	if p.State != protocol.StateFrozen {
-		return protocol.ThawResult{}, fmt.Errorf("process %q is %s, not frozen", name, p.State)
+		return protocol.ThawResult{}, nil, fmt.Errorf("process %q is %s, not frozen", name, p.State)
+	}
+	if err := d.checkExclusive(p.GPU, p.params.Exclusive, p); err != nil {
+		return protocol.ThawResult{}, nil, err
+	}
+	if err := d.checkThawReserve(p); err != nil {
+		return protocol.ThawResult{}, nil, err
+	}
+	if err := d.checkQuota(p.params.UID, p.GPU, p.MemMB); err != nil {
+		return protocol.ThawResult{}, nil, err
 	}
 
-	syscall.Kill(p.PID, syscall.SIGCONT)
+	tier, _ := swapTier(p.MemMB, procfs.SwapMB(p.PID), d.cfg.SwapInMBps)
+	op := "thaw"
+	if p.parked {
+		op = "unpark"
+	}
 
-	dur, err := d.cuda.Thaw(p.PID)
-	if err != nil {
-		syscall.Kill(p.PID, syscall.SIGSTOP)
-		return protocol.ThawResult{}, fmt.Errorf("cuda thaw: %w", err)
+	freeBefore, freeErr := gpu.FreeMB(p.GPU)
+	var phases checkpoint.Phases
+	if len(p.workers) > 0 {
+		var err error
+		if phases, err = d.thawElastic(p); err != nil {
+			return protocol.ThawResult{}, nil, fmt.Errorf("cuda thaw: %w", err)
+		}
+	} else {
+		// A parked process was never stopped.
+		if !p.parked {
+			contStart := time.Now()
+			syscall.Kill(p.PID, syscall.SIGCONT)
+			phases = checkpoint.Phases{{Name: "sigcont", Duration: time.Since(contStart)}}
+		}
+
+		cudaPhases, err := d.cudaFor(p).Thaw(p.PID)
+		if err != nil {
+			if !p.parked {
+				syscall.Kill(p.PID, syscall.SIGSTOP)
+			}
+			d.degradeIf(p, op, cause, err)
+			return protocol.ThawResult{}, nil, fmt.Errorf("cuda %s: %w", op, err)
+		}
+		phases = append(phases, cudaPhases...)
 	}
+	dur := phases.Total()
+	gpuFree := gpuFreeDelta(p.GPU, freeBefore, freeErr)
+	p.recordThaw(dur.Milliseconds(), tier)
 
-	p.State = protocol.StateActive
+	d.resumed(p, time.Now())
 
 	d.metrics.Thaws++
 	d.thawTotalMs += dur.Milliseconds()
 	d.metrics.AvgThawMs = d.thawTotalMs / int64(d.metrics.Thaws)
+	d.metrics.ThawLatency.Observe(dur.Milliseconds())
+
+	d.setTransition(p, cause, detail)
 
 	d.emit(protocol.Event{
-		Type:     "thaw",
+		Type:     op,
 		Process:  p.Name,
 		Duration: dur.Milliseconds(),
-		Detail:   fmt.Sprintf("← RAM (%d MB)", p.MemMB),
+		Detail:   fmt.Sprintf("← RAM (%d MB)%s", p.MemMB, gpuFreeNote(gpuFree)),
+		Cause:    cause,
+	})
+
+	d.recordOp(protocol.OpRecord{
+		Op: op, Process: p.Name, GPU: p.GPU, Tier: protocol.TierRAM,
+		MemMB: p.MemMB, DurationMs: dur.Milliseconds(), Cause: cause,
 	})
 
-	d.log.Printf("THAW %s pid=%d %dms ← RAM", p.Name, p.PID, dur.Milliseconds())
+	d.log.Printf("%s %s pid=%d %dms ← RAM%s [%s] (%s)", strings.ToUpper(op), p.Name, p.PID, dur.Milliseconds(), gpuFreeNote(gpuFree), formatPhases(phases), causeNote(cause, detail))
 	return protocol.ThawResult{
 		Name:       p.Name,
 		DurationMs: dur.Milliseconds(),
 		MemMB:      p.MemMB,
-	}, nil
+		Phases:     toProtocolPhases(phases),
+		GPUFree:    gpuFree,
+	}, p.Readiness, nil
+}
+
+// continueStopped sends SIGCONT to p, and to its torchrun workers, if a
+// freeze may have left them stopped, so they act on the signal that
+// follows.
+func continueStopped(p *Proc) {
+	if p.State != protocol.StateFrozen && p.State != protocol.StateDegraded {
+		return
+	}
+	syscall.Kill(p.PID, syscall.SIGCONT)
+	for _, w := range p.workers {
+		syscall.Kill(w, syscall.SIGCONT)
+	}
 }
 
 func (d *Daemon) Kill(name string) error {
@@ -256,23 +1243,24 @@ func (d *Daemon) Kill(name string) error {
 
 	p, ok := d.procs[name]
 	if !ok {
-		return fmt.Errorf("process %q not found", name)
+		return errorf(protocol.CodeNotFound, "process %q not found", name)
 	}
 
-	if p.State == protocol.StateFrozen {
-		syscall.Kill(p.PID, syscall.SIGCONT)
-	}
+	continueStopped(p)
 	syscall.Kill(p.PID, syscall.SIGTERM)
 	go func(pid int) {
 		time.Sleep(3 * time.Second)
 		syscall.Kill(pid, syscall.SIGKILL)
 	}(p.PID)
 
+	d.endActive(p, time.Now())
 	p.State = protocol.StateDead
 	if p.logFile != nil {
 		p.logFile.Close()
 	}
 
+	d.cpu.Forget(p.PID)
+
 	d.emit(protocol.Event{Type: "kill", Process: name})
 	d.log.Printf("KILL %s pid=%d", name, p.PID)
 
@@ -281,90 +1269,204 @@ func (d *Daemon) Kill(name string) error {
 }
 
 func (d *Daemon) Migrate(params protocol.MigrateParams) (protocol.MigrateResult, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	name := protocol.QualifiedName(params.Namespace, params.Name)
 
-	p, ok := d.procs[params.Name]
+	d.mu.RLock()
+	p, ok := d.procs[name]
+	var memMB int64
+	var fromGPU int
+	var exclusive bool
+	if ok {
+		memMB, fromGPU, exclusive = p.MemMB, p.GPU, p.params.Exclusive
+	}
+	d.mu.RUnlock()
 	if !ok {
-		return protocol.MigrateResult{}, fmt.Errorf("process %q not found", params.Name)
+		return protocol.MigrateResult{}, errorf(protocol.CodeNotFound, "process %q not found", name)
 	}
-	if !d.cuda.Available {
+
+	var placed *protocol.Placement
+	if params.AutoGPU {
+		pl, err := d.place(placement.Request{Process: name, NeedMB: memMB, Exclude: fromGPU}, exclusive)
+		if err != nil {
+			return protocol.MigrateResult{}, err
+		}
+		params.GPU = pl.GPU
+		placed = &pl
+	}
+	d.mu.RLock()
+	err := d.checkExclusive(params.GPU, exclusive, p)
+	d.mu.RUnlock()
+	if err != nil {
+		return protocol.MigrateResult{}, err
+	}
+
+	estimate := d.migrateEstimate(memMB)
+	if params.DryRun {
+		res := protocol.MigrateResult{Name: params.Name, FromGPU: fromGPU, ToGPU: params.GPU, DryRun: true, Estimate: estimate}
+		if limit := params.MaxDowntimeMs; limit > 0 && estimate.Ms > limit {
+			res.Warning = fmt.Sprintf("estimated downtime %s exceeds max %s", msDuration(estimate.Ms), msDuration(limit))
+		}
+		return res, nil
+	}
+	if limit := params.MaxDowntimeMs; limit > 0 && estimate.Ms > limit {
+		return protocol.MigrateResult{}, fmt.Errorf("estimated downtime %s exceeds max %s (%s)",
+			msDuration(estimate.Ms), msDuration(limit), estimate.Basis)
+	}
+
+	d.drainInference(name)
+
+	// A process frozen by a draining freeze gets its Resume hook once it
+	// runs again, after the lock is released.
+	var thawed bool
+	defer func() {
+		if thawed {
+			d.resumeDrained(name)
+		}
+	}()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p, ok = d.procs[name]
+	if !ok {
+		return protocol.MigrateResult{}, errorf(protocol.CodeNotFound, "process %q not found", name)
+	}
+	if !d.cuda.Available {
 		return protocol.MigrateResult{}, fmt.Errorf("cuda-checkpoint not available")
 	}
+	if p.params.MPS {
+		return protocol.MigrateResult{}, fmt.Errorf("process %q is an MPS client, which cuda-checkpoint can't checkpoint", name)
+	}
+	if detectTorchrun(p.params.Cmd) != nil {
+		return protocol.MigrateResult{}, fmt.Errorf("process %q is a torchrun job, whose workers can't be migrated", name)
+	}
+	if err := d.checkExclusive(params.GPU, p.params.Exclusive, p); err != nil {
+		return protocol.MigrateResult{}, err
+	}
+	if placed == nil {
+		if err := d.checkGPUBudget(params.GPU, max(p.MemMB, p.params.MemMB), p, name); err != nil {
+			return protocol.MigrateResult{}, err
+		}
+	}
 
-	fromGPU := p.GPU
+	fromGPU = p.GPU
 
-	if p.State == protocol.StateActive {
+	wasActive := p.State == protocol.StateActive
+	if wasActive {
 		if mem := gpu.ProcessGPUMem(p.PID); mem > 0 {
 			p.MemMB = mem
 		}
-		if _, err := d.cuda.Freeze(p.PID); err != nil {
+		if _, err := d.cudaFor(p).Freeze(p.PID); err != nil {
+			d.degradeIf(p, "migrate", protocol.CauseUser, err)
 			return protocol.MigrateResult{}, fmt.Errorf("freeze for migrate: %w", err)
 		}
 		syscall.Kill(p.PID, syscall.SIGSTOP)
 	}
 
 	syscall.Kill(p.PID, syscall.SIGCONT)
-	dur, err := d.cuda.RestoreOnDevice(p.PID, params.GPU)
+	phases, err := d.cudaFor(p).ThawOnDevice(p.PID, params.GPU)
 	if err != nil {
-		return protocol.MigrateResult{}, fmt.Errorf("restore on gpu %d: %w", params.GPU, err)
-	}
-	if _, err := d.cuda.Unlock(p.PID); err != nil {
-		return protocol.MigrateResult{}, fmt.Errorf("unlock after migrate: %w", err)
+		return protocol.MigrateResult{}, d.migrateFailed(p, wasActive, fmt.Errorf("restore on gpu %d: %w", params.GPU, err))
 	}
+	dur := phases.Total()
 
-	p.State = protocol.StateActive
+	now := time.Now()
+	d.endActive(p, now)
+	thawed = !wasActive
 	p.GPU = params.GPU
+	d.resumed(p, now)
 
+	d.setTransition(p, protocol.CauseUser, fmt.Sprintf("migrated from GPU %d", fromGPU))
+
+	detail := fmt.Sprintf("GPU %d → GPU %d", fromGPU, params.GPU)
+	if placed != nil {
+		detail += " (" + placement.Explain(*placed) + ")"
+	}
 	d.metrics.Migrations++
+	d.metrics.MigrateLatency.Observe(dur.Milliseconds())
 	d.emit(protocol.Event{
-		Type:     "migrate",
-		Process:  params.Name,
-		Duration: dur.Milliseconds(),
-		Detail:   fmt.Sprintf("GPU %d → GPU %d", fromGPU, params.GPU),
+		Type:      "migrate",
+		Process:   name,
+		Duration:  dur.Milliseconds(),
+		Detail:    detail,
+		Cause:     protocol.CauseUser,
+		Placement: placed,
+	})
+
+	d.recordOp(protocol.OpRecord{
+		Op: "migrate", Process: name, GPU: params.GPU, Tier: protocol.TierGPU,
+		MemMB: p.MemMB, DurationMs: dur.Milliseconds(), Cause: protocol.CauseUser,
 	})
 
-	d.log.Printf("MIGRATE %s GPU %d → %d %dms", params.Name, fromGPU, params.GPU, dur.Milliseconds())
+	d.log.Printf("MIGRATE %s GPU %d → %d %dms", name, fromGPU, params.GPU, dur.Milliseconds())
 	return protocol.MigrateResult{
-		Name:    params.Name,
-		FromGPU: fromGPU,
-		ToGPU:   params.GPU,
+		Name:     params.Name,
+		FromGPU:  fromGPU,
+		ToGPU:    params.GPU,
+		Estimate: estimate,
 	}, nil
 }
 
-func (d *Daemon) Status() protocol.StatusResult {
+// migrateFailed puts p back after its restore onto another GPU failed with
+// err. A process cuda-checkpoint couldn't bring back is degraded; one still
+// checkpointed goes back to frozen, or is resumed on its old GPU if it was
+// active, and is degraded if that fails too. Caller must hold d.mu.
+func (d *Daemon) migrateFailed(p *Proc, wasActive bool, err error) error {
+	if errors.Is(err, checkpoint.ErrUnrecovered) {
+		d.degradeIf(p, "migrate", protocol.CauseUser, err)
+		return err
+	}
+	if !wasActive {
+		if !p.parked {
+			syscall.Kill(p.PID, syscall.SIGSTOP)
+		}
+		return err
+	}
+	if _, rerr := d.cudaFor(p).Thaw(p.PID); rerr != nil {
+		if !errors.Is(rerr, checkpoint.ErrUnrecovered) {
+			rerr = fmt.Errorf("%w: %w", rerr, checkpoint.ErrUnrecovered)
+		}
+		d.degradeIf(p, "migrate", protocol.CauseUser, rerr)
+		return fmt.Errorf("%w; resuming on gpu %d: %v", err, p.GPU, rerr)
+	}
+	return err
+}
+
+// Status reports GPUs, host memory, and the processes and recent events in
+// params.Namespace, or in every namespace if it is empty.
+func (d *Daemon) Status(params protocol.StatusParams) protocol.StatusResult {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	gpus, _ := gpu.QueryGPUs()
-	totalRAM, freeRAM := gpu.HostMemInfo()
+	gpus, gpuCaps, driver := d.cachedGPUs()
+	totalRAM, freeRAM, apps := d.sampled()
+	for i := range gpus {
+		if h := d.exclusiveHolder(gpus[i].Index, nil); h != nil {
+			gpus[i].ReservedBy = h.Name
+		}
+		gpus[i].ReserveMB = d.gpuReserveMB(gpus[i].Index)
+		if gpus[i].BudgetMB = d.budgetMB(gpus[i]); gpus[i].BudgetMB > 0 {
+			gpus[i].CommittedMB = d.committedMB(gpus[i].Index, nil)
+		}
+	}
 
 	var procs []protocol.ProcessInfo
 	var snapshotsMB int64
 
 	for _, p := range d.procs {
+		if !inNamespace(p.Name, params.Namespace) {
+			continue
+		}
 		if p.State == protocol.StateActive {
-			if mem := gpu.ProcessGPUMem(p.PID); mem > 0 {
+			if mem := procGPUMemIn(p, apps); mem > 0 {
 				p.MemMB = mem
 			}
 		}
 
-		tier := protocol.TierGPU
-		if p.State == protocol.StateFrozen {
-			tier = protocol.TierRAM
-			snapshotsMB += p.MemMB
-		}
-
-		procs = append(procs, protocol.ProcessInfo{
-			Name:    p.Name,
-			PID:     p.PID,
-			State:   p.State,
-			GPU:     p.GPU,
-			MemMB:   p.MemMB,
-			Age:     formatDuration(time.Since(p.Started)),
-			Started: p.Started,
-			Tier:    tier,
-		})
+		info := d.processInfo(p)
+		if info.Tier == protocol.TierRAM || info.Tier == protocol.TierRAMSwapped {
+			snapshotsMB += snapshotMB(p)
+		}
+		procs = append(procs, info)
 	}
 
 	sort.Slice(procs, func(i, j int) bool {
@@ -376,10 +1478,19 @@ func (d *Daemon) Status() protocol.StatusResult {
 		return order[procs[i].State] < order[procs[j].State]
 	})
 
-	recentEvents := d.events
-	if len(recentEvents) > 20 {
-		recentEvents = recentEvents[len(recentEvents)-20:]
+	var recentEvents []protocol.Event
+	for i := len(d.events) - 1; i >= 0 && len(recentEvents) < 20; i-- {
+		e := d.events[i]
+		if e.Process == "" || inNamespace(e.Process, params.Namespace) {
+			recentEvents = append(recentEvents, e)
+		}
 	}
+	for i, j := 0, len(recentEvents)-1; i < j; i, j = i+1, j-1 {
+		recentEvents[i], recentEvents[j] = recentEvents[j], recentEvents[i]
+	}
+
+	margin := d.ramMarginMB(totalRAM)
+	headroom, marginBound := ramHeadroom(d.cfg.RAMBudgetMB, d.snapshotsMB(), freeRAM, margin)
 
 	return protocol.StatusResult{
 		GPUs:      gpus,
@@ -389,194 +1500,743 @@ func (d *Daemon) Status() protocol.StatusResult {
 			HostRAMFreeMB:   freeRAM,
 			HostRAMBudgetMB: d.cfg.RAMBudgetMB,
 			SnapshotsMB:     snapshotsMB,
+			RAMMarginMB:     margin,
+			HeadroomMB:      headroom,
+			MarginBound:     marginBound,
 		},
-		Metrics: d.metrics,
+		Metrics: d.metricsSnapshot(),
 		Events:  recentEvents,
 		Caps: protocol.Capabilities{
-			CUDACheckpoint: d.cuda.Available,
-			DriverVersion:  gpu.DriverVersion(),
+			CUDACheckpoint:       d.cuda.Available,
+			CUDACheckpointBinary: d.cuda.Binary,
+			DriverVersion:        driver,
 		},
+		GPUCaps: gpuCaps,
+		Queued:  d.queuedRuns(params.Namespace),
+		Users:   d.userUsages(),
 	}
 }
 
-func (d *Daemon) Logs(name string, lines int) (protocol.LogsResult, error) {
+// inNamespace reports whether the qualified name belongs to namespace. The
+// empty namespace matches everything.
+func inNamespace(qualified, namespace string) bool {
+	if namespace == "" {
+		return true
+	}
+	ns, _ := protocol.SplitQualifiedName(qualified)
+	return ns == namespace
+}
+
+// processInfo builds the wire view of p. Caller must hold d.mu.
+func (d *Daemon) processInfo(p *Proc) protocol.ProcessInfo {
+	tier := protocol.TierGPU
+	if p.State == protocol.StateFrozen {
+		tier = protocol.TierRAM
+	}
+
+	namespace, name := protocol.SplitQualifiedName(p.Name)
+	info := protocol.ProcessInfo{
+		Namespace: namespace,
+
+		Name:    name,
+		PID:     p.PID,
+		State:   p.State,
+		GPU:     p.GPU,
+		MemMB:   p.MemMB,
+		Age:     formatDuration(time.Since(p.Started)),
+		Started: p.Started,
+		Tier:    tier,
+		Command: p.command,
+		Shell:   p.Shell,
+		Group:   p.params.Group,
+		Parked:  p.parked && p.State == protocol.StateFrozen,
+
+		Restarts:    p.Restarts,
+		Restart:     restartPolicy(p),
+		Freezes:     p.Freezes,
+		SuspendedMs: p.suspended(time.Now()).Milliseconds(),
+		LastChange:  p.lastChange,
+		Liveness:    livenessStatus(p),
+		Notes:       append([]protocol.Note(nil), p.Notes...),
+
+		AppCheckpoint: p.AppCheckpoint,
+
+		OnShutdown:  d.shutdownPolicy(p),
+		Inference:   p.params.Inference,
+		Rendezvous:  detectTorchrun(p.params.Cmd),
+		Exclusive:   p.params.Exclusive,
+		TTY:         p.params.TTY,
+		Wake:        p.params.Wake,
+		Nice:        p.params.Nice,
+		IOClass:     p.params.IOClass,
+		OOMScoreAdj: p.params.OOMScoreAdj,
+		Cgroup:      p.cgroup,
+		CPUs:        p.params.CPUs,
+		HostMemMB:   p.params.HostMemMB,
+
+		GPUMemLimitMB:     p.params.GPUMemLimitMB,
+		GPUMemLimitAction: p.params.GPUMemLimitAction,
+		TimeSlice:         p.params.TimeSlice,
+		MPS:               p.params.MPS,
+
+		MaxRuntimeMs:     p.params.MaxRuntimeMs,
+		MaxRuntimeAction: p.params.MaxRuntimeAction,
+		TTLAfterExitMs:   p.params.TTLAfterExitMs,
+		Deadline:         p.deadline(time.Now()),
+	}
+	if !p.nextRestart.IsZero() {
+		at := p.nextRestart
+		info.NextRestart = &at
+	}
+	if p.State != protocol.StateDead {
+		info.CPUPercent = d.cpu.Percent(p.PID)
+		info.RSSMB = hostMemMB(p)
+	}
+	if p.State == protocol.StateFrozen {
+		info.SwapMB = procfs.SwapMB(p.PID)
+		info.Tier, info.ThawPenaltyMs = swapTier(p.MemMB, info.SwapMB, d.cfg.SwapInMBps)
+		est := d.thawEstimate(p, info.Tier, info.ThawPenaltyMs)
+		info.ThawEstimate = &est
+	}
+	for _, t := range p.thaws {
+		info.RecentThawMs = append(info.RecentThawMs, t.ms)
+	}
+	return info
+}
+
+// Describe returns the full view of a single process.
+func (d *Daemon) Describe(name string) (protocol.ProcessInfo, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	p, ok := d.procs[name]
+	if !ok {
+		return protocol.ProcessInfo{}, errorf(protocol.CodeNotFound, "process %q not found", name)
+	}
+	return d.processInfo(p), nil
+}
+
+// Annotate appends a free-form note to a process, or clears its notes.
+func (d *Daemon) Annotate(params protocol.AnnotateParams) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name := protocol.QualifiedName(params.Namespace, params.Name)
+	p, ok := d.procs[name]
+	if !ok {
+		return errorf(protocol.CodeNotFound, "process %q not found", name)
+	}
+	if params.Clear {
+		p.Notes = nil
+		d.emit(protocol.Event{Type: "annotate", Process: p.Name, Detail: "notes cleared"})
+		return nil
+	}
+	if strings.TrimSpace(params.Note) == "" {
+		return fmt.Errorf("empty note")
+	}
+
+	p.Notes = append(p.Notes, protocol.Note{Time: time.Now(), Text: params.Note})
+	d.emit(protocol.Event{Type: "annotate", Process: p.Name, Detail: params.Note})
+	return nil
+}
+
+// RecordAppCheckpoint notes that a process saved a checkpoint of its own,
+// replacing the one it reported before.
+func (d *Daemon) RecordAppCheckpoint(params protocol.AppCheckpointParams) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name := protocol.QualifiedName(params.Namespace, params.Name)
+	p, ok := d.procs[name]
+	if !ok {
+		return errorf(protocol.CodeNotFound, "process %q not found", name)
+	}
+	if params.Step == 0 && strings.TrimSpace(params.Detail) == "" {
+		return fmt.Errorf("a step or detail is required")
+	}
+
+	p.AppCheckpoint = &protocol.AppCheckpoint{Time: time.Now(), Step: params.Step, Detail: params.Detail}
+	d.emit(protocol.Event{Type: "app-checkpoint", Process: p.Name, Detail: appCheckpointDetail(p.AppCheckpoint)})
+	return nil
+}
+
+// appCheckpointDetail describes a reported checkpoint for events.
+func appCheckpointDetail(c *protocol.AppCheckpoint) string {
+	switch {
+	case c.Step != 0 && c.Detail != "":
+		return fmt.Sprintf("step %d: %s", c.Step, c.Detail)
+	case c.Step != 0:
+		return fmt.Sprintf("step %d", c.Step)
+	}
+	return c.Detail
+}
+
+// Logs returns the last params.Lines lines of a process's log that pass
+// the Grep filter. The file is streamed rather than read whole, so
+// filtering a multi-gigabyte log holds only the kept lines in memory.
+func (d *Daemon) Logs(name string, params protocol.LogsParams) (protocol.LogsResult, error) {
+	res, _, err := d.logs(name, params)
+	return res, err
+}
+
+// logs is Logs, also returning how far into the current log file it read,
+// for FollowLogs to carry on from.
+func (d *Daemon) logs(name string, params protocol.LogsParams) (protocol.LogsResult, int64, error) {
 	d.mu.RLock()
 	p, ok := d.procs[name]
 	d.mu.RUnlock()
 
 	if !ok {
-		return protocol.LogsResult{}, fmt.Errorf("process %q not found", name)
+		return protocol.LogsResult{}, 0, errorf(protocol.CodeNotFound, "process %q not found", name)
 	}
 
-	data, err := os.ReadFile(p.LogPath)
+	grep, highlight, err := logFilters(params)
 	if err != nil {
-		return protocol.LogsResult{}, fmt.Errorf("reading logs: %w", err)
+		return protocol.LogsResult{}, 0, err
+	}
+
+	logs, cur, closeLogs, err := openLogs(p.LogPath)
+	if err != nil {
+		return protocol.LogsResult{}, 0, fmt.Errorf("reading logs: %w", err)
+	}
+	defer closeLogs()
+
+	// kept is a ring of the last params.Lines matching lines; next is the
+	// slot the following line goes in once the ring is full.
+	var kept []string
+	next := 0
+	r := bufio.NewReader(logs)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(line, "\n")
+			if grep == nil || grep.MatchString(line) != params.Invert {
+				if params.Lines <= 0 || len(kept) < params.Lines {
+					kept = append(kept, line)
+				} else {
+					kept[next] = line
+					next = (next + 1) % len(kept)
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return protocol.LogsResult{}, 0, fmt.Errorf("reading logs: %w", err)
+		}
+	}
+	kept = append(kept[next:], kept[:next]...)
+	offset, _ := cur.Seek(0, io.SeekCurrent)
+	return logsResult(kept, highlight), offset, nil
+}
+
+func livenessStatus(p *Proc) *protocol.ProbeStatus {
+	if p.params.Liveness == nil || p.liveness.LastCheck.IsZero() {
+		return nil
 	}
+	st := p.liveness
+	return &st
+}
 
-	allLines := splitLines(string(data))
-	if lines > 0 && lines < len(allLines) {
-		allLines = allLines[len(allLines)-lines:]
+// recordOp adds r to the op history, with the request behind it if it
+// was user-caused. Caller must hold d.mu.
+func (d *Daemon) recordOp(r protocol.OpRecord) {
+	if r.Cause == protocol.CauseUser {
+		req := d.requests[r.Process]
+		r.RequestID, r.User = req.id, req.user
+	}
+	if err := d.history.add(r); err != nil {
+		d.log.Printf("WARN: writing op history: %v", err)
 	}
+}
 
-	return protocol.LogsResult{Lines: allLines}, nil
+// OpsHistory returns matching operation records and aggregate throughput.
+func (d *Daemon) OpsHistory(params protocol.OpsHistoryParams) protocol.OpsHistoryResult {
+	return d.history.query(params)
 }
 
-func (d *Daemon) Subscribe() chan protocol.Event {
-	ch := make(chan protocol.Event, 64)
-	d.subMu.Lock()
-	d.subs = append(d.subs, ch)
-	d.subMu.Unlock()
-	return ch
+// setTransition records p's move to its current state. User-caused
+// transitions carry the ID of the request behind them. Caller must hold d.mu.
+func (d *Daemon) setTransition(p *Proc, cause, detail string) {
+	t := &protocol.Transition{Time: time.Now(), State: p.State, Cause: cause, Detail: detail}
+	if cause == protocol.CauseUser {
+		t.RequestID = d.requests[p.Name].id
+	}
+	p.lastChange = t
 }
 
-func (d *Daemon) Unsubscribe(ch chan protocol.Event) {
-	d.subMu.Lock()
-	defer d.subMu.Unlock()
-	for i, s := range d.subs {
-		if s == ch {
-			d.subs = append(d.subs[:i], d.subs[i+1:]...)
-			close(ch)
-			return
-		}
+// causeNote formats a cause and optional detail for logs.
+func causeNote(cause, detail string) string {
+	if detail == "" {
+		return cause
 	}
+	return cause + ": " + detail
 }
 
+func (d *Daemon) cudaFor(p *Proc) *checkpoint.CUDA {
+	if p.cuda != nil {
+		return p.cuda
+	}
+	return d.cuda
+}
+
+// Handle dispatches a request. A request ID, if set, is echoed in the
+// response, logged, and attached to events about the named process.
 func (d *Daemon) Handle(req protocol.Request) protocol.Response {
+	return d.HandleAs(req, nil)
+}
+
+// HandleAs dispatches a request from the user uid, as vouched for by the
+// connection it came in on; nil means unknown.
+func (d *Daemon) HandleAs(req protocol.Request, uid *int) protocol.Response {
 	d.metrics.Requests++
+	if req.ID == "" {
+		return d.handle(req, uid)
+	}
+
+	var target protocol.NameParams
+	json.Unmarshal(req.Params, &target)
+	key := protocol.QualifiedName(target.Namespace, target.Name)
+	call := req.Method
+	if target.Name != "" {
+		call += " " + key
+		d.mu.Lock()
+		d.requests[key] = activeRequest{id: req.ID, user: callerName(uid)}
+		d.mu.Unlock()
+		defer func() {
+			d.mu.Lock()
+			if d.requests[key].id == req.ID {
+				delete(d.requests, key)
+			}
+			d.mu.Unlock()
+		}()
+	}
+
+	resp := d.handle(req, uid)
+	resp.ID = req.ID
+	if resp.OK {
+		d.log.Printf("REQ %s %s ok", req.ID, call)
+	} else {
+		d.log.Printf("REQ %s %s failed: %s", req.ID, call, resp.Error)
+	}
+	return resp
+}
+
+func (d *Daemon) handle(req protocol.Request, uid *int) protocol.Response {
+	if d.cfg.ReadOnly {
+		if err := checkReadOnly(req.Method); err != nil {
+			return errResponse(err)
+		}
+	}
+	if err := d.authorize(req, uid); err != nil {
+		return errResponse(err)
+	}
+	if req.Node != "" && req.Node != d.cfg.NodeName {
+		// The peer sees this daemon's token, not the caller, and this
+		// daemon can't tell who owns the peer's processes.
+		if !readMethods[req.Method] && d.roleOf(uid) != RoleAdmin {
+			return protocol.ErrCodeResponse(protocol.CodePermissionDenied, fmt.Sprintf("permission denied: only admins may send %s to other nodes", req.Method))
+		}
+		return d.relay(req)
+	}
 
 	switch req.Method {
 	case "run":
 		var p protocol.RunParams
 		if err := json.Unmarshal(req.Params, &p); err != nil {
-			return protocol.ErrResponse("bad params: " + err.Error())
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
 		}
+		p.UID = uid
+		runAs, err := d.runAs(uid)
+		if err != nil {
+			return errResponse(err)
+		}
+		p.RunAs = runAs
 		res, err := d.Run(p)
 		if err != nil {
-			return protocol.ErrResponse(err.Error())
+			return errResponse(err)
 		}
 		return protocol.OkResponse(res)
 
 	case "freeze":
+		var p protocol.FreezeParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+		}
+		name := protocol.QualifiedName(p.Namespace, p.Name)
+		var res protocol.FreezeResult
+		var err error
+		if p.Drain {
+			res, err = d.FreezeDrained(name, time.Duration(p.DrainTimeoutMs)*time.Millisecond)
+		} else {
+			res, err = d.Freeze(name)
+		}
+		if err != nil {
+			return errResponse(err)
+		}
+		return protocol.OkResponse(res)
+
+	case "park":
+		var p protocol.NameParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+		}
+		res, err := d.Park(protocol.QualifiedName(p.Namespace, p.Name))
+		if err != nil {
+			return errResponse(err)
+		}
+		return protocol.OkResponse(res)
+
+	case "unpark":
 		var p protocol.NameParams
 		if err := json.Unmarshal(req.Params, &p); err != nil {
-			return protocol.ErrResponse("bad params: " + err.Error())
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
 		}
-		res, err := d.Freeze(p.Name)
+		res, err := d.Unpark(protocol.QualifiedName(p.Namespace, p.Name))
 		if err != nil {
-			return protocol.ErrResponse(err.Error())
+			return errResponse(err)
 		}
 		return protocol.OkResponse(res)
 
 	case "thaw":
 		var p protocol.NameParams
 		if err := json.Unmarshal(req.Params, &p); err != nil {
-			return protocol.ErrResponse("bad params: " + err.Error())
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
 		}
-		res, err := d.Thaw(p.Name)
+		res, err := d.Thaw(protocol.QualifiedName(p.Namespace, p.Name))
 		if err != nil {
-			return protocol.ErrResponse(err.Error())
+			return errResponse(err)
+		}
+		return protocol.OkResponse(res)
+
+	case "recover":
+		var p protocol.NameParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+		}
+		res, err := d.Recover(protocol.QualifiedName(p.Namespace, p.Name))
+		if err != nil {
+			return errResponse(err)
 		}
 		return protocol.OkResponse(res)
 
 	case "kill":
 		var p protocol.NameParams
 		if err := json.Unmarshal(req.Params, &p); err != nil {
-			return protocol.ErrResponse("bad params: " + err.Error())
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+		}
+		if err := d.Kill(protocol.QualifiedName(p.Namespace, p.Name)); err != nil {
+			return errResponse(err)
+		}
+		return protocol.OkResponse("ok")
+
+	case "queue":
+		var p protocol.QueueParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &p); err != nil {
+				return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+			}
+		}
+		return protocol.OkResponse(d.Queue(p))
+
+	case "queue_move":
+		var p protocol.QueueMoveParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+		}
+		if err := d.MoveQueued(protocol.QualifiedName(p.Namespace, p.Name), p.Position); err != nil {
+			return errResponse(err)
+		}
+		return protocol.OkResponse("ok")
+
+	case "apply":
+		var p protocol.ApplyParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+		}
+		for i := range p.Specs {
+			p.Specs[i].UID = uid
+		}
+		res, err := d.Apply(p)
+		if err != nil {
+			return errResponse(err)
+		}
+		return protocol.OkResponse(res)
+	case "queue_remove":
+		var p protocol.NameParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
 		}
-		if err := d.Kill(p.Name); err != nil {
-			return protocol.ErrResponse(err.Error())
+		if err := d.Dequeue(protocol.QualifiedName(p.Namespace, p.Name)); err != nil {
+			return errResponse(err)
 		}
 		return protocol.OkResponse("ok")
 
+	case "group_freeze", "group_thaw", "group_migrate", "group_kill":
+		var p protocol.GroupParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+		}
+		op := map[string]func(protocol.GroupParams) (protocol.GroupResult, error){
+			"group_freeze":  d.GroupFreeze,
+			"group_thaw":    d.GroupThaw,
+			"group_migrate": d.GroupMigrate,
+			"group_kill":    d.GroupKill,
+		}[req.Method]
+		res, err := op(p)
+		if err != nil {
+			// group_kill carries on past failures, so say which went.
+			resp := errResponse(err)
+			if len(res.Processes) > 0 {
+				resp.Result, _ = json.Marshal(res)
+			}
+			return resp
+		}
+		return protocol.OkResponse(res)
+
 	case "migrate":
 		var p protocol.MigrateParams
 		if err := json.Unmarshal(req.Params, &p); err != nil {
-			return protocol.ErrResponse("bad params: " + err.Error())
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
 		}
 		res, err := d.Migrate(p)
 		if err != nil {
-			return protocol.ErrResponse(err.Error())
+			return errResponse(err)
+		}
+		return protocol.OkResponse(res)
+
+	case "plan":
+		var p protocol.PlanParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+		}
+		res, err := d.Plan(p)
+		if err != nil {
+			return errResponse(err)
 		}
 		return protocol.OkResponse(res)
 
 	case "status":
-		return protocol.OkResponse(d.Status())
+		var p protocol.StatusParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &p); err != nil {
+				return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+			}
+		}
+		if p.Cluster {
+			return protocol.OkResponse(d.ClusterStatus(p))
+		}
+		return protocol.OkResponse(d.Status(p))
+
+	case "describe":
+		var p protocol.NameParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+		}
+		res, err := d.Describe(protocol.QualifiedName(p.Namespace, p.Name))
+		if err != nil {
+			return errResponse(err)
+		}
+		return protocol.OkResponse(res)
+
+	case "annotate":
+		var p protocol.AnnotateParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+		}
+		if err := d.Annotate(p); err != nil {
+			return errResponse(err)
+		}
+		return protocol.OkResponse("ok")
+
+	case "app_checkpoint":
+		var p protocol.AppCheckpointParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+		}
+		if err := d.RecordAppCheckpoint(p); err != nil {
+			return errResponse(err)
+		}
+		return protocol.OkResponse("ok")
+
+	case "adopt":
+		var p protocol.AdoptParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+		}
+		res, err := d.Adopt(p)
+		if err != nil {
+			return errResponse(err)
+		}
+		return protocol.OkResponse(res)
 
 	case "logs":
 		var p protocol.LogsParams
 		if err := json.Unmarshal(req.Params, &p); err != nil {
-			return protocol.ErrResponse("bad params: " + err.Error())
+			return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
 		}
 		if p.Lines == 0 {
 			p.Lines = 50
 		}
-		res, err := d.Logs(p.Name, p.Lines)
+		res, err := d.Logs(protocol.QualifiedName(p.Namespace, p.Name), p)
+		if err != nil {
+			return errResponse(err)
+		}
+		return protocol.OkResponse(res)
+
+	case "debug":
+		return protocol.OkResponse(d.Debug())
+
+	case "gc":
+		var p protocol.GCParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &p); err != nil {
+				return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+			}
+		}
+		res, err := d.GC(p)
 		if err != nil {
-			return protocol.ErrResponse(err.Error())
+			return errResponse(err)
 		}
 		return protocol.OkResponse(res)
 
+	case "usage":
+		var p protocol.UsageParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &p); err != nil {
+				return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+			}
+		}
+		res, err := d.Usage(p)
+		if err != nil {
+			return errResponse(err)
+		}
+		return protocol.OkResponse(res)
+
+	case "events":
+		var p protocol.EventsParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &p); err != nil {
+				return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+			}
+		}
+		res, err := d.eventLog.query(p)
+		if err != nil {
+			return errResponse(err)
+		}
+		return protocol.OkResponse(res)
+
+	case "ops_history":
+		var p protocol.OpsHistoryParams
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &p); err != nil {
+				return protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error())
+			}
+		}
+		return protocol.OkResponse(d.OpsHistory(p))
+
 	default:
-		return protocol.ErrResponse("unknown method: " + req.Method)
+		return protocol.ErrCodeResponse(protocol.CodeInvalid, "unknown method: "+req.Method)
 	}
 }
 
+// Shutdown applies each process's shutdown policy: "leave" processes keep
+// running and are recorded for reattach, "kill" processes get SIGTERM and,
+// after DrainTimeout, SIGKILL.
 func (d *Daemon) Shutdown() {
+	d.doneOnce.Do(func() { close(d.done) })
+
 	d.mu.Lock()
-	defer d.mu.Unlock()
 
-	d.log.Println("shutting down — cleaning up processes")
+	d.log.Println("shutting down — applying shutdown policies")
+	var leave []savedProc
+	var draining []*Proc
 	for name, p := range d.procs {
-		switch p.State {
-		case protocol.StateActive:
-			d.log.Printf("  killing active process %s (pid=%d)", name, p.PID)
-			syscall.Kill(p.PID, syscall.SIGTERM)
-		case protocol.StateFrozen:
-			d.log.Printf("  killing frozen process %s (pid=%d)", name, p.PID)
-			syscall.Kill(p.PID, syscall.SIGCONT)
+		if p.State == protocol.StateDead {
+			continue
+		}
+		d.endActive(p, time.Now())
+		switch d.shutdownPolicy(p) {
+		case protocol.ShutdownLeave:
+			d.log.Printf("  leaving %s process %s running (pid=%d)", p.State, name, p.PID)
+			leave = append(leave, savedProc{
+				Name: name, PID: p.PID, State: p.State, GPU: p.GPU, MemMB: p.MemMB,
+				Started: p.Started, Argv: p.Argv, LogPath: p.LogPath,
+				Restarts: p.Restarts, Notes: p.Notes, Params: p.params,
+				LastChange: p.lastChange, AppCheckpoint: p.AppCheckpoint,
+			})
+		default:
+			d.log.Printf("  killing %s process %s (pid=%d)", p.State, name, p.PID)
+			continueStopped(p)
 			syscall.Kill(p.PID, syscall.SIGTERM)
+			draining = append(draining, p)
 		}
 		if p.logFile != nil {
 			p.logFile.Close()
 		}
 	}
-
-	d.subMu.Lock()
-	for _, ch := range d.subs {
-		close(ch)
+	if err := d.writeState(leave); err != nil {
+		d.log.Printf("WARN: writing state file: %v", err)
 	}
-	d.subs = nil
-	d.subMu.Unlock()
-}
+	d.mu.Unlock()
 
-func (d *Daemon) emit(e protocol.Event) {
-	e.Time = time.Now()
-	d.events = append(d.events, e)
-
-	if len(d.events) > 1000 {
-		d.events = d.events[len(d.events)-500:]
+	timer := time.NewTimer(d.cfg.DrainTimeout)
+	defer timer.Stop()
+drain:
+	for _, p := range draining {
+		select {
+		case <-p.exited:
+		case <-timer.C:
+			break drain
+		}
 	}
-
-	d.subMu.Lock()
-	for _, ch := range d.subs {
+	for _, p := range draining {
 		select {
-		case ch <- e:
+		case <-p.exited:
 		default:
+			d.log.Printf("  %s (pid=%d) still running after %s — SIGKILL", p.Name, p.PID, d.cfg.DrainTimeout)
+			syscall.Kill(p.PID, syscall.SIGKILL)
+		}
+	}
+
+	d.stopMPS(leave)
+	d.closeSubscribers()
+	d.eventLog.close()
+}
+
+func (d *Daemon) emit(e protocol.Event) {
+	if r, ok := d.requests[e.Process]; ok && e.Process != "" {
+		if e.RequestID == "" {
+			e.RequestID = r.id
+		}
+		if e.RequestID == r.id {
+			e.User = r.user
 		}
 	}
-	d.subMu.Unlock()
+	e.Time = time.Now()
+	d.retainEvent(e)
+	d.eventLog.add(e)
+	d.broadcast(e)
+	if releasesGPU[e.Type] {
+		d.kickQueue()
+	}
 }
 
-func (d *Daemon) monitorProcess(name string, cmd *exec.Cmd) {
+func (d *Daemon) monitorProcess(name string, cmd *exec.Cmd, exited chan struct{}) {
 	err := cmd.Wait()
+	close(exited)
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	p, ok := d.procs[name]
 	if !ok {
+		// Killed and removed; a restarted instance keeps the cgroup.
+		d.removeCgroup(name)
 		return
 	}
-	if p.State == protocol.StateDead {
+	if p.Cmd != cmd || p.State == protocol.StateDead {
 		return
 	}
 
@@ -584,14 +2244,22 @@ func (d *Daemon) monitorProcess(name string, cmd *exec.Cmd) {
 	if err != nil {
 		detail = err.Error()
 	}
+	if d.oomKilled(err) {
+		d.reportOOM(p, p.State)
+	}
 
+	d.endActive(p, time.Now())
 	p.State = protocol.StateDead
 	if p.logFile != nil {
 		p.logFile.Close()
 	}
+	p.exitedAt = time.Now()
+	d.cpu.Forget(p.PID)
+	d.removeCgroup(name)
 
 	d.emit(protocol.Event{Type: "exit", Process: name, Detail: detail})
 	d.log.Printf("EXIT %s pid=%d: %s", name, p.PID, detail)
+	d.scheduleRestart(p, err != nil, detail)
 }
 
 func formatDuration(d time.Duration) string {
@@ -609,45 +2277,99 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%dd%dh", days, hours)
 }
 
-func appendPythonPath(env []string) []string {
-	var extraPaths []string
-	entries, _ := os.ReadDir("/home")
-	for _, e := range entries {
-		if !e.IsDir() {
-			continue
-		}
-		globPattern := "/home/" + e.Name() + "/.local/lib/python*/site-packages"
-		matches, _ := filepath.Glob(globPattern)
-		extraPaths = append(extraPaths, matches...)
+func toProtocolPhases(phases checkpoint.Phases) []protocol.Phase {
+	out := make([]protocol.Phase, len(phases))
+	for i, ph := range phases {
+		out[i] = protocol.Phase{Name: ph.Name, DurationMs: ph.Duration.Milliseconds()}
 	}
-	if len(extraPaths) == 0 {
-		return env
+	return out
+}
+
+// gpuFreeDelta pairs a GPU's free memory read before a freeze or thaw
+// (err being that read's error) with a fresh reading. It is nil if either
+// read failed.
+func gpuFreeDelta(idx int, before int64, err error) *protocol.GPUMemDelta {
+	if err != nil {
+		return nil
 	}
+	after, err := gpu.FreeMB(idx)
+	if err != nil {
+		return nil
+	}
+	return &protocol.GPUMemDelta{GPU: idx, BeforeMB: before, AfterMB: after}
+}
+
+// gpuFreeNote renders a free-memory change for event details and logs,
+// e.g. "; GPU 0 free 1.2 GiB → 24.7 GiB (+23.5 GiB)".
+func gpuFreeNote(g *protocol.GPUMemDelta) string {
+	if g == nil {
+		return ""
+	}
+	sign := ""
+	if g.DeltaMB() >= 0 {
+		sign = "+"
+	}
+	return fmt.Sprintf("; GPU %d free %s → %s (%s%s)", g.GPU,
+		bytesize.FormatMB(g.BeforeMB), bytesize.FormatMB(g.AfterMB), sign, bytesize.FormatMB(g.DeltaMB()))
+}
+
+func formatPhases(phases checkpoint.Phases) string {
+	parts := make([]string, len(phases))
+	for i, ph := range phases {
+		parts[i] = fmt.Sprintf("%s=%dms", ph.Name, ph.Duration.Milliseconds())
+	}
+	return strings.Join(parts, " ")
+}
 
-	extra := strings.Join(extraPaths, ":")
-	for i, e := range env {
-		if strings.HasPrefix(e, "PYTHONPATH=") {
-			env[i] = e + ":" + extra
-			return env
+// buildArgv resolves the argv to exec. Shell mode hands the joined command to
+// /bin/sh -c; direct mode optionally expands $VAR / ${VAR} in each argument
+// against the managed process environment, without any word splitting.
+func buildArgv(params protocol.RunParams, env []string) []string {
+	if params.Shell {
+		return []string{"/bin/sh", "-c", strings.Join(params.Cmd, " ")}
+	}
+	argv := append([]string(nil), params.Cmd...)
+	if !params.ExpandEnv {
+		return argv
+	}
+	lookup := func(key string) string {
+		for i := len(env) - 1; i >= 0; i-- {
+			if k, v, ok := strings.Cut(env[i], "="); ok && k == key {
+				return v
+			}
 		}
+		return ""
+	}
+	for i, a := range argv {
+		argv[i] = os.Expand(a, lookup)
 	}
-	return append(env, "PYTHONPATH="+extra)
+	return argv
 }
 
-func splitLines(s string) []string {
+// quoteArgv renders argv as a POSIX shell command line that parses back to
+// the same arguments.
+func quoteArgv(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, a := range argv {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func shellQuote(s string) string {
 	if s == "" {
-		return nil
+		return "''"
 	}
-	var lines []string
-	start := 0
-	for i := 0; i < len(s); i++ {
-		if s[i] == '\n' {
-			lines = append(lines, s[start:i])
-			start = i + 1
+	safe := true
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			strings.ContainsRune("@%+=:,./-_", r)) {
+			safe = false
+			break
 		}
 	}
-	if start < len(s) {
-		lines = append(lines, s[start:])
+	if safe {
+		return s
 	}
-	return lines
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
@@ -1,23 +1,62 @@
 package daemon
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
 
+	"gpusched/api/gpuschedpb"
+	"gpusched/internal/audit"
+	"gpusched/internal/checkpoint"
+	"gpusched/internal/client"
+	"gpusched/internal/placement"
+	"gpusched/internal/procfs"
 	"gpusched/internal/protocol"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 func tempDaemon(t *testing.T) *Daemon {
 	t.Helper()
 	dir := t.TempDir()
-	return New(Config{
+	return newTestDaemon(t, Config{
 		LogDir:      dir + "/logs",
 		RAMBudgetMB: 8192,
 	})
 }
 
+// newTestDaemon is New, with the event log closed before the test's
+// temporary directories are removed.
+func newTestDaemon(t *testing.T, cfg Config) *Daemon {
+	d := New(cfg)
+	t.Cleanup(d.eventLog.close)
+	return d
+}
+
 func TestNewDaemon(t *testing.T) {
 	d := tempDaemon(t)
 	if d == nil {
@@ -49,6 +88,90 @@ func TestRunDuplicateName(t *testing.T) {
 	d.Kill("test")
 }
 
+func TestRunSameNameInNamespaces(t *testing.T) {
+	d := tempDaemon(t)
+	for _, ns := range []string{"team-a", "team-b"} {
+		if _, err := d.Run(protocol.RunParams{Namespace: ns, Name: "train", Cmd: []string{"sleep", "3600"}}); err != nil {
+			t.Fatalf("run in %s: %v", ns, err)
+		}
+	}
+	defer d.Kill("team-a/train")
+	defer d.Kill("team-b/train")
+
+	s := d.Status(protocol.StatusParams{Namespace: "team-a"})
+	if len(s.Processes) != 1 || s.Processes[0].Namespace != "team-a" || s.Processes[0].Name != "train" {
+		t.Fatalf("team-a status = %+v", s.Processes)
+	}
+	if all := d.Status(protocol.StatusParams{}); len(all.Processes) != 2 {
+		t.Fatalf("all namespaces: got %d processes, want 2", len(all.Processes))
+	}
+
+	resp := d.Handle(protocol.Request{Method: "kill", Params: []byte(`{"namespace":"team-b","name":"train"}`)})
+	if !resp.OK {
+		t.Fatalf("kill team-b/train: %s", resp.Error)
+	}
+	if _, err := d.Describe("team-a/train"); err != nil {
+		t.Fatalf("team-a/train should survive: %v", err)
+	}
+}
+
+func TestRunRejectsSlashInName(t *testing.T) {
+	d := tempDaemon(t)
+	if _, err := d.Run(protocol.RunParams{Name: "a/b", Cmd: []string{"true"}}); err == nil {
+		t.Fatal("expected error for '/' in name")
+	}
+	for _, params := range []protocol.RunParams{
+		{Name: ".."},
+		{Name: "."},
+		{Namespace: "..", Name: "train"},
+		{Name: "train", Group: ".."},
+	} {
+		params.Cmd = []string{"true"}
+		if _, err := d.Run(params); err == nil || !strings.Contains(err.Error(), "must not be") {
+			t.Fatalf("expected %+v to be refused, got %v", params, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(d.cfg.LogDir, "..", "train.log")); err == nil {
+		t.Fatal("a log was written outside the log directory")
+	}
+}
+
+func TestRequestIDCorrelation(t *testing.T) {
+	d := tempDaemon(t)
+	bin := filepath.Join(t.TempDir(), "cuda-checkpoint")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d.cuda = checkpoint.NewCUDAAt(bin)
+	resp := d.Handle(protocol.Request{ID: "r1", Method: "run", Params: []byte(`{"name":"job","cmd":["sleep","3600"]}`)})
+	if !resp.OK || resp.ID != "r1" {
+		t.Fatalf("run: ok=%v id=%q err=%s", resp.OK, resp.ID, resp.Error)
+	}
+	defer d.Kill("job")
+
+	if e := d.events[len(d.events)-1]; e.Type != "run" || e.RequestID != "r1" {
+		t.Fatalf("expected run event tagged r1, got %+v", e)
+	}
+	if len(d.requests) != 0 {
+		t.Fatalf("in-flight request not cleared: %v", d.requests)
+	}
+
+	resp = d.Handle(protocol.Request{ID: "r2", Method: "freeze", Params: []byte(`{"name":"missing"}`)})
+	if resp.OK || resp.ID != "r2" {
+		t.Fatalf("expected failed response with id r2, got %+v", resp)
+	}
+
+	// The op history says which request froze it, and who sent it.
+	uid := os.Getuid()
+	if resp := d.HandleAs(protocol.Request{ID: "r3", Method: "freeze", Params: []byte(`{"name":"job"}`)}, &uid); !resp.OK {
+		t.Fatalf("freeze: %s", resp.Error)
+	}
+	ops := d.OpsHistory(protocol.OpsHistoryParams{Op: "freeze"}).Records
+	if len(ops) != 1 || ops[0].RequestID != "r3" || ops[0].User != userName(uid) {
+		t.Fatalf("expected a freeze op from r3 by %s, got %+v", userName(uid), ops)
+	}
+}
+
 func TestRunAndKill(t *testing.T) {
 	d := tempDaemon(t)
 	result, err := d.Run(protocol.RunParams{Name: "sleeper", Cmd: []string{"sleep", "3600"}})
@@ -62,7 +185,7 @@ func TestRunAndKill(t *testing.T) {
 		t.Fatalf("expected name 'sleeper', got %q", result.Name)
 	}
 
-	status := d.Status()
+	status := d.Status(protocol.StatusParams{})
 	found := false
 	for _, p := range status.Processes {
 		if p.Name == "sleeper" {
@@ -108,7 +231,7 @@ func TestThawNonexistent(t *testing.T) {
 
 func TestStatus(t *testing.T) {
 	d := tempDaemon(t)
-	s := d.Status()
+	s := d.Status(protocol.StatusParams{})
 	if s.Caps.DriverVersion == "" {
 		t.Log("no NVIDIA driver detected (expected in CI)")
 	}
@@ -130,16 +253,307 @@ func TestLogs(t *testing.T) {
 		t.Fatal("log file not created")
 	}
 
-	result, err := d.Logs("echo", 10)
+	result, err := d.Logs("echo", protocol.LogsParams{Lines: 10})
 	if err != nil {
 		t.Fatalf("logs: %v", err)
 	}
 	_ = result
 }
 
+func TestLogRotation(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.LogMaxMB, d.cfg.LogKeep = 1, 2
+	logPath := filepath.Join(d.cfg.LogDir, "train.log")
+	// Stands in for the process's stdout, opened the way spawn opens it.
+	w, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_TRUNC, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	d.procs["train"] = &Proc{Name: "train", PID: 101, State: protocol.StateActive, LogPath: logPath}
+
+	filler := strings.Repeat("x", 1<<20)
+	for gen := 1; gen <= 3; gen++ {
+		fmt.Fprintf(w, "gen %d\n%s\nend %d\n", gen, filler, gen)
+		d.rotateLogs()
+	}
+	fmt.Fprintln(w, "tail")
+	d.rotateLogs()
+
+	if fi, err := os.Stat(logPath); err != nil || fi.Size() != int64(len("tail\n")) {
+		t.Fatalf("current log after rotation: %v, %v", fi.Size(), err)
+	}
+	if _, err := os.Stat(logPath + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("kept more than 2 generations: %v", err)
+	}
+	res, err := d.Logs("train", protocol.LogsParams{Grep: "^(gen|end|tail)"})
+	if err != nil {
+		t.Fatalf("logs: %v", err)
+	}
+	if want := []string{"gen 2", "end 2", "gen 3", "end 3", "tail"}; !reflect.DeepEqual(res.Lines, want) {
+		t.Fatalf("lines = %q, want %q", res.Lines, want)
+	}
+}
+
+func TestStatusUsesSample(t *testing.T) {
+	d := tempDaemon(t)
+	d.smp = hostSample{loaded: true, totalRAM: 65536, freeRAM: 32768, apps: map[int]int64{4242: 1234}}
+	d.procs["train"] = &Proc{Name: "train", PID: 4242, State: protocol.StateActive}
+
+	s := d.Status(protocol.StatusParams{})
+	if s.Memory.HostRAMTotalMB != 65536 || s.Memory.HostRAMFreeMB != 32768 {
+		t.Fatalf("host memory not from sample: %+v", s.Memory)
+	}
+	if len(s.Processes) != 1 || s.Processes[0].MemMB != 1234 {
+		t.Fatalf("process memory not from sample: %+v", s.Processes)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	d := tempDaemon(t)
+	if _, err := d.Run(protocol.RunParams{Name: "repl", Cmd: []string{"sh", "-c", `read line; echo "got $line"; sleep 3600`}, TTY: true}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	defer d.Kill("repl")
+	if _, err := d.Run(protocol.RunParams{Name: "plain", Cmd: []string{"sleep", "3600"}}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	defer d.Kill("plain")
+	if _, err := d.Run(protocol.RunParams{Name: "bad", Cmd: []string{"true"}, TTY: true, OnShutdown: protocol.ShutdownLeave}); err == nil {
+		t.Fatal("expected a tty process with the leave policy to be refused")
+	}
+
+	srv := NewServer(d, "")
+	if err := srv.ListenTCP("127.0.0.1:0", TCPAuth{}); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.tcp.Close()
+	c := client.New(protocol.TCPPrefix + srv.tcp.Addr().String())
+
+	if _, err := c.Attach(protocol.AttachParams{Name: "plain"}); err == nil {
+		t.Fatal("expected attach to a process without a terminal to fail")
+	}
+	a, err := c.Attach(protocol.AttachParams{Name: "repl", Rows: 40, Cols: 120})
+	if err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+	defer a.Close()
+	if _, err := a.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	out := make(chan string)
+	go func() {
+		var seen []byte
+		buf := make([]byte, 1024)
+		for {
+			n, err := a.Read(buf)
+			seen = append(seen, buf[:n]...)
+			if bytes.Contains(seen, []byte("got hello")) || err != nil {
+				out <- string(seen)
+				return
+			}
+		}
+	}()
+	select {
+	case got := <-out:
+		if !strings.Contains(got, "got hello") {
+			t.Fatalf("attached output = %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no output from the attached process within 5s")
+	}
+
+	logs, err := d.Logs("repl", protocol.LogsParams{Lines: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(logs.Lines, "\n"), "got hello") {
+		t.Fatalf("terminal output missing from the log: %q", logs.Lines)
+	}
+}
+
+func TestFollowLogs(t *testing.T) {
+	d := tempDaemon(t)
+	logPath := filepath.Join(d.cfg.LogDir, "train.log")
+	if err := os.WriteFile(logPath, []byte("step 1\nstep 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := &Proc{Name: "train", PID: 101, State: protocol.StateActive, LogPath: logPath}
+	d.procs["train"] = p
+	srv := NewServer(d, "")
+	if err := srv.ListenTCP("127.0.0.1:0", TCPAuth{}); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.tcp.Close()
+
+	c := client.New(protocol.TCPPrefix + srv.tcp.Addr().String())
+	ch, cancel, err := c.FollowLogs(protocol.LogsParams{Name: "train", Lines: 1, Grep: "step"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	next := func() []string {
+		t.Helper()
+		select {
+		case res, ok := <-ch:
+			if !ok {
+				t.Fatal("stream ended early")
+			}
+			return res.Lines
+		case <-time.After(5 * time.Second):
+			t.Fatal("no logs within 5s")
+			return nil
+		}
+	}
+	if got := next(); !reflect.DeepEqual(got, []string{"step 2"}) {
+		t.Fatalf("first batch = %q", got)
+	}
+
+	f, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString("noise\nstep 3\nstep 4 partial")
+	if got := next(); !reflect.DeepEqual(got, []string{"step 3"}) {
+		t.Fatalf("second batch = %q", got)
+	}
+	f.WriteString(" done\n")
+	if got := next(); !reflect.DeepEqual(got, []string{"step 4 partial done"}) {
+		t.Fatalf("third batch = %q", got)
+	}
+
+	d.mu.Lock()
+	p.State = protocol.StateDead
+	d.mu.Unlock()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("unexpected batch after exit")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream didn't end after the process exited")
+	}
+}
+
+func TestLogsGrep(t *testing.T) {
+	d := tempDaemon(t)
+	logPath := filepath.Join(d.cfg.LogDir, "train.log")
+	log := "step 1 loss 2.5\nstep 2 loss 2.1\nwarning: slow\nstep 3 loss 1.9\nerror: nan"
+	if err := os.WriteFile(logPath, []byte(log), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d.procs["train"] = &Proc{Name: "train", PID: 101, State: protocol.StateActive, LogPath: logPath}
+
+	res, err := d.Logs("train", protocol.LogsParams{Lines: 2, Grep: "loss|error"})
+	if err != nil {
+		t.Fatalf("logs: %v", err)
+	}
+	if want := []string{"step 3 loss 1.9", "error: nan"}; !reflect.DeepEqual(res.Lines, want) {
+		t.Fatalf("lines = %q, want %q", res.Lines, want)
+	}
+	if want := [][][2]int{{{7, 11}}, {{0, 5}}}; !reflect.DeepEqual(res.Matches, want) {
+		t.Fatalf("matches = %v, want %v", res.Matches, want)
+	}
+
+	res, err = d.Logs("train", protocol.LogsParams{Grep: "loss", Invert: true})
+	if err != nil {
+		t.Fatalf("logs: %v", err)
+	}
+	if want := []string{"warning: slow", "error: nan"}; !reflect.DeepEqual(res.Lines, want) {
+		t.Fatalf("inverted lines = %q, want %q", res.Lines, want)
+	}
+	if res.Matches != nil {
+		t.Fatalf("inverted grep should not highlight, got %v", res.Matches)
+	}
+
+	if _, err := d.Logs("train", protocol.LogsParams{Grep: "("}); err == nil {
+		t.Fatal("expected error for bad pattern")
+	}
+}
+
+func TestRunStdio(t *testing.T) {
+	d := tempDaemon(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("prompt 1\nprompt 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := d.Run(protocol.RunParams{
+		Name:   "batch",
+		Cmd:    []string{"sh", "-c", "cat; echo done >&2"},
+		Dir:    dir,
+		Input:  "in.txt",
+		Output: "out.txt",
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	d.mu.RLock()
+	exited := d.procs["batch"].exited
+	d.mu.RUnlock()
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("process did not exit")
+	}
+
+	out, _ := os.ReadFile(filepath.Join(dir, "out.txt"))
+	if string(out) != "prompt 1\nprompt 2\n" {
+		t.Fatalf("output = %q", out)
+	}
+	res, err := d.Logs("batch", protocol.LogsParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Lines, []string{"done"}) {
+		t.Fatalf("log should hold only stderr, got %q", res.Lines)
+	}
+
+	if _, err := d.Run(protocol.RunParams{Name: "missing", Cmd: []string{"cat"}, Input: filepath.Join(dir, "nope")}); err == nil {
+		t.Fatal("expected error for missing input")
+	}
+}
+
+func TestRedactCommand(t *testing.T) {
+	d := tempDaemon(t)
+	_, err := d.Run(protocol.RunParams{
+		Name:      "secret",
+		Cmd:       []string{"sh", "-c", "sleep 60", "x", "--api-key", "abcd1234", "DB_PASSWORD=hunter22", "--model=$HF_TOKEN", "--lr", "0.001"},
+		ExpandEnv: true,
+		Env:       &protocol.EnvPolicy{Set: map[string]string{"HF_TOKEN": "hf_topsecret"}},
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	info, err := d.Describe("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"abcd1234", "hunter22", "hf_topsecret"} {
+		if strings.Contains(info.Command, s) {
+			t.Errorf("command %q leaks %q", info.Command, s)
+		}
+	}
+	if !strings.Contains(info.Command, "--lr 0.001") {
+		t.Errorf("command %q lost a harmless flag", info.Command)
+	}
+	d.mu.RLock()
+	argv := d.procs["secret"].Argv
+	for _, e := range d.events {
+		if e.Type == "run" && strings.Contains(e.Detail, "hunter22") {
+			t.Errorf("run event leaks a secret: %s", e.Detail)
+		}
+	}
+	d.mu.RUnlock()
+	if argv[7] != "--model=hf_topsecret" {
+		t.Fatalf("real argv must keep secrets for restarts, got %q", argv)
+	}
+}
+
 func TestLogsNonexistent(t *testing.T) {
 	d := tempDaemon(t)
-	_, err := d.Logs("doesnotexist", 10)
+	_, err := d.Logs("doesnotexist", protocol.LogsParams{Lines: 10})
 	if err == nil {
 		t.Fatal("expected error for nonexistent process")
 	}
@@ -178,3 +592,3076 @@ func TestShutdownKillsProcesses(t *testing.T) {
 		t.Fatal("process still alive after shutdown")
 	}
 }
+
+func TestBuildArgvDirect(t *testing.T) {
+	argv := buildArgv(protocol.RunParams{Cmd: []string{"echo", "$HOME", "a b"}}, nil)
+	if len(argv) != 3 || argv[1] != "$HOME" || argv[2] != "a b" {
+		t.Fatalf("unexpected argv: %q", argv)
+	}
+}
+
+func TestBuildArgvExpandEnv(t *testing.T) {
+	env := []string{"OUT=/tmp/one", "OUT=/tmp/two"}
+	argv := buildArgv(protocol.RunParams{Cmd: []string{"train", "--out=${OUT}/ckpt"}, ExpandEnv: true}, env)
+	if argv[1] != "--out=/tmp/two/ckpt" {
+		t.Fatalf("expected last OUT to win, got %q", argv[1])
+	}
+}
+
+func TestBuildArgvShell(t *testing.T) {
+	argv := buildArgv(protocol.RunParams{Cmd: []string{"python train.py", "| tee out"}, Shell: true}, nil)
+	if len(argv) != 3 || argv[0] != "/bin/sh" || argv[2] != "python train.py | tee out" {
+		t.Fatalf("unexpected shell argv: %q", argv)
+	}
+}
+
+func TestRunShellExpandEnvRefused(t *testing.T) {
+	d := tempDaemon(t)
+	err := d.validateRunParams(protocol.RunParams{Name: "a", Cmd: []string{"echo $HOME"}, Shell: true, ExpandEnv: true})
+	if err == nil || !strings.Contains(err.Error(), "expand-env") {
+		t.Fatalf("expected shell with expand-env to be refused, got %v", err)
+	}
+}
+
+func TestQuoteArgv(t *testing.T) {
+	got := quoteArgv([]string{"sh", "-c", "echo 'hi' && sleep 1", ""})
+	want := `sh -c 'echo '\''hi'\'' && sleep 1' ''`
+	if got != want {
+		t.Fatalf("quoteArgv = %s, want %s", got, want)
+	}
+}
+
+func TestOpsHistoryPersists(t *testing.T) {
+	path := t.TempDir() + "/ops.jsonl"
+	h := openHistory(path, false)
+	h.add(protocol.OpRecord{Op: "freeze", Process: "a", Tier: protocol.TierRAM, MemMB: 1000, DurationMs: 500})
+	h.add(protocol.OpRecord{Op: "freeze", Process: "b", Tier: protocol.TierRAM, MemMB: 3000, DurationMs: 1500})
+	h.add(protocol.OpRecord{Op: "thaw", Process: "a", Tier: protocol.TierRAM, MemMB: 1000, DurationMs: 250})
+
+	res := openHistory(path, false).query(protocol.OpsHistoryParams{Op: "freeze"})
+	if len(res.Records) != 2 {
+		t.Fatalf("expected 2 freeze records after reload, got %d", len(res.Records))
+	}
+	if len(res.Stats) != 1 || res.Stats[0].AvgMBps != 2000 || res.Stats[0].AvgMs != 1000 {
+		t.Fatalf("unexpected stats: %+v", res.Stats)
+	}
+}
+
+func TestOpsHistoryChained(t *testing.T) {
+	path := t.TempDir() + "/ops.jsonl"
+	openHistory(path, false).add(protocol.OpRecord{Op: "freeze", Process: "a"})
+	openHistory(path, true).add(protocol.OpRecord{Op: "thaw", Process: "a"})
+	// A restarted daemon must pick the chain up where it left off.
+	h := openHistory(path, true)
+	h.add(protocol.OpRecord{Op: "freeze", Process: "b"})
+
+	res, err := audit.VerifyFile(path)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if res.Unchained != 1 || res.Chained != 2 || res.Head != h.head {
+		t.Fatalf("unexpected result: %+v (head %s)", res, h.head)
+	}
+	if got := len(openHistory(path, true).query(protocol.OpsHistoryParams{}).Records); got != 3 {
+		t.Fatalf("expected 3 records after reload, got %d", got)
+	}
+}
+
+func TestLivenessRestart(t *testing.T) {
+	d := tempDaemon(t)
+	first, err := d.Run(protocol.RunParams{
+		Name:           "flaky",
+		Cmd:            []string{"sleep", "3600"},
+		Liveness:       &protocol.Probe{Exec: []string{"false"}, PeriodMs: 20, FailureThreshold: 1},
+		LivenessAction: protocol.LivenessRestart,
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	defer d.Kill("flaky")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		d.mu.RLock()
+		p := d.procs["flaky"]
+		restarts, pid := p.Restarts, p.PID
+		d.mu.RUnlock()
+		if restarts > 0 {
+			if pid == first.PID {
+				t.Fatal("restart kept the old pid")
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("process was not restarted after liveness failure")
+}
+
+func TestAnnotate(t *testing.T) {
+	d := tempDaemon(t)
+	_, _ = d.Run(protocol.RunParams{Name: "exp", Cmd: []string{"sleep", "3600"}})
+	defer d.Kill("exp")
+
+	if err := d.Annotate(protocol.AnnotateParams{Name: "exp", Note: "restarted after lr fix"}); err != nil {
+		t.Fatalf("annotate: %v", err)
+	}
+	if err := d.Annotate(protocol.AnnotateParams{Name: "exp", Note: "  "}); err == nil {
+		t.Fatal("expected error for empty note")
+	}
+
+	info, err := d.Describe("exp")
+	if err != nil {
+		t.Fatalf("describe: %v", err)
+	}
+	if len(info.Notes) != 1 || info.Notes[0].Text != "restarted after lr fix" {
+		t.Fatalf("unexpected notes: %+v", info.Notes)
+	}
+
+	d.Annotate(protocol.AnnotateParams{Name: "exp", Clear: true})
+	if info, _ := d.Describe("exp"); len(info.Notes) != 0 {
+		t.Fatal("expected notes cleared")
+	}
+}
+
+func TestAppCheckpoint(t *testing.T) {
+	d := tempDaemon(t)
+	if _, err := d.Run(protocol.RunParams{Namespace: "alice", Name: "train", Cmd: []string{"sleep", "3600"}}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	defer d.Kill("alice/train")
+
+	if err := d.RecordAppCheckpoint(protocol.AppCheckpointParams{Name: "alice/train"}); err == nil {
+		t.Fatal("expected error for a report with no step or detail")
+	}
+	if err := d.RecordAppCheckpoint(protocol.AppCheckpointParams{Name: "bob/train", Step: 1}); err == nil {
+		t.Fatal("expected error for an unknown process")
+	}
+	// A process reports under its qualified GPUSCHED_NAME, with no namespace.
+	if err := d.RecordAppCheckpoint(protocol.AppCheckpointParams{Name: "alice/train", Step: 12000, Detail: "saved"}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	d.mu.Lock()
+	err := d.restart("alice/train", "test")
+	d.mu.Unlock()
+	if err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	info, err := d.Describe("alice/train")
+	if err != nil {
+		t.Fatalf("describe: %v", err)
+	}
+	if c := info.AppCheckpoint; c == nil || c.Step != 12000 || c.Detail != "saved" {
+		t.Fatalf("app checkpoint not kept across restart: %+v", c)
+	}
+	var found bool
+	for _, e := range d.events {
+		found = found || e.Type == "app-checkpoint" && e.Detail == "step 12000: saved"
+	}
+	if !found {
+		t.Fatal("expected an app-checkpoint event")
+	}
+}
+
+func TestRestartPolicy(t *testing.T) {
+	d := tempDaemon(t)
+	if _, err := d.Run(protocol.RunParams{Name: "bad", Cmd: []string{"true"}, Restart: "sometimes"}); err == nil {
+		t.Fatal("expected error for an unknown restart policy")
+	}
+	if _, err := d.Run(protocol.RunParams{Name: "crash", Cmd: []string{"sh", "-c", "exit 3"}, Restart: protocol.RestartOnFailure, RestartMax: 1}); err != nil {
+		t.Fatalf("run crash: %v", err)
+	}
+	if _, err := d.Run(protocol.RunParams{Name: "done", Cmd: []string{"true"}, Restart: protocol.RestartOnFailure}); err != nil {
+		t.Fatalf("run done: %v", err)
+	}
+
+	// crash is relaunched once after the first backoff, then given up on.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		d.mu.RLock()
+		var gaveUp bool
+		for _, e := range d.events {
+			gaveUp = gaveUp || e.Type == "restart-gave-up" && e.Process == "crash"
+		}
+		d.mu.RUnlock()
+		if gaveUp {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for crash to be given up on")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	info, err := d.Describe("crash")
+	if err != nil {
+		t.Fatalf("describe crash: %v", err)
+	}
+	if info.Restarts != 1 || info.Restart != "on-failure:1" || info.NextRestart != nil || info.State != protocol.StateDead {
+		t.Fatalf("crash: restarts=%d restart=%q next=%v state=%s", info.Restarts, info.Restart, info.NextRestart, info.State)
+	}
+
+	// A clean exit isn't a failure.
+	info, err = d.Describe("done")
+	if err != nil {
+		t.Fatalf("describe done: %v", err)
+	}
+	if info.Restarts != 0 || info.NextRestart != nil {
+		t.Fatalf("done: restarts=%d next=%v", info.Restarts, info.NextRestart)
+	}
+
+	if got := restartBackoff(0); got != restartBackoffMin {
+		t.Fatalf("first backoff = %s", got)
+	}
+	if got := restartBackoff(3); got != 8*restartBackoffMin {
+		t.Fatalf("fourth backoff = %s", got)
+	}
+	if got := restartBackoff(100); got != restartBackoffMax {
+		t.Fatalf("backoff not capped: %s", got)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	d := tempDaemon(t)
+	now := time.Now()
+	d.procs["frozen"] = &Proc{Name: "frozen", PID: 101, State: protocol.StateFrozen, Started: now}
+	d.procs["idle"] = &Proc{Name: "idle", PID: 102, State: protocol.StateActive, Started: now.Add(-time.Hour)}
+	d.procs["busy"] = &Proc{Name: "busy", PID: 103, State: protocol.StateActive, Started: now.Add(-time.Hour)}
+
+	apps := map[int]int64{101: 2048, 103: 4096}
+	d.reconcile(apps, now)
+	d.reconcile(apps, now.Add(time.Second))
+
+	if d.metrics.ReconcileWarnings != 2 {
+		t.Fatalf("expected 2 warnings (reported once each), got %d", d.metrics.ReconcileWarnings)
+	}
+	for _, e := range d.events {
+		if e.Process == "busy" {
+			t.Fatalf("unexpected warning for healthy process: %+v", e)
+		}
+	}
+}
+
+func TestIdleProcs(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.IdleFreezeAfter = 10 * time.Minute
+	d.cfg.IdleExemptNamespaces = []string{"alice"}
+	start := time.Now().Add(-time.Hour)
+	d.procs["bob/nb"] = &Proc{Name: "bob/nb", PID: 101, State: protocol.StateActive, Started: start}
+	d.procs["alice/nb"] = &Proc{Name: "alice/nb", PID: 102, State: protocol.StateActive, Started: start}
+	d.procs["train"] = &Proc{Name: "train", PID: 103, State: protocol.StateActive, Started: start}
+	d.procs["cpu-only"] = &Proc{Name: "cpu-only", PID: 104, State: protocol.StateActive, Started: start}
+
+	now := time.Now()
+	got := d.idleProcs(map[int]int{101: 0, 102: 0, 103: 85}, now)
+	if len(got) != 1 || got[0] != "bob/nb" {
+		t.Fatalf("expected only bob/nb idle, got %v", got)
+	}
+
+	// The idle clock restarts after a freeze attempt and on any activity.
+	if got := d.idleProcs(map[int]int{101: 0, 103: 0}, now.Add(time.Minute)); len(got) != 0 {
+		t.Fatalf("expected no idle processes a minute later, got %v", got)
+	}
+}
+
+func TestAutoFreezeCap(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.MaxAutoFreezesPerHour = 2
+	now := time.Now()
+	d.procs["nb"] = &Proc{
+		Name: "nb", PID: 101, State: protocol.StateActive, Started: now.Add(-2 * time.Hour),
+		autoFreezes: []time.Time{now.Add(-90 * time.Minute), now.Add(-30 * time.Minute), now.Add(-time.Minute)},
+	}
+
+	if err := d.autoFreeze("nb", protocol.CauseIdle, "idle"); err != nil {
+		t.Fatalf("autoFreeze: %v", err)
+	}
+	p := d.procs["nb"]
+	if p.State != protocol.StateActive {
+		t.Fatal("capped process should not be frozen")
+	}
+	if len(p.autoFreezes) != 2 {
+		t.Fatalf("expected freezes older than an hour pruned, got %d", len(p.autoFreezes))
+	}
+	if last := d.events[len(d.events)-1]; last.Type != "freeze-capped" {
+		t.Fatalf("expected freeze-capped event, got %+v", last)
+	}
+}
+
+func TestSuspendedTime(t *testing.T) {
+	now := time.Now()
+	p := &Proc{State: protocol.StateFrozen, frozenTotal: time.Minute, frozenAt: now.Add(-30 * time.Second)}
+	if got := p.suspended(now); got != 90*time.Second {
+		t.Fatalf("suspended = %s, want 1m30s", got)
+	}
+	p.State = protocol.StateActive
+	if got := p.suspended(now); got != time.Minute {
+		t.Fatalf("suspended while active = %s, want 1m0s", got)
+	}
+}
+
+func TestShutdownLeaveAndReattach(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{LogDir: dir + "/logs", RAMBudgetMB: 8192}
+
+	d := newTestDaemon(t, cfg)
+	result, err := d.Run(protocol.RunParams{
+		Name:       "survivor",
+		Cmd:        []string{"sleep", "3600"},
+		OnShutdown: protocol.ShutdownLeave,
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	d.Shutdown()
+	if err := syscall.Kill(result.PID, 0); err != nil {
+		t.Fatal("leave-policy process was killed on shutdown")
+	}
+
+	d2 := newTestDaemon(t, cfg)
+	d2.reattach()
+	// Both daemons record the survivor's exit in the event log, which
+	// lives in dir, so wait for them before dir is removed.
+	defer func() {
+		syscall.Kill(result.PID, syscall.SIGKILL)
+		deadline := time.Now().Add(5 * time.Second)
+		for _, dd := range []*Daemon{d, d2} {
+			for time.Now().Before(deadline) {
+				if info, err := dd.Describe("survivor"); err != nil || info.State == protocol.StateDead {
+					break
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+		}
+	}()
+	info, err := d2.Describe("survivor")
+	if err != nil {
+		t.Fatalf("not reattached: %v", err)
+	}
+	if info.PID != result.PID || info.State != protocol.StateActive {
+		t.Fatalf("unexpected reattached process: %+v", info)
+	}
+	if _, err := os.Stat(d2.cfg.StatePath); !os.IsNotExist(err) {
+		t.Fatal("state file should be removed after reattach")
+	}
+}
+
+func TestRecoverOrphans(t *testing.T) {
+	cmd := exec.Command("sleep", "3600")
+	cmd.Env = append(os.Environ(), "GPUSCHED_MANAGED=1", "GPUSCHED_NAME=alice/lost", "CUDA_VISIBLE_DEVICES=1")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	// Still owned by a live daemon (this test process), so not an orphan.
+	owned := exec.Command("sleep", "3600")
+	owned.Env = append(os.Environ(), "GPUSCHED_MANAGED=1", "GPUSCHED_NAME=alice/owned",
+		fmt.Sprintf("GPUSCHED_DAEMON_PID=%d", os.Getpid()))
+	if err := owned.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		owned.Process.Kill()
+		owned.Wait()
+	}()
+
+	d := tempDaemon(t)
+	d.recoverOrphans()
+
+	info, err := d.Describe("alice/lost")
+	if err != nil {
+		t.Fatalf("orphan not recovered: %v", err)
+	}
+	if info.PID != cmd.Process.Pid || info.GPU != 1 || info.State != protocol.StateActive {
+		t.Fatalf("unexpected recovered process: %+v", info)
+	}
+	if _, err := d.Describe("alice/owned"); err == nil {
+		t.Fatal("adopted a process whose daemon is alive")
+	}
+
+	if got := recoveredState(true, 0, ""); got != protocol.StateFrozen {
+		t.Fatalf("stopped without GPU memory: %s", got)
+	}
+	if got := recoveredState(true, 0, "running"); got != protocol.StateActive {
+		t.Fatalf("cuda-checkpoint says running: %s", got)
+	}
+	if got := recoveredState(false, 500, "checkpointed"); got != protocol.StateFrozen {
+		t.Fatalf("cuda-checkpoint says checkpointed: %s", got)
+	}
+}
+
+func TestAdopt(t *testing.T) {
+	cmd := exec.Command("sleep", "3600")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	pid := cmd.Process.Pid
+
+	d := tempDaemon(t)
+	me, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+	apps := map[int]int64{pid: 2048, 999999: 100}
+	res := d.adopt(protocol.AdoptParams{All: true, OwnerMap: map[string]string{me.Username: "lab"}}, apps, map[int]int{pid: 1})
+	if len(res.Adopted) != 1 || len(res.Skipped) != 1 || res.Skipped[0].PID != 999999 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	info := res.Adopted[0]
+	if info.Namespace != "lab" || info.Name != "sleep" || info.GPU != 1 || info.MemMB != 2048 || info.OnShutdown != protocol.ShutdownLeave {
+		t.Fatalf("unexpected adopted process: %+v", info)
+	}
+
+	res = d.adopt(protocol.AdoptParams{PIDs: []int{pid, os.Getpid()}}, apps, nil)
+	if len(res.Adopted) != 0 || len(res.Skipped) != 2 || res.Skipped[0].Reason != "already managed" {
+		t.Fatalf("unexpected second adopt: %+v", res)
+	}
+
+	for argv, want := range map[string]string{
+		"/usr/bin/python3.11 -u train.py --lr 1e-4":              "train",
+		"torchrun --nproc_per_node 4 scripts/finetune.py":        "finetune",
+		"python -m vllm.entrypoints.openai.api_server --port 80": "api_server",
+		"/opt/bin/llama-server -m model.gguf":                    "llama-server",
+		"python -c import torch":                                 "python",
+	} {
+		if got := commandName(strings.Fields(argv)); got != want {
+			t.Errorf("commandName(%q) = %q, want %q", argv, got, want)
+		}
+	}
+}
+
+func TestExclusiveGPU(t *testing.T) {
+	d := tempDaemon(t)
+	run := func(name string, gpu int, exclusive bool) error {
+		_, err := d.Run(protocol.RunParams{Name: name, Cmd: []string{"sleep", "3600"}, GPU: gpu, Exclusive: exclusive})
+		if err == nil {
+			t.Cleanup(func() { d.Kill(name) })
+		}
+		return err
+	}
+
+	if err := run("bench", 0, true); err != nil {
+		t.Fatalf("exclusive run: %v", err)
+	}
+	if err := run("other", 0, false); err == nil || !strings.Contains(err.Error(), "reserved by") {
+		t.Fatalf("run on a reserved GPU: %v", err)
+	}
+	if err := run("other", 1, false); err != nil {
+		t.Fatalf("run on another GPU: %v", err)
+	}
+	if err := run("bench2", 1, true); err == nil || !strings.Contains(err.Error(), "in use by other") {
+		t.Fatalf("exclusive run on a shared GPU: %v", err)
+	}
+
+	// Frozen, the reservation is released; the exclusive process then
+	// can't come back while something else is on its GPU.
+	d.mu.Lock()
+	d.procs["bench"].State = protocol.StateFrozen
+	d.mu.Unlock()
+	if err := run("late", 0, false); err != nil {
+		t.Fatalf("run after exclusive froze: %v", err)
+	}
+	if _, err := d.Thaw("bench"); err == nil || !strings.Contains(err.Error(), "in use by late") {
+		t.Fatalf("thaw onto a shared GPU: %v", err)
+	}
+	d.mu.Lock()
+	d.procs["bench"].State = protocol.StateActive
+	d.mu.Unlock()
+}
+
+func TestSlowSubscriberDisconnected(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.MaxSubscriberDrops = 3
+	ch := d.Subscribe()
+
+	for i := 0; i < subscriberBuffer+3; i++ {
+		d.emit(protocol.Event{Type: "test"})
+	}
+
+	var last protocol.Event
+	n := 0
+	for e := range ch {
+		last = e
+		n++
+	}
+	if last.Type != "disconnect" {
+		t.Fatalf("expected final disconnect event, got %+v", last)
+	}
+	if n != subscriberBuffer {
+		t.Fatalf("expected %d buffered events, got %d", subscriberBuffer, n)
+	}
+	if m := d.metricsSnapshot(); m.EventsDropped != 4 || len(m.Subscribers) != 0 {
+		t.Fatalf("unexpected metrics: dropped=%d subs=%d", m.EventsDropped, len(m.Subscribers))
+	}
+	d.Unsubscribe(ch) // must not double-close
+}
+
+func TestEventRetention(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.EventRingSize = 10
+	d.cfg.EventTypeLimits = map[string]int{"reconcile": 2, "noise": 0}
+
+	d.emit(protocol.Event{Type: "freeze", Process: "keep"})
+	for i := 0; i < 5; i++ {
+		d.emit(protocol.Event{Type: "reconcile"})
+		d.emit(protocol.Event{Type: "noise"})
+	}
+	info := d.Debug().EventRing
+	if info.ByType["reconcile"] != 2 || info.ByType["noise"] != 0 || info.ByType["freeze"] != 1 {
+		t.Fatalf("unexpected retention: %+v", info.ByType)
+	}
+
+	for i := 0; i < 20; i++ {
+		d.emit(protocol.Event{Type: "thaw"})
+	}
+	if len(d.events) != 10 || d.events[0].Type != "thaw" {
+		t.Fatalf("ring not capped at 10: len=%d first=%s", len(d.events), d.events[0].Type)
+	}
+}
+
+func TestStatusStream(t *testing.T) {
+	d := tempDaemon(t)
+	srv := NewServer(d, "")
+	server, client := net.Pipe()
+	defer client.Close()
+
+	req := protocol.Request{Method: "status_stream", Params: []byte(`{"interval_ms":1}`)}
+	go srv.handleStatusStream(server, req, true)
+
+	dec := protocol.NewDecoder(client)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		data, err := dec.Next()
+		if err != nil {
+			t.Fatalf("snapshot %d: %v", i, err)
+		}
+		var resp protocol.Response
+		if err := json.Unmarshal(data, &resp); err != nil || !resp.OK {
+			t.Fatalf("snapshot %d: bad response %s", i, data)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < minStatusStreamInterval {
+		t.Fatalf("interval not clamped: two snapshots in %s", elapsed)
+	}
+}
+
+func TestSubscribeStatusDeltas(t *testing.T) {
+	d := tempDaemon(t)
+	srv := NewServer(d, "")
+	server, client := net.Pipe()
+	defer client.Close()
+
+	req := protocol.Request{Method: "subscribe", Params: []byte(`{"status_deltas":true,"interval_ms":1}`)}
+	go srv.handleSubscribe(server, req, true)
+
+	dec := protocol.NewDecoder(client)
+	data, err := dec.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp protocol.Response
+	var status protocol.StatusResult
+	if err := json.Unmarshal(data, &resp); err != nil || !resp.OK || json.Unmarshal(resp.Result, &status) != nil {
+		t.Fatalf("bad initial status %s", data)
+	}
+
+	if _, err := d.Run(protocol.RunParams{Name: "job", Cmd: []string{"sleep", "60"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Kill("job")
+
+	var sawRun bool
+	for {
+		data, err := dec.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e protocol.Event
+		if err := json.Unmarshal(data, &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Type == "run" {
+			sawRun = true
+			continue
+		}
+		if e.Type != "status-delta" || e.Delta == nil {
+			t.Fatalf("unexpected message %s", data)
+		}
+		e.Delta.Apply(&status)
+		if len(status.Processes) == 1 {
+			break
+		}
+	}
+	if !sawRun || status.Processes[0].Name != "job" {
+		t.Fatalf("run event seen: %v, processes: %+v", sawRun, status.Processes)
+	}
+}
+
+func TestListenTCP(t *testing.T) {
+	d := tempDaemon(t)
+	srv := NewServer(d, "")
+	if err := srv.ListenTCP("127.0.0.1:0", TCPAuth{}); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.tcp.Close()
+
+	c := client.New(protocol.TCPPrefix + srv.tcp.Addr().String())
+	resp, err := c.Call("status", protocol.StatusParams{})
+	if err != nil {
+		t.Fatalf("call over tcp: %v", err)
+	}
+	if !resp.OK {
+		t.Fatalf("status: %s", resp.Error)
+	}
+}
+
+func TestListenTLSToken(t *testing.T) {
+	// Borrow httptest's self-signed localhost certificate.
+	ts := httptest.NewTLSServer(nil)
+	defer ts.Close()
+	roots := ts.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	d := tempDaemon(t)
+	srv := NewServer(d, "")
+	auth := TCPAuth{TLS: &tls.Config{Certificates: ts.TLS.Certificates}, Token: "s3cret"}
+	if err := srv.ListenTCP("127.0.0.1:0", auth); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.tcp.Close()
+	addr := protocol.TLSPrefix + srv.tcp.Addr().String()
+
+	trusted := &tls.Config{RootCAs: roots}
+	if resp, err := client.New(addr).WithTLS(trusted).WithToken("s3cret").Call("status", protocol.StatusParams{}); err != nil || !resp.OK {
+		t.Fatalf("status with token: %+v, %v", resp, err)
+	}
+	resp, err := client.New(addr).WithTLS(trusted).WithToken("wrong").Call("status", protocol.StatusParams{})
+	if err != nil || resp.OK || !strings.Contains(resp.Error, "unauthorized") {
+		t.Fatalf("wrong token: %+v, %v", resp, err)
+	}
+	if _, err := client.New(addr).WithToken("s3cret").Call("status", protocol.StatusParams{}); err == nil {
+		t.Fatal("untrusted certificate accepted")
+	}
+	if _, err := client.New(protocol.TCPPrefix+srv.tcp.Addr().String()).WithToken("s3cret").Call("status", protocol.StatusParams{}); err == nil {
+		t.Fatal("plain TCP accepted by a TLS listener")
+	}
+}
+
+func TestGPUBudget(t *testing.T) {
+	d := newTestDaemon(t, Config{
+		LogDir:        t.TempDir() + "/logs",
+		RAMBudgetMB:   8192,
+		GPUReserveMB:  map[int]int64{0: 2048, AllGPUs: 512},
+		GPUOvercommit: map[int]float64{1: 1.5},
+	})
+	d.procs["a"] = &Proc{Name: "a", GPU: 1, State: protocol.StateActive, MemMB: 10000}
+	d.procs["b"] = &Proc{Name: "b", GPU: 1, State: protocol.StateFrozen, MemMB: 10000}
+	d.procs["dead"] = &Proc{Name: "dead", GPU: 1, State: protocol.StateDead, MemMB: 10000}
+
+	gpus := []protocol.GPUInfo{
+		{Index: 0, MemTotal: 24576, MemFree: 4096},
+		{Index: 1, MemTotal: 16896, MemFree: 6896},
+	}
+	reserved := d.withReserve(gpus)
+	if reserved[0].MemFree != 2048 || reserved[1].MemFree != 6384 {
+		t.Fatalf("free after reserve = %d, %d", reserved[0].MemFree, reserved[1].MemFree)
+	}
+	if gpus[0].MemFree != 4096 {
+		t.Fatal("withReserve modified its input")
+	}
+
+	// GPU 1's budget is 1.5 * (16896 - 512) = 24576, 20000 committed.
+	if got := d.withinBudget(reserved, 4000, nil); len(got) != 2 {
+		t.Fatalf("4000 MB should fit both GPUs, got %+v", got)
+	}
+	if got := d.withinBudget(reserved, 5000, nil); len(got) != 1 || got[0].Index != 0 {
+		t.Fatalf("5000 MB should only fit GPU 0, got %+v", got)
+	}
+	if got := d.withinBudget(reserved, 5000, d.procs["a"]); len(got) != 2 {
+		t.Fatalf("a moving within its own budget should fit, got %+v", got)
+	}
+}
+
+func TestGPUBudgetOnChosenGPU(t *testing.T) {
+	dir := t.TempDir()
+	smi := "#!/bin/sh\ncase \"$1\" in --query-gpu=index,*) echo '0, Fake, 24576, 15360, 9216, 0, GPU-0'; echo '1, Fake, 24576, 4096, 20480, 0, GPU-1' ;; esac\n"
+	if err := os.WriteFile(filepath.Join(dir, "nvidia-smi"), []byte(smi), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+":"+os.Getenv("PATH"))
+	d := tempDaemon(t)
+	d.cfg.GPUReserveMB = map[int]int64{0: 2048}
+	d.cfg.GPUOvercommit = map[int]float64{1: 1}
+	bin := filepath.Join(dir, "cuda-checkpoint")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d.cuda = checkpoint.NewCUDAAt(bin)
+
+	// 8 GiB would leave GPU 0 less than its 2 GiB reserve.
+	if _, err := d.Run(protocol.RunParams{Name: "big", Cmd: []string{"sleep", "60"}, GPU: 0, MemMB: 8192}); err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Fatalf("expected the reserve to refuse the run, got %v", err)
+	}
+	if _, err := d.Run(protocol.RunParams{Name: "small", Cmd: []string{"sleep", "60"}, GPU: 0, MemMB: 4096}); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Kill("small")
+
+	// GPU 1 may only have 24 GiB committed to it.
+	d.procs["held"] = &Proc{Name: "held", GPU: 1, State: protocol.StateFrozen, MemMB: 22528}
+	if _, err := d.Migrate(protocol.MigrateParams{Name: "small", GPU: 1}); err == nil || !strings.Contains(err.Error(), "overcommit") {
+		t.Fatalf("expected the overcommit budget to refuse the migrate, got %v", err)
+	}
+	if info, _ := d.Describe("small"); info.GPU != 0 {
+		t.Fatalf("small moved to GPU %d", info.GPU)
+	}
+	delete(d.procs, "held")
+	if _, err := d.Migrate(protocol.MigrateParams{Name: "small", GPU: 1}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.GPUReserveMB = map[int]int64{0: 1024}
+	d.procs["holder"] = &Proc{Name: "holder", GPU: 1, State: protocol.StateActive, params: protocol.RunParams{Exclusive: true}}
+	gpus := []protocol.GPUInfo{
+		{Index: 0, MemTotal: 24576, MemFree: 9216},
+		{Index: 1, MemTotal: 24576, MemFree: 20480},
+	}
+
+	d.mu.RLock()
+	fixed := d.startable(protocol.RunParams{GPU: 0, MemMB: 8192}, gpus, nil)
+	auto := d.startable(protocol.RunParams{AutoGPU: true, MemMB: 8192}, gpus, nil)
+	pending := d.startable(protocol.RunParams{GPU: 0, MemMB: 4096}, gpus, map[int]int64{0: 4096})
+	d.mu.RUnlock()
+	if len(fixed) != 1 || fixed[0].Index != 0 {
+		t.Fatalf("8 GiB fits GPU 0 after its 1 GiB reserve, got %+v", fixed)
+	}
+	if len(auto) != 1 || auto[0].Index != 0 {
+		t.Fatalf("auto should skip the exclusively held GPU 1, got %+v", auto)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("4 GiB pending leaves 4 GiB, got %+v", pending)
+	}
+	d.mu.RLock()
+	tooBig := d.startable(protocol.RunParams{GPU: 0, MemMB: 9000}, gpus, nil)
+	d.mu.RUnlock()
+	if len(tooBig) != 0 {
+		t.Fatalf("9000 MB should not fit in 8 GiB, got %+v", tooBig)
+	}
+
+	for _, name := range []string{"a", "b", "c"} {
+		d.queue = append(d.queue, &queuedRun{name: "alice/" + name, params: protocol.RunParams{Namespace: "alice", Name: name, MemMB: 1024}})
+	}
+	if err := d.MoveQueued("alice/c", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Dequeue("alice/a"); err != nil {
+		t.Fatal(err)
+	}
+	runs := d.Queue(protocol.QueueParams{Namespace: "alice"}).Runs
+	if len(runs) != 2 || runs[0].Name != "c" || runs[1].Name != "b" || runs[1].Position != 2 {
+		t.Fatalf("queue = %+v", runs)
+	}
+	if err := d.MoveQueued("alice/c", 99); err != nil {
+		t.Fatal(err)
+	}
+	if runs := d.Queue(protocol.QueueParams{}).Runs; runs[1].Name != "c" {
+		t.Fatalf("position past the end should move to last, got %+v", runs)
+	}
+	if _, err := d.Run(protocol.RunParams{Namespace: "alice", Name: "b", Cmd: []string{"true"}}); err == nil {
+		t.Fatal("run should refuse a name that is queued")
+	}
+	if _, err := d.Run(protocol.RunParams{Name: "x", Cmd: []string{"true"}, Queue: true}); err == nil {
+		t.Fatal("queued run without memory should fail")
+	}
+	for want, params := range map[string]protocol.RunParams{
+		"empty command":  {Name: "y", MemMB: 1024},
+		"restart policy": {Name: "y", Cmd: []string{"true"}, MemMB: 1024, Restart: "sometimes"},
+	} {
+		params.Queue = true
+		if _, err := d.Run(params); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("queued run %+v: expected %q before it is queued, got %v", params, want, err)
+		}
+	}
+	if d.queued("y") >= 0 {
+		t.Fatal("an invalid run was queued")
+	}
+}
+
+func TestClusterStatus(t *testing.T) {
+	peer := tempDaemon(t)
+	peer.procs["bob/train"] = &Proc{Name: "bob/train", PID: 101, State: protocol.StateActive, Started: time.Now()}
+	srv := NewServer(peer, "")
+	if err := srv.ListenTCP("127.0.0.1:0", TCPAuth{}); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.tcp.Close()
+
+	d := newTestDaemon(t, Config{
+		LogDir:      t.TempDir() + "/logs",
+		RAMBudgetMB: 8192,
+		NodeName:    "a",
+		Peers: map[string]string{
+			"b":    protocol.TCPPrefix + srv.tcp.Addr().String(),
+			"down": protocol.TCPPrefix + "127.0.0.1:1",
+		},
+	})
+
+	s := d.ClusterStatus(protocol.StatusParams{})
+	if len(s.Nodes) != 3 || s.Nodes[0].Name != "a" || s.Nodes[1].Name != "b" || s.Nodes[2].Name != "down" {
+		t.Fatalf("nodes = %+v", s.Nodes)
+	}
+	if s.Nodes[1].Error != "" || s.Nodes[2].Error == "" {
+		t.Fatalf("expected only the down peer to fail: %+v", s.Nodes)
+	}
+	if len(s.Processes) != 1 || s.Processes[0].Node != "b" || s.Processes[0].Name != "train" {
+		t.Fatalf("processes = %+v", s.Processes)
+	}
+
+	resp := d.Handle(protocol.Request{Method: "describe", Node: "b", Params: []byte(`{"namespace":"bob","name":"train"}`)})
+	if !resp.OK {
+		t.Fatalf("relayed describe: %s", resp.Error)
+	}
+	if resp := d.Handle(protocol.Request{Method: "status", Node: "nope"}); resp.OK || !strings.Contains(resp.Error, "unknown node") {
+		t.Fatalf("expected unknown node error, got %+v", resp)
+	}
+}
+
+func TestCommandNotifications(t *testing.T) {
+	d := tempDaemon(t)
+	srv := NewServer(d, "")
+	server, client := net.Pipe()
+	defer client.Close()
+	srv.wg.Add(1)
+	go srv.handleConn(server, TCPAuth{})
+
+	dec := protocol.NewDecoder(client)
+	call := func(method, params string) {
+		t.Helper()
+		req := protocol.Request{ID: method, Method: method, Params: []byte(params), Framing: protocol.FramingLength}
+		if err := protocol.WriteMessage(client, req, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func() protocol.Response {
+		t.Helper()
+		data, err := dec.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp protocol.Response
+		if err := json.Unmarshal(data, &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	call("notify", `{"namespace":"alice"}`)
+	if resp := read(); !resp.OK || resp.ID != "notify" {
+		t.Fatalf("notify: %+v", resp)
+	}
+
+	d.emit(protocol.Event{Type: "run", Process: "alice/a"})
+	d.emit(protocol.Event{Type: "oom-killed", Process: "bob/b"})
+	d.emit(protocol.Event{Type: "oom-killed", Process: "alice/a", Detail: "evicted"})
+	call("status", "")
+
+	var gotNote, gotStatus bool
+	for !gotNote || !gotStatus {
+		resp := read()
+		switch {
+		case resp.Notification != nil:
+			if gotNote || resp.Notification.Process != "alice/a" || resp.Notification.Type != "oom-killed" {
+				t.Fatalf("unexpected notification %+v", resp.Notification)
+			}
+			gotNote = true
+		case resp.ID == "status":
+			if !resp.OK {
+				t.Fatalf("status: %s", resp.Error)
+			}
+			gotStatus = true
+		default:
+			t.Fatalf("unexpected message %+v", resp)
+		}
+	}
+
+	call("notify", "")
+	if resp := read(); resp.OK {
+		t.Fatal("second notify should fail")
+	}
+}
+
+func TestGRPCAPI(t *testing.T) {
+	d := tempDaemon(t)
+	ln := bufconn.Listen(1 << 20)
+	gs := d.GRPCServer("secret", false)
+	go gs.Serve(ln)
+	defer gs.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	api := gpuschedpb.NewGpuschedClient(conn)
+	if _, err := api.Status(context.Background(), &gpuschedpb.StatusRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("status without the token: %v", err)
+	}
+	wrong := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer wrong")
+	if _, err := api.Status(wrong, &gpuschedpb.StatusRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("status with the wrong token: %v", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+
+	run, err := api.Run(ctx, &gpuschedpb.RunRequest{Namespace: "alice", Name: "sleeper", Cmd: []string{"sleep", "3600"}})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	defer d.Kill("alice/sleeper")
+	if run.Name != "sleeper" || run.Pid == 0 {
+		t.Fatalf("unexpected run response %+v", run)
+	}
+
+	st, err := api.Status(ctx, &gpuschedpb.StatusRequest{Namespace: "alice"})
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(st.Processes) != 1 || st.Processes[0].Pid != run.Pid || st.Processes[0].State != "active" {
+		t.Fatalf("unexpected status %+v", st.Processes)
+	}
+
+	_, err = api.Freeze(ctx, &gpuschedpb.NameRequest{Name: "ghost"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("freeze of missing process: %v", err)
+	}
+	_, err = api.Run(ctx, &gpuschedpb.RunRequest{Namespace: "alice", Name: "sleeper", Cmd: []string{"true"}})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("duplicate run: %v", err)
+	}
+	_, err = api.Run(ctx, &gpuschedpb.RunRequest{Namespace: "alice", Name: "other", Cmd: []string{"true"}, Shell: true, ExpandEnv: true})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("refused run: %v", err)
+	}
+
+	// With roles, gRPC callers carry no identity and are read-only.
+	d.cfg.Roles = map[int]string{1001: RoleAdmin}
+	_, err = api.Freeze(ctx, &gpuschedpb.NameRequest{Namespace: "alice", Name: "sleeper"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("freeze by a read-only caller: %v", err)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	wrapped := fmt.Errorf("group %q: %w", "g", errorf(protocol.CodeNotFound, "process %q is gone", "a"))
+	if resp := errResponse(wrapped); resp.Code != protocol.CodeNotFound || resp.Error != wrapped.Error() {
+		t.Fatalf("wrapped error lost its kind: %+v", resp)
+	}
+	if resp := errResponse(fmt.Errorf("permission denied: not found")); resp.Code != "" {
+		t.Fatalf("plain error was classified by its wording: %+v", resp)
+	}
+	for code, want := range map[string]codes.Code{
+		protocol.CodeNotFound:         codes.NotFound,
+		protocol.CodePermissionDenied: codes.PermissionDenied,
+		protocol.CodeUnauthenticated:  codes.Unauthenticated,
+		"":                            codes.FailedPrecondition,
+	} {
+		if got := grpcCode(code); got != want {
+			t.Fatalf("grpcCode(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestHTTPAPI(t *testing.T) {
+	d := tempDaemon(t)
+	srv := httptest.NewServer(d.HTTPHandler("", false))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/v1/processes?namespace=alice", "application/json",
+		strings.NewReader(`{"name":"sleeper","cmd":["sleep","3600"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("run: %s", resp.Status)
+	}
+	defer d.Kill("alice/sleeper")
+
+	resp, err = http.Get(srv.URL + "/v1/processes?namespace=alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var procs []protocol.ProcessInfo
+	json.NewDecoder(resp.Body).Decode(&procs)
+	resp.Body.Close()
+	if len(procs) != 1 || procs[0].Name != "sleeper" || procs[0].State != protocol.StateActive {
+		t.Fatalf("unexpected processes %+v", procs)
+	}
+
+	req, _ := http.NewRequest("POST", srv.URL+"/v1/processes/ghost/freeze", nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-Id", "r1")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || resp.Header.Get("X-Request-Id") != "r1" {
+		t.Fatalf("freeze of missing process: %s id=%q", resp.Status, resp.Header.Get("X-Request-Id"))
+	}
+
+	// What a web page could send: a simple cross-site POST, one with the
+	// page's Origin, and one through a rebound DNS name.
+	refused := []struct {
+		contentType, origin, host string
+		status                    int
+	}{
+		{"text/plain", "", "", http.StatusUnsupportedMediaType},
+		{"application/json", "https://evil.example", "", http.StatusForbidden},
+		{"application/json", "", "evil.example", http.StatusForbidden},
+	}
+	for _, c := range refused {
+		req, _ := http.NewRequest("POST", srv.URL+"/v1/processes", strings.NewReader(`{"name":"pwned","cmd":["true"]}`))
+		req.Header.Set("Content-Type", c.contentType)
+		if c.origin != "" {
+			req.Header.Set("Origin", c.origin)
+		}
+		if c.host != "" {
+			req.Host = c.host
+		}
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.status {
+			t.Fatalf("%+v: %s, want %d", c, resp.Status, c.status)
+		}
+	}
+	if _, err := d.Describe("pwned"); err == nil {
+		t.Fatal("a refused request ran a process")
+	}
+
+	resp, err = http.Get(srv.URL + "/v1/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths      map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	json.NewDecoder(resp.Body).Decode(&doc)
+	resp.Body.Close()
+	if doc.Paths["/v1/processes/{name}/freeze"]["post"] == nil {
+		t.Fatalf("freeze route missing from schema: %v", doc.Paths)
+	}
+	for _, name := range []string{"RunParams", "ProcessInfo", "Event", "Placement"} {
+		if doc.Components.Schemas[name] == nil {
+			t.Fatalf("schema %s missing", name)
+		}
+	}
+}
+
+func TestHTTPToken(t *testing.T) {
+	d := tempDaemon(t)
+	srv := httptest.NewServer(d.HTTPHandler("secret", false))
+	defer srv.Close()
+	get := func(auth string) int {
+		req, _ := http.NewRequest("GET", srv.URL+"/v1/processes", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	for _, auth := range []string{"", "Bearer wrong", "secret"} {
+		if code := get(auth); code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want 401", auth, code)
+		}
+	}
+	if code := get("Bearer secret"); code != http.StatusOK {
+		t.Fatalf("with the token: status %d", code)
+	}
+}
+
+func TestHTTPEvents(t *testing.T) {
+	d := tempDaemon(t)
+	srv := httptest.NewServer(d.HTTPHandler("", false))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/events?namespace=alice&type=oom-killed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+
+	d.emit(protocol.Event{Type: "run", Process: "alice/a"})
+	d.emit(protocol.Event{Type: "oom-killed", Process: "bob/b"})
+	d.emit(protocol.Event{Type: "oom-killed", Process: "alice/a"})
+
+	buf := make([]byte, 4096)
+	n, err := resp.Body.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(buf[:n])
+	if !strings.HasPrefix(got, "event: oom-killed\ndata: ") || !strings.Contains(got, `"process":"alice/a"`) {
+		t.Fatalf("unexpected stream %q", got)
+	}
+}
+
+func TestWriteMetricsProcessSeries(t *testing.T) {
+	s := protocol.StatusResult{Processes: []protocol.ProcessInfo{
+		{Namespace: "alice", Name: "a", GPU: 0, State: protocol.StateActive, MemMB: 1000},
+		{Namespace: "alice", Name: "b", GPU: 0, State: protocol.StateFrozen, MemMB: 500},
+		{Namespace: "bob", Name: "c", GPU: 1, State: protocol.StateActive, MemMB: 200},
+		{Namespace: "bob", Name: "gone", GPU: 1, State: protocol.StateDead, MemMB: 900},
+	}}
+
+	var buf bytes.Buffer
+	writeMetrics(&buf, s, []string{"owner", "gpu"})
+	out := buf.String()
+	for _, want := range []string{
+		`gpusched_process_gpu_mem_mb{owner="alice",gpu="0"} 1500`,
+		`gpusched_process_gpu_mem_mb{owner="bob",gpu="1"} 200`,
+		`gpusched_processes{state="dead"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "gone") || strings.Contains(out, "name=") {
+		t.Errorf("unexpected series in:\n%s", out)
+	}
+
+	buf.Reset()
+	writeMetrics(&buf, s, nil)
+	if strings.Contains(buf.String(), "gpusched_process_gpu_mem_mb") {
+		t.Error("per-process series should be disabled without labels")
+	}
+}
+
+func TestWriteMetricsHistograms(t *testing.T) {
+	var s protocol.StatusResult
+	for _, ms := range []int64{80, 400, 900, 90000, 200000} {
+		s.Metrics.FreezeLatency.Observe(ms)
+	}
+	s.Metrics.Evictions = 2
+	s.GPUs = []protocol.GPUInfo{{Index: 1, Name: "NVIDIA A10", MemTotal: 24000, MemUsed: 6000}}
+
+	var buf bytes.Buffer
+	writeMetrics(&buf, s, nil)
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE gpusched_freeze_duration_seconds histogram",
+		`gpusched_freeze_duration_seconds_bucket{le="0.1"} 1`,
+		`gpusched_freeze_duration_seconds_bucket{le="0.5"} 2`,
+		`gpusched_freeze_duration_seconds_bucket{le="1"} 3`,
+		`gpusched_freeze_duration_seconds_bucket{le="120"} 4`,
+		`gpusched_freeze_duration_seconds_bucket{le="+Inf"} 5`,
+		"gpusched_freeze_duration_seconds_sum 291.38",
+		"gpusched_freeze_duration_seconds_count 5",
+		`gpusched_thaw_duration_seconds_bucket{le="+Inf"} 0`,
+		"gpusched_evictions_total 2",
+		`gpusched_gpu_mem_used_mb{gpu="1",model="NVIDIA A10"} 6000`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.GPURates = map[string]float64{"H100": 4, "A10": 1, "A100": 2}
+	d.gpuModels = map[int]string{0: "NVIDIA H100 80GB HBM3", 1: "NVIDIA A10"}
+
+	now := time.Now()
+	since := now.Add(-24 * time.Hour)
+	d.usage.add(protocol.UsageRecord{Process: "alice/a", GPU: 0, Model: "NVIDIA H100 80GB HBM3", Start: now.Add(-26 * time.Hour), End: now.Add(-22 * time.Hour)})
+	d.usage.add(protocol.UsageRecord{Process: "bob/b", GPU: 1, Model: "NVIDIA A10", Start: now.Add(-3 * time.Hour), End: now.Add(-2 * time.Hour)})
+	d.procs["alice/live"] = &Proc{Name: "alice/live", GPU: 0, State: protocol.StateActive, activeSince: now.Add(-time.Hour)}
+
+	res, err := d.Usage(protocol.UsageParams{By: "owner", Since: since})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rows) != 2 || res.Rows[0].Key != "alice" || res.Rows[1].Key != "bob" {
+		t.Fatalf("unexpected rows: %+v", res.Rows)
+	}
+	// alice: 2h clipped to the window + 1h still open, all on H100 at $4.
+	if h := res.Rows[0].GPUHours; h < 2.99 || h > 3.01 {
+		t.Fatalf("alice GPU-hours = %.3f, want 3", h)
+	}
+	if c := res.Rows[0].Cost; c < 11.9 || c > 12.1 {
+		t.Fatalf("alice cost = %.2f, want 12", c)
+	}
+	if c := res.Rows[1].Cost; c < 0.99 || c > 1.01 {
+		t.Fatalf("bob cost = %.2f, want 1 (A10, not A100)", c)
+	}
+
+	if _, err := d.Usage(protocol.UsageParams{By: "color"}); err == nil {
+		t.Fatal("expected error for unknown grouping")
+	}
+}
+
+func TestFrozenOOMPolicy(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.FrozenOOMPolicy = OOMPolicyPrefer
+	if _, err := d.Run(protocol.RunParams{Name: "oom", Cmd: []string{"sleep", "3600"}}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	defer d.Kill("oom")
+
+	p := d.procs["oom"]
+	orig, err := procfs.OOMScoreAdj(p.PID)
+	if err != nil {
+		t.Skipf("no oom_score_adj: %v", err)
+	}
+	d.applyFrozenOOM(p)
+	if adj, _ := procfs.OOMScoreAdj(p.PID); adj != 900 {
+		t.Fatalf("oom_score_adj while frozen = %d, want 900", adj)
+	}
+	d.restoreOOM(p)
+	if adj, _ := procfs.OOMScoreAdj(p.PID); adj != orig {
+		t.Fatalf("oom_score_adj after thaw = %d, want %d", adj, orig)
+	}
+
+	if d.oomKilled(nil) {
+		t.Fatal("clean exit reported as OOM kill")
+	}
+}
+
+func TestSwapTier(t *testing.T) {
+	cases := []struct {
+		snapshot, swap int64
+		tier           protocol.Tier
+		penaltyMs      int64
+	}{
+		{snapshot: 8000, swap: 0, tier: protocol.TierRAM},
+		{snapshot: 8000, swap: 400, tier: protocol.TierRAM, penaltyMs: 2000},
+		{snapshot: 8000, swap: 4000, tier: protocol.TierRAMSwapped, penaltyMs: 20000},
+		{snapshot: 0, swap: 100, tier: protocol.TierRAMSwapped, penaltyMs: 500},
+	}
+	for _, c := range cases {
+		tier, penalty := swapTier(c.snapshot, c.swap, 200)
+		if tier != c.tier || penalty != c.penaltyMs {
+			t.Errorf("swapTier(%d, %d) = %s, %dms; want %s, %dms", c.snapshot, c.swap, tier, penalty, c.tier, c.penaltyMs)
+		}
+	}
+}
+
+func spreadPlacer(t *testing.T) placement.Strategy {
+	t.Helper()
+	s, err := placement.New(placement.Spread, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestPlanPlacement(t *testing.T) {
+	gpus := []protocol.GPUInfo{
+		{Index: 0, MemFree: 10_000},
+		{Index: 1, MemFree: 12_000},
+	}
+	now := time.Now()
+	candidates := []planCandidate{
+		{name: "busy", memMB: 20_000, lastBusy: now},
+		{name: "small", memMB: 8_000, lastBusy: now.Add(-time.Hour)},
+		{name: "idle", memMB: 16_000, lastBusy: now.Add(-2 * time.Hour)},
+	}
+
+	res, err := planPlacement(spreadPlacer(t), gpus, candidates, protocol.PlanParams{GPU: 0, AddMB: 30_000}, 64_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// idle doesn't fit on GPU 1 and is frozen; small is migrated there.
+	want := []protocol.PlanStep{
+		{Action: "freeze", Process: "idle", MemMB: 16_000},
+		{Action: "migrate", Process: "small", MemMB: 8_000, ToGPU: 1},
+	}
+	if !res.Fits || len(res.Steps) != len(want) {
+		t.Fatalf("unexpected plan: %+v", res)
+	}
+	for i := range want {
+		if res.Steps[i] != want[i] {
+			t.Fatalf("step %d = %+v, want %+v", i, res.Steps[i], want[i])
+		}
+	}
+
+	// With no RAM for snapshots nothing can be frozen.
+	res, _ = planPlacement(spreadPlacer(t), gpus, candidates, protocol.PlanParams{GPU: 0, AddMB: 30_000}, 0)
+	if res.Fits || res.ShortMB != 12_000 {
+		t.Fatalf("expected 12000MB short without RAM budget, got %+v", res)
+	}
+
+	if _, err := planPlacement(spreadPlacer(t), gpus, nil, protocol.PlanParams{GPU: 3, AddMB: 1}, 0); err == nil {
+		t.Fatal("expected error for unknown GPU")
+	}
+}
+
+func TestInferenceProfile(t *testing.T) {
+	params := protocol.RunParams{Inference: &protocol.InferenceServer{Kind: "vllm"}}
+	if err := applyInferenceProfile(&params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Inference.URL != "http://127.0.0.1:8000" {
+		t.Fatalf("default URL = %q", params.Inference.URL)
+	}
+	if params.Readiness == nil || params.Readiness.HTTP != "http://127.0.0.1:8000/health" {
+		t.Fatalf("unexpected readiness probe: %+v", params.Readiness)
+	}
+	if params.Liveness == nil || params.Liveness.FailureThreshold != inferenceLivenessFailures {
+		t.Fatalf("unexpected liveness probe: %+v", params.Liveness)
+	}
+
+	// Explicit probes are kept.
+	own := &protocol.Probe{TCP: "127.0.0.1:9000"}
+	params = protocol.RunParams{Readiness: own, Inference: &protocol.InferenceServer{Kind: "tgi", URL: "http://gpu1:8080/"}}
+	if err := applyInferenceProfile(&params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Readiness != own || params.Inference.URL != "http://gpu1:8080" {
+		t.Fatalf("unexpected params: %+v %+v", params.Readiness, params.Inference)
+	}
+
+	params = protocol.RunParams{Inference: &protocol.InferenceServer{Kind: "triton"}}
+	if err := applyInferenceProfile(&params); err == nil {
+		t.Fatal("expected error for unknown server kind")
+	}
+}
+
+func TestMarkServing(t *testing.T) {
+	var served, running int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "tgi_request_count %d\ntgi_queue_size 0\ntgi_batch_current_size %d\n", served, running)
+	}))
+	defer srv.Close()
+
+	d := tempDaemon(t)
+	start := time.Now().Add(-time.Hour)
+	p := &Proc{
+		Name: "tgi", PID: 101, State: protocol.StateActive, Started: start, lastBusy: start,
+		params: protocol.RunParams{Inference: &protocol.InferenceServer{Kind: "tgi", URL: srv.URL}},
+	}
+	d.procs["tgi"] = p
+
+	served = 10
+	now := time.Now()
+	d.markServing(now)
+	if !p.lastBusy.Equal(now) {
+		t.Fatal("expected completed requests to mark the server busy")
+	}
+
+	later := now.Add(time.Minute)
+	d.markServing(later)
+	if !p.lastBusy.Equal(now) {
+		t.Fatal("expected no new requests to leave the server idle")
+	}
+
+	running = 1
+	d.markServing(later)
+	if !p.lastBusy.Equal(later) {
+		t.Fatal("expected in-flight requests to mark the server busy")
+	}
+}
+
+func TestDetectTorchrun(t *testing.T) {
+	cases := []struct {
+		cmd  []string
+		want *protocol.Rendezvous
+	}{
+		{[]string{"python", "train.py"}, nil},
+		{
+			[]string{"torchrun", "--standalone", "--nproc_per_node=8", "train.py", "--nnodes", "99"},
+			&protocol.Rendezvous{Standalone: true, NprocPerNode: "8"},
+		},
+		{
+			[]string{"/opt/venv/bin/torchrun", "--nnodes", "2:4", "--nproc-per-node", "4",
+				"--rdzv-backend=c10d", "--rdzv-endpoint", "head:29400", "--rdzv_id", "job7", "train.py"},
+			&protocol.Rendezvous{Nnodes: "2:4", NprocPerNode: "4", Backend: "c10d", Endpoint: "head:29400", ID: "job7"},
+		},
+		{
+			[]string{"cd /work && python -m torch.distributed.run --nnodes=1 train.py"},
+			&protocol.Rendezvous{Nnodes: "1"},
+		},
+	}
+	for _, c := range cases {
+		got := detectTorchrun(c.cmd)
+		if (got == nil) != (c.want == nil) || (got != nil && *got != *c.want) {
+			t.Errorf("detectTorchrun(%q) = %+v, want %+v", c.cmd, got, c.want)
+		}
+	}
+
+	if (&protocol.Rendezvous{Nnodes: "1:1"}).MultiNode() || !(&protocol.Rendezvous{Nnodes: "2"}).MultiNode() {
+		t.Error("unexpected MultiNode result")
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	cfg := Config{LogDir: t.TempDir() + "/logs", RAMBudgetMB: 8192}
+	d := newTestDaemon(t, cfg)
+	d.mu.Lock()
+	d.requests["alice/train"] = activeRequest{id: "r1", user: "alice"}
+	d.emit(protocol.Event{Type: "freeze", Process: "alice/train", Duration: 500})
+	d.emit(protocol.Event{Type: "gpu-added", Detail: "GPU 1"})
+	d.emit(protocol.Event{Type: "thaw", Process: "bob/eval"})
+	d.mu.Unlock()
+	d.eventLog.close()
+
+	// A restarted daemon still has them.
+	d = newTestDaemon(t, cfg)
+	query := func(p protocol.EventsParams) []protocol.Event {
+		t.Helper()
+		params, _ := json.Marshal(p)
+		resp := d.Handle(protocol.Request{Method: "events", Params: params})
+		if !resp.OK {
+			t.Fatalf("events: %s", resp.Error)
+		}
+		var res protocol.EventsResult
+		json.Unmarshal(resp.Result, &res)
+		return res.Events
+	}
+
+	got := query(protocol.EventsParams{Namespace: "alice"})
+	if len(got) != 2 || got[0].Type != "freeze" || got[1].Type != "gpu-added" {
+		t.Fatalf("alice's events = %+v", got)
+	}
+	if got[0].User != "alice" || got[0].RequestID != "r1" {
+		t.Fatalf("freeze event lacks its request: %+v", got[0])
+	}
+	if got := query(protocol.EventsParams{Type: "thaw"}); len(got) != 1 || got[0].Process != "bob/eval" {
+		t.Fatalf("thaw events = %+v", got)
+	}
+	if got := query(protocol.EventsParams{Limit: 1}); len(got) != 1 || got[0].Type != "thaw" {
+		t.Fatalf("last event = %+v", got)
+	}
+	if got := query(protocol.EventsParams{Since: time.Now().Add(time.Minute)}); len(got) != 0 {
+		t.Fatalf("events from the future = %+v", got)
+	}
+}
+
+func TestEventLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	l := newEventLog(path, 0, 2, log.New(io.Discard, "", 0))
+	l.maxBytes = 4096
+	// One event longer than a bufio.Scanner line, then enough to rotate
+	// three times.
+	l.add(protocol.Event{Type: "long", Detail: strings.Repeat("x", 2<<20)})
+	for i := range 300 {
+		l.add(protocol.Event{Type: "tick", Detail: strconv.Itoa(i)})
+		if i%50 == 0 {
+			l.flush()
+		}
+	}
+	l.flush()
+
+	if _, err := os.Stat(rotatedLog(path, 3)); err == nil {
+		t.Fatal("kept more generations than asked")
+	}
+	res, err := l.query(protocol.EventsParams{Type: "tick", Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Events) != 5 || res.Events[4].Detail != "299" {
+		t.Fatalf("last ticks = %+v", res.Events)
+	}
+
+	l = newEventLog(path, 0, 0, log.New(io.Discard, "", 0))
+	l.add(protocol.Event{Type: "long", Detail: strings.Repeat("x", 2<<20)})
+	if res, err := l.query(protocol.EventsParams{Type: "long"}); err != nil || len(res.Events) != 1 {
+		t.Fatalf("long event: %d, %v", len(res.Events), err)
+	}
+}
+
+func TestTransitionCause(t *testing.T) {
+	d := tempDaemon(t)
+	p := &Proc{Name: "nb", State: protocol.StateFrozen, Started: time.Now()}
+	d.procs["nb"] = p
+	d.requests["nb"] = activeRequest{id: "req-1"}
+
+	d.setTransition(p, protocol.CauseUser, "")
+	if p.lastChange.RequestID != "req-1" || p.lastChange.State != protocol.StateFrozen {
+		t.Fatalf("unexpected user transition: %+v", p.lastChange)
+	}
+
+	// A daemon-initiated freeze racing a user request must not take its ID.
+	d.setTransition(p, protocol.CauseIdle, "no GPU utilization for 30m0s")
+	if p.lastChange.RequestID != "" || p.lastChange.Cause != protocol.CauseIdle {
+		t.Fatalf("unexpected idle transition: %+v", p.lastChange)
+	}
+	if info := d.processInfo(p); info.LastChange == nil || info.LastChange.Detail != "no GPU utilization for 30m0s" {
+		t.Fatalf("expected last change in process info, got %+v", info.LastChange)
+	}
+}
+
+func TestMigrateEstimate(t *testing.T) {
+	d := tempDaemon(t)
+	if e := d.migrateEstimate(8000); e.Ms != 0 {
+		t.Fatalf("expected no estimate without history, got %+v", e)
+	}
+
+	d.history.add(protocol.OpRecord{Op: "freeze", MemMB: 4000, DurationMs: 2000})
+	d.history.add(protocol.OpRecord{Op: "thaw", MemMB: 4000, DurationMs: 1000})
+	// 8000 MB at 2000 MB/s freeze + 4000 MB/s thaw.
+	if e := d.migrateEstimate(8000); e.Ms != 6000 {
+		t.Fatalf("freeze+thaw estimate = %+v, want 6000ms", e)
+	}
+
+	d.history.add(protocol.OpRecord{Op: "migrate", MemMB: 1000, DurationMs: 1000})
+	d.history.add(protocol.OpRecord{Op: "migrate", MemMB: 3000, DurationMs: 1000})
+	if e := d.migrateEstimate(8000); e.Ms != 4000 || !strings.Contains(e.Basis, "2 past migrations") {
+		t.Fatalf("migrate estimate = %+v, want 4000ms from 2 migrations", e)
+	}
+
+	// A dry run reports the estimate and warns past the limit without a GPU.
+	d.procs["train"] = &Proc{Name: "train", State: protocol.StateActive, GPU: 0, MemMB: 8000}
+	res, err := d.Migrate(protocol.MigrateParams{Name: "train", GPU: 1, DryRun: true, MaxDowntimeMs: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.DryRun || res.Estimate.Ms != 4000 || res.Warning == "" {
+		t.Fatalf("unexpected dry run result: %+v", res)
+	}
+	if _, err := d.Migrate(protocol.MigrateParams{Name: "train", GPU: 1, MaxDowntimeMs: 1000}); err == nil ||
+		!strings.Contains(err.Error(), "exceeds max") {
+		t.Fatalf("expected downtime refusal, got %v", err)
+	}
+}
+
+func TestRAMHeadroom(t *testing.T) {
+	if h, bound := ramHeadroom(10000, 4000, 50000, 4096); h != 6000 || bound {
+		t.Fatalf("budget-bound headroom = %d, %v", h, bound)
+	}
+	if h, bound := ramHeadroom(10000, 4000, 6000, 4096); h != 1904 || !bound {
+		t.Fatalf("margin-bound headroom = %d, %v", h, bound)
+	}
+	if h, _ := ramHeadroom(10000, 12000, 50000, 4096); h != 0 {
+		t.Fatalf("over-budget headroom = %d, want 0", h)
+	}
+	// Unknown free RAM leaves only the budget.
+	if h, bound := ramHeadroom(10000, 0, 0, 4096); h != 10000 || bound {
+		t.Fatalf("headroom without meminfo = %d, %v", h, bound)
+	}
+
+	d := newTestDaemon(t, Config{LogDir: t.TempDir() + "/logs", RAMBudgetMB: 8192, RAMMarginMB: 1024, RAMMarginPct: 50})
+	if got := d.ramMarginMB(16000); got != 8000 {
+		t.Fatalf("margin = %d, want the 50%% bound", got)
+	}
+	if got := d.ramMarginMB(1000); got != 1024 {
+		t.Fatalf("margin = %d, want the absolute bound", got)
+	}
+	if d := tempDaemon(t); d.cfg.RAMMarginMB != defaultRAMMarginMB {
+		t.Fatalf("default margin = %d", d.cfg.RAMMarginMB)
+	}
+
+	d.procs["a/big"] = &Proc{Name: "a/big", State: protocol.StateFrozen, MemMB: 8000}
+	err := d.checkRAM("a/next", 500)
+	if err == nil || !strings.Contains(err.Error(), "not enough host RAM") {
+		t.Fatalf("expected refusal, got %v", err)
+	}
+	if e := d.events[len(d.events)-1]; e.Type != "ram-budget" && e.Type != "ram-margin" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+}
+
+func TestInventoryChanges(t *testing.T) {
+	a := protocol.GPUInfo{Index: 0, Name: "NVIDIA A100", MemTotal: 81920, UUID: "GPU-a"}
+	b := protocol.GPUInfo{Index: 1, Name: "NVIDIA A100", MemTotal: 81920, UUID: "GPU-b"}
+	c := protocol.GPUInfo{Index: 1, Name: "NVIDIA H100", MemTotal: 81920, UUID: "GPU-c"}
+	caps := map[int]protocol.GPUCapabilities{0: {MIGSupported: true}}
+	mig := map[int]protocol.GPUCapabilities{0: {MIGSupported: true, MIG: true}}
+
+	if got := inventoryChanges([]protocol.GPUInfo{a, b}, []protocol.GPUInfo{a, b}, caps, caps); len(got) != 0 {
+		t.Fatalf("unchanged inventory reported %+v", got)
+	}
+	got := inventoryChanges([]protocol.GPUInfo{a, b}, []protocol.GPUInfo{a, c}, caps, mig)
+	var types []string
+	for _, e := range got {
+		types = append(types, e.Type+": "+e.Detail)
+	}
+	want := []string{
+		"gpu-reconfigured: GPU 0 MIG enabled",
+		"gpu-added: GPU 1 NVIDIA H100 (80 GiB)",
+		"gpu-removed: GPU 1 NVIDIA A100 (80 GiB)",
+	}
+	if strings.Join(types, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("changes:\n%s\nwant:\n%s", strings.Join(types, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestProcessEnv(t *testing.T) {
+	base := []string{"PATH=/usr/bin", "HOME=/root", "AWS_SECRET_ACCESS_KEY=x", "LANG=C"}
+	daemonPolicy := protocol.EnvPolicy{
+		Deny: []string{"AWS_*"},
+		Set:  map[string]string{"HF_HOME": "/data/hf/{{.User}}", "RUN": "{{.Name}}-gpu{{.GPU}}"},
+	}
+	params := protocol.RunParams{Namespace: "alice", Name: "train", GPU: 2}
+
+	env, err := processEnv(base, daemonPolicy, nil, params, "alice/train")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(env, " ")
+	for _, want := range []string{"PATH=/usr/bin", "HF_HOME=/data/hf/alice", "RUN=train-gpu2", "GPUSCHED_NAME=alice/train", "CUDA_VISIBLE_DEVICES=2"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in %s", want, got)
+		}
+	}
+	if strings.Contains(got, "AWS_SECRET") {
+		t.Errorf("denied variable passed: %s", got)
+	}
+
+	// A run narrows inheritance, denies more, and overrides a setting.
+	run := &protocol.EnvPolicy{Inherit: []string{"PATH", "LANG"}, Deny: []string{"LANG"}, Set: map[string]string{"HF_HOME": "/scratch"}}
+	env, err = processEnv(base, daemonPolicy, run, params, "alice/train")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = strings.Join(env, " ")
+	if strings.Contains(got, "HOME=/root") || strings.Contains(got, "LANG=") || !strings.Contains(got, "HF_HOME=/scratch") || !strings.Contains(got, "RUN=train-gpu2") {
+		t.Errorf("unexpected merged env: %s", got)
+	}
+	if len(daemonPolicy.Deny) != 1 || daemonPolicy.Set["HF_HOME"] != "/data/hf/{{.User}}" {
+		t.Errorf("merge modified the daemon policy: %+v", daemonPolicy)
+	}
+
+	// A run's values are not templates.
+	run = &protocol.EnvPolicy{Set: map[string]string{"PS1": "{{ literal }}", "RUN": "{{.Name}}"}}
+	env, err = processEnv(base, daemonPolicy, run, params, "alice/train")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(env, "PS1={{ literal }}") || !slices.Contains(env, "RUN={{.Name}}") || !slices.Contains(env, "HF_HOME=/data/hf/alice") {
+		t.Errorf("expected run values passed literally, got %v", env)
+	}
+
+	if _, err := processEnv(base, protocol.EnvPolicy{Set: map[string]string{"X": "{{.Nope}}"}}, nil, params, "alice/train"); err == nil {
+		t.Error("expected an error for an unknown template field")
+	}
+
+	// A known caller is the user, whatever namespace they run in.
+	root := 0
+	params.UID = &root
+	env, err = processEnv(nil, protocol.EnvPolicy{Set: map[string]string{"WHO": "{{.User}}:{{.Home}}"}}, nil, params, "alice/train")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(env, " "); !strings.Contains(got, "WHO=root:/root") {
+		t.Errorf("expected the caller's user and home, got %v", env)
+	}
+}
+
+func TestProcessEnvDropsToken(t *testing.T) {
+	base := []string{"PATH=/usr/bin", "GPUSCHED_TOKEN=secret"}
+	params := protocol.RunParams{Namespace: "alice", Name: "train"}
+	env, err := processEnv(base, protocol.EnvPolicy{Inherit: []string{"*"}}, nil, params, "alice/train")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "GPUSCHED_TOKEN=") {
+			t.Fatalf("token passed to the process: %v", env)
+		}
+	}
+	if !slices.Contains(env, "PATH=/usr/bin") {
+		t.Errorf("expected PATH to be inherited, got %v", env)
+	}
+	if !slices.Contains(base, "GPUSCHED_TOKEN=secret") {
+		t.Errorf("processEnv modified its base: %v", base)
+	}
+}
+
+func TestThawEstimate(t *testing.T) {
+	d := tempDaemon(t)
+	p := &Proc{Name: "serve", MemMB: 4000}
+	if e := d.thawEstimate(p, protocol.TierRAM, 0); e.Ms != 0 {
+		t.Fatalf("expected no estimate without history, got %+v", e)
+	}
+
+	d.history.add(protocol.OpRecord{Op: "thaw", MemMB: 2000, DurationMs: 1000})
+	if e := d.thawEstimate(p, protocol.TierRAMSwapped, 500); e.Ms != 2500 || !strings.Contains(e.Basis, "host") {
+		t.Fatalf("host estimate = %+v, want 2500ms", e)
+	}
+
+	for _, ms := range []int64{9000, 900, 1000, 1100, 1200, 800} {
+		p.recordThaw(ms, protocol.TierRAM)
+	}
+	if len(p.thaws) != thawWindow || p.thaws[0].ms != 900 {
+		t.Fatalf("window not trimmed: %+v", p.thaws)
+	}
+	if e := d.thawEstimate(p, protocol.TierRAM, 0); e.Ms != 1000 || !strings.Contains(e.Basis, "last 5 thaws") {
+		t.Fatalf("own estimate = %+v, want 1000ms from 5 thaws", e)
+	}
+	if e := d.thawEstimate(p, protocol.TierRAMSwapped, 3000); e.Ms != 4000 {
+		t.Fatalf("swapped estimate = %+v, want RAM average + penalty", e)
+	}
+	p.recordThaw(7000, protocol.TierRAMSwapped)
+	if e := d.thawEstimate(p, protocol.TierRAMSwapped, 3000); e.Ms != 7000 {
+		t.Fatalf("swapped estimate = %+v, want own swapped thaw", e)
+	}
+}
+
+func TestFreezeDegradedAndRecover(t *testing.T) {
+	d := tempDaemon(t)
+	dir := t.TempDir()
+	state := filepath.Join(dir, "state")
+	os.WriteFile(state, []byte("running\n"), 0o644)
+	bin := filepath.Join(dir, "cuda-checkpoint")
+	fake := func(fail string) {
+		script := "#!/bin/sh\nst=" + state + "\n" +
+			`[ "$1" = --get-state ] && exec cat $st` + "\n" +
+			`case " ` + fail + ` " in *" $2 "*) exit 1 ;; esac` + "\n" +
+			`case "$2" in lock) echo locked > $st ;; unlock) echo running > $st ;; esac` + "\n"
+		if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake("checkpoint unlock")
+	d.cuda = checkpoint.NewCUDAAt(bin)
+
+	if _, err := d.Run(protocol.RunParams{Name: "train", Cmd: []string{"sleep", "60"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Freeze("train"); err == nil {
+		t.Fatal("expected freeze to fail")
+	}
+	info, _ := d.Describe("train")
+	if info.State != protocol.StateDegraded {
+		t.Fatalf("state = %s, want degraded", info.State)
+	}
+	if _, err := d.Recover("train"); err == nil {
+		t.Fatal("recover should fail while unlock does")
+	}
+
+	fake("")
+	if _, err := d.Recover("train"); err != nil {
+		t.Fatal(err)
+	}
+	info, _ = d.Describe("train")
+	if info.State != protocol.StateActive {
+		t.Fatalf("state = %s after recover, want active", info.State)
+	}
+}
+
+func TestMigrateRestoreFails(t *testing.T) {
+	d := tempDaemon(t)
+	dir := t.TempDir()
+	state := filepath.Join(dir, "state")
+	os.WriteFile(state, []byte("running\n"), 0o644)
+	bin := filepath.Join(dir, "cuda-checkpoint")
+	fake := func(fail string) {
+		script := "#!/bin/sh\nst=" + state + "\n" +
+			`[ "$1" = --get-state ] && exec cat $st` + "\n" +
+			`case " ` + fail + ` " in *" $2 "*) exit 1 ;; esac` + "\n" +
+			`case "$2" in lock|restore) echo locked > $st ;; checkpoint) echo checkpointed > $st ;; unlock) echo running > $st ;; esac` + "\n"
+		if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake("")
+	d.cuda = checkpoint.NewCUDAAt(bin)
+	if _, err := d.Run(protocol.RunParams{Name: "train", Cmd: []string{"sleep", "60"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Kill("train")
+
+	// A frozen process whose restore fails stays frozen where it was.
+	if _, err := d.Freeze("train"); err != nil {
+		t.Fatal(err)
+	}
+	fake("restore")
+	if _, err := d.Migrate(protocol.MigrateParams{Name: "train", GPU: 1}); err == nil {
+		t.Fatal("expected migrate to fail")
+	}
+	if info, _ := d.Describe("train"); info.State != protocol.StateFrozen || info.GPU != 0 {
+		t.Fatalf("after a failed migrate: %s on GPU %d, want frozen on GPU 0", info.State, info.GPU)
+	}
+
+	// An active one that can't be resumed on its old GPU either is
+	// degraded, and recovers there.
+	fake("")
+	if _, err := d.Thaw("train"); err != nil {
+		t.Fatal(err)
+	}
+	fake("restore")
+	if _, err := d.Migrate(protocol.MigrateParams{Name: "train", GPU: 1}); err == nil {
+		t.Fatal("expected migrate to fail")
+	}
+	if info, _ := d.Describe("train"); info.State != protocol.StateDegraded || info.GPU != 0 {
+		t.Fatalf("after a failed migrate: %s on GPU %d, want degraded on GPU 0", info.State, info.GPU)
+	}
+	fake("")
+	if _, err := d.Recover("train"); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := d.Describe("train"); info.State != protocol.StateActive || info.GPU != 0 {
+		t.Fatalf("after recover: %s on GPU %d, want active on GPU 0", info.State, info.GPU)
+	}
+}
+
+func TestMigrateFrozenResumes(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.FrozenOOMPolicy = OOMPolicyPrefer
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "cuda-checkpoint")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d.cuda = checkpoint.NewCUDAAt(bin)
+	resumed := filepath.Join(dir, "resumed")
+	hooks := &protocol.DrainHooks{Stop: []string{"true"}, Resume: []string{"touch", resumed}}
+	if _, err := d.Run(protocol.RunParams{Name: "srv", Cmd: []string{"sleep", "60"}, DrainHooks: hooks}); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Kill("srv")
+	p := d.procs["srv"]
+	orig, err := procfs.OOMScoreAdj(p.PID)
+	if err != nil {
+		t.Skipf("no oom_score_adj: %v", err)
+	}
+
+	if _, err := d.FreezeDrained("srv", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Migrate(protocol.MigrateParams{Name: "srv", GPU: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if adj, _ := procfs.OOMScoreAdj(p.PID); adj != orig {
+		t.Fatalf("oom_score_adj after migrate = %d, want %d", adj, orig)
+	}
+	d.mu.RLock()
+	frozenAt, suspended := p.frozenAt, p.suspended(time.Now())
+	d.mu.RUnlock()
+	if !frozenAt.IsZero() || suspended <= 0 {
+		t.Fatalf("frozen time not recorded: frozenAt=%v suspended=%v", frozenAt, suspended)
+	}
+	if _, err := os.Stat(resumed); err != nil {
+		t.Fatal("resume hook did not run after migrate")
+	}
+}
+
+func TestMigrateTorchrunRefused(t *testing.T) {
+	d := tempDaemon(t)
+	bin := filepath.Join(t.TempDir(), "cuda-checkpoint")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d.cuda = checkpoint.NewCUDAAt(bin)
+	if _, err := d.Run(protocol.RunParams{Name: "job", Cmd: []string{"sh", "-c", "sleep 60 & wait # torchrun train.py"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Kill("job")
+	if _, err := d.Migrate(protocol.MigrateParams{Name: "job", GPU: 1}); err == nil || !strings.Contains(err.Error(), "torchrun") {
+		t.Fatalf("expected a torchrun job to be refused, got %v", err)
+	}
+	if info, _ := d.Describe("job"); info.GPU != 0 || info.State != protocol.StateActive {
+		t.Fatalf("job after a refused migrate: %s on GPU %d", info.State, info.GPU)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.Quotas = map[int]protocol.Quota{AnyUser: {GPUs: 1, GPUMemMB: 1000}}
+	uid, root := 1000, 0
+	run := func(name string, uid *int, gpu int, memMB int64) error {
+		_, err := d.Run(protocol.RunParams{Name: name, Cmd: []string{"sleep", "60"}, GPU: gpu, MemMB: memMB, UID: uid})
+		return err
+	}
+
+	if err := run("a", &uid, 0, 600); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("b", &uid, 0, 600); err == nil || !strings.Contains(err.Error(), "GPU memory") {
+		t.Fatalf("want a GPU memory quota error, got %v", err)
+	}
+	if err := run("c", &uid, 1, 100); err == nil || !strings.Contains(err.Error(), "GPUs") {
+		t.Fatalf("want a GPU count quota error, got %v", err)
+	}
+	if err := run("d", &root, 1, 5000); err != nil {
+		t.Fatalf("the default quota must not apply to root: %v", err)
+	}
+
+	// A UID sent by the client is not trusted.
+	resp := d.Handle(protocol.Request{Method: "run", Params: json.RawMessage(
+		`{"name":"e","cmd":["sleep","60"],"mem_mb":5000,"uid":1000}`)})
+	if !resp.OK {
+		t.Fatalf("run without peer credentials: %s", resp.Error)
+	}
+
+	d.mu.RLock()
+	users := d.userUsages()
+	d.mu.RUnlock()
+	if len(users) != 2 || users[1].UID != uid || users[1].GPUMemMB != 600 || users[1].Quota == nil {
+		t.Fatalf("unexpected usage: %+v", users)
+	}
+}
+
+func TestPeerUID(t *testing.T) {
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "s.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if c, err := net.Dial("unix", ln.Addr().String()); err == nil {
+			defer c.Close()
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if uid := peerUID(conn); uid == nil || *uid != os.Getuid() {
+		t.Fatalf("peerUID = %v, want %d", uid, os.Getuid())
+	}
+}
+
+func TestGPUFreeNote(t *testing.T) {
+	if got := gpuFreeNote(nil); got != "" {
+		t.Fatalf("nil delta: %q", got)
+	}
+	g := &protocol.GPUMemDelta{GPU: 1, BeforeMB: 1024, AfterMB: 25600}
+	if got, want := gpuFreeNote(g), "; GPU 1 free 1 GiB → 25 GiB (+24 GiB)"; got != want {
+		t.Fatalf("freeze: got %q, want %q", got, want)
+	}
+	g.BeforeMB, g.AfterMB = g.AfterMB, g.BeforeMB
+	if got, want := gpuFreeNote(g), "; GPU 1 free 25 GiB → 1 GiB (-24 GiB)"; got != want {
+		t.Fatalf("thaw: got %q, want %q", got, want)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("running processes as their users needs root")
+	}
+	d := tempDaemon(t)
+	// alice is nobody, a user that exists almost everywhere.
+	alice, bob, viewer := 65534, 1001, 2000
+	d.cfg.Roles = map[int]string{AnyUser: RoleUser, viewer: RoleReadOnly}
+	call := func(uid *int, method, params string) protocol.Response {
+		return d.HandleAs(protocol.Request{Method: method, Params: json.RawMessage(params)}, uid)
+	}
+
+	if resp := call(&alice, "run", `{"name":"train","cmd":["sleep","60"],"run_as":0}`); !resp.OK {
+		t.Fatalf("user run: %s", resp.Error)
+	}
+	if uid, err := procfs.UID(d.procs["train"].PID); err != nil || uid != alice {
+		t.Fatalf("user's process runs as uid %d (%v), want %d", uid, err, alice)
+	}
+	if resp := call(nil, "run", `{"name":"anon","cmd":["sleep","60"]}`); resp.OK || !strings.Contains(resp.Error, "permission denied") {
+		t.Fatalf("unidentified caller ran a process: %+v", resp)
+	}
+	if resp := call(&viewer, "run", `{"name":"x","cmd":["sleep","60"]}`); resp.OK {
+		t.Fatal("read-only user ran a process")
+	}
+	if resp := call(&viewer, "status", `{}`); !resp.OK {
+		t.Fatalf("read-only status: %s", resp.Error)
+	}
+	for _, uid := range []*int{&bob, &viewer, nil} {
+		if resp := call(uid, "annotate", `{"name":"train","note":"mine now"}`); resp.OK || !strings.Contains(resp.Error, "permission denied") {
+			t.Fatalf("uid %v annotated someone else's process: %+v", uid, resp)
+		}
+	}
+	if resp := call(&bob, "adopt", `{"all":true}`); resp.OK || !strings.Contains(resp.Error, "admins") {
+		t.Fatalf("user adopt: %+v", resp)
+	}
+	if resp := call(&alice, "annotate", `{"name":"train","note":"ok"}`); !resp.OK {
+		t.Fatalf("owner annotate: %s", resp.Error)
+	}
+
+	// Relayed requests are authorized here first; the peer would see the
+	// daemon's token.
+	d.cfg.Peers = map[string]string{"peer": protocol.TCPPrefix + "127.0.0.1:1"}
+	relay := func(uid *int, method, params string) protocol.Response {
+		return d.HandleAs(protocol.Request{Method: method, Node: "peer", Params: json.RawMessage(params)}, uid)
+	}
+	if resp := relay(&viewer, "kill", `{"name":"train"}`); resp.OK || !strings.Contains(resp.Error, "permission denied") {
+		t.Fatalf("read-only user relayed a kill: %+v", resp)
+	}
+	if resp := relay(&alice, "kill", `{"name":"train"}`); resp.OK || !strings.Contains(resp.Error, "permission denied") {
+		t.Fatalf("user relayed a kill: %+v", resp)
+	}
+
+	root := 0
+	if resp := call(&root, "kill", `{"name":"train"}`); !resp.OK {
+		t.Fatalf("root kill: %s", resp.Error)
+	}
+}
+
+func TestAuthorizeRunParams(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.Roles = map[int]string{AnyUser: RoleUser}
+	alice, root := 1001, 0
+	for _, params := range []string{
+		`{"name":"x","cmd":["true"],"checkpoint_args":["--pid","1"]}`,
+		`{"name":"x","cmd":["true"],"checkpoint_timeouts_ms":{"restore":1}}`,
+		`{"name":"x","cmd":["true"],"readiness":{"exec":["cat","/etc/shadow"]}}`,
+		`{"name":"x","cmd":["true"],"liveness":{"exec":["touch","/etc/nologin"]}}`,
+		`{"name":"x","cmd":["true"],"drain_hooks":{"stop":["rm","-rf","/srv"]}}`,
+		`{"name":"x","cmd":["cat"],"input":"/etc/shadow"}`,
+		`{"name":"x","cmd":["true"],"output":"/etc/passwd"}`,
+		`{"name":"x","cmd":["true"],"wake":{"fifos":["/etc/cron.d/x"]}}`,
+		`{"name":"x","cmd":["true"],"wake":{"paths":["/root"]}}`,
+	} {
+		req := protocol.Request{Method: "run", Params: json.RawMessage(params)}
+		if err := d.authorize(req, &alice); err == nil || !strings.Contains(err.Error(), "permission denied") {
+			t.Fatalf("user run with %s: %v", params, err)
+		}
+		if err := d.authorize(req, &root); err != nil {
+			t.Fatalf("admin run with %s: %v", params, err)
+		}
+	}
+	if err := d.authorize(protocol.Request{Method: "run", Params: json.RawMessage(`{"name":"x","cmd":["true"],"env":{"inherit":["*"]}}`)}, &alice); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("user run with env inherit: %v", err)
+	}
+	if err := d.authorize(protocol.Request{Method: "run", Params: json.RawMessage(`{"name":"x","cmd":["true"],"env":{"deny":["AWS_*"],"set":{"A":"b"}},"readiness":{"tcp":"127.0.0.1:8000"}}`)}, &alice); err != nil {
+		t.Fatalf("plain user run: %v", err)
+	}
+}
+
+func TestAuthorizeQueueMove(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.Roles = map[int]string{AnyUser: RoleUser}
+	alice, bob, root := 1001, 1002, 0
+	d.queue = []*queuedRun{
+		{name: "first", params: protocol.RunParams{Name: "first", UID: &bob}},
+		{name: "mine", params: protocol.RunParams{Name: "mine", UID: &alice}},
+		{name: "last", params: protocol.RunParams{Name: "last", UID: &bob}},
+	}
+	move := func(uid *int, position int) error {
+		return d.authorize(protocol.Request{Method: "queue_move", Params: json.RawMessage(fmt.Sprintf(`{"name":"mine","position":%d}`, position))}, uid)
+	}
+	if err := move(&alice, 1); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("user moved their run ahead: %v", err)
+	}
+	for _, position := range []int{2, 3} {
+		if err := move(&alice, position); err != nil {
+			t.Fatalf("user moving their run to %d: %v", position, err)
+		}
+	}
+	if err := move(&bob, 3); err == nil {
+		t.Fatal("user moved someone else's run")
+	}
+	if err := move(&root, 1); err != nil {
+		t.Fatalf("admin moving a run ahead: %v", err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.ReadOnly = true
+	root := 0
+	if resp := d.HandleAs(protocol.Request{Method: "run", Params: json.RawMessage(`{"name":"x","cmd":["true"]}`)}, &root); resp.OK || !strings.Contains(resp.Error, "read-only") {
+		t.Fatalf("read-only daemon ran a process for root: %+v", resp)
+	}
+	if resp := d.Handle(protocol.Request{Method: "status"}); !resp.OK {
+		t.Fatalf("status: %s", resp.Error)
+	}
+	// attach is served outside handle, but typing into a terminal isn't
+	// a read either.
+	if _, err := d.Run(protocol.RunParams{Name: "repl", Cmd: []string{"sleep", "3600"}, TTY: true}); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Kill("repl")
+	srv := NewServer(d, "")
+	if err := srv.ListenTCP("127.0.0.1:0", TCPAuth{}); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.tcp.Close()
+	if a, err := client.New(protocol.TCPPrefix + srv.tcp.Addr().String()).Attach(protocol.AttachParams{Name: "repl"}); err == nil || !strings.Contains(err.Error(), "read-only") {
+		if a != nil {
+			a.Close()
+		}
+		t.Fatalf("read-only daemon attached: %v", err)
+	}
+
+	// A read-only listener on an otherwise writable daemon.
+	d = tempDaemon(t)
+	srv = NewServer(d, "")
+	if err := srv.ListenTCP("127.0.0.1:0", TCPAuth{ReadOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.tcp.Close()
+	c, err := client.New(protocol.TCPPrefix + srv.tcp.Addr().String()).OpenCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if resp, err := c.Call("run", protocol.RunParams{Name: "x", Cmd: []string{"true"}}); err != nil || resp.OK {
+		t.Fatalf("read-only listener ran a process: %+v, %v", resp, err)
+	}
+	if resp, err := c.Call("status", nil); err != nil || !resp.OK {
+		t.Fatalf("status on the same connection: %+v, %v", resp, err)
+	}
+
+	hs := httptest.NewServer(d.HTTPHandler("", true))
+	defer hs.Close()
+	resp, err := http.Post(hs.URL+"/v1/processes", "application/json", strings.NewReader(`{"name":"x","cmd":["true"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("read-only REST run: %s", resp.Status)
+	}
+	if len(d.procs) != 0 {
+		t.Fatalf("processes started through read-only listeners: %v", d.procs)
+	}
+}
+
+func TestFreezeDrained(t *testing.T) {
+	d := tempDaemon(t)
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "cuda-checkpoint")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d.cuda = checkpoint.NewCUDAAt(bin)
+	stopped, resumed := filepath.Join(dir, "stopped"), filepath.Join(dir, "resumed")
+	hooks := &protocol.DrainHooks{
+		Stop:   []string{"sh", "-c", `echo "$GPUSCHED_NAME" > ` + stopped},
+		Resume: []string{"touch", resumed},
+	}
+
+	if _, err := d.Run(protocol.RunParams{Name: "plain", Cmd: []string{"sleep", "60"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.FreezeDrained("plain", time.Second); err == nil || !strings.Contains(err.Error(), "can't be drained") {
+		t.Fatalf("want an error for a process with nothing to drain, got %v", err)
+	}
+
+	if _, err := d.Run(protocol.RunParams{Name: "srv", Cmd: []string{"sleep", "60"}, DrainHooks: hooks}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := d.FreezeDrained("srv", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(stopped); strings.TrimSpace(string(got)) != "srv" {
+		t.Fatalf("stop hook wrote %q", got)
+	}
+	if res.Name != "srv" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if _, err := os.Stat(resumed); err == nil {
+		t.Fatal("resume hook ran before thaw")
+	}
+	if _, err := d.Thaw("srv"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(resumed); err != nil {
+		t.Fatal("resume hook did not run after thaw")
+	}
+
+	os.Remove(resumed)
+	d.mu.Lock()
+	d.procs["srv"].params.DrainHooks = &protocol.DrainHooks{Stop: []string{"false"}, Resume: hooks.Resume}
+	d.mu.Unlock()
+	if _, err := d.FreezeDrained("srv", time.Second); err == nil || !strings.Contains(err.Error(), "stop hook") {
+		t.Fatalf("want a stop hook error, got %v", err)
+	}
+	if _, err := os.Stat(resumed); err != nil {
+		t.Fatal("a failed drain should resume the process")
+	}
+	if info, _ := d.Describe("srv"); info.State != protocol.StateActive {
+		t.Fatalf("state = %s after a failed drain", info.State)
+	}
+}
+
+func TestWakeTriggers(t *testing.T) {
+	d := tempDaemon(t)
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "cuda-checkpoint")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d.cuda = checkpoint.NewCUDAAt(bin)
+	inbox := filepath.Join(dir, "inbox")
+	if err := os.Mkdir(inbox, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Run(protocol.RunParams{Name: "bad", Cmd: []string{"true"}, Wake: &protocol.WakeTriggers{Paths: []string{filepath.Join(dir, "missing")}}}); err == nil {
+		t.Fatal("expected error for a wake path that doesn't exist")
+	}
+	wake := &protocol.WakeTriggers{Paths: []string{"inbox"}, FIFOs: []string{"jobs"}}
+	if _, err := d.Run(protocol.RunParams{Name: "worker", Dir: dir, Cmd: []string{"sleep", "60"}, Wake: wake}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	defer d.Kill("worker")
+	fifo := filepath.Join(dir, "jobs")
+	if fi, err := os.Stat(fifo); err != nil || fi.Mode()&os.ModeNamedPipe == 0 {
+		t.Fatalf("wake fifo not created: %v", err)
+	}
+
+	waitWoken := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			info, err := d.Describe("worker")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c := info.LastChange; info.State == protocol.StateActive && c != nil && c.Cause == protocol.CauseWake {
+				if !strings.Contains(c.Detail, want) {
+					t.Fatalf("woken by %q, want %s", c.Detail, want)
+				}
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("not woken by %s: %s", want, info.State)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	if _, err := d.Freeze("worker"); err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inbox, "job-1.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitWoken("job-1.json")
+
+	// The process would hold the pipe open; stand in for it so the write
+	// doesn't block.
+	r, err := os.OpenFile(fifo, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := d.Freeze("worker"); err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+	w, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("job-2\n"))
+	w.Close()
+	waitWoken("jobs")
+	buf := make([]byte, 16)
+	if n, _ := r.Read(buf); string(buf[:n]) != "job-2\n" {
+		t.Fatalf("fifo data not left for the process: %q", buf[:n])
+	}
+}
+
+func TestCgroups(t *testing.T) {
+	d := tempDaemon(t)
+	if _, err := d.Run(protocol.RunParams{Name: "capped", Cmd: []string{"true"}, CPUs: 2}); err == nil || !strings.Contains(err.Error(), "cgroup v2") {
+		t.Fatalf("want limits refused without cgroups, got %v", err)
+	}
+
+	// A stand-in for a cgroup v2 mount: the kernel would provide the files.
+	mount := t.TempDir()
+	if err := os.WriteFile(filepath.Join(mount, "cgroup.controllers"), []byte("cpu memory\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if d.cgroups = d.initCgroups(filepath.Join(mount, "gpusched")); d.cgroups == "" {
+		t.Fatal("cgroups not set up")
+	}
+	f, err := d.makeCgroup("alice/train", protocol.RunParams{CPUs: 1.5, HostMemMB: 1024})
+	if err != nil {
+		t.Fatalf("make cgroup: %v", err)
+	}
+	f.Close()
+	dir := filepath.Join(mount, "gpusched", "alice.slice", "train.scope")
+	for file, want := range map[string]string{
+		filepath.Join(dir, "cpu.max"):                                             "150000 100000",
+		filepath.Join(dir, "memory.max"):                                          "1073741824",
+		filepath.Join(mount, "gpusched", "alice.slice", "cgroup.subtree_control"): "+cpu +memory",
+	} {
+		if got, _ := os.ReadFile(file); string(got) != want {
+			t.Fatalf("%s = %q, want %q", file, got, want)
+		}
+	}
+	if got := d.cgroupPath("train"); got != filepath.Join(mount, "gpusched", "train.scope") {
+		t.Fatalf("cgroup without a namespace = %s", got)
+	}
+
+	// A frozen process in a cgroup counts what the cgroup is charged.
+	if err := os.WriteFile(filepath.Join(dir, "memory.current"), []byte("3221225472\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := &Proc{Name: "alice/train", State: protocol.StateFrozen, MemMB: 1000, cgroup: dir}
+	if got := snapshotMB(p); got != 3072 {
+		t.Fatalf("snapshot in a cgroup = %dMB, want 3072", got)
+	}
+	p.cgroup = ""
+	if got := snapshotMB(p); got != 1000 {
+		t.Fatalf("snapshot without a cgroup = %dMB, want the GPU memory", got)
+	}
+}
+
+func TestRunPriority(t *testing.T) {
+	d := tempDaemon(t)
+	if _, err := d.Run(protocol.RunParams{Name: "bad", Cmd: []string{"true"}, Nice: 40}); err == nil {
+		t.Fatal("expected error for nice out of range")
+	}
+	if _, err := d.Run(protocol.RunParams{Name: "bad", Cmd: []string{"true"}, IOClass: "lowest"}); err == nil {
+		t.Fatal("expected error for an unknown I/O class")
+	}
+
+	res, err := d.Run(protocol.RunParams{Name: "sweep", Cmd: []string{"sleep", "60"}, Nice: 10, IOClass: protocol.IOClassIdle, OOMScoreAdj: 300})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	defer d.Kill("sweep")
+
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", res.PID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fields after the parenthesized command; nice is the 19th overall.
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	if fields[16] != "10" {
+		t.Fatalf("nice = %s, want 10", fields[16])
+	}
+	prio, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(res.PID), 0)
+	if errno != 0 {
+		t.Fatal(errno)
+	}
+	if class := int(prio) >> ioprioClassShift; class != ioprioClasses[protocol.IOClassIdle] {
+		t.Fatalf("I/O class = %d, want idle", class)
+	}
+	if adj, err := procfs.OOMScoreAdj(res.PID); err != nil || adj != 300 {
+		t.Fatalf("oom_score_adj = %d (%v), want 300", adj, err)
+	}
+}
+
+func TestGPUMemLimit(t *testing.T) {
+	d := tempDaemon(t)
+	if _, err := d.Run(protocol.RunParams{Name: "bad", Cmd: []string{"true"}, GPUMemLimitMB: 1024, GPUMemLimitAction: "throttle"}); err == nil {
+		t.Fatal("expected error for an unknown limit action")
+	}
+	if _, err := d.Run(protocol.RunParams{Name: "bad", Cmd: []string{"true"}, GPUMemLimitMB: 1024, GPUMemLimitSignal: "NOPE"}); err == nil {
+		t.Fatal("expected error for an unknown signal")
+	}
+
+	hog, err := d.Run(protocol.RunParams{Name: "hog", Cmd: []string{"sleep", "60"}, GPUMemLimitMB: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// polite traps USR1, marking that it got it, and survives. It says
+	// when the trap is set, so the signal can't beat it.
+	dir := t.TempDir()
+	ready, marker := filepath.Join(dir, "ready"), filepath.Join(dir, "usr1")
+	polite, err := d.Run(protocol.RunParams{
+		Name: "polite", Cmd: []string{"sh", "-c", "trap 'touch " + marker + "' USR1; touch " + ready + "; while :; do sleep 0.1; done"},
+		GPUMemLimitMB: 1024, GPUMemLimitAction: protocol.LimitSignal, GPUMemLimitSignal: "usr1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Kill("polite")
+	waitFile := func(path string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if _, err := os.Stat(path); err == nil {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", path)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	waitFile(ready)
+
+	violations := func() []protocol.Event {
+		d.mu.RLock()
+		defer d.mu.RUnlock()
+		var evs []protocol.Event
+		for _, e := range d.events {
+			if e.Type == "gpu-mem-limit" {
+				evs = append(evs, e)
+			}
+		}
+		return evs
+	}
+
+	d.checkGPUMemLimits(map[int]int64{hog.PID: 512, polite.PID: 1024})
+	if evs := violations(); len(evs) != 0 {
+		t.Fatalf("events while within limits: %v", evs)
+	}
+
+	d.checkGPUMemLimits(map[int]int64{hog.PID: 2048, polite.PID: 2048})
+	d.checkGPUMemLimits(map[int]int64{hog.PID: 2048, polite.PID: 2048})
+	evs := violations()
+	if len(evs) != 2 {
+		t.Fatalf("want one event per process over its limit, got %v", evs)
+	}
+	for _, e := range evs {
+		if !strings.Contains(e.Detail, "2 GiB of its 1 GiB") {
+			t.Fatalf("unexpected detail: %q", e.Detail)
+		}
+	}
+	d.mu.RLock()
+	n := d.metrics.GPUMemLimitViolations
+	d.mu.RUnlock()
+	if n != 2 {
+		t.Fatalf("violations = %d, want 2", n)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := d.Describe("hog")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.State == protocol.StateDead {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for hog to be killed")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	waitFile(marker)
+	if info, err := d.Describe("polite"); err != nil || info.State != protocol.StateActive {
+		t.Fatalf("polite: %+v, %v", info, err)
+	}
+
+	// Back under and over again is a new violation.
+	d.checkGPUMemLimits(map[int]int64{polite.PID: 100})
+	d.checkGPUMemLimits(map[int]int64{polite.PID: 2048})
+	if evs := violations(); len(evs) != 3 {
+		t.Fatalf("want a second violation for polite, got %v", evs)
+	}
+
+	// A torchrun job's usage is its workers', and they are killed with it.
+	job, err := d.Run(protocol.RunParams{Name: "job", Cmd: []string{"sh", "-c", "sleep 60 & wait # torchrun train.py"}, GPUMemLimitMB: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var worker int
+	for deadline := time.Now().Add(5 * time.Second); worker == 0; {
+		if kids := procfs.Descendants(job.PID); len(kids) > 0 {
+			worker = kids[0]
+		} else if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the worker to start")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	d.checkGPUMemLimits(map[int]int64{job.PID: 0, worker: 2048})
+	if evs := violations(); len(evs) != 4 {
+		t.Fatalf("want a violation for the torchrun job, got %v", evs)
+	}
+	// Once killed, the worker is gone or a zombie left to its new parent.
+	alive := func(pid int) bool {
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			return false
+		}
+		s := string(stat)
+		return !strings.HasPrefix(s[strings.LastIndex(s, ")")+1:], " Z")
+	}
+	for deadline := time.Now().Add(5 * time.Second); alive(worker); {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the worker to be killed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestTimeSlice(t *testing.T) {
+	d := tempDaemon(t)
+	if _, err := d.Run(protocol.RunParams{Name: "a", Cmd: []string{"sleep", "60"}, TimeSlice: true}); err == nil {
+		t.Fatal("expected error for a time-sliced run with time slicing off")
+	}
+	d.cfg.TimeSliceQuantum = time.Hour
+	bin := filepath.Join(t.TempDir(), "cuda-checkpoint")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d.cuda = checkpoint.NewCUDAAt(bin)
+
+	for _, name := range []string{"a", "b", "other"} {
+		if _, err := d.Run(protocol.RunParams{Name: name, Cmd: []string{"sleep", "60"}, TimeSlice: name != "other"}); err != nil {
+			t.Fatal(err)
+		}
+		defer d.Kill(name)
+		time.Sleep(10 * time.Millisecond)
+	}
+	states := func() string {
+		var s []string
+		for _, name := range []string{"a", "b", "other"} {
+			info, err := d.Describe(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			st := string(info.State)
+			if info.WaitingForSlice() {
+				st += "(waiting)"
+			}
+			s = append(s, name+"="+st)
+		}
+		return strings.Join(s, " ")
+	}
+
+	// b started last, so it takes the GPU and a waits.
+	d.timeSlice(time.Now())
+	if got, want := states(), "a=frozen(waiting) b=active other=active"; got != want {
+		t.Fatalf("after b started: %s, want %s", got, want)
+	}
+	d.timeSlice(time.Now())
+	if got, want := states(), "a=frozen(waiting) b=active other=active"; got != want {
+		t.Fatalf("within b's slice: %s, want %s", got, want)
+	}
+
+	// Once b's slice is up, they trade places.
+	d.timeSlice(time.Now().Add(2 * time.Hour))
+	if got, want := states(), "a=active b=frozen(waiting) other=active"; got != want {
+		t.Fatalf("after b's slice: %s, want %s", got, want)
+	}
+
+	// A process frozen by hand sits out, and its turn goes to b.
+	if _, err := d.Freeze("a"); err != nil {
+		t.Fatal(err)
+	}
+	d.timeSlice(time.Now())
+	if got, want := states(), "a=frozen b=active other=active"; got != want {
+		t.Fatalf("after a was frozen by hand: %s, want %s", got, want)
+	}
+}
+
+func TestDeadlines(t *testing.T) {
+	d := tempDaemon(t)
+	if _, err := d.Run(protocol.RunParams{Name: "bad", Cmd: []string{"true"}, MaxRuntimeMs: 1000, MaxRuntimeAction: "hibernate"}); err == nil {
+		t.Fatal("expected error for an unknown max runtime action")
+	}
+	bin := filepath.Join(t.TempDir(), "cuda-checkpoint")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d.cuda = checkpoint.NewCUDAAt(bin)
+
+	if _, err := d.Run(protocol.RunParams{Name: "long", Cmd: []string{"sleep", "60"}, MaxRuntimeMs: 60000, Restart: protocol.RestartAlways}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Run(protocol.RunParams{Name: "paused", Cmd: []string{"sleep", "60"}, MaxRuntimeMs: 60000, MaxRuntimeAction: protocol.DeadlineFreeze}); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Kill("paused")
+	if _, err := d.Run(protocol.RunParams{Name: "done", Cmd: []string{"true"}, TTLAfterExitMs: 60000}); err != nil {
+		t.Fatal(err)
+	}
+
+	waitDead := func(name string) protocol.ProcessInfo {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			info, err := d.Describe(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.State == protocol.StateDead {
+				return info
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s to exit", name)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	if info := waitDead("done"); info.Deadline == nil {
+		t.Fatal("no deadline for an exited process with a TTL")
+	}
+
+	d.checkDeadlines(time.Now())
+	d.mu.RLock()
+	n := len(d.procs)
+	d.mu.RUnlock()
+	if n != 3 {
+		t.Fatalf("acted on before any deadline: %d processes left", n)
+	}
+
+	d.checkDeadlines(time.Now().Add(2 * time.Minute))
+	if _, err := d.Describe("done"); err == nil {
+		t.Fatal("done not removed after its TTL")
+	}
+	if info, err := d.Describe("paused"); err != nil || info.State != protocol.StateFrozen || info.LastChange.Cause != protocol.CauseDeadline {
+		t.Fatalf("paused: %+v, %v", info, err)
+	}
+	if info := waitDead("long"); info.NextRestart != nil {
+		t.Fatal("process killed at its max runtime is being restarted")
+	}
+	d.mu.RLock()
+	n = 0
+	for _, e := range d.events {
+		if e.Type == "deadline" {
+			n++
+		}
+	}
+	d.mu.RUnlock()
+	if n != 3 {
+		t.Fatalf("got %d deadline events, want 3", n)
+	}
+}
+
+func TestGC(t *testing.T) {
+	d := tempDaemon(t)
+	if _, err := d.Run(protocol.RunParams{Name: "done", Cmd: []string{"true"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Run(protocol.RunParams{Name: "live", Cmd: []string{"sleep", "60"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Kill("live")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := d.Describe("done")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.State == protocol.StateDead {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for done to exit")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	d.mu.Lock()
+	d.procs["done"].exitedAt = old
+	d.mu.Unlock()
+	logs := d.cfg.LogDir
+	if err := os.MkdirAll(filepath.Join(logs, "ns"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"gone.log", "gone.log.1", "ns/old.log", "fresh.log", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(logs, name), []byte("output\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"gone.log", "gone.log.1", "ns/old.log", "notes.txt", "done.log", "live.log"} {
+		if err := os.Chtimes(filepath.Join(logs, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := func(cat protocol.GCCategory) []string {
+		var out []string
+		for _, item := range cat.Items {
+			name, _ := filepath.Rel(logs, item.Name)
+			if cat.Name == protocol.GCProcesses {
+				name = item.Name
+			}
+			out = append(out, name)
+		}
+		sort.Strings(out)
+		return out
+	}
+	res, err := d.GC(protocol.GCParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(res.Categories[0]), []string{"done.log", "gone.log", "gone.log.1", "ns/old.log"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("stale logs: %v, want %v", got, want)
+	}
+	if got := names(res.Categories[1]); !reflect.DeepEqual(got, []string{"done"}) {
+		t.Fatalf("expired processes: %v", got)
+	}
+	if res.Applied {
+		t.Fatal("dry run reported as applied")
+	}
+	if _, err := os.Stat(filepath.Join(logs, "gone.log")); err != nil {
+		t.Fatal("dry run removed a log")
+	}
+	if _, err := d.Describe("done"); err != nil {
+		t.Fatal("dry run removed a process")
+	}
+
+	if _, err := d.GC(protocol.GCParams{Apply: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"gone.log", "gone.log.1", "ns", "done.log"} {
+		if _, err := os.Stat(filepath.Join(logs, name)); !os.IsNotExist(err) {
+			t.Fatalf("%s not removed", name)
+		}
+	}
+	for _, name := range []string{"fresh.log", "notes.txt", "live.log"} {
+		if _, err := os.Stat(filepath.Join(logs, name)); err != nil {
+			t.Fatalf("%s removed", name)
+		}
+	}
+	if _, err := d.Describe("done"); err == nil {
+		t.Fatal("done not removed")
+	}
+	if _, err := d.Describe("live"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMPS(t *testing.T) {
+	d := tempDaemon(t)
+	if _, err := d.Run(protocol.RunParams{Name: "off", Cmd: []string{"true"}, MPS: true}); err == nil {
+		t.Fatal("expected error for an MPS run with MPS off")
+	}
+
+	// A stand-in control daemon: -d creates the control pipe, quit removes
+	// it, and other commands fail unless it is there.
+	bin := t.TempDir()
+	script := `#!/bin/sh
+control="$CUDA_MPS_PIPE_DIRECTORY/control"
+if [ "$1" = -d ]; then touch "$control"; exit 0; fi
+read cmd
+[ -e "$control" ] || exit 1
+[ "$cmd" = quit ] && rm "$control"
+exit 0
+`
+	if err := os.WriteFile(filepath.Join(bin, mpsControlBin), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+":"+os.Getenv("PATH"))
+	pipes := filepath.Join(t.TempDir(), "mps")
+	d.mps = d.initMPS(pipes)
+	if d.mps != pipes || !d.mpsStarted {
+		t.Fatalf("MPS not started: %q", d.mps)
+	}
+	control := filepath.Join(pipes, "control")
+	if _, err := os.Stat(control); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Run(protocol.RunParams{Name: "sliced", Cmd: []string{"true"}, MPS: true, TimeSlice: true}); err == nil {
+		t.Fatal("expected error for a time-sliced MPS client")
+	}
+	cmd := []string{"sh", "-c", "echo $CUDA_MPS_PIPE_DIRECTORY $CUDA_MPS_PINNED_DEVICE_MEM_LIMIT; sleep 60"}
+	if _, err := d.Run(protocol.RunParams{Name: "client", Cmd: cmd, MPS: true, GPUMemLimitMB: 4096}); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Kill("client")
+	want := pipes + " 0=4096M"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		out, _ := os.ReadFile(filepath.Join(d.cfg.LogDir, "client.log"))
+		if strings.TrimSpace(string(out)) == want {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("log: %q, want %q", out, want)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if info, err := d.Describe("client"); err != nil || !info.MPS {
+		t.Fatalf("client: %+v, %v", info, err)
+	}
+
+	bin2 := filepath.Join(t.TempDir(), "cuda-checkpoint")
+	if err := os.WriteFile(bin2, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d.cuda = checkpoint.NewCUDAAt(bin2)
+	if _, err := d.Freeze("client"); err == nil || !strings.Contains(err.Error(), "MPS") {
+		t.Fatalf("freezing an MPS client: %v", err)
+	}
+
+	// A second daemon finds the running control daemon and leaves it be.
+	d2 := tempDaemon(t)
+	if d2.initMPS(pipes) != pipes || d2.mpsStarted {
+		t.Fatal("running control daemon not reused")
+	}
+	d2.stopMPS(nil)
+	if _, err := os.Stat(control); err != nil {
+		t.Fatal("control daemon stopped by a daemon that didn't start it")
+	}
+	d.stopMPS([]savedProc{{Name: "client", Params: protocol.RunParams{MPS: true}}})
+	if _, err := os.Stat(control); err != nil {
+		t.Fatal("control daemon stopped under a client left running")
+	}
+	d.stopMPS(nil)
+	if _, err := os.Stat(control); !os.IsNotExist(err) {
+		t.Fatal("control daemon not stopped")
+	}
+}
+
+func TestGroups(t *testing.T) {
+	d := tempDaemon(t)
+	if _, err := d.Run(protocol.RunParams{Name: "bad", Cmd: []string{"true"}, Group: "a/b"}); err == nil {
+		t.Fatal("expected error for a group with '/'")
+	}
+	bin := filepath.Join(t.TempDir(), "cuda-checkpoint")
+	writeCheckpoint := func(script string) {
+		t.Helper()
+		if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCheckpoint("#!/bin/sh\nexit 0\n")
+	d.cuda = checkpoint.NewCUDAAt(bin)
+	for _, name := range []string{"g1", "g2", "solo"} {
+		group := "exp"
+		if name == "solo" {
+			group = ""
+		}
+		if _, err := d.Run(protocol.RunParams{Name: name, Cmd: []string{"sleep", "60"}, Group: group}); err != nil {
+			t.Fatal(err)
+		}
+		defer d.Kill(name)
+	}
+	state := func(name string) protocol.ProcessState {
+		t.Helper()
+		info, err := d.Describe(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.State
+	}
+
+	if _, err := d.GroupFreeze(protocol.GroupParams{Group: "nope"}); err == nil {
+		t.Fatal("expected error for an unknown group")
+	}
+	res, err := d.GroupFreeze(protocol.GroupParams{Group: "exp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Processes, []string{"g1", "g2"}) {
+		t.Fatalf("froze %v", res.Processes)
+	}
+	if state("g1") != protocol.StateFrozen || state("g2") != protocol.StateFrozen || state("solo") != protocol.StateActive {
+		t.Fatal("group freeze froze the wrong processes")
+	}
+	if res, err := d.GroupThaw(protocol.GroupParams{Group: "exp"}); err != nil || len(res.Processes) != 2 {
+		t.Fatalf("thaw: %+v, %v", res, err)
+	}
+
+	// g2 can't be checkpointed, so g1 is thawed again.
+	info, _ := d.Describe("g2")
+	writeCheckpoint(fmt.Sprintf("#!/bin/sh\ncase \" $* \" in *\" --pid %d \"*) exit 1;; esac\nexit 0\n", info.PID))
+	if _, err := d.GroupFreeze(protocol.GroupParams{Group: "exp"}); err == nil || !strings.Contains(err.Error(), "g2") {
+		t.Fatalf("expected g2 to fail the group freeze, got %v", err)
+	}
+	if state("g1") != protocol.StateActive {
+		t.Fatal("g1 left frozen after the group freeze failed")
+	}
+
+	// A frozen member stays frozen when the group moves, and when it is
+	// moved back because g2 can't be.
+	writeCheckpoint("#!/bin/sh\nexit 0\n")
+	if _, err := d.Freeze("g1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GroupMigrate(protocol.GroupParams{Group: "exp", GPU: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := d.Describe("g1"); info.GPU != 1 || info.State != protocol.StateFrozen {
+		t.Fatalf("g1 after group migrate: %+v", info)
+	}
+	writeCheckpoint(fmt.Sprintf("#!/bin/sh\ncase \" $* \" in *\" --pid %d \"*) exit 1;; esac\nexit 0\n", info.PID))
+	if _, err := d.GroupMigrate(protocol.GroupParams{Group: "exp", GPU: 2}); err == nil || !strings.Contains(err.Error(), "g2") {
+		t.Fatalf("expected g2 to fail the group migrate, got %v", err)
+	}
+	if info, _ := d.Describe("g1"); info.GPU != 1 || info.State != protocol.StateFrozen {
+		t.Fatalf("g1 not put back after the group migrate failed: %+v", info)
+	}
+	// cuda-checkpoint can't tell what state g2 was left in.
+	if info, _ := d.Describe("g2"); info.GPU != 1 || info.State != protocol.StateDegraded {
+		t.Fatalf("g2 after its migrate failed: %+v", info)
+	}
+
+	if res, err := d.GroupKill(protocol.GroupParams{Group: "exp"}); err != nil || len(res.Processes) != 2 {
+		t.Fatalf("kill: %+v, %v", res, err)
+	}
+	if _, err := d.Describe("g1"); err == nil {
+		t.Fatal("g1 not killed")
+	}
+	if state("solo") != protocol.StateActive {
+		t.Fatal("solo killed with the group")
+	}
+}
+
+func TestPark(t *testing.T) {
+	d := tempDaemon(t)
+	bin := filepath.Join(t.TempDir(), "cuda-checkpoint")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d.cuda = checkpoint.NewCUDAAt(bin)
+	res, err := d.Run(protocol.RunParams{Name: "prep", Cmd: []string{"sleep", "60"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Kill("prep")
+	stopped := func() bool {
+		t.Helper()
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", res.PID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields := strings.Fields(string(data[strings.LastIndexByte(string(data), ')')+1:]))
+		return fields[0] == "T"
+	}
+
+	if _, err := d.Unpark("prep"); err == nil {
+		t.Fatal("expected error unparking an active process")
+	}
+	if _, err := d.Park("prep"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := d.Describe("prep")
+	if err != nil || info.State != protocol.StateFrozen || !info.Parked {
+		t.Fatalf("after park: %+v, %v", info, err)
+	}
+	if stopped() {
+		t.Fatal("parked process was stopped")
+	}
+	if _, err := d.Unpark("prep"); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := d.Describe("prep"); info.State != protocol.StateActive || info.Parked {
+		t.Fatalf("after unpark: %+v", info)
+	}
+
+	if _, err := d.Freeze("prep"); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); !stopped(); time.Sleep(20 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("frozen process not stopped")
+		}
+	}
+	if _, err := d.Unpark("prep"); err == nil {
+		t.Fatal("expected error unparking a frozen process")
+	}
+	if _, err := d.Thaw("prep"); err != nil {
+		t.Fatal(err)
+	}
+
+	d.mu.RLock()
+	var types []string
+	for _, e := range d.events {
+		if e.Process == "prep" && e.Type != "run" {
+			types = append(types, e.Type)
+		}
+	}
+	d.mu.RUnlock()
+	if want := []string{"park", "unpark", "freeze", "thaw"}; !reflect.DeepEqual(types, want) {
+		t.Fatalf("events %v, want %v", types, want)
+	}
+}
+
+func TestDesiredState(t *testing.T) {
+	d := tempDaemon(t)
+	d.cfg.SpecDir = t.TempDir()
+	defer func() {
+		for _, name := range []string{"web", "ns/job", "manual"} {
+			d.Kill(name)
+		}
+	}()
+	writeSpec := func(name, spec string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(d.cfg.SpecDir, name), []byte(spec), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(got []protocol.ApplyAction, want ...protocol.ApplyAction) {
+		t.Helper()
+		if want == nil {
+			want = []protocol.ApplyAction{}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("actions %+v, want %+v", got, want)
+		}
+	}
+	pid := func(name string) int {
+		t.Helper()
+		d.mu.RLock()
+		defer d.mu.RUnlock()
+		p, ok := d.procs[name]
+		if !ok {
+			t.Fatalf("%s not running", name)
+		}
+		return p.PID
+	}
+
+	writeSpec("web.json", `{"cmd": ["sleep", "60"]}`)
+	expect(d.reconcileDesired(), protocol.ApplyAction{Name: "web", Action: protocol.DesiredStarted})
+	expect(d.reconcileDesired())
+	d.mu.RLock()
+	restart := d.procs["web"].params.Restart
+	d.mu.RUnlock()
+	if restart != protocol.RestartOnFailure {
+		t.Fatalf("restart policy %q, want %q", restart, protocol.RestartOnFailure)
+	}
+
+	// A process already running under a spec's name is left alone.
+	if _, err := d.Run(protocol.RunParams{Name: "manual", Cmd: []string{"sleep", "60"}}); err != nil {
+		t.Fatal(err)
+	}
+	first := pid("manual")
+	writeSpec("manual.json", `{"cmd": ["sleep", "61"]}`)
+	expect(d.reconcileDesired())
+	if pid("manual") != first {
+		t.Fatal("manual was restarted")
+	}
+
+	res, err := d.Apply(protocol.ApplyParams{Namespace: "ns", Specs: []protocol.RunParams{{Name: "job", Cmd: []string{"sleep", "60"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(res.Actions, protocol.ApplyAction{Name: "ns/job", Action: protocol.DesiredStarted})
+
+	first = pid("web")
+	writeSpec("web.json", `{"cmd": ["sleep", "61"]}`)
+	expect(d.reconcileDesired(), protocol.ApplyAction{Name: "web", Action: protocol.DesiredReplaced})
+	if pid("web") == first {
+		t.Fatal("web was not replaced")
+	}
+
+	if err := d.Kill("web"); err != nil {
+		t.Fatal(err)
+	}
+	expect(d.reconcileDesired(), protocol.ApplyAction{Name: "web", Action: protocol.DesiredStarted})
+
+	// A spec file caught halfway through a write keeps its last spec.
+	writeSpec("web.json", `{"cmd": [`)
+	expect(d.reconcileDesired())
+	if _, ok := d.fileErrors[filepath.Join(d.cfg.SpecDir, "web.json")]; !ok {
+		t.Fatal("bad spec file not reported")
+	}
+
+	if err := os.Remove(filepath.Join(d.cfg.SpecDir, "web.json")); err != nil {
+		t.Fatal(err)
+	}
+	expect(d.reconcileDesired(), protocol.ApplyAction{Name: "web", Action: protocol.DesiredRemoved})
+	if _, err := d.Describe("web"); err == nil {
+		t.Fatal("web still exists after its spec was deleted")
+	}
+
+	res, err = d.Apply(protocol.ApplyParams{Namespace: "ns", Delete: []string{"job"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(res.Actions, protocol.ApplyAction{Name: "ns/job", Action: protocol.DesiredRemoved})
+	if _, err := d.Apply(protocol.ApplyParams{Delete: []string{"web"}}); err == nil {
+		t.Fatal("deleting a spec that was never applied succeeded")
+	}
+}
@@ -0,0 +1,130 @@
+package daemon
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+// deadlineTick is how often max runtimes and TTLs after exit are checked.
+const deadlineTick = time.Second
+
+func validDeadline(params protocol.RunParams) error {
+	if params.MaxRuntimeMs < 0 || params.TTLAfterExitMs < 0 {
+		return fmt.Errorf("max runtime and TTL after exit must not be negative")
+	}
+	switch params.MaxRuntimeAction {
+	case "", protocol.DeadlineKill, protocol.DeadlineFreeze:
+		return nil
+	}
+	return fmt.Errorf("unknown max runtime action %q", params.MaxRuntimeAction)
+}
+
+func maxRuntimeAction(p *Proc) string {
+	if p.params.MaxRuntimeAction == "" {
+		return protocol.DeadlineKill
+	}
+	return p.params.MaxRuntimeAction
+}
+
+// runtime is how long p has been active, not counting time frozen.
+func (p *Proc) runtime(now time.Time) time.Duration {
+	total := p.activeTotal
+	if !p.activeSince.IsZero() {
+		total += now.Sub(p.activeSince)
+	}
+	return total
+}
+
+// deadline is when p reaches its max runtime if it stays active, or when
+// its TTL removes it once it has exited; nil if neither is coming.
+func (p *Proc) deadline(now time.Time) *time.Time {
+	var at time.Time
+	switch {
+	case p.State == protocol.StateActive && p.params.MaxRuntimeMs > 0 && !p.deadlineHit:
+		at = now.Add(time.Duration(p.params.MaxRuntimeMs)*time.Millisecond - p.runtime(now))
+	case p.State == protocol.StateDead && p.params.TTLAfterExitMs > 0 && !p.exitedAt.IsZero() && p.nextRestart.IsZero():
+		at = p.exitedAt.Add(time.Duration(p.params.TTLAfterExitMs) * time.Millisecond)
+	default:
+		return nil
+	}
+	return &at
+}
+
+// deadlineLoop enforces max runtimes and TTLs after exit until the daemon
+// shuts down.
+func (d *Daemon) deadlineLoop() {
+	ticker := time.NewTicker(deadlineTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+		d.checkDeadlines(time.Now())
+	}
+}
+
+// checkDeadlines acts on processes whose deadline has passed at now. An
+// active process past its max runtime is killed, getting DrainTimeout
+// between SIGTERM and SIGKILL, or frozen, once: thawing it afterwards lets
+// it carry on. An exited process past its TTL is removed unless a restart
+// is pending.
+func (d *Daemon) checkDeadlines(now time.Time) {
+	type due struct {
+		name, detail string
+	}
+	var freeze []due
+
+	d.mu.Lock()
+	for name, p := range d.procs {
+		at := p.deadline(now)
+		if at == nil || now.Before(*at) {
+			continue
+		}
+		if p.State == protocol.StateDead {
+			detail := fmt.Sprintf("exited %s ago; removing", formatDuration(now.Sub(p.exitedAt)))
+			d.emit(protocol.Event{Type: "deadline", Process: name, Detail: detail})
+			d.log.Printf("DEADLINE %s: %s", name, detail)
+			delete(d.procs, name)
+			continue
+		}
+
+		p.deadlineHit = true
+		action := maxRuntimeAction(p)
+		max := time.Duration(p.params.MaxRuntimeMs) * time.Millisecond
+		detail := fmt.Sprintf("ran for %s (max %s)", formatDuration(p.runtime(now)), formatDuration(max))
+		d.emit(protocol.Event{Type: "deadline", Process: name, Detail: fmt.Sprintf("%s → %s", detail, action)})
+		d.log.Printf("DEADLINE %s: %s → %s", name, detail, action)
+
+		switch action {
+		case protocol.DeadlineKill:
+			pids := append([]int{p.PID}, p.workers...)
+			for _, pid := range pids {
+				syscall.Kill(pid, syscall.SIGTERM)
+			}
+			go func(exited chan struct{}) {
+				select {
+				case <-exited:
+				case <-time.After(d.cfg.DrainTimeout):
+					for _, pid := range pids {
+						syscall.Kill(pid, syscall.SIGKILL)
+					}
+				}
+			}(p.exited)
+		case protocol.DeadlineFreeze:
+			freeze = append(freeze, due{name, detail})
+		}
+	}
+	d.mu.Unlock()
+
+	for _, f := range freeze {
+		if _, err := d.freeze(f.name, protocol.CauseDeadline, f.detail); err != nil {
+			d.log.Printf("DEADLINE %s: freeze failed: %v", f.name, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"gpusched/internal/checkpoint"
+	"gpusched/internal/protocol"
+)
+
+// degradeIf marks p degraded when err says a failed freeze or thaw left
+// it in a CUDA state that couldn't be rolled back; cause is the freeze or
+// thaw's. Caller must hold d.mu.
+func (d *Daemon) degradeIf(p *Proc, op, cause string, err error) {
+	if !errors.Is(err, checkpoint.ErrUnrecovered) {
+		return
+	}
+	now := time.Now()
+	d.endActive(p, now)
+	if p.State == protocol.StateFrozen {
+		p.frozenTotal += now.Sub(p.frozenAt)
+		p.frozenAt = time.Time{}
+	}
+	p.State = protocol.StateDegraded
+	p.parked = false
+	d.setTransition(p, cause, op+" failed")
+
+	detail := fmt.Sprintf("%s failed: %v; run 'gpusched recover %s'", op, err, p.Name)
+	d.emit(protocol.Event{Type: "degraded", Process: p.Name, Detail: detail, Cause: cause})
+	d.log.Printf("DEGRADED %s pid=%d: %s", p.Name, p.PID, detail)
+}
+
+// Recover retries bringing a degraded process back to running and, once
+// cuda-checkpoint agrees it is, resumes it as active.
+func (d *Daemon) Recover(name string) (protocol.RecoverResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p, ok := d.procs[name]
+	if !ok {
+		return protocol.RecoverResult{}, errorf(protocol.CodeNotFound, "process %q not found", name)
+	}
+	if p.State != protocol.StateDegraded {
+		return protocol.RecoverResult{}, fmt.Errorf("process %q is %s, not degraded", name, p.State)
+	}
+
+	start := time.Now()
+	if _, err := d.cudaFor(p).Recover(p.PID, checkpoint.RecoverAttempts); err != nil {
+		d.log.Printf("RECOVER %s failed: %v", name, err)
+		return protocol.RecoverResult{}, fmt.Errorf("recover %s: %w", name, err)
+	}
+	syscall.Kill(p.PID, syscall.SIGCONT)
+	dur := time.Since(start)
+
+	p.State = protocol.StateActive
+	d.restoreOOM(p)
+	d.startActive(p, time.Now())
+	d.setTransition(p, protocol.CauseUser, "recovered")
+
+	d.emit(protocol.Event{Type: "recover", Process: name, Duration: dur.Milliseconds()})
+	d.log.Printf("RECOVER %s pid=%d %dms", name, p.PID, dur.Milliseconds())
+	return protocol.RecoverResult{Name: name, DurationMs: dur.Milliseconds()}, nil
+}
@@ -0,0 +1,273 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+// desiredInterval is how often processes are reconciled with the desired
+// state, which picks up changes to the spec directory.
+const desiredInterval = 10 * time.Second
+
+// desiredDetails describe reconcile actions in events and the log.
+var desiredDetails = map[string]string{
+	protocol.DesiredStarted:  "started from its spec",
+	protocol.DesiredReplaced: "replaced: its spec changed",
+	protocol.DesiredRemoved:  "removed: its spec was deleted",
+}
+
+// desiredSpec is a process the daemon keeps running, and the key that
+// tells whether its spec has changed.
+type desiredSpec struct {
+	params protocol.RunParams
+	key    string
+}
+
+// newDesiredSpec fills in what params leaves out. Processes are
+// relaunched when they fail unless their spec says otherwise, which
+// leaves crashes to the restart policy and its backoff.
+func newDesiredSpec(params protocol.RunParams) desiredSpec {
+	if params.Restart == "" {
+		params.Restart = protocol.RestartOnFailure
+	}
+	// Who pushed a spec doesn't make it a different spec.
+	uid := params.UID
+	params.UID = nil
+	key, _ := json.Marshal(params)
+	params.UID = uid
+	return desiredSpec{params: params, key: string(key)}
+}
+
+// desiredLoop reconciles processes with the desired state every
+// desiredInterval until the daemon shuts down.
+func (d *Daemon) desiredLoop() {
+	ticker := time.NewTicker(desiredInterval)
+	defer ticker.Stop()
+
+	for {
+		d.reconcileDesired()
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// loadSpecDir reads the specs in SpecDir by qualified name. A file that
+// can't be read or parsed keeps the spec last read from it, so a process
+// isn't killed over a file caught halfway through being written. Caller
+// must hold d.desiredMu.
+func (d *Daemon) loadSpecDir() (map[string]desiredSpec, map[string]string) {
+	specs := make(map[string]desiredSpec)
+	failed := make(map[string]string)
+	dir := d.cfg.SpecDir
+	if dir == "" {
+		return specs, failed
+	}
+	if _, err := os.Stat(dir); err != nil {
+		failed[dir] = err.Error()
+		return specs, failed
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+
+	files := make(map[string]desiredSpec, len(paths))
+	for _, path := range paths {
+		var params protocol.RunParams
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, &params)
+		}
+		if err != nil {
+			failed[path] = err.Error()
+			if s, ok := d.specFiles[path]; ok {
+				files[path] = s
+			}
+			continue
+		}
+		if params.Name == "" {
+			params.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+		}
+		params.UID = nil
+		files[path] = newDesiredSpec(params)
+	}
+	d.specFiles = files
+
+	for _, path := range paths {
+		s, ok := files[path]
+		if !ok {
+			continue
+		}
+		name := protocol.QualifiedName(s.params.Namespace, s.params.Name)
+		if _, dup := specs[name]; dup {
+			failed[path] = fmt.Sprintf("%s is already specified by another file", name)
+			continue
+		}
+		specs[name] = s
+	}
+	return specs, failed
+}
+
+// reconcileDesired brings processes in line with the desired state: it
+// starts specs that have no process, replaces processes whose spec has
+// changed, and kills those whose spec is gone. Relaunching processes that
+// exit is left to their restart policy. A process already running under
+// a spec's name when the spec is first seen, such as one reattached after
+// the daemon restarted, is taken to be running it.
+func (d *Daemon) reconcileDesired() []protocol.ApplyAction {
+	d.desiredMu.Lock()
+	defer d.desiredMu.Unlock()
+
+	specs, failed := d.loadSpecDir()
+	maps.Copy(specs, d.applied)
+
+	type step struct {
+		name, action string
+		spec         *desiredSpec
+	}
+	var steps []step
+
+	d.mu.Lock()
+	for _, path := range slices.Sorted(maps.Keys(failed)) {
+		if d.fileErrors[path] != failed[path] {
+			d.emit(protocol.Event{Type: "desired-failed", Detail: path + ": " + failed[path]})
+			d.log.Printf("DESIRED %s: %s", path, failed[path])
+		}
+	}
+	d.fileErrors = failed
+
+	for _, name := range slices.Sorted(maps.Keys(specs)) {
+		s := specs[name]
+		_, exists := d.procs[name]
+		exists = exists || d.queued(name) >= 0
+		key, owned := d.specKeys[name]
+		switch {
+		case !exists:
+			steps = append(steps, step{name, protocol.DesiredStarted, &s})
+		case !owned:
+			d.specKeys[name] = s.key
+		case key != s.key:
+			steps = append(steps, step{name, protocol.DesiredReplaced, &s})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(d.specKeys)) {
+		if _, ok := specs[name]; ok {
+			continue
+		}
+		delete(d.specKeys, name)
+		_, exists := d.procs[name]
+		if exists || d.queued(name) >= 0 {
+			steps = append(steps, step{name: name, action: protocol.DesiredRemoved})
+		}
+	}
+	for name := range d.specErrors {
+		if _, ok := specs[name]; !ok {
+			delete(d.specErrors, name)
+		}
+	}
+	d.mu.Unlock()
+
+	actions := []protocol.ApplyAction{}
+	for _, s := range steps {
+		var err error
+		if s.action != protocol.DesiredStarted {
+			err = d.removeDesired(s.name)
+		}
+		if err == nil && s.spec != nil {
+			_, err = d.Run(s.spec.params)
+		}
+
+		action := protocol.ApplyAction{Name: s.name, Action: s.action}
+		d.mu.Lock()
+		if err != nil {
+			action.Error = err.Error()
+			if msg := s.action + ": " + err.Error(); d.specErrors[s.name] != msg {
+				d.specErrors[s.name] = msg
+				d.emit(protocol.Event{Type: "desired-failed", Process: s.name, Detail: msg})
+				d.log.Printf("DESIRED %s: %s", s.name, msg)
+			}
+		} else {
+			if s.spec != nil {
+				d.specKeys[s.name] = s.spec.key
+			}
+			delete(d.specErrors, s.name)
+			d.emit(protocol.Event{Type: "desired", Process: s.name, Detail: desiredDetails[s.action]})
+			d.log.Printf("DESIRED %s: %s", s.name, desiredDetails[s.action])
+		}
+		d.mu.Unlock()
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// removeDesired takes name off the queue or kills it. The entry of a
+// process that has already exited is just dropped.
+func (d *Daemon) removeDesired(name string) error {
+	d.mu.Lock()
+	p, ok := d.procs[name]
+	exited := ok && p.State == protocol.StateDead
+	if exited {
+		delete(d.procs, name)
+	}
+	queued := d.queued(name) >= 0
+	d.mu.Unlock()
+
+	switch {
+	case exited:
+		return nil
+	case queued:
+		return d.Dequeue(name)
+	default:
+		return d.Kill(name)
+	}
+}
+
+// Apply pushes and deletes specs, then reconciles with the result.
+func (d *Daemon) Apply(params protocol.ApplyParams) (protocol.ApplyResult, error) {
+	push := make(map[string]protocol.RunParams, len(params.Specs))
+	for _, spec := range params.Specs {
+		if spec.Name == "" {
+			return protocol.ApplyResult{}, fmt.Errorf("every spec needs a name")
+		}
+		if spec.Namespace == "" {
+			spec.Namespace = params.Namespace
+		}
+		name := protocol.QualifiedName(spec.Namespace, spec.Name)
+		if _, dup := push[name]; dup {
+			return protocol.ApplyResult{}, fmt.Errorf("%s is specified twice", name)
+		}
+		push[name] = spec
+	}
+
+	d.desiredMu.Lock()
+	var drop []string
+	for _, name := range params.Delete {
+		if !strings.Contains(name, "/") {
+			name = protocol.QualifiedName(params.Namespace, name)
+		}
+		if _, ok := d.applied[name]; !ok {
+			if _, ok := push[name]; !ok {
+				d.desiredMu.Unlock()
+				return protocol.ApplyResult{}, fmt.Errorf("no spec %q has been applied", name)
+			}
+		}
+		drop = append(drop, name)
+	}
+	for name, spec := range push {
+		d.applied[name] = newDesiredSpec(spec)
+	}
+	for _, name := range drop {
+		delete(d.applied, name)
+	}
+	d.desiredMu.Unlock()
+
+	return protocol.ApplyResult{Actions: d.reconcileDesired()}, nil
+}
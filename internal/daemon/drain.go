@@ -0,0 +1,139 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+// resumeHookTimeout bounds a DrainHooks.Resume command.
+const resumeHookTimeout = 30 * time.Second
+
+// FreezeDrained stops name taking new work with its Stop hook, waits for
+// an inference server's in-flight requests to finish, and then freezes it.
+// If the hook fails or requests are still in flight after timeout (zero:
+// the inference server's drain timeout), the process is resumed and left
+// running.
+func (d *Daemon) FreezeDrained(name string, timeout time.Duration) (protocol.FreezeResult, error) {
+	d.mu.RLock()
+	p, ok := d.procs[name]
+	var hooks protocol.DrainHooks
+	var srv *protocol.InferenceServer
+	if ok {
+		if p.params.DrainHooks != nil {
+			hooks = *p.params.DrainHooks
+		}
+		srv = p.params.Inference
+	}
+	d.mu.RUnlock()
+	switch {
+	case !ok:
+		return protocol.FreezeResult{}, errorf(protocol.CodeNotFound, "process %q not found", name)
+	case p.State != protocol.StateActive:
+		return protocol.FreezeResult{}, fmt.Errorf("process %q is %s, not active", name, p.State)
+	case len(hooks.Stop) == 0 && srv == nil:
+		return protocol.FreezeResult{}, fmt.Errorf("process %q has no drain hooks and is not an inference server, so it can't be drained", name)
+	}
+	if timeout <= 0 {
+		timeout = defaultInferenceDrain
+		if srv != nil {
+			timeout = inferenceDrainTimeout(*srv)
+		}
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if len(hooks.Stop) > 0 {
+		if err := runHook(ctx, hooks.Stop, p); err != nil {
+			return protocol.FreezeResult{}, d.drainFailed(p, hooks, fmt.Errorf("stop hook: %w", err))
+		}
+	}
+	if srv != nil {
+		left, err := waitDrained(ctx, *srv)
+		if err == nil && left > 0 {
+			err = fmt.Errorf("%.0f requests still in flight after %s", left, timeout)
+		}
+		if err != nil {
+			return protocol.FreezeResult{}, d.drainFailed(p, hooks, err)
+		}
+	}
+	drained := time.Since(start)
+	d.log.Printf("DRAIN %s done in %dms", name, drained.Milliseconds())
+
+	res, err := d.freeze(name, protocol.CauseUser, "drained")
+	if err != nil {
+		d.resume(p, hooks)
+		return res, err
+	}
+	res.DrainMs = drained.Milliseconds()
+	d.mu.Lock()
+	p.drained = len(hooks.Resume) > 0
+	d.mu.Unlock()
+	return res, nil
+}
+
+// drainFailed reports a drain that didn't finish, resumes the process,
+// and returns the error for the caller.
+func (d *Daemon) drainFailed(p *Proc, hooks protocol.DrainHooks, err error) error {
+	d.mu.Lock()
+	d.emit(protocol.Event{Type: "drain-failed", Process: p.Name, Detail: err.Error() + " — not freezing"})
+	d.mu.Unlock()
+	d.resume(p, hooks)
+	return fmt.Errorf("drain %s: %w; not frozen", p.Name, err)
+}
+
+// resumeDrained runs the Resume hook of a process thawed after a draining
+// freeze.
+func (d *Daemon) resumeDrained(name string) {
+	d.mu.Lock()
+	p, ok := d.procs[name]
+	if !ok || !p.drained {
+		d.mu.Unlock()
+		return
+	}
+	p.drained = false
+	hooks := *p.params.DrainHooks
+	d.mu.Unlock()
+	d.resume(p, hooks)
+}
+
+// resume runs hooks.Resume, if any, reporting a failure as an event.
+func (d *Daemon) resume(p *Proc, hooks protocol.DrainHooks) {
+	if len(hooks.Resume) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resumeHookTimeout)
+	defer cancel()
+	err := runHook(ctx, hooks.Resume, p)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		d.emit(protocol.Event{Type: "resume-failed", Process: p.Name, Detail: err.Error()})
+		d.log.Printf("RESUME %s failed: %v", p.Name, err)
+		return
+	}
+	d.log.Printf("RESUME %s", p.Name)
+}
+
+// runHook runs a drain hook command for p until it exits or ctx ends.
+func runHook(ctx context.Context, argv []string, p *Proc) error {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = append(withoutToken(os.Environ()), "GPUSCHED_NAME="+p.Name, "GPUSCHED_PID="+strconv.Itoa(p.PID))
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s: timed out", argv[0])
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s (%w)", argv[0], strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
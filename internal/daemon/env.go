@@ -0,0 +1,129 @@
+package daemon
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/user"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gpusched/internal/protocol"
+)
+
+// envTemplateData is what Set values can refer to, e.g.
+// "{{.Home}}/.local/lib/python3.11/site-packages".
+type envTemplateData struct {
+	User      string // the caller, or the namespace if the caller is unknown
+	Home      string // the home directory of User, if it is a local user
+	Namespace string
+	Name      string
+	GPU       int
+}
+
+// mergeEnvPolicy layers a run's policy over the daemon's: a run's Inherit
+// list replaces the daemon's (only admins may send one), Deny lists add
+// up, and Set entries override by key.
+func mergeEnvPolicy(base protocol.EnvPolicy, run *protocol.EnvPolicy) protocol.EnvPolicy {
+	if run == nil {
+		return base
+	}
+	merged := protocol.EnvPolicy{
+		Inherit: base.Inherit,
+		Deny:    append(slices.Clone(base.Deny), run.Deny...),
+		Set:     maps.Clone(base.Set),
+	}
+	if len(run.Inherit) > 0 {
+		merged.Inherit = run.Inherit
+	}
+	if len(run.Set) > 0 && merged.Set == nil {
+		merged.Set = make(map[string]string, len(run.Set))
+	}
+	maps.Copy(merged.Set, run.Set)
+	return merged
+}
+
+// tokenEnv holds the daemon's TCP and peer token. It never reaches a
+// managed process or hook, whatever the policy says.
+const tokenEnv = "GPUSCHED_TOKEN"
+
+// withoutToken returns env less tokenEnv.
+func withoutToken(env []string) []string {
+	return slices.DeleteFunc(slices.Clone(env), func(kv string) bool {
+		return strings.HasPrefix(kv, tokenEnv+"=")
+	})
+}
+
+// matchAny reports whether key matches one of the glob patterns.
+func matchAny(patterns []string, key string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// processEnv builds a managed process's environment from the daemon's own,
+// less tokenEnv, under the daemon's policy merged with the run's:
+// variables matching the Inherit patterns (all, if there are none) and no
+// Deny pattern, then the Set additions. The daemon's Set values are
+// rendered as templates; the run's are taken literally. The GPUSCHED_
+// variables and CUDA_VISIBLE_DEVICES are appended last and always win.
+func processEnv(base []string, daemon protocol.EnvPolicy, run *protocol.EnvPolicy, params protocol.RunParams, name string) ([]string, error) {
+	policy := mergeEnvPolicy(daemon, run)
+	var env []string
+	for _, kv := range withoutToken(base) {
+		key, _, _ := strings.Cut(kv, "=")
+		if len(policy.Inherit) > 0 && !matchAny(policy.Inherit, key) {
+			continue
+		}
+		if matchAny(policy.Deny, key) {
+			continue
+		}
+		env = append(env, kv)
+	}
+
+	// The namespace is only a stand-in for the user when the caller is
+	// unknown: anyone may run in any namespace.
+	data := envTemplateData{User: params.Namespace, Namespace: params.Namespace, Name: params.Name, GPU: params.GPU}
+	var u *user.User
+	var err error
+	if params.UID != nil {
+		data.User = userName(*params.UID)
+		u, err = user.LookupId(strconv.Itoa(*params.UID))
+	} else {
+		u, err = user.Lookup(params.Namespace)
+	}
+	if err == nil {
+		data.Home = u.HomeDir
+	}
+	keys := slices.Sorted(maps.Keys(policy.Set))
+	for _, key := range keys {
+		if run != nil {
+			if v, ok := run.Set[key]; ok {
+				env = append(env, key+"="+v)
+				continue
+			}
+		}
+		tmpl, err := template.New(key).Option("missingkey=error").Parse(policy.Set[key])
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", key, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("env %s: %w", key, err)
+		}
+		env = append(env, key+"="+b.String())
+	}
+
+	return append(env,
+		"GPUSCHED_MANAGED=1",
+		"GPUSCHED_NAME="+name,
+		fmt.Sprintf("GPUSCHED_DAEMON_PID=%d", os.Getpid()),
+		fmt.Sprintf("CUDA_VISIBLE_DEVICES=%d", params.GPU),
+	), nil
+}
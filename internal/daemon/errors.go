@@ -0,0 +1,36 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+
+	"gpusched/internal/protocol"
+)
+
+// codedError is an error of a known kind (a protocol.Code), which the
+// REST and gRPC APIs map to a status without reading the message.
+type codedError struct {
+	code string
+	msg  string
+}
+
+func (e *codedError) Error() string { return e.msg }
+
+// errorf formats an error of kind code.
+func errorf(code, format string, args ...interface{}) error {
+	return &codedError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// errorCode returns err's kind, or "" if it has none.
+func errorCode(err error) string {
+	var ce *codedError
+	if errors.As(err, &ce) {
+		return ce.code
+	}
+	return ""
+}
+
+// errResponse reports err, with its kind if it has one.
+func errResponse(err error) protocol.Response {
+	return protocol.ErrCodeResponse(errorCode(err), err.Error())
+}
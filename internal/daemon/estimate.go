@@ -0,0 +1,130 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+// estimateWindow is how far back throughput history is considered.
+const estimateWindow = 30 * 24 * time.Hour
+
+// opThroughput returns the average MB/s and count of recent op records.
+func (d *Daemon) opThroughput(op string) (float64, int) {
+	res := d.history.query(protocol.OpsHistoryParams{Op: op, Since: time.Now().Add(-estimateWindow)})
+	var totalMB int64
+	var totalMs int64
+	var count int
+	for _, r := range res.Records {
+		if r.MemMB <= 0 || r.DurationMs <= 0 {
+			continue
+		}
+		totalMB += r.MemMB
+		totalMs += r.DurationMs
+		count++
+	}
+	if totalMs == 0 {
+		return 0, 0
+	}
+	return float64(totalMB) / (float64(totalMs) / 1000), count
+}
+
+// migrateEstimate predicts the downtime of migrating memMB of GPU state:
+// from past migrations if there are any, else from freeze plus thaw
+// throughput, since a migration is a checkpoint and a restore.
+func (d *Daemon) migrateEstimate(memMB int64) protocol.Estimate {
+	if memMB <= 0 {
+		return protocol.Estimate{Basis: "process GPU memory unknown"}
+	}
+	if mbps, n := d.opThroughput("migrate"); n > 0 {
+		return protocol.Estimate{
+			Ms:    transferMs(memMB, mbps),
+			Basis: fmt.Sprintf("%d past migrations at %.0f MB/s", n, mbps),
+		}
+	}
+	freeze, nf := d.opThroughput("freeze")
+	thaw, nt := d.opThroughput("thaw")
+	if nf > 0 && nt > 0 {
+		return protocol.Estimate{
+			Ms:    transferMs(memMB, freeze) + transferMs(memMB, thaw),
+			Basis: fmt.Sprintf("freeze at %.0f MB/s + thaw at %.0f MB/s", freeze, thaw),
+		}
+	}
+	return protocol.Estimate{Basis: "no throughput history"}
+}
+
+// thawWindow is how many of a process's own thaws its estimate uses.
+const thawWindow = 5
+
+// thawSample is one thaw's duration and the tier it restored from.
+type thawSample struct {
+	ms   int64
+	tier protocol.Tier
+}
+
+func (p *Proc) recordThaw(ms int64, tier protocol.Tier) {
+	p.thaws = append(p.thaws, thawSample{ms, tier})
+	if len(p.thaws) > thawWindow {
+		p.thaws = p.thaws[len(p.thaws)-thawWindow:]
+	}
+}
+
+// thawEstimate predicts how long thawing p from tier takes, preferring its
+// own recent thaws from the same tier. Thaws from plain RAM plus the swap-in
+// penalty stand in for a swapped tier with no samples of its own; with no
+// thaws at all, the host's thaw throughput is used.
+func (d *Daemon) thawEstimate(p *Proc, tier protocol.Tier, penaltyMs int64) protocol.Estimate {
+	avg := func(tier protocol.Tier) (int64, int) {
+		var total int64
+		var n int
+		for _, t := range p.thaws {
+			if t.tier == tier {
+				total += t.ms
+				n++
+			}
+		}
+		if n == 0 {
+			return 0, 0
+		}
+		return total / int64(n), n
+	}
+	if ms, n := avg(tier); n > 0 {
+		return protocol.Estimate{Ms: ms, Basis: fmt.Sprintf("last %d thaws of this process", n)}
+	}
+	if ms, n := avg(protocol.TierRAM); n > 0 {
+		return protocol.Estimate{
+			Ms:    ms + penaltyMs,
+			Basis: fmt.Sprintf("last %d thaws of this process + swap-in", n),
+		}
+	}
+	if ms := d.opEstimateMs("thaw", p.MemMB); ms > 0 {
+		basis := "host thaw throughput"
+		if penaltyMs > 0 {
+			basis += " + swap-in"
+		}
+		return protocol.Estimate{Ms: ms + penaltyMs, Basis: basis}
+	}
+	return protocol.Estimate{Basis: "no thaw history"}
+}
+
+// opEstimateMs predicts how long op takes for memMB, or 0 without history.
+func (d *Daemon) opEstimateMs(op string, memMB int64) int64 {
+	if op == "migrate" {
+		return d.migrateEstimate(memMB).Ms
+	}
+	mbps, n := d.opThroughput(op)
+	if n == 0 || memMB <= 0 {
+		return 0
+	}
+	return transferMs(memMB, mbps)
+}
+
+func transferMs(memMB int64, mbps float64) int64 {
+	return int64(float64(memMB) / mbps * 1000)
+}
+
+// msDuration formats milliseconds for messages.
+func msDuration(ms int64) time.Duration {
+	return (time.Duration(ms) * time.Millisecond).Round(100 * time.Millisecond)
+}
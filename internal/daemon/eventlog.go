@@ -0,0 +1,229 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"gpusched/internal/protocol"
+)
+
+// activeRequest is a request acting on a process: its ID and the login
+// name of its caller, if known.
+type activeRequest struct {
+	id   string
+	user string
+}
+
+// eventLog is a JSON-lines file of every event, so events outlive the
+// in-memory ring and daemon restarts. Events are queued by add, which is
+// called under d.mu, and written by writeLoop, so emitting never waits on
+// the disk. Past maxBytes the file is rotated like a process log, keeping
+// keep older generations.
+type eventLog struct {
+	path     string
+	maxBytes int64
+	keep     int
+	log      *log.Logger
+
+	// mu guards pending, the events not written yet, and closed, which
+	// stops more being queued; kick wakes writeLoop.
+	mu      sync.Mutex
+	pending []protocol.Event
+	closed  bool
+	kick    chan struct{}
+
+	// wmu serializes writes and rotation; size is the file's size, or
+	// -1 until it is known.
+	wmu  sync.Mutex
+	size int64
+}
+
+func newEventLog(path string, maxMB int64, keep int, logger *log.Logger) *eventLog {
+	return &eventLog{path: path, maxBytes: maxMB << 20, keep: keep, log: logger, kick: make(chan struct{}, 1), size: -1}
+}
+
+// add queues e to be written, unless the log is closed.
+func (l *eventLog) add(e protocol.Event) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	l.pending = append(l.pending, e)
+	l.mu.Unlock()
+	select {
+	case l.kick <- struct{}{}:
+	default:
+	}
+}
+
+// writeLoop writes queued events as they come until done is closed, then
+// writes what is left.
+func (l *eventLog) writeLoop(done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			l.flush()
+			return
+		case <-l.kick:
+		}
+		l.flush()
+	}
+}
+
+// close writes the queued events and stops any more being written.
+func (l *eventLog) close() {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+	l.flush()
+}
+
+// flush writes the queued events, then rotates the file if it has grown
+// past maxBytes.
+func (l *eventLog) flush() {
+	l.wmu.Lock()
+	defer l.wmu.Unlock()
+	l.mu.Lock()
+	batch := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	var buf []byte
+	for _, e := range batch {
+		data, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		l.log.Printf("event log: %v", err)
+		return
+	}
+	if l.size < 0 {
+		if fi, err := f.Stat(); err == nil {
+			l.size = fi.Size()
+		}
+	}
+	n, err := f.Write(buf)
+	f.Close()
+	l.size += int64(n)
+	if err != nil {
+		l.log.Printf("event log: %v", err)
+		return
+	}
+
+	if l.maxBytes <= 0 || l.size <= l.maxBytes {
+		return
+	}
+	if err := l.rotate(); err != nil {
+		l.log.Printf("event log: rotating: %v", err)
+		return
+	}
+	l.size = 0
+}
+
+// rotate shifts the rotated generations up by one, dropping any beyond
+// keep, and moves the file to generation 1. Caller must hold l.wmu.
+func (l *eventLog) rotate() error {
+	if l.keep <= 0 {
+		return os.Remove(l.path)
+	}
+	os.Remove(rotatedLog(l.path, l.keep))
+	for n := l.keep - 1; n >= 1; n-- {
+		if err := os.Rename(rotatedLog(l.path, n), rotatedLog(l.path, n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(l.path, rotatedLog(l.path, 1))
+}
+
+// open opens the rotated generations, oldest first, then the file itself.
+// Writes and rotation wait only while the files are opened, not while
+// they are read.
+func (l *eventLog) open() ([]*os.File, error) {
+	l.wmu.Lock()
+	defer l.wmu.Unlock()
+	var files []*os.File
+	for n := 1; ; n++ {
+		f, err := os.Open(rotatedLog(l.path, n))
+		if err != nil {
+			break
+		}
+		files = append([]*os.File{f}, files...)
+	}
+	f, err := os.Open(l.path)
+	if err != nil && !os.IsNotExist(err) {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, err
+	}
+	if err == nil {
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// query scans the log for events matching p, oldest first.
+func (l *eventLog) query(p protocol.EventsParams) (protocol.EventsResult, error) {
+	l.flush()
+
+	res := protocol.EventsResult{Events: []protocol.Event{}}
+	files, err := l.open()
+	if err != nil {
+		return res, fmt.Errorf("reading event log: %w", err)
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, f := range files {
+		r := bufio.NewReader(f)
+		for {
+			// Lines have no length limit; one being written as it is
+			// read fails to parse and is passed over.
+			line, err := r.ReadBytes('\n')
+			var e protocol.Event
+			if len(line) > 0 && json.Unmarshal(line, &e) == nil && matchEvent(e, p) {
+				res.Events = append(res.Events, e)
+				if p.Limit > 0 && len(res.Events) > 2*p.Limit {
+					res.Events = append(res.Events[:0], res.Events[len(res.Events)-p.Limit:]...)
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return res, fmt.Errorf("reading event log: %w", err)
+			}
+		}
+	}
+	if p.Limit > 0 && len(res.Events) > p.Limit {
+		res.Events = res.Events[len(res.Events)-p.Limit:]
+	}
+	return res, nil
+}
+
+func matchEvent(e protocol.Event, p protocol.EventsParams) bool {
+	switch {
+	case e.Time.Before(p.Since),
+		p.Type != "" && e.Type != p.Type,
+		p.Process != "" && e.Process != p.Process,
+		e.Process != "" && !inNamespace(e.Process, p.Namespace):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"runtime"
+
+	"gpusched/internal/protocol"
+)
+
+const defaultEventRingSize = 1000
+
+// retainEvent appends e to the in-memory ring, evicting the oldest event of
+// the same type when that type is at its limit and the oldest event overall
+// when the ring is full. Caller must hold d.mu.
+func (d *Daemon) retainEvent(e protocol.Event) {
+	if limit, ok := d.cfg.EventTypeLimits[e.Type]; ok {
+		if limit <= 0 {
+			return
+		}
+		count, oldest := 0, -1
+		for i, ev := range d.events {
+			if ev.Type == e.Type {
+				if oldest < 0 {
+					oldest = i
+				}
+				count++
+			}
+		}
+		if count >= limit {
+			d.events = append(d.events[:oldest], d.events[oldest+1:]...)
+		}
+	}
+
+	d.events = append(d.events, e)
+	if over := len(d.events) - d.cfg.EventRingSize; over > 0 {
+		n := copy(d.events, d.events[over:])
+		d.events = d.events[:n]
+	}
+}
+
+// Debug reports daemon internals for troubleshooting.
+func (d *Daemon) Debug() protocol.DebugInfo {
+	d.mu.RLock()
+	byType := make(map[string]int)
+	for _, e := range d.events {
+		byType[e.Type]++
+	}
+	ring := protocol.EventRingInfo{
+		Capacity:   d.cfg.EventRingSize,
+		Len:        len(d.events),
+		ByType:     byType,
+		TypeLimits: d.cfg.EventTypeLimits,
+	}
+	procs := len(d.procs)
+	d.mu.RUnlock()
+
+	return protocol.DebugInfo{
+		EventRing:   ring,
+		Subscribers: d.metricsSnapshot().Subscribers,
+		Processes:   procs,
+		Goroutines:  runtime.NumGoroutine(),
+	}
+}
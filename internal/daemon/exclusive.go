@@ -0,0 +1,75 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gpusched/internal/protocol"
+)
+
+// An exclusive process cordons its GPU while it is active: nothing else
+// managed may be started, thawed, or migrated onto it. Frozen and dead
+// exclusive processes hold no reservation, and an exclusive process can
+// only become active on a GPU no other managed process is active on.
+
+// exclusiveHolder returns the active exclusive process on gpu other than
+// self, or nil. Caller must hold d.mu.
+func (d *Daemon) exclusiveHolder(gpu int, self *Proc) *Proc {
+	for _, p := range d.procs {
+		if p != self && p.params.Exclusive && p.State == protocol.StateActive && p.GPU == gpu {
+			return p
+		}
+	}
+	return nil
+}
+
+// coTenants returns the names of active processes on gpu other than self.
+// Caller must hold d.mu.
+func (d *Daemon) coTenants(gpu int, self *Proc) []string {
+	var names []string
+	for _, p := range d.procs {
+		if p != self && p.State == protocol.StateActive && p.GPU == gpu {
+			names = append(names, p.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkExclusive reports why a process (self, or nil for a new one) can't
+// become active on gpu. Caller must hold d.mu.
+func (d *Daemon) checkExclusive(gpu int, exclusive bool, self *Proc) error {
+	if h := d.exclusiveHolder(gpu, self); h != nil {
+		return fmt.Errorf("GPU %d is reserved by exclusive process %q", gpu, h.Name)
+	}
+	if exclusive {
+		if others := d.coTenants(gpu, self); len(others) > 0 {
+			return fmt.Errorf("GPU %d is in use by %s; an exclusive process needs it to itself",
+				gpu, strings.Join(others, ", "))
+		}
+	}
+	return nil
+}
+
+// cordonedGPUs returns the GPUs placement must skip for self (nil for a
+// new process). Caller must hold d.mu.
+func (d *Daemon) cordonedGPUs(exclusive bool, self *Proc) map[int]bool {
+	cordoned := make(map[int]bool)
+	for _, p := range d.procs {
+		if p != self && p.State == protocol.StateActive && (exclusive || p.params.Exclusive) {
+			cordoned[p.GPU] = true
+		}
+	}
+	return cordoned
+}
+
+func withoutCordoned(gpus []protocol.GPUInfo, cordoned map[int]bool) []protocol.GPUInfo {
+	var open []protocol.GPUInfo
+	for _, g := range gpus {
+		if !cordoned[g.Index] {
+			open = append(open, g)
+		}
+	}
+	return open
+}
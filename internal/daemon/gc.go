@@ -0,0 +1,118 @@
+package daemon
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gpusched/internal/bytesize"
+	"gpusched/internal/protocol"
+)
+
+// defaultGCRetention is how long gc leaves exited processes and their
+// logs alone.
+const defaultGCRetention = 24 * time.Hour
+
+// logName is the qualified name of the process whose log, or rotated
+// generation of it, is at rel under the log directory, or "" if rel is not
+// a process log.
+func logName(rel string) string {
+	if i := strings.LastIndex(rel, ".log."); i >= 0 {
+		if _, err := strconv.Atoi(rel[i+len(".log."):]); err == nil {
+			rel = rel[:i+len(".log")]
+		}
+	}
+	name, ok := strings.CutSuffix(filepath.ToSlash(rel), ".log")
+	if !ok {
+		return ""
+	}
+	return name
+}
+
+// GC finds processes that exited longer than the retention ago, and logs
+// untouched for as long that belong to no process the daemon still has.
+// With params.Apply it removes them.
+func (d *Daemon) GC(params protocol.GCParams) (protocol.GCResult, error) {
+	retention := time.Duration(params.RetentionMs) * time.Millisecond
+	if retention <= 0 {
+		retention = defaultGCRetention
+	}
+	now := time.Now()
+	procs := protocol.GCCategory{Name: protocol.GCProcesses, Items: []protocol.GCItem{}}
+	logs := protocol.GCCategory{Name: protocol.GCLogs, Items: []protocol.GCItem{}}
+
+	d.mu.Lock()
+	kept := make(map[string]bool, len(d.procs))
+	for name, p := range d.procs {
+		if p.State != protocol.StateDead || p.exitedAt.IsZero() || !p.nextRestart.IsZero() || now.Sub(p.exitedAt) < retention {
+			kept[name] = true
+			continue
+		}
+		procs.Items = append(procs.Items, protocol.GCItem{Name: name})
+		if params.Apply {
+			delete(d.procs, name)
+		}
+	}
+	d.mu.Unlock()
+
+	dirs := make(map[string]bool)
+	err := filepath.WalkDir(d.cfg.LogDir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !e.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(d.cfg.LogDir, path)
+		if err != nil {
+			return err
+		}
+		name := logName(rel)
+		if name == "" || kept[name] {
+			return nil
+		}
+		info, err := e.Info()
+		if err != nil || now.Sub(info.ModTime()) < retention {
+			return nil
+		}
+		item := protocol.GCItem{Name: path, Bytes: info.Size()}
+		if params.Apply {
+			if err := os.Remove(path); err != nil {
+				item.Error = err.Error()
+			}
+			dirs[filepath.Dir(path)] = true
+		}
+		if item.Error == "" {
+			logs.Bytes += item.Bytes
+		}
+		logs.Items = append(logs.Items, item)
+		return nil
+	})
+	if err != nil {
+		return protocol.GCResult{}, fmt.Errorf("scanning logs: %w", err)
+	}
+	// Namespaces' log directories go once they are empty.
+	for dir := range dirs {
+		if dir != d.cfg.LogDir {
+			os.Remove(dir)
+		}
+	}
+
+	sort.Slice(procs.Items, func(i, j int) bool { return procs.Items[i].Name < procs.Items[j].Name })
+	if params.Apply && len(procs.Items)+len(logs.Items) > 0 {
+		detail := fmt.Sprintf("removed %d exited processes and %d logs (%s)", len(procs.Items), len(logs.Items), bytesize.Format(logs.Bytes))
+		d.mu.Lock()
+		d.emit(protocol.Event{Type: "gc", Detail: detail})
+		d.mu.Unlock()
+		d.log.Printf("GC: %s", detail)
+	}
+	return protocol.GCResult{Applied: params.Apply, Categories: []protocol.GCCategory{logs, procs}}, nil
+}
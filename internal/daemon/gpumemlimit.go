@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+
+	"gpusched/internal/bytesize"
+	"gpusched/internal/protocol"
+
+	"golang.org/x/sys/unix"
+)
+
+// gpuMemLimitSignal resolves the signal a process over its GPU memory
+// limit gets under GPUMemLimitSignal, given with or without "SIG";
+// SIGTERM if unset.
+func gpuMemLimitSignal(name string) (syscall.Signal, error) {
+	if name == "" {
+		return syscall.SIGTERM, nil
+	}
+	sig := unix.SignalNum("SIG" + strings.TrimPrefix(strings.ToUpper(name), "SIG"))
+	if sig == 0 {
+		return 0, fmt.Errorf("unknown signal %q", name)
+	}
+	return sig, nil
+}
+
+func validGPUMemLimit(params protocol.RunParams) error {
+	if params.GPUMemLimitMB < 0 {
+		return fmt.Errorf("GPU memory limit must not be negative")
+	}
+	switch params.GPUMemLimitAction {
+	case "", protocol.LimitKill, protocol.LimitFreeze, protocol.LimitSignal:
+	default:
+		return fmt.Errorf("unknown GPU memory limit action %q", params.GPUMemLimitAction)
+	}
+	if _, err := gpuMemLimitSignal(params.GPUMemLimitSignal); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkGPUMemLimits acts on active processes using more GPU memory than
+// their GPUMemLimitMB, per apps (MB by PID); a torchrun job's usage is its
+// workers'. A process is acted on once each time it goes over; signalled
+// processes that stay over aren't signalled again until they have come
+// back under.
+func (d *Daemon) checkGPUMemLimits(apps map[int]int64) {
+	type over struct {
+		name, detail string
+	}
+	var freeze []over
+
+	d.mu.Lock()
+	for name, p := range d.procs {
+		limit := p.params.GPUMemLimitMB
+		if limit <= 0 || p.State != protocol.StateActive {
+			continue
+		}
+		used := procGPUMemIn(p, apps)
+		if used <= limit {
+			p.overGPUMemLimit = false
+			continue
+		}
+		if p.overGPUMemLimit {
+			continue
+		}
+		p.overGPUMemLimit = true
+
+		action := p.params.GPUMemLimitAction
+		if action == "" {
+			action = protocol.LimitKill
+		}
+		detail := fmt.Sprintf("using %s of its %s GPU memory limit", bytesize.FormatMB(used), bytesize.FormatMB(limit))
+		d.metrics.GPUMemLimitViolations++
+		d.emit(protocol.Event{Type: "gpu-mem-limit", Process: name, Detail: fmt.Sprintf("%s → %s", detail, action)})
+		d.log.Printf("GPU MEM LIMIT %s: %s → %s", name, detail, action)
+
+		switch action {
+		case protocol.LimitKill:
+			// Workers are found through the agent, so before it dies.
+			var workers []int
+			if detectTorchrun(p.params.Cmd) != nil {
+				workers, _ = gpuWorkersIn(p.PID, apps)
+			}
+			syscall.Kill(p.PID, syscall.SIGKILL)
+			for _, w := range workers {
+				syscall.Kill(w, syscall.SIGKILL)
+			}
+		case protocol.LimitSignal:
+			sig, _ := gpuMemLimitSignal(p.params.GPUMemLimitSignal)
+			syscall.Kill(p.PID, sig)
+		case protocol.LimitFreeze:
+			freeze = append(freeze, over{name, detail})
+		}
+	}
+	d.mu.Unlock()
+
+	for _, o := range freeze {
+		if err := d.autoFreeze(o.name, protocol.CauseGPUMemLimit, o.detail); err != nil {
+			d.log.Printf("GPU MEM LIMIT %s: freeze failed: %v", o.name, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"gpusched/internal/protocol"
+)
+
+// groupMember is a process in a group as it was when a group method
+// started.
+type groupMember struct {
+	name  string
+	state protocol.ProcessState
+	gpu   int
+}
+
+// groupMembers returns the processes in group in namespace, by name.
+func (d *Daemon) groupMembers(namespace, group string) []groupMember {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var members []groupMember
+	for name, p := range d.procs {
+		if p.params.Group == group && p.params.Namespace == namespace {
+			members = append(members, groupMember{name, p.State, p.GPU})
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].name < members[j].name })
+	return members
+}
+
+// groupOp runs do on each member of params' group that act selects. If
+// one fails, undo (if any) puts back those already done, most recent
+// first, so the group ends up as it was.
+func (d *Daemon) groupOp(params protocol.GroupParams, act func(groupMember) bool, do, undo func(groupMember) error) (protocol.GroupResult, error) {
+	if params.Group == "" {
+		return protocol.GroupResult{}, fmt.Errorf("group is required")
+	}
+	members := d.groupMembers(params.Namespace, params.Group)
+	if len(members) == 0 {
+		return protocol.GroupResult{}, errorf(protocol.CodeNotFound, "group %q not found", params.Group)
+	}
+
+	res := protocol.GroupResult{Group: params.Group, Processes: []string{}}
+	var done []groupMember
+	for _, m := range members {
+		if !act(m) {
+			res.Skipped = append(res.Skipped, m.name)
+			continue
+		}
+		if err := do(m); err != nil {
+			for i := len(done) - 1; i >= 0 && undo != nil; i-- {
+				if err := undo(done[i]); err != nil {
+					d.log.Printf("GROUP %s: putting back %s: %v", params.Group, done[i].name, err)
+				}
+			}
+			return protocol.GroupResult{}, fmt.Errorf("%s: %w", m.name, err)
+		}
+		done = append(done, m)
+		res.Processes = append(res.Processes, m.name)
+	}
+	return res, nil
+}
+
+// GroupFreeze freezes the group's active members.
+func (d *Daemon) GroupFreeze(params protocol.GroupParams) (protocol.GroupResult, error) {
+	detail := "group " + params.Group
+	return d.groupOp(params,
+		func(m groupMember) bool { return m.state == protocol.StateActive },
+		func(m groupMember) error {
+			_, err := d.freeze(m.name, protocol.CauseUser, detail)
+			return err
+		},
+		func(m groupMember) error {
+			_, err := d.thawReady(m.name, protocol.CauseUser, detail+" freeze failed")
+			return err
+		})
+}
+
+// GroupThaw thaws the group's frozen members.
+func (d *Daemon) GroupThaw(params protocol.GroupParams) (protocol.GroupResult, error) {
+	detail := "group " + params.Group
+	return d.groupOp(params,
+		func(m groupMember) bool { return m.state == protocol.StateFrozen },
+		func(m groupMember) error {
+			_, err := d.thawReady(m.name, protocol.CauseUser, detail)
+			return err
+		},
+		func(m groupMember) error {
+			_, err := d.freeze(m.name, protocol.CauseUser, detail+" thaw failed")
+			return err
+		})
+}
+
+// GroupMigrate moves the group's live members to params.GPU. Migrating
+// restores a frozen process on its new GPU, so members that were frozen
+// are frozen again there, and again on the way back if the group is put
+// back.
+func (d *Daemon) GroupMigrate(params protocol.GroupParams) (protocol.GroupResult, error) {
+	detail := "group " + params.Group
+	migrate := func(m groupMember, gpu int) error {
+		_, base := protocol.SplitQualifiedName(m.name)
+		_, err := d.Migrate(protocol.MigrateParams{Namespace: params.Namespace, Name: base, GPU: gpu})
+		return err
+	}
+	refreeze := func(m groupMember, detail string) error {
+		if m.state != protocol.StateFrozen {
+			return nil
+		}
+		_, err := d.freeze(m.name, protocol.CauseUser, detail)
+		return err
+	}
+	undo := func(m groupMember) error {
+		if err := migrate(m, m.gpu); err != nil {
+			return err
+		}
+		return refreeze(m, detail+" migrate failed")
+	}
+	return d.groupOp(params,
+		func(m groupMember) bool { return m.state != protocol.StateDead && m.gpu != params.GPU },
+		func(m groupMember) error {
+			if err := migrate(m, params.GPU); err != nil {
+				return err
+			}
+			if err := refreeze(m, detail); err != nil {
+				// It has moved, so it goes back with the others.
+				if err := undo(m); err != nil {
+					d.log.Printf("GROUP %s: putting back %s: %v", params.Group, m.name, err)
+				}
+				return err
+			}
+			return nil
+		},
+		undo)
+}
+
+// GroupKill kills every member of the group, exited ones included. It
+// doesn't stop at a member that can't be killed, and reports every one
+// that couldn't be.
+func (d *Daemon) GroupKill(params protocol.GroupParams) (protocol.GroupResult, error) {
+	if params.Group == "" {
+		return protocol.GroupResult{}, fmt.Errorf("group is required")
+	}
+	members := d.groupMembers(params.Namespace, params.Group)
+	if len(members) == 0 {
+		return protocol.GroupResult{}, errorf(protocol.CodeNotFound, "group %q not found", params.Group)
+	}
+
+	res := protocol.GroupResult{Group: params.Group, Processes: []string{}}
+	var errs []error
+	for _, m := range members {
+		if err := d.Kill(m.name); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+			continue
+		}
+		res.Processes = append(res.Processes, m.name)
+	}
+	return res, errors.Join(errs...)
+}
@@ -0,0 +1,262 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+
+	"gpusched/api/gpuschedpb"
+	"gpusched/internal/protocol"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPCServer returns a gRPC server for the API in api/gpuschedpb. Calls go
+// through Handle, so they are counted, logged, and correlated by request
+// ID (the x-request-id metadata key) exactly like socket requests. If
+// token is set, every call must carry it as "authorization: Bearer TOKEN"
+// metadata. A readOnly server refuses calls that change anything.
+func (d *Daemon) GRPCServer(token string, readOnly bool) *grpc.Server {
+	check := func(ctx context.Context, method string) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		var got string
+		if auth := md.Get("authorization"); len(auth) > 0 {
+			got = bearerToken(auth[0])
+		}
+		if !tokenOK(got, token) {
+			d.log.Printf("REJECT gRPC %s: missing or wrong token", method)
+			return status.Error(codes.Unauthenticated, "unauthorized: missing or wrong token")
+		}
+		return nil
+	}
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := check(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := check(ss.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	gpuschedpb.RegisterGpuschedServer(s, &grpcAPI{d: d, readOnly: readOnly})
+	return s
+}
+
+type grpcAPI struct {
+	gpuschedpb.UnimplementedGpuschedServer
+	d        *Daemon
+	readOnly bool
+}
+
+// call runs method through Handle and decodes its result.
+func (g *grpcAPI) call(ctx context.Context, method string, params, result interface{}) error {
+	if g.readOnly {
+		if err := checkReadOnly(method); err != nil {
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	req := protocol.Request{Method: method, Params: raw}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get("x-request-id"); len(ids) > 0 {
+			req.ID = ids[0]
+		}
+	}
+	resp := g.d.Handle(req)
+	if !resp.OK {
+		return status.Error(grpcCode(resp.Code), resp.Error)
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// grpcCode maps a daemon error kind (Response.Code) to a status code.
+// Errors of no known kind are refusals in the daemon's current state.
+func grpcCode(code string) codes.Code {
+	switch code {
+	case protocol.CodeNotFound:
+		return codes.NotFound
+	case protocol.CodeAlreadyExists:
+		return codes.AlreadyExists
+	case protocol.CodeInvalid:
+		return codes.InvalidArgument
+	case protocol.CodePermissionDenied:
+		return codes.PermissionDenied
+	case protocol.CodeUnauthenticated:
+		return codes.Unauthenticated
+	}
+	return codes.FailedPrecondition
+}
+
+func (g *grpcAPI) Run(ctx context.Context, req *gpuschedpb.RunRequest) (*gpuschedpb.RunResponse, error) {
+	var r protocol.RunResult
+	err := g.call(ctx, "run", protocol.RunParams{
+		Namespace: req.Namespace,
+		Name:      req.Name,
+		Cmd:       req.Cmd,
+		Dir:       req.Dir,
+		GPU:       int(req.Gpu),
+		Shell:     req.Shell,
+		ExpandEnv: req.ExpandEnv,
+		AutoGPU:   req.AutoGpu,
+		Exclusive: req.Exclusive,
+	}, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &gpuschedpb.RunResponse{Name: r.Name, Pid: int32(r.PID), Gpu: int32(r.GPU)}, nil
+}
+
+func (g *grpcAPI) Freeze(ctx context.Context, req *gpuschedpb.NameRequest) (*gpuschedpb.FreezeResponse, error) {
+	var r protocol.FreezeResult
+	if err := g.call(ctx, "freeze", nameParams(req), &r); err != nil {
+		return nil, err
+	}
+	return &gpuschedpb.FreezeResponse{
+		Name:       r.Name,
+		DurationMs: r.DurationMs,
+		MemMb:      r.MemMB,
+		Phases:     pbPhases(r.Phases),
+	}, nil
+}
+
+func (g *grpcAPI) Thaw(ctx context.Context, req *gpuschedpb.NameRequest) (*gpuschedpb.ThawResponse, error) {
+	var r protocol.ThawResult
+	if err := g.call(ctx, "thaw", nameParams(req), &r); err != nil {
+		return nil, err
+	}
+	return &gpuschedpb.ThawResponse{
+		Name:       r.Name,
+		DurationMs: r.DurationMs,
+		MemMb:      r.MemMB,
+		Phases:     pbPhases(r.Phases),
+		Ready:      r.Ready,
+		ReadyMs:    r.ReadyMs,
+		ReadyError: r.ReadyError,
+	}, nil
+}
+
+func (g *grpcAPI) Migrate(ctx context.Context, req *gpuschedpb.MigrateRequest) (*gpuschedpb.MigrateResponse, error) {
+	var r protocol.MigrateResult
+	err := g.call(ctx, "migrate", protocol.MigrateParams{
+		Namespace:     req.Namespace,
+		Name:          req.Name,
+		GPU:           int(req.Gpu),
+		AutoGPU:       req.AutoGpu,
+		DryRun:        req.DryRun,
+		MaxDowntimeMs: req.MaxDowntimeMs,
+	}, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &gpuschedpb.MigrateResponse{
+		Name:     r.Name,
+		FromGpu:  int32(r.FromGPU),
+		ToGpu:    int32(r.ToGPU),
+		Estimate: &gpuschedpb.Estimate{Ms: r.Estimate.Ms, Basis: r.Estimate.Basis},
+		DryRun:   r.DryRun,
+		Warning:  r.Warning,
+	}, nil
+}
+
+func (g *grpcAPI) Status(ctx context.Context, req *gpuschedpb.StatusRequest) (*gpuschedpb.StatusResponse, error) {
+	var r protocol.StatusResult
+	if err := g.call(ctx, "status", protocol.StatusParams{Namespace: req.Namespace}, &r); err != nil {
+		return nil, err
+	}
+	out := &gpuschedpb.StatusResponse{
+		Memory: &gpuschedpb.Memory{
+			HostRamTotalMb:  r.Memory.HostRAMTotalMB,
+			HostRamFreeMb:   r.Memory.HostRAMFreeMB,
+			HostRamBudgetMb: r.Memory.HostRAMBudgetMB,
+			SnapshotsMb:     r.Memory.SnapshotsMB,
+		},
+	}
+	for _, gi := range r.GPUs {
+		out.Gpus = append(out.Gpus, &gpuschedpb.GPU{
+			Index:      int32(gi.Index),
+			Name:       gi.Name,
+			MemTotalMb: gi.MemTotal,
+			MemUsedMb:  gi.MemUsed,
+			MemFreeMb:  gi.MemFree,
+			UtilPct:    int32(gi.UtilPct),
+		})
+	}
+	for _, p := range r.Processes {
+		out.Processes = append(out.Processes, &gpuschedpb.Process{
+			Namespace: p.Namespace,
+			Name:      p.Name,
+			Pid:       int32(p.PID),
+			State:     string(p.State),
+			Gpu:       int32(p.GPU),
+			MemMb:     p.MemMB,
+			Started:   timestamppb.New(p.Started),
+			Tier:      string(p.Tier),
+			Command:   p.Command,
+			Exclusive: p.Exclusive,
+		})
+	}
+	return out, nil
+}
+
+// Events streams events as they are emitted. It does not replay history;
+// Status returns recent events for that.
+func (g *grpcAPI) Events(req *gpuschedpb.EventsRequest, stream gpuschedpb.Gpusched_EventsServer) error {
+	match := eventFilter(req.Namespace, req.Types)
+
+	ch := g.d.Subscribe()
+	defer g.d.Unsubscribe(ch)
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-ch:
+			if !ok {
+				return status.Error(codes.Unavailable, "event stream closed")
+			}
+			if !match(e) {
+				continue
+			}
+			if err := stream.Send(pbEvent(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func nameParams(req *gpuschedpb.NameRequest) protocol.NameParams {
+	return protocol.NameParams{Namespace: req.Namespace, Name: req.Name}
+}
+
+func pbPhases(phases []protocol.Phase) []*gpuschedpb.Phase {
+	out := make([]*gpuschedpb.Phase, len(phases))
+	for i, p := range phases {
+		out[i] = &gpuschedpb.Phase{Name: p.Name, DurationMs: p.DurationMs}
+	}
+	return out
+}
+
+func pbEvent(e protocol.Event) *gpuschedpb.Event {
+	return &gpuschedpb.Event{
+		Time:       timestamppb.New(e.Time),
+		Type:       e.Type,
+		Process:    e.Process,
+		Detail:     e.Detail,
+		DurationMs: e.Duration,
+		RequestId:  e.RequestID,
+		Cause:      e.Cause,
+	}
+}
@@ -0,0 +1,157 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gpusched/internal/audit"
+	"gpusched/internal/protocol"
+)
+
+// maxHistoryInMemory caps the records kept for queries; the file keeps all.
+const maxHistoryInMemory = 10000
+
+// opHistory is an append-only JSON-lines log of completed operations that
+// survives daemon restarts. When chained, each line is sealed onto the
+// previous one (see package audit) so edits to the file are detectable.
+type opHistory struct {
+	mu      sync.Mutex
+	path    string
+	chained bool
+	head    string
+	records []protocol.OpRecord
+}
+
+func openHistory(path string, chained bool) *opHistory {
+	h := &opHistory{path: path, chained: chained}
+	f, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var last []byte
+	for scanner.Scan() {
+		last = append(last[:0], scanner.Bytes()...)
+		var r protocol.OpRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		h.records = append(h.records, r)
+	}
+	h.head = audit.Head(last)
+	if len(h.records) > maxHistoryInMemory {
+		h.records = h.records[len(h.records)-maxHistoryInMemory:]
+	}
+	return h
+}
+
+func (h *opHistory) add(r protocol.OpRecord) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	if r.DurationMs > 0 {
+		r.MBps = float64(r.MemMB) / (float64(r.DurationMs) / 1000)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records = append(h.records, r)
+	if len(h.records) > 2*maxHistoryInMemory {
+		h.records = h.records[len(h.records)-maxHistoryInMemory:]
+	}
+
+	if h.path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if h.chained {
+		data, h.head = audit.Seal(h.head, data)
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func (h *opHistory) query(params protocol.OpsHistoryParams) protocol.OpsHistoryResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var matched []protocol.OpRecord
+	for _, r := range h.records {
+		if params.Process != "" && r.Process != params.Process {
+			continue
+		}
+		if params.Op != "" && r.Op != params.Op {
+			continue
+		}
+		if r.Time.Before(params.Since) {
+			continue
+		}
+		matched = append(matched, r)
+	}
+
+	stats := aggregateOps(matched)
+	if params.Limit > 0 && len(matched) > params.Limit {
+		matched = matched[len(matched)-params.Limit:]
+	}
+	return protocol.OpsHistoryResult{Records: matched, Stats: stats}
+}
+
+func aggregateOps(records []protocol.OpRecord) []protocol.OpStats {
+	type key struct {
+		op   string
+		tier protocol.Tier
+	}
+	byKey := make(map[key]*protocol.OpStats)
+	totalMs := make(map[key]int64)
+	var keys []key
+
+	for _, r := range records {
+		k := key{r.Op, r.Tier}
+		st, ok := byKey[k]
+		if !ok {
+			st = &protocol.OpStats{Op: r.Op, Tier: r.Tier}
+			byKey[k] = st
+			keys = append(keys, k)
+		}
+		st.Count++
+		st.TotalMB += r.MemMB
+		totalMs[k] += r.DurationMs
+		if r.MBps > st.PeakMBps {
+			st.PeakMBps = r.MBps
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].op != keys[j].op {
+			return keys[i].op < keys[j].op
+		}
+		return keys[i].tier < keys[j].tier
+	})
+
+	stats := make([]protocol.OpStats, 0, len(keys))
+	for _, k := range keys {
+		st := byKey[k]
+		st.AvgMs = totalMs[k] / int64(st.Count)
+		if totalMs[k] > 0 {
+			st.AvgMBps = float64(st.TotalMB) / (float64(totalMs[k]) / 1000)
+		}
+		stats = append(stats, *st)
+	}
+	return stats
+}
@@ -0,0 +1,254 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"gpusched/internal/protocol"
+
+	"google.golang.org/grpc/codes"
+)
+
+// httpRoute maps a REST endpoint onto a daemon method. Body and Result are
+// zero values of the protocol types involved; the OpenAPI schema is
+// generated from them.
+type httpRoute struct {
+	Method  string
+	Path    string
+	Summary string
+	RPC     string
+	Body    interface{}
+	Result  interface{}
+	// Field, if set, returns only this field of the method's result.
+	Field string
+}
+
+var httpRoutes = []httpRoute{
+	{Method: "GET", Path: "/v1/status", Summary: "GPUs, processes, host memory, and recent events", RPC: "status", Result: protocol.StatusResult{}},
+	{Method: "GET", Path: "/v1/processes", Summary: "List processes", RPC: "status", Result: []protocol.ProcessInfo{}, Field: "processes"},
+	{Method: "POST", Path: "/v1/processes", Summary: "Run a process", RPC: "run", Body: protocol.RunParams{}, Result: protocol.RunResult{}},
+	{Method: "GET", Path: "/v1/processes/{name}", Summary: "Describe a process", RPC: "describe", Result: protocol.ProcessInfo{}},
+	{Method: "DELETE", Path: "/v1/processes/{name}", Summary: "Kill a process", RPC: "kill"},
+	{Method: "POST", Path: "/v1/processes/{name}/freeze", Summary: "Freeze a process to host RAM", RPC: "freeze", Body: protocol.FreezeParams{}, Result: protocol.FreezeResult{}},
+	{Method: "POST", Path: "/v1/processes/{name}/thaw", Summary: "Restore a frozen process", RPC: "thaw", Result: protocol.ThawResult{}},
+	{Method: "POST", Path: "/v1/processes/{name}/park", Summary: "Checkpoint a process's GPU state to host RAM, leaving it running on CPU", RPC: "park", Result: protocol.FreezeResult{}},
+	{Method: "POST", Path: "/v1/processes/{name}/unpark", Summary: "Restore a parked process's GPU state", RPC: "unpark", Result: protocol.ThawResult{}},
+	{Method: "POST", Path: "/v1/processes/{name}/migrate", Summary: "Move a process to another GPU", RPC: "migrate", Body: protocol.MigrateParams{}, Result: protocol.MigrateResult{}},
+}
+
+// HTTPHandler serves the REST API: the routes in httpRoutes, an SSE event
+// stream at /v1/events, and the OpenAPI schema at /v1/openapi.json. Like
+// the gRPC API, calls go through Handle; X-Request-Id is used as the
+// request ID and echoed back. If token is set, every request must carry
+// it as "Authorization: Bearer TOKEN". A readOnly handler refuses routes
+// that change anything. Requests a web page could send behind the user's
+// back are refused: see checkBrowser.
+func (d *Daemon) HTTPHandler(token string, readOnly bool) http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range httpRoutes {
+		rt := rt
+		mux.HandleFunc(rt.Method+" "+rt.Path, func(w http.ResponseWriter, r *http.Request) {
+			if readOnly {
+				if err := checkReadOnly(rt.RPC); err != nil {
+					httpError(w, http.StatusForbidden, err.Error())
+					return
+				}
+			}
+			d.serveRoute(w, r, rt)
+		})
+	}
+	mux.HandleFunc("GET /v1/events", d.serveEvents)
+
+	schema, _ := json.MarshalIndent(openAPI(httpRoutes), "", "  ")
+	mux.HandleFunc("GET /v1/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(schema)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !tokenOK(bearerToken(r.Header.Get("Authorization")), token) {
+			d.log.Printf("REJECT %s %s from %s: missing or wrong token", r.Method, r.URL.Path, r.RemoteAddr)
+			httpError(w, http.StatusUnauthorized, "unauthorized: missing or wrong token")
+			return
+		}
+		if code, err := d.checkBrowser(r); err != nil {
+			httpError(w, code, err.Error())
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken returns the token of an "Authorization: Bearer TOKEN"
+// header value, or "" for any other.
+func bearerToken(header string) string {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+// checkBrowser refuses requests that web pages the operator has open
+// could make: any with a cross-site Origin, and any naming a Host other
+// than an IP address, localhost, NodeName, or one of HTTPHosts, which is
+// how DNS rebinding gets through. POST and DELETE must be JSON, which a
+// page can't send another site without a CORS preflight this API never
+// answers.
+func (d *Daemon) checkBrowser(r *http.Request) (int, error) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if net.ParseIP(host) == nil && !strings.EqualFold(host, "localhost") &&
+		!strings.EqualFold(host, d.cfg.NodeName) &&
+		!slices.ContainsFunc(d.cfg.HTTPHosts, func(h string) bool { return strings.EqualFold(h, host) }) {
+		return http.StatusForbidden, fmt.Errorf("unexpected host %q (daemon --http-host)", host)
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			return http.StatusForbidden, fmt.Errorf("cross-site request from %s refused", origin)
+		}
+	}
+	if r.Method == http.MethodPost || r.Method == http.MethodDelete {
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+			return http.StatusUnsupportedMediaType, fmt.Errorf("%s needs Content-Type: application/json", r.Method)
+		}
+	}
+	return 0, nil
+}
+
+// serveRoute builds method params from the JSON body, the {name} path
+// segment, and the namespace query parameter, in that order of precedence
+// (later wins).
+func (d *Daemon) serveRoute(w http.ResponseWriter, r *http.Request, rt httpRoute) {
+	params := make(map[string]interface{})
+	if rt.Body != nil {
+		data, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(strings.TrimSpace(string(data))) > 0 {
+			if err := json.Unmarshal(data, &params); err != nil {
+				httpError(w, http.StatusBadRequest, "bad body: "+err.Error())
+				return
+			}
+		}
+	}
+	if name := r.PathValue("name"); name != "" {
+		params["name"] = name
+	}
+	if ns := r.URL.Query().Get("namespace"); ns != "" {
+		params["namespace"] = ns
+	}
+	raw, _ := json.Marshal(params)
+
+	req := protocol.Request{ID: r.Header.Get("X-Request-Id"), Method: rt.RPC, Params: raw}
+	resp := d.Handle(req)
+	if resp.ID != "" {
+		w.Header().Set("X-Request-Id", resp.ID)
+	}
+	if !resp.OK {
+		httpError(w, httpStatus(resp.Code), resp.Error)
+		return
+	}
+	if rt.Result == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	result := resp.Result
+	if rt.Field != "" {
+		var fields map[string]json.RawMessage
+		json.Unmarshal(resp.Result, &fields)
+		result = fields[rt.Field]
+		if len(result) == 0 || string(result) == "null" {
+			result = json.RawMessage("[]")
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(result)
+	w.Write([]byte("\n"))
+}
+
+// serveEvents streams events as server-sent events until the client goes
+// away. ?namespace= and repeated ?type= filter them.
+func (d *Daemon) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	match := eventFilter(r.URL.Query().Get("namespace"), r.URL.Query()["type"])
+
+	ch := d.Subscribe()
+	defer d.Unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if !match(e) {
+				continue
+			}
+			data, _ := json.Marshal(e)
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// eventFilter matches events in namespace (events not tied to a process
+// always match) whose type is in types; empty types match all.
+func eventFilter(namespace string, types []string) func(protocol.Event) bool {
+	want := make(map[string]bool, len(types))
+	for _, t := range types {
+		want[t] = true
+	}
+	return func(e protocol.Event) bool {
+		if len(want) > 0 && !want[e.Type] {
+			return false
+		}
+		return e.Process == "" || inNamespace(e.Process, namespace)
+	}
+}
+
+func httpError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// httpStatus maps a daemon error kind the same way as grpcCode.
+func httpStatus(code string) int {
+	switch grpcCode(code) {
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	}
+	return http.StatusConflict
+}
@@ -0,0 +1,80 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"gpusched/internal/gpu"
+	"gpusched/internal/protocol"
+)
+
+// idleLoop freezes processes that hold GPU memory but have shown no SM
+// utilization for IdleFreezeAfter, e.g. notebook kernels left open
+// overnight. It runs until the daemon shuts down.
+func (d *Daemon) idleLoop() {
+	ticker := time.NewTicker(d.cfg.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+		util, err := gpu.ProcessUtilization()
+		if err != nil {
+			continue
+		}
+		d.markServing(time.Now())
+		d.mu.Lock()
+		names := d.idleProcs(util, time.Now())
+		d.mu.Unlock()
+
+		for _, name := range names {
+			reason := "no GPU utilization for " + formatDuration(d.cfg.IdleFreezeAfter)
+			if err := d.autoFreeze(name, protocol.CauseIdle, reason); err != nil {
+				d.log.Printf("IDLE %s: freeze failed: %v", name, err)
+			}
+		}
+	}
+}
+
+// idleProcs updates each process's last busy time from util (SM percent by
+// PID) and returns the active processes that have been idle on the GPU for
+// longer than IdleFreezeAfter. Processes absent from util hold no GPU
+// context and are never idle. Caller must hold d.mu.
+func (d *Daemon) idleProcs(util map[int]int, now time.Time) []string {
+	var names []string
+	for _, p := range d.procs {
+		sm, onGPU := util[p.PID]
+		if p.State != protocol.StateActive || !onGPU || sm > 0 || d.idleExempt(p) {
+			p.lastBusy = now
+			continue
+		}
+		since := p.lastBusy
+		if since.IsZero() {
+			since = p.Started
+		}
+		if now.Sub(since) < d.cfg.IdleFreezeAfter {
+			continue
+		}
+		p.lastBusy = now
+		d.emit(protocol.Event{
+			Type:    "idle",
+			Process: p.Name,
+			Detail:  fmt.Sprintf("no GPU utilization for %s — freezing", formatDuration(now.Sub(since))),
+		})
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func (d *Daemon) idleExempt(p *Proc) bool {
+	ns, _ := protocol.SplitQualifiedName(p.Name)
+	for _, exempt := range d.cfg.IdleExemptNamespaces {
+		if ns == exempt {
+			return true
+		}
+	}
+	return false
+}
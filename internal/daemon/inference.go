@@ -0,0 +1,154 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gpusched/internal/inference"
+	"gpusched/internal/protocol"
+)
+
+const (
+	defaultInferenceDrain = 30 * time.Second
+	drainPollInterval     = 500 * time.Millisecond
+
+	// Model loading can take minutes, so inference liveness tolerates
+	// five minutes of failed health checks before acting.
+	inferenceLivenessPeriodMs = 10_000
+	inferenceLivenessFailures = 30
+)
+
+// applyInferenceProfile validates params.Inference and fills in its URL
+// and any readiness or liveness probe the caller left unset.
+func applyInferenceProfile(params *protocol.RunParams) error {
+	srv := params.Inference
+	if srv == nil {
+		return nil
+	}
+	prof, ok := inference.Lookup(srv.Kind)
+	if !ok {
+		return fmt.Errorf("unknown inference server %q (want one of %s)", srv.Kind, strings.Join(inference.Kinds(), ", "))
+	}
+	copied := *srv
+	if copied.URL == "" {
+		copied.URL = prof.DefaultURL
+	}
+	copied.URL = strings.TrimRight(copied.URL, "/")
+	params.Inference = &copied
+
+	health := copied.URL + prof.Health
+	if params.Readiness == nil {
+		params.Readiness = &protocol.Probe{HTTP: health}
+	}
+	if params.Liveness == nil {
+		params.Liveness = &protocol.Probe{
+			HTTP:             health,
+			PeriodMs:         inferenceLivenessPeriodMs,
+			FailureThreshold: inferenceLivenessFailures,
+		}
+	}
+	return nil
+}
+
+// drainInference waits for an inference server's in-flight requests to
+// finish before it is frozen, up to its drain timeout. Freezing continues
+// either way; a drain that times out or can't read metrics is reported.
+func (d *Daemon) drainInference(name string) {
+	d.mu.RLock()
+	p, ok := d.procs[name]
+	var srv *protocol.InferenceServer
+	if ok && p.State == protocol.StateActive {
+		srv = p.params.Inference
+	}
+	d.mu.RUnlock()
+	if srv == nil {
+		return
+	}
+
+	timeout := inferenceDrainTimeout(*srv)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	left, err := waitDrained(ctx, *srv)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case err != nil:
+		d.emit(protocol.Event{Type: "drain-failed", Process: name, Detail: err.Error()})
+	case left > 0:
+		d.emit(protocol.Event{
+			Type:    "drain-failed",
+			Process: name,
+			Detail:  fmt.Sprintf("%.0f requests still in flight after %s — freezing anyway", left, timeout),
+		})
+	default:
+		if waited := time.Since(start); waited >= drainPollInterval {
+			d.log.Printf("DRAIN %s done in %dms", name, waited.Milliseconds())
+		}
+	}
+}
+
+// inferenceDrainTimeout is how long srv gets to finish its requests.
+func inferenceDrainTimeout(srv protocol.InferenceServer) time.Duration {
+	if srv.DrainTimeoutMs > 0 {
+		return time.Duration(srv.DrainTimeoutMs) * time.Millisecond
+	}
+	return defaultInferenceDrain
+}
+
+// waitDrained polls srv until it has no requests in flight or ctx ends,
+// returning how many were still in flight.
+func waitDrained(ctx context.Context, srv protocol.InferenceServer) (float64, error) {
+	var left float64
+	for {
+		load, err := inference.Scrape(ctx, srv.Kind, srv.URL)
+		switch {
+		case err != nil && ctx.Err() != nil && left > 0:
+			return left, nil
+		case err != nil:
+			return 0, err
+		case load.InFlight == 0:
+			return 0, nil
+		}
+		left = load.InFlight
+		select {
+		case <-ctx.Done():
+			return left, nil
+		case <-time.After(drainPollInterval):
+		}
+	}
+}
+
+// markServing refreshes the last busy time of inference servers that have
+// requests in flight or completed any since the previous check, so a
+// server handling light traffic isn't frozen for low GPU utilization.
+func (d *Daemon) markServing(now time.Time) {
+	type target struct {
+		p   *Proc
+		srv protocol.InferenceServer
+	}
+	var targets []target
+	d.mu.RLock()
+	for _, p := range d.procs {
+		if p.State == protocol.StateActive && p.params.Inference != nil {
+			targets = append(targets, target{p, *p.params.Inference})
+		}
+	}
+	d.mu.RUnlock()
+
+	for _, t := range targets {
+		load, err := inference.Scrape(context.Background(), t.srv.Kind, t.srv.URL)
+		if err != nil {
+			continue
+		}
+		d.mu.Lock()
+		if load.InFlight > 0 || load.Served > t.p.served {
+			t.p.lastBusy = now
+		}
+		t.p.served = load.Served
+		d.mu.Unlock()
+	}
+}
@@ -0,0 +1,131 @@
+package daemon
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"gpusched/internal/bytesize"
+	"gpusched/internal/gpu"
+	"gpusched/internal/protocol"
+)
+
+// defaultGPUPollInterval is how often GPUs are re-enumerated to refresh
+// the cached inventory and notice hotplug or MIG changes.
+const defaultGPUPollInterval = 5 * time.Second
+
+// gpuInventory is what nvidia-smi last reported, so status calls don't
+// each run it.
+type gpuInventory struct {
+	mu     sync.RWMutex
+	loaded bool
+	gpus   []protocol.GPUInfo
+	caps   map[int]protocol.GPUCapabilities
+	driver string
+}
+
+// cachedGPUs returns the inventory as of the last refresh.
+func (d *Daemon) cachedGPUs() ([]protocol.GPUInfo, map[int]protocol.GPUCapabilities, string) {
+	d.inv.mu.RLock()
+	defer d.inv.mu.RUnlock()
+	return slices.Clone(d.inv.gpus), maps.Clone(d.inv.caps), d.inv.driver
+}
+
+// refreshGPUs re-enumerates GPUs into the cache and emits gpu-added,
+// gpu-removed, and gpu-reconfigured for differences from the previous
+// enumeration. It returns the fresh list for callers, like placement, that
+// need current free memory. Caller must not hold d.mu.
+func (d *Daemon) refreshGPUs() ([]protocol.GPUInfo, error) {
+	gpus, err := gpu.QueryGPUs()
+	if err != nil {
+		return nil, err
+	}
+	caps, _ := gpu.QueryCapabilities(d.cuda.Available)
+	if servers, err := gpu.MPSServers(); err == nil {
+		for i := range gpus {
+			_, gpus[i].MPS = servers[gpus[i].Index]
+		}
+	}
+
+	d.inv.mu.Lock()
+	prev, prevCaps, first := d.inv.gpus, d.inv.caps, !d.inv.loaded
+	d.inv.gpus, d.inv.caps, d.inv.loaded = gpus, caps, true
+	changes := inventoryChanges(prev, gpus, prevCaps, caps)
+	if first || len(changes) > 0 {
+		d.inv.driver = gpu.DriverVersion()
+	}
+	d.inv.mu.Unlock()
+
+	if first || len(changes) == 0 {
+		return gpus, nil
+	}
+	d.mu.Lock()
+	d.gpuModels = nil
+	for _, e := range changes {
+		d.emit(e)
+		d.log.Printf("%s: %s", e.Type, e.Detail)
+	}
+	d.mu.Unlock()
+	return gpus, nil
+}
+
+// gpuLoop re-enumerates GPUs every GPUPollInterval until shutdown.
+func (d *Daemon) gpuLoop() {
+	ticker := time.NewTicker(d.cfg.GPUPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+		d.refreshGPUs()
+	}
+}
+
+// inventoryChanges compares two enumerations. GPUs are matched by UUID,
+// or by index when nvidia-smi doesn't report one, so a device replaced in
+// the same slot shows up as removed and added.
+func inventoryChanges(prev, cur []protocol.GPUInfo, prevCaps, curCaps map[int]protocol.GPUCapabilities) []protocol.Event {
+	key := func(g protocol.GPUInfo) string {
+		if g.UUID != "" {
+			return g.UUID
+		}
+		return fmt.Sprint(g.Index)
+	}
+	describe := func(g protocol.GPUInfo) string {
+		return fmt.Sprintf("GPU %d %s (%s)", g.Index, g.Name, bytesize.FormatMB(g.MemTotal))
+	}
+	before := make(map[string]protocol.GPUInfo, len(prev))
+	for _, g := range prev {
+		before[key(g)] = g
+	}
+	after := make(map[string]bool, len(cur))
+
+	var events []protocol.Event
+	for _, g := range cur {
+		after[key(g)] = true
+		old, ok := before[key(g)]
+		if !ok {
+			events = append(events, protocol.Event{Type: "gpu-added", Detail: describe(g)})
+			continue
+		}
+		was, hadCaps := prevCaps[old.Index]
+		now, hasCaps := curCaps[g.Index]
+		if hadCaps && hasCaps && was.MIG != now.MIG {
+			events = append(events, protocol.Event{
+				Type:   "gpu-reconfigured",
+				Detail: fmt.Sprintf("GPU %d MIG %s", g.Index, map[bool]string{true: "enabled", false: "disabled"}[now.MIG]),
+			})
+		}
+	}
+	for _, g := range prev {
+		if !after[key(g)] {
+			events = append(events, protocol.Event{Type: "gpu-removed", Detail: describe(g)})
+		}
+	}
+	return events
+}
@@ -0,0 +1,112 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gpusched/internal/gpu"
+	"gpusched/internal/probe"
+	"gpusched/internal/protocol"
+)
+
+const defaultFailureThreshold = 3
+
+// livenessLoop probes p while it is active and runs its liveness action
+// after FailureThreshold consecutive failures. It exits once p is no longer
+// the registered instance of its name or has died.
+func (d *Daemon) livenessLoop(p *Proc) {
+	lp := *p.params.Liveness
+	threshold := lp.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+
+	ticker := time.NewTicker(probe.Period(lp))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		d.mu.RLock()
+		current := d.procs[p.Name] == p
+		state := p.State
+		pid := p.PID
+		d.mu.RUnlock()
+
+		if !current || state == protocol.StateDead {
+			return
+		}
+		if state != protocol.StateActive {
+			continue
+		}
+
+		err := checkLiveness(lp, pid)
+
+		d.mu.Lock()
+		if d.procs[p.Name] != p || p.State != protocol.StateActive {
+			d.mu.Unlock()
+			continue
+		}
+		p.liveness.LastCheck = time.Now()
+		p.liveness.OK = err == nil
+		if err == nil {
+			p.liveness.ConsecutiveFailures = 0
+			p.liveness.Error = ""
+			d.mu.Unlock()
+			continue
+		}
+		p.liveness.ConsecutiveFailures++
+		p.liveness.Error = err.Error()
+		failures := p.liveness.ConsecutiveFailures
+		d.mu.Unlock()
+
+		if failures < threshold {
+			continue
+		}
+		d.onLivenessFailure(p, err, failures)
+	}
+}
+
+func checkLiveness(lp protocol.Probe, pid int) error {
+	if lp.GPUMem {
+		if gpu.ProcessGPUMem(pid) <= 0 {
+			return fmt.Errorf("no GPU memory held by pid %d", pid)
+		}
+		return nil
+	}
+	return probe.Check(context.Background(), lp)
+}
+
+func (d *Daemon) onLivenessFailure(p *Proc, err error, failures int) {
+	action := p.params.LivenessAction
+	if action == "" {
+		action = protocol.LivenessAlert
+	}
+	reason := fmt.Sprintf("liveness failed %d times: %v", failures, err)
+
+	d.mu.Lock()
+	d.emit(protocol.Event{Type: "liveness-failed", Process: p.Name, Detail: fmt.Sprintf("%s → %s", reason, action)})
+	d.log.Printf("LIVENESS %s: %s → %s", p.Name, reason, action)
+	p.liveness.ConsecutiveFailures = 0
+	d.mu.Unlock()
+
+	switch action {
+	case protocol.LivenessFreeze:
+		if ferr := d.autoFreeze(p.Name, protocol.CauseLiveness, reason); ferr != nil {
+			d.log.Printf("LIVENESS %s: freeze failed: %v", p.Name, ferr)
+		}
+	case protocol.LivenessRestart:
+		d.mu.Lock()
+		if d.procs[p.Name] == p {
+			d.restart(p.Name, reason)
+		}
+		d.mu.Unlock()
+	}
+}
+
+func validLivenessAction(action string) bool {
+	switch action {
+	case "", protocol.LivenessAlert, protocol.LivenessFreeze, protocol.LivenessRestart:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// logRotateInterval is how often process log sizes are checked.
+const logRotateInterval = 10 * time.Second
+
+// logLoop rotates process logs that have grown past LogMaxMB. It runs
+// until the daemon shuts down.
+func (d *Daemon) logLoop() {
+	ticker := time.NewTicker(logRotateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+		d.rotateLogs()
+	}
+}
+
+// rotateLogs rotates every process log larger than LogMaxMB.
+func (d *Daemon) rotateLogs() {
+	d.mu.RLock()
+	paths := make(map[string]string)
+	for name, p := range d.procs {
+		if p.LogPath != "" {
+			paths[name] = p.LogPath
+		}
+	}
+	d.mu.RUnlock()
+
+	for name, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil || fi.Size() <= d.cfg.LogMaxMB<<20 {
+			continue
+		}
+		if err := rotateLog(path, d.cfg.LogKeep); err != nil {
+			d.log.Printf("LOGROTATE %s failed: %v", name, err)
+			continue
+		}
+		d.log.Printf("LOGROTATE %s at %dMB", name, fi.Size()>>20)
+	}
+}
+
+// rotatedLog names generation n of the log at path; 1 is the newest.
+func rotatedLog(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// rotateLog shifts path's rotated generations up by one, dropping any
+// beyond keep, then copies path to generation 1 and truncates it. The
+// process keeps writing to its open descriptor, which appends, so nothing
+// needs reopening; lines written between the copy and the truncation are
+// lost.
+func rotateLog(path string, keep int) error {
+	if keep > 0 {
+		os.Remove(rotatedLog(path, keep))
+		for n := keep - 1; n >= 1; n-- {
+			if err := os.Rename(rotatedLog(path, n), rotatedLog(path, n+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := copyFile(path, rotatedLog(path, 1)); err != nil {
+			return err
+		}
+	}
+	return os.Truncate(path, 0)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// removeRotatedLogs deletes path's rotated generations, for a process
+// starting over with a fresh log.
+func removeRotatedLogs(path string) {
+	for n := 1; ; n++ {
+		if err := os.Remove(rotatedLog(path, n)); err != nil {
+			return
+		}
+	}
+}
+
+// openLogs opens the log at path preceded by its rotated generations,
+// oldest first, as one reader. It also returns the current log file and a
+// function that closes them all.
+func openLogs(path string) (io.Reader, *os.File, func(), error) {
+	cur, err := os.Open(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	files := []*os.File{cur}
+	for n := 1; ; n++ {
+		f, err := os.Open(rotatedLog(path, n))
+		if err != nil {
+			break
+		}
+		files = append([]*os.File{f}, files...)
+	}
+	readers := make([]io.Reader, len(files))
+	for i, f := range files {
+		readers[i] = f
+	}
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	return io.MultiReader(readers...), cur, closeFiles, nil
+}
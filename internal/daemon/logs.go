@@ -0,0 +1,141 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+// logFollowInterval is how often a followed log is checked for output.
+const logFollowInterval = 250 * time.Millisecond
+
+// logFilters compiles params' grep pattern and the pattern to highlight,
+// which defaults to the grep pattern unless it is inverted.
+func logFilters(params protocol.LogsParams) (grep, highlight *regexp.Regexp, err error) {
+	if params.Grep != "" {
+		if grep, err = regexp.Compile(params.Grep); err != nil {
+			return nil, nil, fmt.Errorf("bad grep pattern: %w", err)
+		}
+	}
+	switch {
+	case params.Highlight != "":
+		if highlight, err = regexp.Compile(params.Highlight); err != nil {
+			return nil, nil, fmt.Errorf("bad highlight pattern: %w", err)
+		}
+	case !params.Invert:
+		highlight = grep
+	}
+	return grep, highlight, nil
+}
+
+// logsResult returns lines with the spans of highlight in each.
+func logsResult(lines []string, highlight *regexp.Regexp) protocol.LogsResult {
+	res := protocol.LogsResult{Lines: lines}
+	if highlight != nil {
+		res.Matches = make([][][2]int, len(lines))
+		for i, line := range lines {
+			for _, m := range highlight.FindAllStringIndex(line, -1) {
+				res.Matches[i] = append(res.Matches[i], [2]int{m[0], m[1]})
+			}
+		}
+	}
+	return res
+}
+
+// FollowLogs sends the last lines of name's log, as Logs returns them,
+// and then each batch of lines written after them. It keeps following
+// across rotations and restarts, and returns once the process has exited
+// and its output is drained, the process is removed, stop is closed, or
+// send fails.
+func (d *Daemon) FollowLogs(name string, params protocol.LogsParams, stop <-chan struct{}, send func(protocol.LogsResult) error) error {
+	res, offset, err := d.logs(name, params)
+	if err != nil {
+		return err
+	}
+	if err := send(res); err != nil {
+		return err
+	}
+	grep, highlight, _ := logFilters(params)
+
+	ticker := time.NewTicker(logFollowInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-d.done:
+			return nil
+		case <-ticker.C:
+		}
+
+		d.mu.RLock()
+		p, ok := d.procs[name]
+		var path string
+		var exited bool
+		if ok {
+			path, exited = p.LogPath, p.State == protocol.StateDead
+		}
+		d.mu.RUnlock()
+		if !ok {
+			return nil
+		}
+
+		lines, next, err := readNewLines(path, offset)
+		if err != nil {
+			return fmt.Errorf("reading logs: %w", err)
+		}
+		offset = next
+		var kept []string
+		for _, line := range lines {
+			if grep == nil || grep.MatchString(line) != params.Invert {
+				kept = append(kept, line)
+			}
+		}
+		if len(kept) > 0 {
+			if err := send(logsResult(kept, highlight)); err != nil {
+				return err
+			}
+		}
+		if exited && len(lines) == 0 {
+			return nil
+		}
+	}
+}
+
+// readNewLines returns the complete lines written to path from offset on,
+// and the offset after them. A file shorter than offset has been truncated
+// by rotation and is read from the start.
+func readNewLines(path string, offset int64) ([]string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, offset, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, offset, err
+	}
+	if fi.Size() < offset {
+		offset = 0
+	}
+	if fi.Size() == offset {
+		return nil, offset, nil
+	}
+
+	data := make([]byte, fi.Size()-offset)
+	n, err := f.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		return nil, offset, err
+	}
+	end := bytes.LastIndexByte(data[:n], '\n')
+	if end < 0 {
+		return nil, offset, nil
+	}
+	return strings.Split(string(data[:end]), "\n"), offset + int64(end) + 1, nil
+}
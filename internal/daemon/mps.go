@@ -0,0 +1,95 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gpusched/internal/protocol"
+)
+
+// mpsControlBin is NVIDIA's MPS control daemon, which starts an MPS server
+// for the GPUs its clients use.
+const mpsControlBin = "nvidia-cuda-mps-control"
+
+// mpsEnv points MPS tools and clients at the control daemon whose pipes
+// are in dir.
+func mpsEnv(dir string) []string {
+	return []string{
+		"CUDA_MPS_PIPE_DIRECTORY=" + dir,
+		"CUDA_MPS_LOG_DIRECTORY=" + filepath.Join(dir, "log"),
+	}
+}
+
+// mpsClientEnv is the environment that makes a process started with
+// params a client of the control daemon at dir. A GPU memory limit is
+// also handed to MPS, which then refuses allocations past it.
+func mpsClientEnv(dir string, params protocol.RunParams) []string {
+	env := mpsEnv(dir)
+	if params.GPUMemLimitMB > 0 {
+		// The process sees only its own GPU, as device 0.
+		env = append(env, fmt.Sprintf("CUDA_MPS_PINNED_DEVICE_MEM_LIMIT=0=%dM", params.GPUMemLimitMB))
+	}
+	return env
+}
+
+// mpsControl sends command to the control daemon at dir and returns its
+// reply.
+func mpsControl(dir, command string) (string, error) {
+	cmd := exec.Command(mpsControlBin)
+	cmd.Env = append(withoutToken(os.Environ()), mpsEnv(dir)...)
+	cmd.Stdin = strings.NewReader(command + "\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w: %s", mpsControlBin, command, err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// initMPS starts an MPS control daemon with its pipes in dir, or uses the
+// one already running there. It returns dir, or "" to refuse MPS runs.
+func (d *Daemon) initMPS(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	if _, err := exec.LookPath(mpsControlBin); err != nil {
+		d.log.Printf("WARN: %s not found; refusing MPS runs", mpsControlBin)
+		return ""
+	}
+	if _, err := mpsControl(dir, "get_server_list"); err == nil {
+		d.log.Printf("MPS: using the control daemon already at %s", dir)
+		return dir
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "log"), 0o755); err != nil {
+		d.log.Printf("WARN: creating MPS directory: %v; refusing MPS runs", err)
+		return ""
+	}
+	cmd := exec.Command(mpsControlBin, "-d")
+	cmd.Env = append(withoutToken(os.Environ()), mpsEnv(dir)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		d.log.Printf("WARN: starting %s: %v: %s; refusing MPS runs", mpsControlBin, err, strings.TrimSpace(string(out)))
+		return ""
+	}
+	d.mpsStarted = true
+	d.log.Printf("MPS: control daemon at %s", dir)
+	return dir
+}
+
+// stopMPS shuts down the control daemon if the daemon started it, unless
+// processes left running at shutdown are still its clients.
+func (d *Daemon) stopMPS(leave []savedProc) {
+	if !d.mpsStarted {
+		return
+	}
+	for _, sp := range leave {
+		if sp.Params.MPS {
+			d.log.Printf("  leaving the MPS control daemon running for %s", sp.Name)
+			return
+		}
+	}
+	if _, err := mpsControl(d.mps, "quit"); err != nil {
+		d.log.Printf("WARN: stopping MPS control daemon: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+
+	"gpusched/internal/procfs"
+	"gpusched/internal/protocol"
+)
+
+// Frozen-process OOM policies: what oom_score_adj a process gets while its
+// GPU snapshot sits in host RAM. The original value is restored on thaw.
+const (
+	OOMPolicyNone    = ""
+	OOMPolicyProtect = "protect" // frozen processes are killed last
+	OOMPolicyPrefer  = "prefer"  // frozen processes are killed first
+)
+
+var oomPolicyAdj = map[string]int{
+	OOMPolicyProtect: -900,
+	OOMPolicyPrefer:  900,
+}
+
+// ValidOOMPolicy reports whether policy is a known FrozenOOMPolicy.
+func ValidOOMPolicy(policy string) bool {
+	_, ok := oomPolicyAdj[policy]
+	return ok || policy == OOMPolicyNone
+}
+
+// applyFrozenOOM sets p's oom_score_adj for the frozen state, remembering
+// the original. Caller must hold d.mu.
+func (d *Daemon) applyFrozenOOM(p *Proc) {
+	adj, ok := oomPolicyAdj[d.cfg.FrozenOOMPolicy]
+	if !ok {
+		return
+	}
+	orig, err := procfs.OOMScoreAdj(p.PID)
+	if err != nil {
+		return
+	}
+	if err := procfs.SetOOMScoreAdj(p.PID, adj); err != nil {
+		d.log.Printf("WARN: setting oom_score_adj for %s: %v", p.Name, err)
+		return
+	}
+	p.oomAdjOrig = &orig
+}
+
+// restoreOOM puts back the oom_score_adj p had before it was frozen.
+// Caller must hold d.mu.
+func (d *Daemon) restoreOOM(p *Proc) {
+	if p.oomAdjOrig == nil {
+		return
+	}
+	if err := procfs.SetOOMScoreAdj(p.PID, *p.oomAdjOrig); err != nil {
+		d.log.Printf("WARN: restoring oom_score_adj for %s: %v", p.Name, err)
+	}
+	p.oomAdjOrig = nil
+}
+
+// oomKilled reports whether a process that exited with waitErr was most
+// likely taken by the OOM killer: it died of SIGKILL and the kernel's OOM
+// kill counter moved since we last looked. Caller must hold d.mu.
+func (d *Daemon) oomKilled(waitErr error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(waitErr, &exitErr) {
+		return false
+	}
+	ws, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() || ws.Signal() != syscall.SIGKILL {
+		return false
+	}
+	kills, err := procfs.OOMKills()
+	if err != nil || kills <= d.oomKills {
+		return false
+	}
+	d.oomKills = kills
+	return true
+}
+
+func (d *Daemon) reportOOM(p *Proc, state protocol.ProcessState) {
+	detail := fmt.Sprintf("killed by the kernel OOM killer while %s", state)
+	if state == protocol.StateFrozen {
+		detail += fmt.Sprintf(" (%d MB snapshot in host RAM lost)", p.MemMB)
+	}
+	d.emit(protocol.Event{Type: "oom-killed", Process: p.Name, Detail: detail})
+	d.log.Printf("OOM %s pid=%d: %s", p.Name, p.PID, detail)
+}
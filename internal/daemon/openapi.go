@@ -0,0 +1,180 @@
+package daemon
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+// openAPI describes routes as an OpenAPI 3 document. Schemas are derived
+// from the protocol types by reflection, following their json tags, so the
+// document can't drift from what the daemon actually sends.
+func openAPI(routes []httpRoute) map[string]interface{} {
+	g := &schemaGen{defs: make(map[string]interface{})}
+	errRef := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"error": map[string]interface{}{"type": "string"}},
+	}
+
+	paths := make(map[string]interface{})
+	for _, rt := range routes {
+		op := map[string]interface{}{
+			"summary":     rt.Summary,
+			"operationId": rt.Method + strings.ReplaceAll(rt.Path, "/", "_"),
+		}
+		params := []interface{}{map[string]interface{}{
+			"name": "namespace", "in": "query",
+			"schema": map[string]interface{}{"type": "string"},
+		}}
+		if strings.Contains(rt.Path, "{name}") {
+			params = append(params, map[string]interface{}{
+				"name": "name", "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		op["parameters"] = params
+		if rt.Body != nil {
+			op["requestBody"] = map[string]interface{}{
+				"content": jsonContent(g.schema(reflect.TypeOf(rt.Body))),
+			}
+		}
+
+		responses := map[string]interface{}{
+			"default": map[string]interface{}{"description": "error", "content": jsonContent(errRef)},
+		}
+		if rt.Result == nil {
+			responses["204"] = map[string]interface{}{"description": "done"}
+		} else {
+			responses["200"] = map[string]interface{}{
+				"description": "ok",
+				"content":     jsonContent(g.schema(reflect.TypeOf(rt.Result))),
+			}
+		}
+		op["responses"] = responses
+
+		item, _ := paths[rt.Path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[rt.Path] = item
+		}
+		item[strings.ToLower(rt.Method)] = op
+	}
+
+	paths["/v1/events"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"summary":     "Stream events as server-sent events",
+			"operationId": "GET_v1_events",
+			"parameters": []interface{}{
+				map[string]interface{}{"name": "namespace", "in": "query", "schema": map[string]interface{}{"type": "string"}},
+				map[string]interface{}{"name": "type", "in": "query", "schema": map[string]interface{}{
+					"type": "array", "items": map[string]interface{}{"type": "string"},
+				}},
+			},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "one SSE message per event; data is an Event",
+					"content": map[string]interface{}{
+						"text/event-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+					},
+				},
+			},
+		},
+	}
+	g.schema(reflect.TypeOf(protocol.Event{}))
+
+	return map[string]interface{}{
+		"openapi":    "3.0.3",
+		"info":       map[string]interface{}{"title": "gpusched", "version": "v1"},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": g.defs},
+	}
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaGen turns Go types into JSON schemas. Named structs are emitted
+// once under components/schemas and referenced from everywhere else.
+type schemaGen struct {
+	defs map[string]interface{}
+}
+
+func (g *schemaGen) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawJSONType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, done := g.defs[t.Name()]; !done {
+			g.defs[t.Name()] = nil // placeholder in case t refers to itself
+			g.defs[t.Name()] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// object describes a struct's json-tagged fields. Fields without
+// omitempty are required.
+func (g *schemaGen) object(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			g.schema(f.Type)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	obj := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
@@ -0,0 +1,133 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gpusched/internal/gpu"
+	"gpusched/internal/placement"
+	"gpusched/internal/protocol"
+)
+
+// planCandidate is an active process that a plan may move off the GPU.
+type planCandidate struct {
+	name     string
+	memMB    int64
+	lastBusy time.Time
+}
+
+// Plan simulates fitting params.AddMB more onto params.GPU and returns the
+// migrations and freezes that would make room. Nothing is executed.
+func (d *Daemon) Plan(params protocol.PlanParams) (protocol.PlanResult, error) {
+	if params.AddMB <= 0 {
+		return protocol.PlanResult{}, fmt.Errorf("size to add must be positive")
+	}
+	gpus, err := d.refreshGPUs()
+	if err != nil {
+		return protocol.PlanResult{}, fmt.Errorf("query gpus: %w", err)
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if h := d.exclusiveHolder(params.GPU, nil); h != nil {
+		return protocol.PlanResult{}, fmt.Errorf("GPU %d is reserved by exclusive process %q", params.GPU, h.Name)
+	}
+
+	var candidates []planCandidate
+	var snapshotsMB int64
+	for _, p := range d.procs {
+		switch {
+		case p.State == protocol.StateFrozen:
+			snapshotsMB += p.MemMB
+		case p.State == protocol.StateActive && p.GPU == params.GPU:
+			mem := procGPUMem(p)
+			if mem <= 0 {
+				mem = p.MemMB
+			}
+			if mem <= 0 {
+				continue
+			}
+			busy := p.lastBusy
+			if busy.IsZero() {
+				busy = p.Started
+			}
+			candidates = append(candidates, planCandidate{name: p.Name, memMB: mem, lastBusy: busy})
+		}
+	}
+	// Migrations can't target a GPU an exclusive process holds.
+	open := d.withReserve(withoutCordoned(gpus, d.cordonedGPUs(false, nil)))
+	total, free := gpu.HostMemInfo()
+	headroom, _ := ramHeadroom(d.cfg.RAMBudgetMB, snapshotsMB, free, d.ramMarginMB(total))
+	res, err := planPlacement(d.placer, open, candidates, params, headroom)
+	if err != nil {
+		return res, err
+	}
+	for i := range res.Steps {
+		res.Steps[i].EstimatedMs = d.opEstimateMs(res.Steps[i].Action, res.Steps[i].MemMB)
+	}
+	return res, nil
+}
+
+// planPlacement frees memory on params.GPU by moving candidates off it,
+// least recently busy first. A candidate is migrated if placer finds
+// another GPU with room for it, otherwise frozen if ramFreeMB still has
+// room for its snapshot.
+func planPlacement(placer placement.Strategy, gpus []protocol.GPUInfo, candidates []planCandidate, params protocol.PlanParams, ramFreeMB int64) (protocol.PlanResult, error) {
+	free := make(map[int]int64, len(gpus))
+	for _, g := range gpus {
+		free[g.Index] = g.MemFree
+	}
+	target, ok := free[params.GPU]
+	if !ok {
+		return protocol.PlanResult{}, errorf(protocol.CodeNotFound, "gpu %d not found", params.GPU)
+	}
+	res := protocol.PlanResult{GPU: params.GPU, AddMB: params.AddMB, FreeMB: target}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].lastBusy.Equal(candidates[j].lastBusy) {
+			return candidates[i].lastBusy.Before(candidates[j].lastBusy)
+		}
+		return candidates[i].memMB > candidates[j].memMB
+	})
+
+	for _, c := range candidates {
+		if target >= params.AddMB {
+			break
+		}
+		if to, ok := migrationTarget(placer, gpus, free, params.GPU, c); ok {
+			free[to] -= c.memMB
+			target += c.memMB
+			res.Steps = append(res.Steps, protocol.PlanStep{Action: "migrate", Process: c.name, MemMB: c.memMB, ToGPU: to})
+			continue
+		}
+		if c.memMB <= ramFreeMB {
+			ramFreeMB -= c.memMB
+			target += c.memMB
+			res.Steps = append(res.Steps, protocol.PlanStep{Action: "freeze", Process: c.name, MemMB: c.memMB})
+		}
+	}
+
+	res.Fits = target >= params.AddMB
+	if !res.Fits {
+		res.ShortMB = params.AddMB - target
+	}
+	return res, nil
+}
+
+// migrationTarget places c on a GPU other than from, given the simulated
+// free memory so far.
+func migrationTarget(placer placement.Strategy, gpus []protocol.GPUInfo, free map[int]int64, from int, c planCandidate) (int, bool) {
+	sim := make([]protocol.GPUInfo, len(gpus))
+	for i, g := range gpus {
+		g.MemFree = free[g.Index]
+		g.MemUsed = g.MemTotal - g.MemFree
+		sim[i] = g
+	}
+	pl, err := placement.Choose(placer, placement.Request{Process: c.name, NeedMB: c.memMB, Exclude: from}, sim)
+	if err != nil {
+		return 0, false
+	}
+	return pl.GPU, true
+}
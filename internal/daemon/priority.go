@@ -0,0 +1,87 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"gpusched/internal/procfs"
+	"gpusched/internal/protocol"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioprio_set(2) constants, which x/sys/unix doesn't define.
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	// ioprioLevel is the priority level within the best-effort and
+	// realtime classes, 0 (highest) to 7; 4 is the kernel's default.
+	ioprioLevel = 4
+)
+
+var ioprioClasses = map[string]int{
+	protocol.IOClassRealtime:   1,
+	protocol.IOClassBestEffort: 2,
+	protocol.IOClassIdle:       3,
+}
+
+// validPriority checks the nice, I/O class, and oom_score_adj of params.
+func validPriority(params protocol.RunParams) error {
+	if params.Nice < -20 || params.Nice > 19 {
+		return fmt.Errorf("nice must be between -20 and 19")
+	}
+	if _, ok := ioprioClasses[params.IOClass]; !ok && params.IOClass != "" {
+		return fmt.Errorf("unknown I/O class %q", params.IOClass)
+	}
+	if params.OOMScoreAdj < -1000 || params.OOMScoreAdj > 1000 {
+		return fmt.Errorf("oom_score_adj must be between -1000 and 1000")
+	}
+	return nil
+}
+
+// applyPriority gives the process pid the nice value, I/O class, and
+// oom_score_adj of params. Nice values and I/O classes belong to threads,
+// so they are set on every thread the process has started so far; threads
+// it starts later inherit them.
+func applyPriority(pid int, params protocol.RunParams) error {
+	if params.Nice != 0 || params.IOClass != "" {
+		for _, tid := range threads(pid) {
+			if params.Nice != 0 {
+				if err := unix.Setpriority(unix.PRIO_PROCESS, tid, params.Nice); err != nil {
+					return fmt.Errorf("setting nice: %w", err)
+				}
+			}
+			if class, ok := ioprioClasses[params.IOClass]; ok {
+				prio := class << ioprioClassShift
+				if params.IOClass != protocol.IOClassIdle {
+					prio |= ioprioLevel
+				}
+				if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio)); errno != 0 {
+					return fmt.Errorf("setting I/O class: %w", errno)
+				}
+			}
+		}
+	}
+	if params.OOMScoreAdj != 0 {
+		if err := procfs.SetOOMScoreAdj(pid, params.OOMScoreAdj); err != nil {
+			return fmt.Errorf("setting oom_score_adj: %w", err)
+		}
+	}
+	return nil
+}
+
+// threads lists pid's thread IDs, or just pid if they can't be read.
+func threads(pid int) []int {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/task", pid))
+	if err != nil {
+		return []int{pid}
+	}
+	tids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if tid, err := strconv.Atoi(e.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids
+}
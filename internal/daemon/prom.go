@@ -0,0 +1,135 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gpusched/internal/protocol"
+)
+
+// ProcessMetricLabels are the labels available on per-process series.
+// "owner" is the process namespace.
+var ProcessMetricLabels = []string{"name", "gpu", "state", "owner"}
+
+// ValidProcessMetricLabel reports whether l may appear in
+// Config.MetricsProcessLabels.
+func ValidProcessMetricLabel(l string) bool {
+	for _, v := range ProcessMetricLabels {
+		if l == v {
+			return true
+		}
+	}
+	return false
+}
+
+// MetricsHandler serves daemon and per-process metrics in the Prometheus
+// text format.
+func (d *Daemon) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w, d.Status(protocol.StatusParams{}), d.cfg.MetricsProcessLabels)
+	})
+}
+
+// writeMetrics renders s. Per-process series carry only the allowed labels;
+// processes that collapse onto the same label set are summed, and dead
+// processes are left out so series don't accumulate. No labels disables
+// per-process series.
+func writeMetrics(w io.Writer, s protocol.StatusResult, labels []string) {
+	m := s.Metrics
+	counter := func(name, help string, v int) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	counter("gpusched_requests_total", "Requests handled by the daemon.", m.Requests)
+	counter("gpusched_freezes_total", "Processes frozen to host RAM.", m.Freezes)
+	counter("gpusched_thaws_total", "Processes restored to the GPU.", m.Thaws)
+	counter("gpusched_migrations_total", "Processes moved between GPUs.", m.Migrations)
+	counter("gpusched_cold_starts_total", "Processes started.", m.ColdStarts)
+	counter("gpusched_evictions_total", "Freezes initiated by the daemon (idle, liveness, or a GPU memory limit) rather than a user.", m.Evictions)
+	counter("gpusched_gpu_mem_limit_violations_total", "Processes found over their GPU memory limit.", m.GPUMemLimitViolations)
+	counter("gpusched_events_dropped_total", "Events not delivered to slow subscribers.", m.EventsDropped)
+
+	histogram(w, "gpusched_freeze_duration_seconds", "Time to freeze a process.", m.FreezeLatency)
+	histogram(w, "gpusched_thaw_duration_seconds", "Time to thaw a process.", m.ThawLatency)
+	histogram(w, "gpusched_migrate_duration_seconds", "Time to move a process between GPUs.", m.MigrateLatency)
+
+	gauge := func(name, help string, v int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, v)
+	}
+	gauge("gpusched_snapshots_mb", "Host RAM held by frozen processes.", s.Memory.SnapshotsMB)
+	if s.Memory.HostRAMBudgetMB > 0 {
+		gauge("gpusched_snapshot_budget_mb", "Host RAM frozen processes may use (--ram-budget).", s.Memory.HostRAMBudgetMB)
+	}
+	gauge("gpusched_host_ram_free_mb", "Free host RAM.", s.Memory.HostRAMFreeMB)
+
+	if len(s.GPUs) > 0 {
+		gpuGauge := func(name, help string, v func(protocol.GPUInfo) int64) {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+			for _, g := range s.GPUs {
+				fmt.Fprintf(w, "%s{gpu=\"%d\",model=%q} %d\n", name, g.Index, g.Name, v(g))
+			}
+		}
+		gpuGauge("gpusched_gpu_mem_total_mb", "GPU memory capacity.", func(g protocol.GPUInfo) int64 { return g.MemTotal })
+		gpuGauge("gpusched_gpu_mem_used_mb", "GPU memory in use, by any process.", func(g protocol.GPUInfo) int64 { return g.MemUsed })
+		gpuGauge("gpusched_gpu_utilization_percent", "GPU SM utilization.", func(g protocol.GPUInfo) int64 { return int64(g.UtilPct) })
+	}
+
+	byState := map[protocol.ProcessState]int{protocol.StateActive: 0, protocol.StateFrozen: 0, protocol.StateDead: 0}
+	for _, p := range s.Processes {
+		byState[p.State]++
+	}
+	fmt.Fprintf(w, "# HELP gpusched_processes Managed processes by state.\n# TYPE gpusched_processes gauge\n")
+	for _, st := range []protocol.ProcessState{protocol.StateActive, protocol.StateFrozen, protocol.StateDead} {
+		fmt.Fprintf(w, "gpusched_processes{state=%q} %d\n", st, byState[st])
+	}
+
+	if len(labels) == 0 {
+		return
+	}
+	series := make(map[string]int64)
+	for _, p := range s.Processes {
+		if p.State == protocol.StateDead {
+			continue
+		}
+		values := map[string]string{
+			"name":  p.Name,
+			"gpu":   strconv.Itoa(p.GPU),
+			"state": string(p.State),
+			"owner": p.Namespace,
+		}
+		pairs := make([]string, len(labels))
+		for i, l := range labels {
+			pairs[i] = fmt.Sprintf("%s=%q", l, values[l])
+		}
+		series["{"+strings.Join(pairs, ",")+"}"] += p.MemMB
+	}
+	keys := make([]string, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(w, "# HELP gpusched_process_gpu_mem_mb GPU memory held (or parked in RAM, if frozen) per process.\n# TYPE gpusched_process_gpu_mem_mb gauge\n")
+	for _, k := range keys {
+		fmt.Fprintf(w, "gpusched_process_gpu_mem_mb%s %d\n", k, series[k])
+	}
+}
+
+// histogram renders h with bucket bounds in seconds, cumulative as
+// Prometheus expects.
+func histogram(w io.Writer, name, help string, h protocol.Histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	cum := 0
+	for i, b := range protocol.LatencyBucketsMs {
+		if i < len(h.Counts) {
+			cum += h.Counts[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(float64(b)/1000, 'g', -1, 64), cum)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.Count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(float64(h.SumMs)/1000, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.Count)
+}
@@ -0,0 +1,291 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"gpusched/internal/bytesize"
+	"gpusched/internal/placement"
+	"gpusched/internal/protocol"
+)
+
+// releasesGPU are the event types after which GPU memory may be free, so
+// the queue is worth another look.
+var releasesGPU = map[string]bool{
+	"freeze":           true,
+	"park":             true,
+	"kill":             true,
+	"exit":             true,
+	"migrate":          true,
+	"oom-killed":       true,
+	"gpu-added":        true,
+	"gpu-reconfigured": true,
+}
+
+// queuedRun is a run waiting for GPU memory.
+type queuedRun struct {
+	name     string
+	params   protocol.RunParams
+	enqueued time.Time
+}
+
+// runOrQueue starts params now if its GPU has params.MemMB free, its user
+// is within quota, and no earlier run is waiting, and queues it otherwise.
+func (d *Daemon) runOrQueue(params protocol.RunParams) (protocol.RunResult, error) {
+	if params.MemMB <= 0 {
+		return protocol.RunResult{}, fmt.Errorf("a queued run needs the GPU memory it will use")
+	}
+	if err := d.validateRunParams(params); err != nil {
+		return protocol.RunResult{}, err
+	}
+	params.Queue = false
+	name := protocol.QualifiedName(params.Namespace, params.Name)
+
+	gpus, err := d.refreshGPUs()
+	if err != nil {
+		return protocol.RunResult{}, fmt.Errorf("query gpus: %w", err)
+	}
+
+	d.mu.RLock()
+	waiting := d.nextQueued() != nil
+	open := d.startable(params, gpus, nil)
+	d.mu.RUnlock()
+	if !waiting {
+		if pl, ok := d.choose(name, params, open); ok {
+			return d.Run(startParams(params, pl))
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, exists := d.procs[name]; exists {
+		return protocol.RunResult{}, errorf(protocol.CodeAlreadyExists, "process %q already exists", name)
+	}
+	if d.queued(name) >= 0 {
+		return protocol.RunResult{}, fmt.Errorf("process %q is already queued", name)
+	}
+	d.queue = append(d.queue, &queuedRun{name: name, params: params, enqueued: time.Now()})
+	where := "any GPU"
+	if !params.AutoGPU {
+		where = fmt.Sprintf("GPU %d", params.GPU)
+	}
+	detail := fmt.Sprintf("waiting for %s free on %s, position %d", bytesize.FormatMB(params.MemMB), where, len(d.queue))
+	if err := d.checkQuota(params.UID, -1, params.MemMB); err != nil {
+		detail += " (" + err.Error() + ")"
+	}
+	d.emit(protocol.Event{Type: "queued", Process: name, Detail: detail})
+	d.log.Printf("QUEUE %s %s", name, detail)
+	return protocol.RunResult{Name: params.Name, Queued: true, Position: len(d.queue)}, nil
+}
+
+// startParams pins a queued run to the GPU it was placed on.
+func startParams(params protocol.RunParams, pl protocol.Placement) protocol.RunParams {
+	params.GPU = pl.GPU
+	params.AutoGPU = false
+	return params
+}
+
+// startable returns the GPUs params could start on now: those with
+// MemMB free after reserves and pending launches, within budget, and
+// not held by an exclusive process (or shared, for an exclusive run).
+// Caller must hold d.mu.
+func (d *Daemon) startable(params protocol.RunParams, gpus []protocol.GPUInfo, pendingMB map[int]int64) []protocol.GPUInfo {
+	open := withoutCordoned(gpus, d.cordonedGPUs(params.Exclusive, nil))
+	var fit []protocol.GPUInfo
+	for _, g := range d.withinBudget(d.withReserve(open), params.MemMB, nil) {
+		g.MemFree -= pendingMB[g.Index]
+		switch {
+		case !params.AutoGPU && g.Index != params.GPU:
+		case g.MemFree < params.MemMB:
+		case params.Exclusive && len(d.coTenants(g.Index, nil)) > 0:
+		case d.checkQuota(params.UID, g.Index, params.MemMB) != nil:
+		default:
+			fit = append(fit, g)
+		}
+	}
+	return fit
+}
+
+// choose picks where a run goes among the GPUs it fits on. It may run a
+// placement plugin, so the caller must not hold d.mu.
+func (d *Daemon) choose(name string, params protocol.RunParams, fit []protocol.GPUInfo) (protocol.Placement, bool) {
+	if len(fit) == 0 {
+		return protocol.Placement{}, false
+	}
+	if !params.AutoGPU {
+		return protocol.Placement{GPU: params.GPU}, true
+	}
+	pl, err := placement.Choose(d.placer, placement.Request{Process: name, NeedMB: params.MemMB, Exclude: -1}, fit)
+	return pl, err == nil
+}
+
+// queued returns name's index in the queue, or -1. Caller must hold d.mu.
+func (d *Daemon) queued(name string) int {
+	for i, q := range d.queue {
+		if q.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// nextQueued returns the first queued run whose user is within quota, or
+// nil. Caller must hold d.mu.
+func (d *Daemon) nextQueued() *queuedRun {
+	for _, q := range d.queue {
+		gpu := q.params.GPU
+		if q.params.AutoGPU {
+			gpu = -1
+		}
+		if d.checkQuota(q.params.UID, gpu, q.params.MemMB) == nil {
+			return q
+		}
+	}
+	return nil
+}
+
+func (d *Daemon) kickQueue() {
+	select {
+	case d.queueKick <- struct{}{}:
+	default:
+	}
+}
+
+// queueLoop starts queued runs as memory frees up: after events that
+// release GPU memory, and every GPUPollInterval for memory freed outside
+// gpusched.
+func (d *Daemon) queueLoop() {
+	ticker := time.NewTicker(d.cfg.GPUPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-d.queueKick:
+		case <-ticker.C:
+		}
+		d.runQueue()
+	}
+}
+
+// runQueue starts queued runs in order until the next doesn't fit,
+// passing over runs whose user is at quota. Runs started in this pass
+// haven't allocated their memory yet, so it is counted against their GPU
+// by hand.
+func (d *Daemon) runQueue() {
+	d.mu.RLock()
+	empty := len(d.queue) == 0
+	d.mu.RUnlock()
+	if empty {
+		return
+	}
+	gpus, err := d.refreshGPUs()
+	if err != nil {
+		return
+	}
+
+	pendingMB := make(map[int]int64)
+	for {
+		d.mu.RLock()
+		head := d.nextQueued()
+		if head == nil {
+			d.mu.RUnlock()
+			return
+		}
+		open := d.startable(head.params, gpus, pendingMB)
+		d.mu.RUnlock()
+
+		pl, ok := d.choose(head.name, head.params, open)
+		if !ok {
+			return
+		}
+		d.mu.Lock()
+		i := d.queued(head.name)
+		if i < 0 || d.queue[i] != head {
+			d.mu.Unlock()
+			continue // removed meanwhile
+		}
+		d.queue = append(d.queue[:i], d.queue[i+1:]...)
+		d.mu.Unlock()
+
+		d.log.Printf("DEQUEUE %s after %s", head.name, time.Since(head.enqueued).Round(time.Second))
+		if _, err := d.Run(startParams(head.params, pl)); err != nil {
+			d.mu.Lock()
+			d.emit(protocol.Event{Type: "queue-failed", Process: head.name, Detail: err.Error()})
+			d.mu.Unlock()
+			d.log.Printf("QUEUE %s failed to start: %v", head.name, err)
+			continue
+		}
+		pendingMB[pl.GPU] += head.params.MemMB
+	}
+}
+
+// Queue lists queued runs in params.Namespace, or all of them.
+func (d *Daemon) Queue(params protocol.QueueParams) protocol.QueueResult {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return protocol.QueueResult{Runs: d.queuedRuns(params.Namespace)}
+}
+
+// queuedRuns reports the queue, keeping each run's overall position.
+// Caller must hold d.mu.
+func (d *Daemon) queuedRuns(namespace string) []protocol.QueuedRun {
+	runs := []protocol.QueuedRun{}
+	patterns := d.redactPatterns()
+	for i, q := range d.queue {
+		if namespace != "" && q.params.Namespace != namespace {
+			continue
+		}
+		var set map[string]string
+		if q.params.Env != nil {
+			set = q.params.Env.Set
+		}
+		secrets := secretValues(patterns, d.queuedEnv(set))
+		runs = append(runs, protocol.QueuedRun{
+			Namespace: q.params.Namespace,
+			Name:      q.params.Name,
+			Cmd:       redactArgs(q.params.Cmd, patterns, secrets),
+			GPU:       q.params.GPU,
+			AutoGPU:   q.params.AutoGPU,
+			MemMB:     q.params.MemMB,
+			Position:  i + 1,
+			Enqueued:  q.enqueued,
+		})
+	}
+	return runs
+}
+
+// MoveQueued puts a queued run at position (from 1), which may let it
+// start right away.
+func (d *Daemon) MoveQueued(name string, position int) error {
+	if position < 1 {
+		return fmt.Errorf("position must be at least 1")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i := d.queued(name)
+	if i < 0 {
+		return fmt.Errorf("process %q is not queued", name)
+	}
+	q := d.queue[i]
+	d.queue = append(d.queue[:i], d.queue[i+1:]...)
+	position = min(position, len(d.queue)+1)
+	d.queue = append(d.queue[:position-1], append([]*queuedRun{q}, d.queue[position-1:]...)...)
+	d.kickQueue()
+	return nil
+}
+
+// Dequeue drops a queued run without starting it.
+func (d *Daemon) Dequeue(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i := d.queued(name)
+	if i < 0 {
+		return fmt.Errorf("process %q is not queued", name)
+	}
+	d.queue = append(d.queue[:i], d.queue[i+1:]...)
+	d.emit(protocol.Event{Type: "dequeued", Process: name, Detail: "removed from queue"})
+	d.kickQueue()
+	return nil
+}
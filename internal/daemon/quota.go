@@ -0,0 +1,115 @@
+package daemon
+
+import (
+	"fmt"
+	"os/user"
+	"slices"
+	"sort"
+	"strconv"
+
+	"gpusched/internal/bytesize"
+	"gpusched/internal/protocol"
+)
+
+// AnyUser keys the default quota in Config.Quotas.
+const AnyUser = -1
+
+// quotaFor returns uid's quota, or nil if it has none. The default quota
+// doesn't apply to root, only one listed for uid 0 does.
+func (d *Daemon) quotaFor(uid *int) *protocol.Quota {
+	if uid == nil {
+		return nil
+	}
+	if q, ok := d.cfg.Quotas[*uid]; ok {
+		return &q
+	}
+	if q, ok := d.cfg.Quotas[AnyUser]; ok && *uid != 0 {
+		return &q
+	}
+	return nil
+}
+
+// userName returns uid's login name, or the number.
+func userName(uid int) string {
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		return u.Username
+	}
+	return strconv.Itoa(uid)
+}
+
+// userUsage totals what uid's processes hold. Caller must hold d.mu.
+func (d *Daemon) userUsage(uid int) protocol.UserUsage {
+	u := protocol.UserUsage{UID: uid, User: userName(uid), GPUs: []int{}}
+	gpus := make(map[int]bool)
+	for _, p := range d.procs {
+		if p.params.UID == nil || *p.params.UID != uid {
+			continue
+		}
+		switch p.State {
+		case protocol.StateActive, protocol.StateDegraded:
+			if !gpus[p.GPU] {
+				gpus[p.GPU] = true
+				u.GPUs = append(u.GPUs, p.GPU)
+			}
+			u.GPUMemMB += max(p.MemMB, p.params.MemMB)
+		case protocol.StateFrozen:
+			u.SnapshotMB += snapshotMB(p)
+		}
+	}
+	sort.Ints(u.GPUs)
+	u.Quota = d.quotaFor(&uid)
+	return u
+}
+
+// userUsages reports every user with a process, by UID. Caller must hold
+// d.mu.
+func (d *Daemon) userUsages() []protocol.UserUsage {
+	seen := make(map[int]bool)
+	var uids []int
+	for _, p := range d.procs {
+		if uid := p.params.UID; uid != nil && !seen[*uid] {
+			seen[*uid] = true
+			uids = append(uids, *uid)
+		}
+	}
+	sort.Ints(uids)
+	users := make([]protocol.UserUsage, 0, len(uids))
+	for _, uid := range uids {
+		users = append(users, d.userUsage(uid))
+	}
+	return users
+}
+
+// checkQuota refuses to add memMB of GPU memory on gpu for uid's run or
+// thaw. A gpu of -1 (not placed yet) skips the GPU count. Caller must
+// hold d.mu.
+func (d *Daemon) checkQuota(uid *int, gpu int, memMB int64) error {
+	q := d.quotaFor(uid)
+	if q == nil {
+		return nil
+	}
+	u := d.userUsage(*uid)
+	if q.GPUs > 0 && gpu >= 0 && len(u.GPUs) >= q.GPUs && !slices.Contains(u.GPUs, gpu) {
+		return fmt.Errorf("quota: %s already uses %d GPUs (max %d)", u.User, len(u.GPUs), q.GPUs)
+	}
+	if q.GPUMemMB > 0 && u.GPUMemMB+memMB > q.GPUMemMB {
+		return fmt.Errorf("quota: %s would hold %s of GPU memory (max %s)",
+			u.User, bytesize.FormatMB(u.GPUMemMB+memMB), bytesize.FormatMB(q.GPUMemMB))
+	}
+	return nil
+}
+
+// checkSnapshotQuota refuses to freeze p if its owner's snapshots would
+// outgrow their quota. Caller must hold d.mu.
+func (d *Daemon) checkSnapshotQuota(p *Proc) error {
+	q := d.quotaFor(p.params.UID)
+	if q == nil || q.SnapshotMB == 0 {
+		return nil
+	}
+	u := d.userUsage(*p.params.UID)
+	if u.SnapshotMB+p.MemMB > q.SnapshotMB {
+		return fmt.Errorf("quota: %s would hold %s of snapshots (max %s)",
+			u.User, bytesize.FormatMB(u.SnapshotMB+p.MemMB), bytesize.FormatMB(q.SnapshotMB))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"fmt"
+
+	"gpusched/internal/bytesize"
+	"gpusched/internal/gpu"
+	"gpusched/internal/protocol"
+)
+
+// defaultRAMMarginMB is the free host RAM freezes leave when no margin is
+// configured.
+const defaultRAMMarginMB = 4096
+
+// ramMarginMB is the free host RAM a freeze must leave: the larger of
+// RAMMarginMB and RAMMarginPct of totalMB.
+func (d *Daemon) ramMarginMB(totalMB int64) int64 {
+	margin := d.cfg.RAMMarginMB
+	if pct := int64(d.cfg.RAMMarginPct * float64(totalMB) / 100); pct > margin {
+		margin = pct
+	}
+	return margin
+}
+
+// ramHeadroom is how much more snapshot memory fits: what is left of the
+// budget, capped by free host RAM above the margin. marginBound reports
+// that the margin is the tighter of the two.
+func ramHeadroom(budgetMB, snapshotsMB, freeMB, marginMB int64) (headroomMB int64, marginBound bool) {
+	headroomMB = budgetMB - snapshotsMB
+	if free := freeMB - marginMB; freeMB > 0 && free < headroomMB {
+		headroomMB, marginBound = free, true
+	}
+	return max(headroomMB, 0), marginBound
+}
+
+// snapshotsMB is the host RAM held by frozen processes (see snapshotMB).
+// Caller must hold d.mu.
+func (d *Daemon) snapshotsMB() int64 {
+	var total int64
+	for _, p := range d.procs {
+		if p.State == protocol.StateFrozen {
+			total += snapshotMB(p)
+		}
+	}
+	return total
+}
+
+// checkRAM refuses to freeze name's memMB of GPU state when it would go
+// over the snapshot budget or eat into the free-RAM margin, and emits
+// ram-budget or ram-margin to say which. Caller must hold d.mu.
+func (d *Daemon) checkRAM(name string, memMB int64) error {
+	if memMB <= 0 {
+		return nil
+	}
+	total, free := gpu.HostMemInfo()
+	headroom, marginBound := ramHeadroom(d.cfg.RAMBudgetMB, d.snapshotsMB(), free, d.ramMarginMB(total))
+	if memMB <= headroom {
+		return nil
+	}
+	typ, limit := "ram-budget", fmt.Sprintf("the %s snapshot budget", bytesize.FormatMB(d.cfg.RAMBudgetMB))
+	if marginBound {
+		typ, limit = "ram-margin", fmt.Sprintf("the %s free-RAM margin", bytesize.FormatMB(d.ramMarginMB(total)))
+	}
+	detail := fmt.Sprintf("snapshot of %s would exceed %s (%s headroom)", bytesize.FormatMB(memMB), limit, bytesize.FormatMB(headroom))
+	d.emit(protocol.Event{Type: typ, Process: name, Detail: detail})
+	return fmt.Errorf("not enough host RAM: %s", detail)
+}
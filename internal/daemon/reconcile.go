@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"gpusched/internal/gpu"
+	"gpusched/internal/protocol"
+)
+
+const (
+	defaultReconcileInterval = 30 * time.Second
+	defaultZeroMemGrace      = 5 * time.Minute
+)
+
+// reconcileLoop periodically cross-checks nvidia-smi compute apps against
+// the process table until the daemon shuts down.
+func (d *Daemon) reconcileLoop() {
+	ticker := time.NewTicker(d.cfg.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+		apps, err := gpu.ComputeApps()
+		if err != nil {
+			continue
+		}
+		d.mu.Lock()
+		d.reconcile(apps, time.Now())
+		d.mu.Unlock()
+	}
+}
+
+// reconcile compares GPU memory per PID with each process's state and emits
+// a "reconcile" event when a frozen process still holds GPU memory or an
+// active one has held none for longer than the grace period. Each condition
+// is reported once until it clears. Caller must hold d.mu.
+func (d *Daemon) reconcile(apps map[int]int64, now time.Time) {
+	for _, p := range d.procs {
+		mem := apps[p.PID]
+		if mem > 0 {
+			p.lastGPUSeen = now
+		}
+
+		switch p.State {
+		case protocol.StateFrozen:
+			leaked := mem > 0
+			if leaked && !p.gpuLeakWarned {
+				d.reconcileWarn(p, fmt.Sprintf("frozen but still holds %d MB on GPU %d — checkpoint did not release it", mem, p.GPU))
+			}
+			p.gpuLeakWarned = leaked
+			p.zeroMemWarned = false
+
+		case protocol.StateActive:
+			since := p.lastGPUSeen
+			if since.IsZero() {
+				since = p.Started
+			}
+			idle := mem == 0 && now.Sub(since) > d.cfg.ZeroMemGrace
+			if idle && !p.zeroMemWarned {
+				d.reconcileWarn(p, fmt.Sprintf("active but has held no GPU memory for %s", formatDuration(now.Sub(since))))
+			}
+			p.zeroMemWarned = idle
+			p.gpuLeakWarned = false
+		}
+	}
+}
+
+func (d *Daemon) reconcileWarn(p *Proc, detail string) {
+	d.metrics.ReconcileWarnings++
+	d.emit(protocol.Event{Type: "reconcile", Process: p.Name, Detail: detail})
+	d.log.Printf("RECONCILE %s pid=%d: %s", p.Name, p.PID, detail)
+}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// DefaultRedactPatterns are the variable and flag names whose values are
+// hidden from commands the daemon reports, unless Config.RedactPatterns
+// replaces them. Names are matched upper-cased, with '-' read as '_'.
+var DefaultRedactPatterns = []string{
+	"*TOKEN*", "*SECRET*", "*PASSWORD*", "*PASSWD*", "*_KEY", "*CREDENTIAL*",
+}
+
+const (
+	redacted = "***"
+	// minSecretLen keeps values like "1" or "true" from being masked
+	// wherever they appear in a command.
+	minSecretLen = 4
+)
+
+// assignRE finds NAME=value assignments inside an argument, including in
+// shell command strings.
+var assignRE = regexp.MustCompile(`(^|[\s;&|(])(-{0,2}[A-Za-z_][A-Za-z0-9_-]*)=([^\s;&|)]*)`)
+
+// redactPatterns returns the configured patterns, or the defaults.
+func (d *Daemon) redactPatterns() []string {
+	if d.cfg.RedactPatterns != nil {
+		return d.cfg.RedactPatterns
+	}
+	return DefaultRedactPatterns
+}
+
+// sensitive reports whether a variable or flag name matches patterns.
+func sensitive(patterns []string, name string) bool {
+	name = strings.ReplaceAll(strings.ToUpper(strings.TrimLeft(name, "-")), "-", "_")
+	if name == "" {
+		return false
+	}
+	for _, p := range patterns {
+		if matchAny([]string{strings.ToUpper(p)}, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretValues returns the values of the KEY=VALUE entries in env whose
+// keys are sensitive, so they can be masked wherever they appear (e.g.
+// after --expand-env).
+func secretValues(patterns, env []string) []string {
+	var secrets []string
+	for _, kv := range env {
+		k, v, ok := strings.Cut(kv, "=")
+		if ok && len(v) >= minSecretLen && sensitive(patterns, k) {
+			secrets = append(secrets, v)
+		}
+	}
+	return secrets
+}
+
+// redactArgs returns a copy of argv with secrets masked: values of
+// sensitive NAME=value assignments and --flag=value or --flag value
+// options, and any occurrence of the given secret values.
+func redactArgs(argv, patterns, secrets []string) []string {
+	out := make([]string, len(argv))
+	for i, arg := range argv {
+		if i > 0 && strings.HasPrefix(argv[i-1], "-") && !strings.Contains(argv[i-1], "=") &&
+			!strings.HasPrefix(arg, "-") && sensitive(patterns, argv[i-1]) {
+			out[i] = redacted
+			continue
+		}
+		for _, s := range secrets {
+			arg = strings.ReplaceAll(arg, s, redacted)
+		}
+		out[i] = assignRE.ReplaceAllStringFunc(arg, func(m string) string {
+			sub := assignRE.FindStringSubmatch(m)
+			if sub[3] == "" || !sensitive(patterns, sub[2]) {
+				return m
+			}
+			return sub[1] + sub[2] + "=" + redacted
+		})
+	}
+	return out
+}
+
+// displayCommand renders argv for status, events, and logs with secrets
+// masked; env is the process's environment.
+func (d *Daemon) displayCommand(argv, env []string) string {
+	patterns := d.redactPatterns()
+	return quoteArgv(redactArgs(argv, patterns, secretValues(patterns, env)))
+}
+
+// envList flattens a process environment read from /proc.
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	return list
+}
+
+// queuedEnv approximates a queued run's environment for redaction: the
+// daemon's own plus the variables the run and the daemon set.
+func (d *Daemon) queuedEnv(set map[string]string) []string {
+	env := os.Environ()
+	for _, m := range []map[string]string{d.cfg.Env.Set, set} {
+		for k, v := range m {
+			env = append(env, k+"="+v)
+		}
+	}
+	return env
+}
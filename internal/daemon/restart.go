@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+// Relaunches under a restart policy back off from restartBackoffMin,
+// doubling up to restartBackoffMax. A run that lasted restartResetAfter
+// starts the backoff over.
+const (
+	restartBackoffMin = time.Second
+	restartBackoffMax = 5 * time.Minute
+	restartResetAfter = 10 * time.Minute
+)
+
+func validRestartPolicy(policy string) bool {
+	switch policy {
+	case "", protocol.RestartNo, protocol.RestartAlways, protocol.RestartOnFailure:
+		return true
+	}
+	return false
+}
+
+// restartPolicy describes p's restart policy for ProcessInfo, such as
+// "on-failure:5", or "" if it has none.
+func restartPolicy(p *Proc) string {
+	policy := p.params.Restart
+	if policy == "" || policy == protocol.RestartNo {
+		return ""
+	}
+	if p.params.RestartMax > 0 {
+		return fmt.Sprintf("%s:%d", policy, p.params.RestartMax)
+	}
+	return policy
+}
+
+// restartBackoff is the delay before relaunch number streak+1 of a run of
+// relaunches.
+func restartBackoff(streak int) time.Duration {
+	delay := restartBackoffMin
+	for range streak {
+		delay *= 2
+		if delay >= restartBackoffMax {
+			return restartBackoffMax
+		}
+	}
+	return delay
+}
+
+// scheduleRestart relaunches p, which has just exited with failed set if
+// it exited non-zero or was killed by a signal, if its restart policy
+// calls for it. Caller must hold d.mu.
+func (d *Daemon) scheduleRestart(p *Proc, failed bool, detail string) {
+	if p.deadlineHit && maxRuntimeAction(p) == protocol.DeadlineKill {
+		// Killed for running too long; relaunching would defeat that.
+		return
+	}
+	switch p.params.Restart {
+	case protocol.RestartAlways:
+	case protocol.RestartOnFailure:
+		if !failed {
+			return
+		}
+	default:
+		return
+	}
+	if max := p.params.RestartMax; max > 0 && p.Restarts >= max {
+		d.emit(protocol.Event{Type: "restart-gave-up", Process: p.Name, Detail: fmt.Sprintf("restarted %d times", p.Restarts)})
+		d.log.Printf("RESTART %s: giving up after %d restarts", p.Name, p.Restarts)
+		return
+	}
+
+	streak := p.restartStreak
+	if time.Since(p.Started) >= restartResetAfter {
+		streak = 0
+	}
+	delay := restartBackoff(streak)
+	p.nextRestart = time.Now().Add(delay)
+	d.log.Printf("RESTART %s in %s (policy %s)", p.Name, delay, restartPolicy(p))
+
+	go func() {
+		select {
+		case <-d.done:
+			return
+		case <-time.After(delay):
+		}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		// A kill, rm, or manual restart in the meantime supersedes this.
+		if d.procs[p.Name] != p || p.State != protocol.StateDead {
+			return
+		}
+		p.nextRestart = time.Time{}
+		if err := d.restart(p.Name, fmt.Sprintf("%s; policy %s", detail, restartPolicy(p))); err != nil {
+			return
+		}
+		d.procs[p.Name].restartStreak = streak + 1
+	}()
+}
@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"sync"
+	"time"
+
+	"gpusched/internal/gpu"
+)
+
+// defaultSampleInterval is how often host memory and per-process GPU
+// memory are sampled for status.
+const defaultSampleInterval = 2 * time.Second
+
+// hostSample is host RAM and per-process GPU memory as of the last
+// sample, so status calls (the dashboard makes one every tick) don't each
+// run nvidia-smi and read meminfo. Freezes and other operations that act
+// on memory still measure it themselves.
+type hostSample struct {
+	mu       sync.RWMutex
+	loaded   bool
+	totalRAM int64
+	freeRAM  int64
+	apps     map[int]int64
+}
+
+// sampled returns the last sample, taking one first if there is none yet.
+// apps is GPU memory in MB by PID and must not be modified.
+func (d *Daemon) sampled() (totalRAM, freeRAM int64, apps map[int]int64) {
+	d.smp.mu.RLock()
+	loaded := d.smp.loaded
+	d.smp.mu.RUnlock()
+	if !loaded {
+		d.sample()
+	}
+	d.smp.mu.RLock()
+	defer d.smp.mu.RUnlock()
+	return d.smp.totalRAM, d.smp.freeRAM, d.smp.apps
+}
+
+// sample refreshes the cached sample.
+func (d *Daemon) sample() {
+	total, free := gpu.HostMemInfo()
+	apps, err := gpu.ComputeApps()
+	if err != nil {
+		apps = nil
+	}
+	d.smp.mu.Lock()
+	d.smp.totalRAM, d.smp.freeRAM, d.smp.apps, d.smp.loaded = total, free, apps, true
+	d.smp.mu.Unlock()
+}
+
+// sampleLoop samples every SampleInterval until shutdown, enforcing GPU
+// memory limits against each sample.
+func (d *Daemon) sampleLoop() {
+	ticker := time.NewTicker(d.cfg.SampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+		d.sample()
+		d.smp.mu.RLock()
+		apps := d.smp.apps
+		d.smp.mu.RUnlock()
+		if apps != nil {
+			d.checkGPUMemLimits(apps)
+		}
+	}
+}
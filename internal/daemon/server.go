@@ -1,7 +1,8 @@
 package daemon
 
 import (
-	"bufio"
+	"crypto/subtle"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -9,16 +10,18 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"gpusched/internal/protocol"
 )
 
-const DefaultSocket = "/tmp/gpusched.sock"
+const DefaultSocket = protocol.DefaultSocket
 
 type Server struct {
 	daemon   *Daemon
 	sockPath string
 	listener net.Listener
+	tcp      net.Listener
 	wg       sync.WaitGroup
 }
 
@@ -47,67 +50,403 @@ func (s *Server) ListenAndServe() error {
 		sig := <-sigCh
 		s.daemon.log.Printf("received %s — shutting down", sig)
 		s.daemon.Shutdown()
+		if s.tcp != nil {
+			s.tcp.Close()
+		}
 		ln.Close()
 	}()
 
+	s.serve(ln, TCPAuth{})
+	return nil
+}
+
+// tokenOK reports whether got matches want, which is not required if
+// empty.
+func tokenOK(got, want string) bool {
+	return want == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// TCPAuth secures a TCP listener. With neither field set anyone who can
+// reach the port can drive the daemon.
+type TCPAuth struct {
+	// TLS, if set, serves TLS (clients use tls://host:port).
+	TLS *tls.Config
+	// Token, if set, must come with every request.
+	Token string
+	// ReadOnly refuses everything but status, logs, subscriptions, and
+	// other reads.
+	ReadOnly bool
+}
+
+// ListenTCP serves the same protocol on a TCP address as well, so the CLI
+// and dashboard on another machine can reach the daemon with
+// --socket tcp://host:port. Call it before ListenAndServe. TCP callers
+// have no UID, so with roles configured they get the default role.
+func (s *Server) ListenTCP(addr string, auth TCPAuth) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", addr, err)
+	}
+	prefix := protocol.TCPPrefix
+	if auth.TLS != nil {
+		ln = tls.NewListener(ln, auth.TLS)
+		prefix = protocol.TLSPrefix
+	}
+	s.tcp = ln
+	s.daemon.log.Printf("listening on %s%s", prefix, ln.Addr())
+	go s.serve(ln, auth)
+	return nil
+}
+
+// serve accepts connections on ln until it closes, applying auth to every
+// request on them.
+func (s *Server) serve(ln net.Listener, auth TCPAuth) {
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
-			return nil
+			return
 		}
 		s.wg.Add(1)
-		go s.handleConn(conn)
+		go s.handleConn(conn, auth)
 	}
 }
 
-func (s *Server) handleConn(conn net.Conn) {
+func (s *Server) handleConn(conn net.Conn, auth TCPAuth) {
 	defer s.wg.Done()
 	defer conn.Close()
 
-	scanner := bufio.NewScanner(conn)
-	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	dec := protocol.NewRequestDecoder(conn)
+	uid := peerUID(conn)
 
-	for scanner.Scan() {
+	// Once the client opts in to notifications, a second goroutine writes
+	// to conn; wmu keeps its messages from interleaving with responses.
+	var wmu sync.Mutex
+	var notes chan protocol.Event
+	defer func() {
+		if notes != nil {
+			s.daemon.Unsubscribe(notes)
+		}
+	}()
+
+	for {
+		data, err := dec.Next()
+		if err != nil {
+			return
+		}
 		var req protocol.Request
-		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
-			writeJSON(conn, protocol.ErrResponse("invalid json: "+err.Error()))
+		if err := json.Unmarshal(data, &req); err != nil {
+			protocol.WriteMessage(conn, protocol.ErrCodeResponse(protocol.CodeInvalid, "invalid json: "+err.Error()), false)
 			continue
 		}
+		framed := req.Framing == protocol.FramingLength
+		if !tokenOK(req.Token, auth.Token) {
+			s.daemon.log.Printf("REJECT %s from %s: missing or wrong token", req.Method, conn.RemoteAddr())
+			protocol.WriteMessage(conn, protocol.ErrCodeResponse(protocol.CodeUnauthenticated, "unauthorized: missing or wrong token"), framed)
+			return
+		}
+		if auth.ReadOnly {
+			if err := checkReadOnly(req.Method); err != nil {
+				if protocol.WriteMessage(conn, errResponse(err), framed) != nil {
+					return
+				}
+				continue
+			}
+		}
 
 		if req.Method == "subscribe" {
-			s.handleSubscribe(conn)
+			s.handleSubscribe(conn, req, framed)
+			return
+		}
+		if req.Method == "status_stream" {
+			s.handleStatusStream(conn, req, framed)
+			return
+		}
+		if req.Method == "logs" && req.Node == "" {
+			var p protocol.LogsParams
+			if json.Unmarshal(req.Params, &p) == nil && p.Follow {
+				s.handleLogsFollow(conn, p, framed)
+				return
+			}
+		}
+		if req.Method == "attach" {
+			s.handleAttach(conn, dec, req, uid, framed)
 			return
 		}
 
-		resp := s.daemon.Handle(req)
-		if err := writeJSON(conn, resp); err != nil {
+		var resp protocol.Response
+		if req.Method == "notify" {
+			resp = s.startNotify(conn, &wmu, &notes, req, framed)
+		} else {
+			resp = s.daemon.HandleAs(req, uid)
+		}
+		wmu.Lock()
+		err = protocol.WriteMessage(conn, resp, framed)
+		wmu.Unlock()
+		if err != nil {
 			return
 		}
 	}
 }
 
-func (s *Server) handleSubscribe(conn net.Conn) {
+// peerUID returns the UID of the process at the other end of a unix
+// socket connection, or nil for other connections.
+func peerUID(conn net.Conn) *int {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	var cred *syscall.Ucred
+	raw.Control(func(fd uintptr) {
+		cred, err = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil || cred == nil {
+		return nil
+	}
+	uid := int(cred.Uid)
+	return &uid
+}
+
+// startNotify turns a command connection into a notification channel as
+// well: matching events are pushed as Responses with Notification set,
+// interleaved with the replies to whatever the client sends next. The
+// subscription lasts until the connection closes.
+func (s *Server) startNotify(conn net.Conn, wmu *sync.Mutex, notes *chan protocol.Event, req protocol.Request, framed bool) protocol.Response {
+	var p protocol.NotifyParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return protocol.Response{ID: req.ID, Error: "bad params: " + err.Error(), Code: protocol.CodeInvalid}
+		}
+	}
+	if *notes != nil {
+		return protocol.Response{ID: req.ID, Error: "notifications already enabled on this connection"}
+	}
+	ch := s.daemon.Subscribe()
+	*notes = ch
+	match := notifyFilter(p)
+
+	go func() {
+		for e := range ch {
+			if e.Type != "disconnect" && !match(e) {
+				continue
+			}
+			e := e
+			wmu.Lock()
+			err := protocol.WriteMessage(conn, protocol.Response{Notification: &e}, framed)
+			wmu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	s.daemon.log.Printf("notifications enabled on command connection")
+	return protocol.Response{ID: req.ID, OK: true}
+}
+
+// notifyFilter reports whether an event should be pushed to a connection
+// that asked for notifications with p.
+func notifyFilter(p protocol.NotifyParams) func(protocol.Event) bool {
+	types := p.Types
+	if len(types) == 0 {
+		types = protocol.NotifyEventTypes
+	}
+	want := make(map[string]bool, len(types))
+	for _, t := range types {
+		want[t] = true
+	}
+	return func(e protocol.Event) bool {
+		if !want[e.Type] {
+			return false
+		}
+		if p.Namespace != "" {
+			if ns, _ := protocol.SplitQualifiedName(e.Process); ns != p.Namespace {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func (s *Server) handleSubscribe(conn net.Conn, req protocol.Request, framed bool) {
+	var p protocol.SubscribeParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			protocol.WriteMessage(conn, protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error()), framed)
+			return
+		}
+	}
 	ch := s.daemon.Subscribe()
 	defer s.daemon.Unsubscribe(ch)
 
-	status := s.daemon.Status()
-	writeJSON(conn, protocol.OkResponse(status))
+	status := s.daemon.Status(protocol.StatusParams{})
+	protocol.WriteMessage(conn, protocol.OkResponse(status), framed)
 
-	for event := range ch {
-		if err := writeJSON(conn, event); err != nil {
+	// Without deltas, tick stays nil and never fires.
+	var tick <-chan time.Time
+	if p.StatusDeltas {
+		ticker := time.NewTicker(streamInterval(p.IntervalMs))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		var event protocol.Event
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			event = e
+		case now := <-tick:
+			next := s.daemon.Status(protocol.StatusParams{})
+			delta := protocol.DiffStatus(status, next)
+			if delta.Empty() {
+				continue
+			}
+			status = next
+			event = protocol.Event{Time: now, Type: "status-delta", Delta: &delta}
+		}
+		if err := protocol.WriteMessage(conn, event, framed); err != nil {
 			return
 		}
 	}
 }
 
-func writeJSON(conn net.Conn, v interface{}) error {
-	data, err := json.Marshal(v)
+// streamInterval is the push interval asked for in ms, defaulting to
+// defaultStatusStreamInterval and no shorter than minStatusStreamInterval.
+func streamInterval(ms int64) time.Duration {
+	interval := time.Duration(ms) * time.Millisecond
+	if interval == 0 {
+		interval = defaultStatusStreamInterval
+	}
+	if interval < minStatusStreamInterval {
+		interval = minStatusStreamInterval
+	}
+	return interval
+}
+
+const (
+	defaultStatusStreamInterval = 2 * time.Second
+	minStatusStreamInterval     = 250 * time.Millisecond
+)
+
+// handleLogsFollow streams a process's log until it ends or the client
+// hangs up. The first message holds the last lines, as for logs; each
+// later one holds new lines.
+func (s *Server) handleLogsFollow(conn net.Conn, p protocol.LogsParams, framed bool) {
+	if p.Lines == 0 {
+		p.Lines = 50
+	}
+	// The client sends nothing more, so a read returns when it hangs up.
+	gone := make(chan struct{})
+	go func() {
+		conn.Read(make([]byte, 1))
+		close(gone)
+	}()
+	err := s.daemon.FollowLogs(protocol.QualifiedName(p.Namespace, p.Name), p, gone, func(res protocol.LogsResult) error {
+		return protocol.WriteMessage(conn, protocol.OkResponse(res), framed)
+	})
+	if err != nil {
+		protocol.WriteMessage(conn, errResponse(err), framed)
+	}
+}
+
+// handleAttach runs a terminal session with a process run with TTY: input
+// the client sends on dec goes to the process, and the process's output
+// goes back, until the client hangs up or the terminal closes.
+func (s *Server) handleAttach(conn net.Conn, dec *protocol.Decoder, req protocol.Request, uid *int, framed bool) {
+	if req.Node != "" {
+		protocol.WriteMessage(conn, protocol.ErrResponse("attach is not relayed to cluster peers"), framed)
+		return
+	}
+	// attach doesn't go through handle, so it checks what handle would.
+	if s.daemon.cfg.ReadOnly {
+		if err := checkReadOnly(req.Method); err != nil {
+			protocol.WriteMessage(conn, errResponse(err), framed)
+			return
+		}
+	}
+	if err := s.daemon.authorize(req, uid); err != nil {
+		protocol.WriteMessage(conn, errResponse(err), framed)
+		return
+	}
+	var p protocol.AttachParams
+	if err := json.Unmarshal(req.Params, &p); err != nil {
+		protocol.WriteMessage(conn, protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error()), framed)
+		return
+	}
+	t, out, detach, err := s.daemon.attach(p)
 	if err != nil {
-		return err
+		protocol.WriteMessage(conn, errResponse(err), framed)
+		return
+	}
+	defer detach()
+	if err := protocol.WriteMessage(conn, protocol.OkResponse("ok"), framed); err != nil {
+		return
+	}
+
+	go func() {
+		// Hanging up ends the session; detaching closes out.
+		defer detach()
+		for {
+			data, err := dec.Next()
+			if err != nil {
+				return
+			}
+			var in protocol.AttachInput
+			if json.Unmarshal(data, &in) != nil {
+				continue
+			}
+			if in.Rows > 0 && in.Cols > 0 {
+				t.resize(in.Rows, in.Cols)
+			}
+			if len(in.Data) > 0 {
+				if _, err := t.master.Write(in.Data); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for data := range out {
+		if err := protocol.WriteMessage(conn, protocol.AttachOutput{Data: data}, framed); err != nil {
+			return
+		}
+	}
+	detail := "process exited"
+	if !t.isClosed() {
+		detail = "detached: output fell behind"
+	}
+	protocol.WriteMessage(conn, protocol.AttachOutput{Closed: true, Detail: detail}, framed)
+}
+
+// handleStatusStream pushes status snapshots on a fixed interval. The
+// connection is read-only; it ends when the client goes away or the daemon
+// shuts down.
+func (s *Server) handleStatusStream(conn net.Conn, req protocol.Request, framed bool) {
+	var p protocol.StatusStreamParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			protocol.WriteMessage(conn, protocol.ErrCodeResponse(protocol.CodeInvalid, "bad params: "+err.Error()), framed)
+			return
+		}
+	}
+	ticker := time.NewTicker(streamInterval(p.IntervalMs))
+	defer ticker.Stop()
+	for {
+		status := s.daemon.Status(protocol.StatusParams{Namespace: p.Namespace})
+		if err := protocol.WriteMessage(conn, protocol.OkResponse(status), framed); err != nil {
+			return
+		}
+		select {
+		case <-s.daemon.done:
+			return
+		case <-ticker.C:
+		}
 	}
-	data = append(data, '\n')
-	_, err = conn.Write(data)
-	return err
 }
 
 func (s *Server) Cleanup() {
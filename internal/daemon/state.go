@@ -0,0 +1,317 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"gpusched/internal/gpu"
+	"gpusched/internal/procfs"
+	"gpusched/internal/protocol"
+)
+
+const (
+	defaultDrainTimeout = 10 * time.Second
+	adoptPollInterval   = time.Second
+)
+
+// savedProc is a process left running across a daemon restart.
+type savedProc struct {
+	Name     string                `json:"name"`
+	PID      int                   `json:"pid"`
+	State    protocol.ProcessState `json:"state"`
+	GPU      int                   `json:"gpu"`
+	MemMB    int64                 `json:"mem_mb"`
+	Started  time.Time             `json:"started"`
+	Argv     []string              `json:"argv"`
+	LogPath  string                `json:"log_path"`
+	Restarts int                   `json:"restarts,omitempty"`
+	Notes    []protocol.Note       `json:"notes,omitempty"`
+	Params   protocol.RunParams    `json:"params"`
+
+	LastChange    *protocol.Transition    `json:"last_change,omitempty"`
+	AppCheckpoint *protocol.AppCheckpoint `json:"app_checkpoint,omitempty"`
+}
+
+func validShutdownPolicy(policy string) bool {
+	switch policy {
+	case "", protocol.ShutdownKill, protocol.ShutdownLeave:
+		return true
+	}
+	return false
+}
+
+func (d *Daemon) shutdownPolicy(p *Proc) string {
+	// A terminal's master side closes with the daemon, hanging up on the
+	// process, so there is nothing to leave running.
+	if p.params.TTY {
+		return protocol.ShutdownKill
+	}
+	if p.params.OnShutdown != "" {
+		return p.params.OnShutdown
+	}
+	if d.cfg.ShutdownPolicy != "" {
+		return d.cfg.ShutdownPolicy
+	}
+	return protocol.ShutdownKill
+}
+
+func (d *Daemon) writeState(procs []savedProc) error {
+	if len(procs) == 0 {
+		err := os.Remove(d.cfg.StatePath)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := json.MarshalIndent(procs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := d.cfg.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.cfg.StatePath)
+}
+
+// reattach adopts processes a previous daemon left running on shutdown.
+// Entries whose PID is gone or no longer carries GPUSCHED_MANAGED=1 are
+// dropped.
+func (d *Daemon) reattach() {
+	data, err := os.ReadFile(d.cfg.StatePath)
+	if err != nil {
+		return
+	}
+	var saved []savedProc
+	if err := json.Unmarshal(data, &saved); err != nil {
+		d.log.Printf("WARN: ignoring unreadable state file %s: %v", d.cfg.StatePath, err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, sp := range saved {
+		if _, exists := d.procs[sp.Name]; exists {
+			continue
+		}
+		if !isManagedPID(sp.PID) {
+			d.log.Printf("REATTACH %s pid=%d: process gone, dropping", sp.Name, sp.PID)
+			continue
+		}
+		p := &Proc{
+			Name:     sp.Name,
+			PID:      sp.PID,
+			State:    sp.State,
+			GPU:      sp.GPU,
+			MemMB:    sp.MemMB,
+			Started:  sp.Started,
+			Argv:     sp.Argv,
+			Shell:    sp.Params.Shell,
+			LogPath:  sp.LogPath,
+			Restarts: sp.Restarts,
+			Notes:    sp.Notes,
+			params:   sp.Params,
+			cgroup:   d.existingCgroup(sp.Name, sp.PID),
+			exited:   make(chan struct{}),
+
+			lastChange: sp.LastChange,
+
+			AppCheckpoint: sp.AppCheckpoint,
+
+			Readiness: sp.Params.Readiness,
+		}
+		d.procs[sp.Name] = p
+		if p.State == protocol.StateActive {
+			d.startActive(p, time.Now())
+		}
+		go d.monitorPID(p)
+		if sp.Params.Liveness != nil {
+			go d.livenessLoop(p)
+		}
+		if wake, err := openWakeWatch(sp.Params.Wake, sp.Params.Dir); err != nil {
+			d.log.Printf("REATTACH %s: %v", sp.Name, err)
+		} else if wake != nil {
+			go d.wakeLoop(p, wake)
+		}
+
+		d.emit(protocol.Event{Type: "reattach", Process: sp.Name, Detail: fmt.Sprintf("pid=%d %s", sp.PID, sp.State)})
+		d.log.Printf("REATTACH %s pid=%d %s", sp.Name, sp.PID, sp.State)
+	}
+	os.Remove(d.cfg.StatePath)
+}
+
+// recoverOrphans adopts live processes that carry GPUSCHED_MANAGED=1 but
+// are in no registry: a daemon that crashed leaves its processes running
+// with no state file. Processes whose launching daemon is still alive
+// belong to it and are left alone, as are children of a managed process.
+func (d *Daemon) recoverOrphans() {
+	apps, _ := gpu.ComputeApps()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	known := d.knownPIDs()
+	for _, pid := range procfs.PIDs() {
+		if known[pid] || pid == os.Getpid() {
+			continue
+		}
+		env, err := procfs.Environ(pid)
+		if err != nil || env["GPUSCHED_MANAGED"] != "1" {
+			continue
+		}
+		if owner, err := strconv.Atoi(env["GPUSCHED_DAEMON_PID"]); err == nil && syscall.Kill(owner, 0) == nil {
+			continue
+		}
+		if ppid, err := procfs.ParentPID(pid); err == nil && isManagedPID(ppid) {
+			continue
+		}
+		argv, err := procfs.Cmdline(pid)
+		if err != nil || len(argv) == 0 || argv[0] == "" {
+			continue
+		}
+		d.adoptOrphan(pid, env, argv, apps[pid])
+	}
+}
+
+// knownPIDs returns the PIDs of every registered process and its workers.
+// Caller must hold d.mu.
+func (d *Daemon) knownPIDs() map[int]bool {
+	known := make(map[int]bool)
+	for _, p := range d.procs {
+		known[p.PID] = true
+		for _, w := range p.workers {
+			known[w] = true
+		}
+	}
+	return known
+}
+
+// adoptOrphan registers a recovered process. Its name comes from
+// GPUSCHED_NAME (processes started before that was set are named
+// orphan-PID) and its GPU from CUDA_VISIBLE_DEVICES. Caller must hold d.mu.
+func (d *Daemon) adoptOrphan(pid int, env map[string]string, argv []string, memMB int64) {
+	name := env["GPUSCHED_NAME"]
+	if name == "" {
+		name = fmt.Sprintf("orphan-%d", pid)
+	}
+	if _, exists := d.procs[name]; exists {
+		name = fmt.Sprintf("%s-%d", name, pid)
+	}
+	gpuIdx, _ := strconv.Atoi(env["CUDA_VISIBLE_DEVICES"])
+
+	logPath := filepath.Join(d.cfg.LogDir, name+".log")
+	if _, err := os.Stat(logPath); err != nil {
+		logPath = ""
+	}
+	p := d.adoptPID(pid, name, gpuIdx, argv, memMB, logPath, "")
+
+	detail := fmt.Sprintf("pid=%d %s (recovered orphan)", pid, p.State)
+	d.emit(protocol.Event{Type: "reattach", Process: name, Detail: detail})
+	d.log.Printf("RECOVER %s pid=%d %s", name, pid, p.State)
+}
+
+// adoptPID registers a running process this daemon didn't start and
+// watches it for exit. Whether it is frozen is asked of cuda-checkpoint.
+// Caller must hold d.mu.
+func (d *Daemon) adoptPID(pid int, name string, gpuIdx int, argv []string, memMB int64, logPath, onShutdown string) *Proc {
+	cudaState, _ := d.cuda.State(pid)
+	state := recoveredState(procfs.Stopped(pid), memMB, cudaState)
+
+	env, _ := procfs.Environ(pid)
+	namespace, short := protocol.SplitQualifiedName(name)
+	p := &Proc{
+		Name:    name,
+		PID:     pid,
+		State:   state,
+		GPU:     gpuIdx,
+		MemMB:   memMB,
+		Started: time.Now(),
+		Argv:    argv,
+		LogPath: logPath,
+		command: d.displayCommand(argv, envList(env)),
+		params: protocol.RunParams{
+			Namespace:  namespace,
+			Name:       short,
+			Cmd:        argv,
+			GPU:        gpuIdx,
+			OnShutdown: onShutdown,
+		},
+		cgroup: d.existingCgroup(name, pid),
+		exited: make(chan struct{}),
+	}
+	d.procs[name] = p
+	if p.State == protocol.StateActive {
+		d.startActive(p, p.Started)
+	}
+	go d.monitorPID(p)
+	return p
+}
+
+// recoveredState guesses whether an orphan was frozen. cuda-checkpoint
+// knows; without it, a stopped process holding no GPU memory is assumed
+// to have been frozen by the daemon that crashed.
+func recoveredState(stopped bool, memMB int64, cudaState string) protocol.ProcessState {
+	switch cudaState {
+	case "checkpointed":
+		return protocol.StateFrozen
+	case "":
+		if stopped && memMB == 0 {
+			return protocol.StateFrozen
+		}
+	}
+	return protocol.StateActive
+}
+
+// isManagedPID reports whether pid is alive and was launched by gpusched.
+func isManagedPID(pid int) bool {
+	if pid <= 0 || syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	env, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return false
+	}
+	for _, kv := range bytes.Split(env, []byte{0}) {
+		if string(kv) == "GPUSCHED_MANAGED=1" {
+			return true
+		}
+	}
+	return false
+}
+
+// monitorPID watches a process that is not our child (so cannot be
+// waited on) and marks it dead once it disappears.
+func (d *Daemon) monitorPID(p *Proc) {
+	ticker := time.NewTicker(adoptPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if syscall.Kill(p.PID, 0) == nil {
+			continue
+		}
+		close(p.exited)
+
+		d.mu.Lock()
+		if d.procs[p.Name] == p && p.State != protocol.StateDead {
+			d.endActive(p, time.Now())
+			p.State = protocol.StateDead
+			p.exitedAt = time.Now()
+			d.cpu.Forget(p.PID)
+			if p.cgroup != "" {
+				d.removeCgroup(p.Name)
+			}
+			d.emit(protocol.Event{Type: "exit", Process: p.Name, Detail: "exited (adopted, status unknown)"})
+			d.log.Printf("EXIT %s pid=%d: exited (adopted)", p.Name, p.PID)
+			d.scheduleRestart(p, true, "exited (status unknown)")
+		}
+		d.mu.Unlock()
+		return
+	}
+}
@@ -0,0 +1,75 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"gpusched/internal/protocol"
+)
+
+// openStdio opens a run's Input and Output for the child's stdin and
+// stdout. Either may be nil, meaning /dev/null and the log respectively.
+// Output is truncated unless appendOut is set, as for the log on restart.
+//
+// Named pipes are opened non-blocking so a missing peer can't stall the
+// daemon: the writer of an input pipe, or the reader of an output pipe,
+// must already be waiting when the process starts. The descriptors are
+// then made blocking again, since the child inherits them as they are.
+func openStdio(params protocol.RunParams, appendOut bool) (in, out *os.File, err error) {
+	if params.Input != "" {
+		in, err = openStdioFile(resolve(params.Dir, params.Input), os.O_RDONLY, 0)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening input: %w", err)
+		}
+	}
+	if params.Output != "" {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if appendOut {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		out, err = openStdioFile(resolve(params.Dir, params.Output), flags, 0o644)
+		if err != nil {
+			if in != nil {
+				in.Close()
+			}
+			if errors.Is(err, syscall.ENXIO) {
+				err = fmt.Errorf("%w (no reader on the pipe yet)", err)
+			}
+			return nil, nil, fmt.Errorf("opening output: %w", err)
+		}
+	}
+	return in, out, nil
+}
+
+func openStdioFile(path string, flags int, perm os.FileMode) (*os.File, error) {
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeNamedPipe != 0 {
+		f, err := os.OpenFile(path, flags|syscall.O_NONBLOCK, perm)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.SetNonblock(int(f.Fd()), false); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+	return os.OpenFile(path, flags, perm)
+}
+
+func closeAll(files ...*os.File) {
+	for _, f := range files {
+		if f != nil {
+			f.Close()
+		}
+	}
+}
+
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) || dir == "" {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"fmt"
+	"slices"
+
+	"gpusched/internal/protocol"
+)
+
+const subscriberBuffer = 64
+
+type subscriber struct {
+	id      int
+	ch      chan protocol.Event
+	dropped int
+}
+
+func (d *Daemon) Subscribe() chan protocol.Event {
+	ch := make(chan protocol.Event, subscriberBuffer)
+	d.subMu.Lock()
+	d.nextSubID++
+	d.subs = append(d.subs, &subscriber{id: d.nextSubID, ch: ch})
+	d.subMu.Unlock()
+	return ch
+}
+
+func (d *Daemon) Unsubscribe(ch chan protocol.Event) {
+	d.subMu.Lock()
+	defer d.subMu.Unlock()
+	for i, s := range d.subs {
+		if s.ch == ch {
+			d.subs = append(d.subs[:i], d.subs[i+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
+
+// broadcast delivers e to every subscriber without blocking. Events that
+// don't fit in a subscriber's buffer are counted as dropped; past
+// MaxSubscriberDrops the subscriber is sent a final "disconnect" event and
+// its channel is closed.
+func (d *Daemon) broadcast(e protocol.Event) {
+	d.subMu.Lock()
+	defer d.subMu.Unlock()
+
+	kept := d.subs[:0]
+	for _, s := range d.subs {
+		select {
+		case s.ch <- e:
+			kept = append(kept, s)
+			continue
+		default:
+		}
+
+		s.dropped++
+		d.dropped++
+		if d.cfg.MaxSubscriberDrops <= 0 || s.dropped < d.cfg.MaxSubscriberDrops {
+			kept = append(kept, s)
+			continue
+		}
+
+		// Make room for the explanation by discarding the oldest event.
+		select {
+		case <-s.ch:
+			s.dropped++
+			d.dropped++
+		default:
+		}
+		select {
+		case s.ch <- protocol.Event{
+			Time:   e.Time,
+			Type:   "disconnect",
+			Detail: fmt.Sprintf("subscriber too slow: dropped %d events", s.dropped),
+		}:
+		default:
+		}
+		close(s.ch)
+		d.log.Printf("SUBSCRIBER %d disconnected after dropping %d events", s.id, s.dropped)
+	}
+	for i := len(kept); i < len(d.subs); i++ {
+		d.subs[i] = nil
+	}
+	d.subs = kept
+}
+
+func (d *Daemon) closeSubscribers() {
+	d.subMu.Lock()
+	for _, s := range d.subs {
+		close(s.ch)
+	}
+	d.subs = nil
+	d.subMu.Unlock()
+}
+
+// metricsSnapshot returns the counters plus live subscriber lag.
+func (d *Daemon) metricsSnapshot() protocol.Metrics {
+	m := d.metrics
+	for _, h := range []*protocol.Histogram{&m.FreezeLatency, &m.ThawLatency, &m.MigrateLatency} {
+		h.Counts = slices.Clone(h.Counts)
+	}
+
+	d.subMu.Lock()
+	m.EventsDropped = d.dropped
+	for _, s := range d.subs {
+		m.Subscribers = append(m.Subscribers, protocol.SubscriberStats{
+			ID:      s.id,
+			Queued:  len(s.ch),
+			Dropped: s.dropped,
+		})
+	}
+	d.subMu.Unlock()
+	return m
+}
@@ -0,0 +1,25 @@
+package daemon
+
+import "gpusched/internal/protocol"
+
+const (
+	defaultSwapInMBps = 200
+
+	// swappedFraction is the share of a snapshot that must be in swap
+	// before a frozen process is reported as ram(swapped).
+	swappedFraction = 0.25
+)
+
+// swapTier returns the effective tier of a frozen process with snapshotMB
+// of GPU state, swapMB of which the kernel has swapped out, and the
+// estimated extra thaw time at swapInMBps.
+func swapTier(snapshotMB, swapMB, swapInMBps int64) (protocol.Tier, int64) {
+	if swapMB <= 0 {
+		return protocol.TierRAM, 0
+	}
+	penaltyMs := swapMB * 1000 / swapInMBps
+	if snapshotMB > 0 && float64(swapMB) < swappedFraction*float64(snapshotMB) {
+		return protocol.TierRAM, penaltyMs
+	}
+	return protocol.TierRAMSwapped, penaltyMs
+}
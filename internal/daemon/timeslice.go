@@ -0,0 +1,126 @@
+package daemon
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+// timeSliceTick is how often the time-slicing scheduler looks for slices
+// that are up.
+const timeSliceTick = time.Second
+
+// timeSliceLoop shares each GPU among the time-sliced processes on it
+// until the daemon shuts down.
+func (d *Daemon) timeSliceLoop() {
+	ticker := time.NewTicker(timeSliceTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+		d.timeSlice(time.Now())
+	}
+}
+
+// sliceSwitch is a change of turns on one GPU: the processes to freeze,
+// and why, then the one to thaw, if any.
+type sliceSwitch struct {
+	out []sliceOut
+	in  string
+}
+
+type sliceOut struct {
+	name, detail string
+}
+
+// timeSlice makes the switches due at now. Freezes and thaws run without
+// the daemon lock, one GPU after another.
+func (d *Daemon) timeSlice(now time.Time) {
+	d.mu.Lock()
+	switches := d.sliceSwitches(now)
+	d.mu.Unlock()
+
+	for _, s := range switches {
+		frozen := true
+		for _, o := range s.out {
+			if _, err := d.freeze(o.name, protocol.CauseTimeSlice, o.detail); err != nil {
+				d.log.Printf("TIME SLICE %s: freeze failed: %v", o.name, err)
+				frozen = false
+			}
+		}
+		// The GPU may still be in use; the next tick tries again.
+		if s.in == "" || !frozen {
+			continue
+		}
+		if _, err := d.thawReady(s.in, protocol.CauseTimeSlice, "its turn"); err != nil {
+			d.log.Printf("TIME SLICE %s: thaw failed: %v", s.in, err)
+		}
+	}
+}
+
+// sliceSwitches decides, for each GPU, who runs next. A time-sliced
+// process that has just started or been thawed by hand takes the GPU at
+// once, and any other running time-sliced process waits. Otherwise the
+// one running keeps the GPU for TimeSliceQuantum if others are waiting,
+// then hands it to the one that has waited longest. Processes frozen for
+// any other reason sit out until thawed. Caller must hold d.mu.
+func (d *Daemon) sliceSwitches(now time.Time) []sliceSwitch {
+	type turns struct {
+		running, waiting []*Proc
+	}
+	byGPU := make(map[int]*turns)
+	for _, p := range d.procs {
+		if !p.params.TimeSlice {
+			continue
+		}
+		t := byGPU[p.GPU]
+		if t == nil {
+			t = &turns{}
+			byGPU[p.GPU] = t
+		}
+		switch {
+		case p.State == protocol.StateActive:
+			t.running = append(t.running, p)
+		case p.State == protocol.StateFrozen && p.lastChange != nil && p.lastChange.Cause == protocol.CauseTimeSlice:
+			t.waiting = append(t.waiting, p)
+		}
+	}
+
+	var switches []sliceSwitch
+	for _, gpu := range slices.Sorted(maps.Keys(byGPU)) {
+		t := byGPU[gpu]
+		// Latest to start running first, then longest waiting first.
+		sort.Slice(t.running, func(i, j int) bool {
+			return t.running[i].activeSince.After(t.running[j].activeSince)
+		})
+		sort.Slice(t.waiting, func(i, j int) bool {
+			return t.waiting[i].frozenAt.Before(t.waiting[j].frozenAt)
+		})
+
+		var s sliceSwitch
+		if len(t.running) > 1 {
+			for _, p := range t.running[1:] {
+				s.out = append(s.out, sliceOut{p.Name, t.running[0].Name + " took the GPU"})
+			}
+			t.running = t.running[:1]
+		}
+		if len(t.running) == 1 && len(t.waiting) > 0 && now.Sub(t.running[0].activeSince) >= d.cfg.TimeSliceQuantum {
+			s.out = append(s.out, sliceOut{t.running[0].Name, "its " + formatDuration(d.cfg.TimeSliceQuantum) + " time slice is up"})
+			t.running = nil
+		}
+		if len(t.running) == 0 && len(t.waiting) > 0 {
+			s.in = t.waiting[0].Name
+		}
+		if len(s.out) > 0 || s.in != "" {
+			switches = append(switches, s)
+		}
+	}
+	return switches
+}
@@ -0,0 +1,182 @@
+package daemon
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"gpusched/internal/checkpoint"
+	"gpusched/internal/gpu"
+	"gpusched/internal/procfs"
+	"gpusched/internal/protocol"
+)
+
+// torchrunValueFlags are the torchrun flags that take a separate value,
+// with underscores normalized to dashes.
+var torchrunValueFlags = map[string]bool{
+	"nnodes": true, "nproc-per-node": true, "node-rank": true,
+	"rdzv-backend": true, "rdzv-endpoint": true, "rdzv-id": true, "rdzv-conf": true,
+	"master-addr": true, "master-port": true, "max-restarts": true,
+	"monitor-interval": true, "start-method": true, "role": true,
+	"log-dir": true, "redirects": true, "tee": true, "local-addr": true,
+}
+
+// detectTorchrun returns the rendezvous configuration if cmd launches
+// torchrun or python -m torch.distributed.run, else nil. Shell command
+// lines are split on whitespace.
+func detectTorchrun(cmd []string) *protocol.Rendezvous {
+	args := strings.Fields(strings.Join(cmd, " "))
+	start := -1
+	for i, a := range args {
+		if filepath.Base(a) == "torchrun" {
+			start = i + 1
+			break
+		}
+		if a == "-m" && i+1 < len(args) &&
+			(args[i+1] == "torch.distributed.run" || args[i+1] == "torch.distributed.launch") {
+			start = i + 2
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	r := &protocol.Rendezvous{}
+	for i := start; i < len(args); i++ {
+		a := args[i]
+		if !strings.HasPrefix(a, "-") {
+			break // the training script; the rest are its arguments
+		}
+		flag, value, hasValue := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		flag = strings.ReplaceAll(flag, "_", "-")
+		if !hasValue && torchrunValueFlags[flag] && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		switch flag {
+		case "standalone":
+			r.Standalone = true
+		case "nnodes":
+			r.Nnodes = value
+		case "nproc-per-node":
+			r.NprocPerNode = value
+		case "rdzv-backend":
+			r.Backend = value
+		case "rdzv-endpoint":
+			r.Endpoint = value
+		case "rdzv-id":
+			r.ID = value
+		}
+	}
+	return r
+}
+
+// gpuWorkers returns the descendants of pid that hold GPU memory and their
+// combined usage in MB.
+func gpuWorkers(pid int) ([]int, int64) {
+	apps, _ := gpu.ComputeApps()
+	return gpuWorkersIn(pid, apps)
+}
+
+// gpuWorkersIn is gpuWorkers with GPU memory by PID taken from apps.
+func gpuWorkersIn(pid int, apps map[int]int64) ([]int, int64) {
+	var workers []int
+	var total int64
+	for _, child := range procfs.Descendants(pid) {
+		if mem := apps[child]; mem > 0 {
+			workers = append(workers, child)
+			total += mem
+		}
+	}
+	return workers, total
+}
+
+// procGPUMem is p's GPU memory in MB, summed over its workers for torchrun
+// jobs, whose agent holds none itself.
+func procGPUMem(p *Proc) int64 {
+	apps, _ := gpu.ComputeApps()
+	return procGPUMemIn(p, apps)
+}
+
+// procGPUMemIn is procGPUMem with GPU memory by PID taken from apps.
+func procGPUMemIn(p *Proc, apps map[int]int64) int64 {
+	if detectTorchrun(p.params.Cmd) != nil {
+		_, mem := gpuWorkersIn(p.PID, apps)
+		return mem
+	}
+	return apps[p.PID]
+}
+
+// freezeElastic freezes a torchrun job. The elastic agent (p.PID) is
+// stopped first so it can't mark workers failed while they are locked for
+// checkpoint, then each GPU worker is checkpointed and stopped. On failure
+// everything is resumed. Caller must hold d.mu.
+func (d *Daemon) freezeElastic(p *Proc, rdzv *protocol.Rendezvous) (checkpoint.Phases, error) {
+	syscall.Kill(p.PID, syscall.SIGSTOP)
+
+	workers, mem := gpuWorkers(p.PID)
+	if len(workers) == 0 {
+		syscall.Kill(p.PID, syscall.SIGCONT)
+		return nil, fmt.Errorf("no GPU workers found under torchrun pid %d", p.PID)
+	}
+
+	var phases checkpoint.Phases
+	for i, w := range workers {
+		ph, err := d.cudaFor(p).Freeze(w)
+		phases = append(phases, workerPhases(w, ph)...)
+		if err != nil {
+			for _, done := range workers[:i] {
+				d.cudaFor(p).Thaw(done) //nolint:errcheck
+			}
+			syscall.Kill(p.PID, syscall.SIGCONT)
+			return phases, fmt.Errorf("worker %d: %w", w, err)
+		}
+	}
+	for _, w := range workers {
+		syscall.Kill(w, syscall.SIGSTOP)
+	}
+
+	p.workers = workers
+	p.MemMB = mem
+	if rdzv.MultiNode() {
+		d.emit(protocol.Event{
+			Type:    "rendezvous-warning",
+			Process: p.Name,
+			Detail: fmt.Sprintf("multi-node job (nnodes=%s, %s rendezvous): agents on other nodes may time out this node while frozen",
+				rdzv.Nnodes, rdzv.Backend),
+		})
+	}
+	return phases, nil
+}
+
+// thawElastic restores the workers frozen by freezeElastic, then resumes
+// the elastic agent. If a worker fails to restore, the ones already
+// restored keep running and a retried thaw picks up the rest; the agent
+// stays stopped until all are back. Caller must hold d.mu.
+func (d *Daemon) thawElastic(p *Proc) (checkpoint.Phases, error) {
+	var phases checkpoint.Phases
+	for len(p.workers) > 0 {
+		w := p.workers[0]
+		syscall.Kill(w, syscall.SIGCONT)
+		ph, err := d.cudaFor(p).Thaw(w)
+		phases = append(phases, workerPhases(w, ph)...)
+		if err != nil {
+			syscall.Kill(w, syscall.SIGSTOP)
+			return phases, fmt.Errorf("worker %d: %w", w, err)
+		}
+		p.workers = p.workers[1:]
+	}
+	syscall.Kill(p.PID, syscall.SIGCONT)
+	return phases, nil
+}
+
+// workerPhases labels each phase with the worker it ran on.
+func workerPhases(pid int, phases checkpoint.Phases) checkpoint.Phases {
+	out := make(checkpoint.Phases, len(phases))
+	for i, ph := range phases {
+		out[i] = checkpoint.Phase{Name: fmt.Sprintf("%s[%d]", ph.Name, pid), Duration: ph.Duration}
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+
+	"gpusched/internal/protocol"
+
+	"golang.org/x/sys/unix"
+)
+
+// attachBuffer is how many chunks of output an attached client may fall
+// behind by before it is detached.
+const attachBuffer = 256
+
+// terminal is the pseudo-terminal of a process run with TTY. The daemon
+// holds the master side: it copies what the process writes to its log and
+// to every attached client, and writes their input to it.
+type terminal struct {
+	master *os.File
+
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+	closed  bool
+}
+
+// openTerminal allocates a pseudo-terminal and returns it with its slave
+// side, for the child's stdin, stdout, and stderr.
+func openTerminal() (*terminal, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening pty: %w", err)
+	}
+	var n uint32
+	err = control(master, func(fd int) error {
+		if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+			return err
+		}
+		n, err = unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+		return err
+	})
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("unlocking pty: %w", err)
+	}
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("opening pty: %w", err)
+	}
+	return &terminal{master: master, clients: make(map[chan []byte]struct{})}, slave, nil
+}
+
+// control runs fn on f's descriptor without taking it out of the
+// runtime's poller, as f.Fd would.
+func control(f *os.File, fn func(fd int) error) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ferr error
+	if err := rc.Control(func(fd uintptr) { ferr = fn(int(fd)) }); err != nil {
+		return err
+	}
+	return ferr
+}
+
+// pump copies the process's output to log and the attached clients until
+// every copy of the slave side is closed, which is when the process and
+// anything it left running have exited. It then detaches the clients and
+// closes the master and log.
+func (t *terminal) pump(log io.WriteCloser) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := t.master.Read(buf)
+		if n > 0 {
+			log.Write(buf[:n])
+			t.broadcast(append([]byte(nil), buf[:n]...))
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	t.mu.Lock()
+	t.closed = true
+	for ch := range t.clients {
+		close(ch)
+		delete(t.clients, ch)
+	}
+	t.mu.Unlock()
+	t.master.Close()
+	log.Close()
+}
+
+// broadcast sends data to every attached client, detaching any that has
+// fallen attachBuffer chunks behind rather than dropping part of what the
+// process wrote.
+func (t *terminal) broadcast(data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ch := range t.clients {
+		select {
+		case ch <- data:
+		default:
+			close(ch)
+			delete(t.clients, ch)
+		}
+	}
+}
+
+// attach returns a channel of the process's output from now on, closed
+// when the terminal closes or the client falls behind, and a function
+// that detaches it.
+func (t *terminal) attach() (<-chan []byte, func(), error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, nil, errors.New("terminal is closed")
+	}
+	ch := make(chan []byte, attachBuffer)
+	t.clients[ch] = struct{}{}
+	detach := func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if _, ok := t.clients[ch]; ok {
+			close(ch)
+			delete(t.clients, ch)
+		}
+	}
+	return ch, detach, nil
+}
+
+func (t *terminal) isClosed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+// resize sets the terminal's size, which signals SIGWINCH to the process.
+func (t *terminal) resize(rows, cols int) error {
+	return control(t.master, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: uint16(rows), Col: uint16(cols)})
+	})
+}
+
+// attach connects to the terminal of a process run with TTY, resizing it
+// to the client's size if given. It returns the terminal, for input and
+// later resizes, the process's output from now on, and a function that
+// ends the session without affecting the process.
+func (d *Daemon) attach(params protocol.AttachParams) (*terminal, <-chan []byte, func(), error) {
+	name := protocol.QualifiedName(params.Namespace, params.Name)
+	d.mu.RLock()
+	p, ok := d.procs[name]
+	var t *terminal
+	if ok {
+		t = p.tty
+	}
+	d.mu.RUnlock()
+	if !ok {
+		return nil, nil, nil, errorf(protocol.CodeNotFound, "process %q not found", name)
+	}
+	if t == nil {
+		return nil, nil, nil, fmt.Errorf("%s was not run with a terminal (run --tty)", name)
+	}
+	if params.Rows > 0 && params.Cols > 0 {
+		if err := t.resize(params.Rows, params.Cols); err != nil {
+			return nil, nil, nil, fmt.Errorf("resizing terminal: %w", err)
+		}
+	}
+	out, detach, err := t.attach()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s has exited", name)
+	}
+	d.log.Printf("ATTACH %s", name)
+	return t, out, detach, nil
+}
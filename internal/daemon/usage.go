@@ -0,0 +1,213 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+// UsageGroupings are the accepted values of UsageParams.By.
+var UsageGroupings = []string{"process", "owner", "gpu", "model"}
+
+// usageLedger is an append-only JSON-lines log of the intervals processes
+// spent active on a GPU. Frozen time is never recorded, so GPU-hours only
+// count time a process actually held a device.
+type usageLedger struct {
+	mu      sync.Mutex
+	path    string
+	records []protocol.UsageRecord
+}
+
+func openUsage(path string) *usageLedger {
+	u := &usageLedger{path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		return u
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r protocol.UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		u.records = append(u.records, r)
+	}
+	return u
+}
+
+func (u *usageLedger) add(r protocol.UsageRecord) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.records = append(u.records, r)
+
+	if u.path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(u.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func (u *usageLedger) snapshot() []protocol.UsageRecord {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]protocol.UsageRecord(nil), u.records...)
+}
+
+// startActive opens a usage interval for p on its current GPU. Caller must
+// hold d.mu.
+func (d *Daemon) startActive(p *Proc, now time.Time) {
+	p.activeSince = now
+}
+
+// endActive closes p's open usage interval, if any. Caller must hold d.mu.
+func (d *Daemon) endActive(p *Proc, now time.Time) {
+	if p.activeSince.IsZero() {
+		return
+	}
+	r := d.usageRecord(p, now)
+	p.activeTotal += now.Sub(p.activeSince)
+	p.activeSince = time.Time{}
+	if err := d.usage.add(r); err != nil {
+		d.log.Printf("WARN: writing usage: %v", err)
+	}
+}
+
+func (d *Daemon) usageRecord(p *Proc, now time.Time) protocol.UsageRecord {
+	return protocol.UsageRecord{
+		Process: p.Name,
+		GPU:     p.GPU,
+		Model:   d.gpuModel(p.GPU),
+		Start:   p.activeSince,
+		End:     now,
+	}
+}
+
+// gpuModel returns the device name of GPU idx from the GPU inventory.
+// Caller must hold d.mu.
+func (d *Daemon) gpuModel(idx int) string {
+	if d.gpuModels == nil {
+		d.gpuModels = make(map[int]string)
+		gpus, _, _ := d.cachedGPUs()
+		for _, g := range gpus {
+			d.gpuModels[g.Index] = g.Name
+		}
+	}
+	return d.gpuModels[idx]
+}
+
+// gpuRate returns the $/GPU-hour for model: the rate whose key is the
+// longest case-insensitive substring of the model name, so "H100" matches
+// "NVIDIA H100 80GB HBM3" and "A100" wins over "A10" for an A100.
+func (d *Daemon) gpuRate(model string) (float64, bool) {
+	best, rate := -1, 0.0
+	lower := strings.ToLower(model)
+	for key, r := range d.cfg.GPURates {
+		if strings.Contains(lower, strings.ToLower(key)) && len(key) > best {
+			best, rate = len(key), r
+		}
+	}
+	return rate, best >= 0
+}
+
+// Usage totals active GPU-hours since params.Since, including intervals
+// still open, grouped by process, owner (namespace), gpu, or model.
+func (d *Daemon) Usage(params protocol.UsageParams) (protocol.UsageResult, error) {
+	by := params.By
+	if by == "" {
+		by = "process"
+	}
+	key, ok := usageKey(by)
+	if !ok {
+		return protocol.UsageResult{}, fmt.Errorf("unknown grouping %q (want %s)", by, strings.Join(UsageGroupings, ", "))
+	}
+
+	now := time.Now()
+	records := d.usage.snapshot()
+	d.mu.Lock()
+	for _, p := range d.procs {
+		if !p.activeSince.IsZero() {
+			records = append(records, d.usageRecord(p, now))
+		}
+	}
+	d.mu.Unlock()
+
+	rows := make(map[string]*protocol.UsageRow)
+	res := protocol.UsageResult{Since: params.Since, By: by}
+	for _, r := range records {
+		start, end := r.Start, r.End
+		if start.Before(params.Since) {
+			start = params.Since
+		}
+		if !end.After(start) {
+			continue
+		}
+		hours := end.Sub(start).Hours()
+		k := key(r)
+		row, ok := rows[k]
+		if !ok {
+			row = &protocol.UsageRow{Key: k}
+			rows[k] = row
+		}
+		row.GPUHours += hours
+		res.GPUHours += hours
+		if rate, ok := d.gpuRate(r.Model); ok {
+			row.Cost += hours * rate
+			res.Cost += hours * rate
+		}
+	}
+
+	for _, row := range rows {
+		res.Rows = append(res.Rows, *row)
+	}
+	sort.Slice(res.Rows, func(i, j int) bool {
+		if res.Rows[i].GPUHours != res.Rows[j].GPUHours {
+			return res.Rows[i].GPUHours > res.Rows[j].GPUHours
+		}
+		return res.Rows[i].Key < res.Rows[j].Key
+	})
+	return res, nil
+}
+
+func usageKey(by string) (func(protocol.UsageRecord) string, bool) {
+	switch by {
+	case "process":
+		return func(r protocol.UsageRecord) string { return r.Process }, true
+	case "owner":
+		return func(r protocol.UsageRecord) string {
+			ns, _ := protocol.SplitQualifiedName(r.Process)
+			if ns == "" {
+				return "(none)"
+			}
+			return ns
+		}, true
+	case "gpu":
+		return func(r protocol.UsageRecord) string { return strconv.Itoa(r.GPU) }, true
+	case "model":
+		return func(r protocol.UsageRecord) string {
+			if r.Model == "" {
+				return "unknown"
+			}
+			return r.Model
+		}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,148 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"gpusched/internal/protocol"
+
+	"golang.org/x/sys/unix"
+)
+
+// pathWakeMask is the inotify events that count as a change to a wake
+// path: a write finishing, or an entry created, deleted, or moved.
+const pathWakeMask = unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE |
+	unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// wakeWatch is an inotify instance watching a process's wake triggers.
+type wakeWatch struct {
+	f *os.File
+	// paths maps watch descriptors to the paths they watch.
+	paths map[int32]string
+}
+
+// openWakeWatch watches the paths and FIFOs of w, relative to dir,
+// creating any FIFO that doesn't exist. It returns nil if w is nil or
+// lists nothing.
+func openWakeWatch(w *protocol.WakeTriggers, dir string) (*wakeWatch, error) {
+	if w == nil || len(w.Paths)+len(w.FIFOs) == 0 {
+		return nil, nil
+	}
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("watching wake triggers: %w", err)
+	}
+	ww := &wakeWatch{f: os.NewFile(uintptr(fd), "inotify"), paths: make(map[int32]string)}
+	add := func(path string, mask uint32) error {
+		wd, err := unix.InotifyAddWatch(fd, path, mask)
+		if err != nil {
+			return fmt.Errorf("watching %s: %w", path, err)
+		}
+		ww.paths[int32(wd)] = path
+		return nil
+	}
+	for _, path := range w.Paths {
+		if err := add(resolve(dir, path), pathWakeMask); err != nil {
+			ww.f.Close()
+			return nil, err
+		}
+	}
+	for _, path := range w.FIFOs {
+		path = resolve(dir, path)
+		if err := makeFIFO(path); err != nil {
+			ww.f.Close()
+			return nil, err
+		}
+		// A FIFO's data stays in the pipe for the process; the write
+		// itself is the event.
+		if err := add(path, unix.IN_MODIFY); err != nil {
+			ww.f.Close()
+			return nil, err
+		}
+	}
+	return ww, nil
+}
+
+// makeFIFO creates a named pipe at path unless one is already there.
+func makeFIFO(path string) error {
+	fi, err := os.Stat(path)
+	if err == nil {
+		if fi.Mode()&os.ModeNamedPipe == 0 {
+			return fmt.Errorf("wake fifo %s exists and is not a named pipe", path)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	if err := unix.Mkfifo(path, 0o660); err != nil {
+		return fmt.Errorf("creating wake fifo %s: %w", path, err)
+	}
+	return nil
+}
+
+// next blocks until triggers fire and returns the first path involved,
+// or an error once the watch is closed.
+func (ww *wakeWatch) next(buf []byte) (string, error) {
+	for {
+		n, err := ww.f.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		if n < unix.SizeofInotifyEvent {
+			continue
+		}
+		ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[0]))
+		path, ok := ww.paths[ev.Wd]
+		if !ok || ev.Mask&unix.IN_IGNORED != 0 {
+			continue
+		}
+		if ev.Len > 0 {
+			name := buf[unix.SizeofInotifyEvent : unix.SizeofInotifyEvent+int(ev.Len)]
+			path = filepath.Join(path, strings.TrimRight(string(name), "\x00"))
+		}
+		return path, nil
+	}
+}
+
+// wakeLoop thaws p whenever its wake triggers fire while it is frozen. It
+// closes the watch and returns once p has exited or the daemon shuts down.
+func (d *Daemon) wakeLoop(p *Proc, ww *wakeWatch) {
+	go func() {
+		select {
+		case <-p.exited:
+		case <-d.done:
+		}
+		ww.f.Close()
+	}()
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		path, err := ww.next(buf)
+		if err != nil {
+			return
+		}
+
+		d.mu.RLock()
+		current := d.procs[p.Name] == p
+		frozen := p.State == protocol.StateFrozen
+		d.mu.RUnlock()
+		if !current {
+			return
+		}
+		if !frozen {
+			continue
+		}
+
+		detail := "activity on " + path
+		if _, err := d.thawReady(p.Name, protocol.CauseWake, detail); err != nil {
+			d.mu.Lock()
+			d.emit(protocol.Event{Type: "wake-failed", Process: p.Name, Detail: fmt.Sprintf("%s: %v", detail, err)})
+			d.mu.Unlock()
+			d.log.Printf("WAKE %s: thaw failed: %v", p.Name, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package discovery advertises daemons on the local network with UDP
+// broadcast beacons and collects them for "gpusched nodes discover".
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+const (
+	// DefaultPort is where beacons are broadcast and listened for.
+	DefaultPort = 9465
+
+	DefaultInterval = 10 * time.Second
+
+	// magic marks gpusched beacons so other traffic on the port is ignored.
+	magic = "gpusched-beacon/1"
+
+	maxBeaconSize = 64 * 1024
+)
+
+// DefaultAddr is the limited broadcast address on DefaultPort.
+var DefaultAddr = fmt.Sprintf("255.255.255.255:%d", DefaultPort)
+
+type packet struct {
+	Magic  string          `json:"magic"`
+	Beacon protocol.Beacon `json:"beacon"`
+}
+
+// Send writes one beacon to addr.
+func Send(addr string, b protocol.Beacon) error {
+	data, err := json.Marshal(packet{Magic: magic, Beacon: b})
+	if err != nil {
+		return err
+	}
+	if len(data) > maxBeaconSize {
+		return fmt.Errorf("beacon too large: %d bytes", len(data))
+	}
+	conn, err := net.Dial("udp4", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(data)
+	return err
+}
+
+// Listen collects beacons arriving on addr for the given time and returns
+// the latest one from each daemon, ordered by host. From is filled in with
+// the sender's IP.
+func Listen(addr string, timeout time.Duration) ([]protocol.Beacon, error) {
+	conn, err := net.ListenPacket("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	seen := make(map[string]protocol.Beacon)
+	buf := make([]byte, maxBeaconSize)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				break
+			}
+			return nil, err
+		}
+		var p packet
+		if err := json.Unmarshal(buf[:n], &p); err != nil || p.Magic != magic {
+			continue
+		}
+		b := p.Beacon
+		if udp, ok := from.(*net.UDPAddr); ok {
+			b.From = udp.IP.String()
+		}
+		seen[b.From+" "+b.Host+" "+b.Socket] = b
+	}
+
+	beacons := make([]protocol.Beacon, 0, len(seen))
+	for _, b := range seen {
+		beacons = append(beacons, b)
+	}
+	sort.Slice(beacons, func(i, j int) bool {
+		if beacons[i].Host != beacons[j].Host {
+			return beacons[i].Host < beacons[j].Host
+		}
+		return beacons[i].Socket < beacons[j].Socket
+	})
+	return beacons, nil
+}
@@ -0,0 +1,53 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+func TestSendListen(t *testing.T) {
+	// Find a free port, then listen on it in the background.
+	probe, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := probe.LocalAddr().String()
+	probe.Close()
+
+	done := make(chan []protocol.Beacon)
+	go func() {
+		beacons, err := Listen(addr, 300*time.Millisecond)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- beacons
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	b := protocol.Beacon{
+		Host:   "gpu-box",
+		Socket: "/tmp/gpusched.sock",
+		GPUs:   []protocol.GPUInfo{{Index: 0, Name: "H100", MemTotal: 81559}},
+	}
+	for i := 0; i < 3; i++ {
+		if err := Send(addr, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Not a beacon: ignored.
+	if conn, err := net.Dial("udp4", addr); err == nil {
+		conn.Write([]byte(`{"hello":1}`))
+		conn.Close()
+	}
+
+	beacons := <-done
+	if len(beacons) != 1 {
+		t.Fatalf("expected one daemon, got %+v", beacons)
+	}
+	if got := beacons[0]; got.Host != "gpu-box" || got.From != "127.0.0.1" || len(got.GPUs) != 1 {
+		t.Fatalf("unexpected beacon %+v", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"bufio"
 	"fmt"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -13,7 +14,7 @@ import (
 
 func QueryGPUs() ([]protocol.GPUInfo, error) {
 	cmd := exec.Command("nvidia-smi",
-		"--query-gpu=index,name,memory.total,memory.used,memory.free",
+		"--query-gpu=index,name,memory.total,memory.used,memory.free,utilization.gpu,uuid",
 		"--format=csv,noheader,nounits",
 	)
 	out, err := cmd.Output()
@@ -36,6 +37,14 @@ func QueryGPUs() ([]protocol.GPUInfo, error) {
 		total, _ := strconv.ParseInt(strings.TrimSpace(parts[2]), 10, 64)
 		used, _ := strconv.ParseInt(strings.TrimSpace(parts[3]), 10, 64)
 		free, _ := strconv.ParseInt(strings.TrimSpace(parts[4]), 10, 64)
+		var util int
+		if len(parts) > 5 {
+			util, _ = strconv.Atoi(strings.TrimSpace(parts[5]))
+		}
+		var uuid string
+		if len(parts) > 6 {
+			uuid = strings.TrimSpace(parts[6])
+		}
 
 		gpus = append(gpus, protocol.GPUInfo{
 			Index:    idx,
@@ -43,30 +52,328 @@ func QueryGPUs() ([]protocol.GPUInfo, error) {
 			MemTotal: total,
 			MemUsed:  used,
 			MemFree:  free,
+			UtilPct:  util,
+			UUID:     uuid,
 		})
 	}
+
+	if clocks, err := queryClocks(); err == nil {
+		for i := range gpus {
+			if c, ok := clocks[gpus[i].Index]; ok {
+				gpus[i].SMClockMHz = c.SMClockMHz
+				gpus[i].MemClockMHz = c.MemClockMHz
+				gpus[i].ThrottleReasons = c.ThrottleReasons
+			}
+		}
+	}
 	return gpus, nil
 }
 
+// throttleBits maps nvmlClocksThrottleReason bits to short names.
+var throttleBits = []struct {
+	bit  uint64
+	name string
+}{
+	{0x1, "idle"},
+	{0x2, "app_clocks"},
+	{0x4, "sw_power_cap"},
+	{0x8, "hw_slowdown"},
+	{0x10, "sync_boost"},
+	{0x20, "sw_thermal"},
+	{0x40, "hw_thermal"},
+	{0x80, "hw_power_brake"},
+	{0x100, "display_clocks"},
+}
+
+// ThrottleReasons decodes an nvidia-smi clocks_throttle_reasons.active
+// bitmask such as "0x0000000000000044".
+func ThrottleReasons(mask string) []string {
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(mask), "0x"), 16, 64)
+	if err != nil {
+		return nil
+	}
+	var reasons []string
+	for _, tb := range throttleBits {
+		if v&tb.bit != 0 {
+			reasons = append(reasons, tb.name)
+		}
+	}
+	return reasons
+}
+
+type clockInfo struct {
+	SMClockMHz      int
+	MemClockMHz     int
+	ThrottleReasons []string
+}
+
+// queryClocks is separate from QueryGPUs because older drivers reject the
+// throttle field, which would otherwise fail the whole query.
+func queryClocks() (map[int]clockInfo, error) {
+	cmd := exec.Command("nvidia-smi",
+		"--query-gpu=index,clocks.sm,clocks.mem,clocks_throttle_reasons.active",
+		"--format=csv,noheader,nounits",
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi: %w", err)
+	}
+
+	clocks := make(map[int]clockInfo)
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	for scanner.Scan() {
+		parts := strings.Split(strings.TrimSpace(scanner.Text()), ", ")
+		if len(parts) < 4 {
+			continue
+		}
+		idx, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			continue
+		}
+		sm, _ := strconv.Atoi(strings.TrimSpace(parts[1]))
+		mem, _ := strconv.Atoi(strings.TrimSpace(parts[2]))
+		clocks[idx] = clockInfo{
+			SMClockMHz:      sm,
+			MemClockMHz:     mem,
+			ThrottleReasons: ThrottleReasons(parts[3]),
+		}
+	}
+	return clocks, nil
+}
+
+// MinCheckpointDriver is the oldest driver branch gpusched supports
+// cuda-checkpoint on.
+const MinCheckpointDriver = 580
+
+// QueryCapabilities reports what each GPU supports, keyed by index.
+// checkpoint says whether a usable cuda-checkpoint binary was found; it
+// applies to every device, but the driver branch still decides per GPU.
+func QueryCapabilities(checkpoint bool) (map[int]protocol.GPUCapabilities, error) {
+	cmd := exec.Command("nvidia-smi",
+		"--query-gpu=index,driver_version,compute_cap,mig.mode.current,display_active",
+		"--format=csv,noheader",
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi: %w", err)
+	}
+	return parseCapabilities(string(out), checkpoint), nil
+}
+
+// parseCapabilities reads QueryCapabilities' nvidia-smi output:
+//
+//	0, 580.126.09, 9.0, Disabled, Disabled
+//
+// Fields the GPU or driver doesn't report come back as "[N/A]".
+func parseCapabilities(out string, checkpoint bool) map[int]protocol.GPUCapabilities {
+	caps := make(map[int]protocol.GPUCapabilities)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		parts := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if len(parts) < 5 {
+			continue
+		}
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		idx, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+
+		c := protocol.GPUCapabilities{
+			DriverVersion: parts[1],
+			ComputeCap:    notAvailable(parts[2]),
+			MIGSupported:  notAvailable(parts[3]) != "",
+			MIG:           parts[3] == "Enabled",
+			Checkpoint:    checkpoint,
+			Reset:         true,
+		}
+		if !checkpoint {
+			c.Notes = append(c.Notes, "cuda-checkpoint not available")
+		} else if major := driverMajor(parts[1]); major < MinCheckpointDriver {
+			c.Checkpoint = false
+			c.Notes = append(c.Notes, fmt.Sprintf("driver %s is older than %d", parts[1], MinCheckpointDriver))
+		}
+		if parts[4] == "Enabled" {
+			c.Reset = false
+			c.Notes = append(c.Notes, "display attached: no reset")
+		}
+		caps[idx] = c
+	}
+	return caps
+}
+
+func notAvailable(v string) string {
+	if v == "[N/A]" || v == "N/A" {
+		return ""
+	}
+	return v
+}
+
+// driverMajor returns the branch of a driver version such as "580.126.09",
+// or 0 if it can't be parsed.
+func driverMajor(version string) int {
+	major, _, _ := strings.Cut(version, ".")
+	n, _ := strconv.Atoi(major)
+	return n
+}
+
+// FreeMB returns the free memory of GPU index in MB.
+func FreeMB(index int) (int64, error) {
+	out, err := exec.Command("nvidia-smi", "--id="+strconv.Itoa(index),
+		"--query-gpu=memory.free", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return 0, fmt.Errorf("nvidia-smi: %w", err)
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+}
+
 func ProcessGPUMem(pid int) int64 {
+	apps, err := ComputeApps()
+	if err != nil {
+		return 0
+	}
+	return apps[pid]
+}
+
+// ComputeApps returns GPU memory in MB for every CUDA compute process,
+// keyed by PID.
+func ComputeApps() (map[int]int64, error) {
 	cmd := exec.Command("nvidia-smi",
 		"--query-compute-apps=pid,used_memory",
 		"--format=csv,noheader,nounits",
 	)
 	out, err := cmd.Output()
 	if err != nil {
-		return 0
+		return nil, fmt.Errorf("nvidia-smi: %w", err)
 	}
-	pidStr := strconv.Itoa(pid)
+	apps := make(map[int]int64)
 	scanner := bufio.NewScanner(strings.NewReader(string(out)))
 	for scanner.Scan() {
 		parts := strings.Split(scanner.Text(), ", ")
-		if len(parts) >= 2 && strings.TrimSpace(parts[0]) == pidStr {
-			mem, _ := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
-			return mem
+		if len(parts) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			continue
+		}
+		mem, _ := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+		apps[pid] += mem
+	}
+	return apps, nil
+}
+
+// ComputeAppGPUs returns the GPU index of every CUDA compute process,
+// keyed by PID. A process using several GPUs maps to the lowest index.
+func ComputeAppGPUs() (map[int]int, error) {
+	apps, err := exec.Command("nvidia-smi", "--query-compute-apps=pid,gpu_uuid", "--format=csv,noheader").Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi: %w", err)
+	}
+	gpus, err := exec.Command("nvidia-smi", "--query-gpu=index,uuid", "--format=csv,noheader").Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi: %w", err)
+	}
+	return parseAppGPUs(string(apps), string(gpus)), nil
+}
+
+// parseAppGPUs joins "pid, gpu_uuid" rows against "index, uuid" rows.
+func parseAppGPUs(apps, gpus string) map[int]int {
+	index := make(map[string]int)
+	for _, line := range strings.Split(gpus, "\n") {
+		i, uuid, ok := strings.Cut(line, ", ")
+		if n, err := strconv.Atoi(strings.TrimSpace(i)); ok && err == nil {
+			index[strings.TrimSpace(uuid)] = n
+		}
+	}
+	out := make(map[int]int)
+	for _, line := range strings.Split(apps, "\n") {
+		p, uuid, ok := strings.Cut(line, ", ")
+		pid, err := strconv.Atoi(strings.TrimSpace(p))
+		if !ok || err != nil {
+			continue
+		}
+		n, known := index[strings.TrimSpace(uuid)]
+		if !known {
+			continue
+		}
+		if cur, seen := out[pid]; !seen || n < cur {
+			out[pid] = n
+		}
+	}
+	return out
+}
+
+// MPSServerName is the process name of an NVIDIA MPS server, which runs
+// on each GPU its clients use.
+const MPSServerName = "nvidia-cuda-mps-server"
+
+// MPSServers returns the PID of the MPS server on every GPU that has one,
+// keyed by GPU index.
+func MPSServers() (map[int]int, error) {
+	apps, err := exec.Command("nvidia-smi", "--query-compute-apps=pid,process_name,gpu_uuid", "--format=csv,noheader").Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi: %w", err)
+	}
+	gpus, err := exec.Command("nvidia-smi", "--query-gpu=index,uuid", "--format=csv,noheader").Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi: %w", err)
+	}
+	return parseMPSServers(string(apps), string(gpus)), nil
+}
+
+// parseMPSServers picks the MPS servers out of "pid, process_name,
+// gpu_uuid" rows and maps them to GPU indices through "index, uuid" rows.
+func parseMPSServers(apps, gpus string) map[int]int {
+	var servers strings.Builder
+	for _, line := range strings.Split(apps, "\n") {
+		parts := strings.Split(line, ", ")
+		if len(parts) < 3 || filepath.Base(strings.TrimSpace(parts[1])) != MPSServerName {
+			continue
+		}
+		servers.WriteString(parts[0] + ", " + parts[2] + "\n")
+	}
+	out := make(map[int]int)
+	for pid, idx := range parseAppGPUs(servers.String(), gpus) {
+		out[idx] = pid
+	}
+	return out
+}
+
+// ProcessUtilization returns SM utilization in percent for every process
+// nvidia-smi pmon sampled, keyed by PID.
+func ProcessUtilization() (map[int]int, error) {
+	out, err := exec.Command("nvidia-smi", "pmon", "-c", "1", "-s", "u").Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi pmon: %w", err)
+	}
+	return parsePmon(string(out)), nil
+}
+
+// parsePmon reads "nvidia-smi pmon -s u" output:
+//
+//	# gpu        pid  type    sm   mem   enc   dec   command
+//	    0      12345     C    45    10     -     -   python
+//
+// A "-" in the sm column means no sample and counts as zero.
+func parsePmon(out string) map[int]int {
+	util := make(map[int]int)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
 		}
+		sm, _ := strconv.Atoi(fields[3])
+		util[pid] += sm
 	}
-	return 0
+	return util
 }
 
 func DriverVersion() string {
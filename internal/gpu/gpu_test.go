@@ -23,3 +23,77 @@ func TestProcessGPUMemNonexistent(t *testing.T) {
 		t.Fatalf("expected 0 for nonexistent PID, got %d", mem)
 	}
 }
+
+func TestThrottleReasons(t *testing.T) {
+	got := ThrottleReasons("0x0000000000000044")
+	if len(got) != 2 || got[0] != "sw_power_cap" || got[1] != "hw_thermal" {
+		t.Fatalf("unexpected reasons: %v", got)
+	}
+	if got := ThrottleReasons("0x0000000000000000"); len(got) != 0 {
+		t.Fatalf("expected no reasons, got %v", got)
+	}
+	if got := ThrottleReasons("[N/A]"); got != nil {
+		t.Fatalf("expected nil for N/A, got %v", got)
+	}
+}
+
+func TestParsePmon(t *testing.T) {
+	out := `# gpu         pid   type     sm    mem    enc    dec    command
+# Idx           #    C/G      %      %      %      %    name
+    0       4242     C     37     12      -      -    python
+    0       4343     C      -      -      -      -    jupyter
+    1          -     -      -      -      -      -    -
+`
+	util := parsePmon(out)
+	if len(util) != 2 || util[4242] != 37 || util[4343] != 0 {
+		t.Fatalf("unexpected utilization: %v", util)
+	}
+}
+
+func TestParseCapabilities(t *testing.T) {
+	out := `0, 580.126.09, 9.0, Disabled, Disabled
+1, 580.126.09, 8.0, Enabled, Enabled
+2, 580.126.09, [N/A], [N/A], Disabled
+`
+	caps := parseCapabilities(out, true)
+	if len(caps) != 3 {
+		t.Fatalf("expected 3 GPUs, got %v", caps)
+	}
+	if c := caps[0]; !c.Checkpoint || !c.Reset || !c.MIGSupported || c.MIG || c.ComputeCap != "9.0" {
+		t.Fatalf("GPU 0: %+v", c)
+	}
+	if c := caps[1]; !c.MIG || c.Reset || len(c.Notes) != 1 {
+		t.Fatalf("GPU 1: %+v", c)
+	}
+	if c := caps[2]; c.MIGSupported || c.ComputeCap != "" {
+		t.Fatalf("GPU 2: %+v", c)
+	}
+
+	old := parseCapabilities("0, 550.54.15, 9.0, Disabled, Disabled\n", true)
+	if c := old[0]; c.Checkpoint || len(c.Notes) != 1 {
+		t.Fatalf("old driver: %+v", c)
+	}
+	if c := parseCapabilities(out, false)[0]; c.Checkpoint {
+		t.Fatalf("checkpoint without cuda-checkpoint: %+v", c)
+	}
+}
+
+func TestParseAppGPUs(t *testing.T) {
+	gpus := "0, GPU-aaa\n1, GPU-bbb\n"
+	apps := "4242, GPU-bbb\n5151, GPU-bbb\n5151, GPU-aaa\n6161, GPU-zzz\n"
+	got := parseAppGPUs(apps, gpus)
+	if len(got) != 2 || got[4242] != 1 || got[5151] != 0 {
+		t.Fatalf("unexpected mapping: %v", got)
+	}
+}
+
+func TestParseMPSServers(t *testing.T) {
+	apps := "4100, nvidia-cuda-mps-server, GPU-aaa\n" +
+		"4200, /usr/bin/python3, GPU-aaa\n" +
+		"4300, /usr/bin/nvidia-cuda-mps-server, GPU-bbb\n"
+	gpus := "0, GPU-aaa\n1, GPU-bbb\n2, GPU-ccc\n"
+	got := parseMPSServers(apps, gpus)
+	if len(got) != 2 || got[0] != 4100 || got[1] != 4300 {
+		t.Fatalf("got %v", got)
+	}
+}
@@ -0,0 +1,150 @@
+// Package inference knows how to health-check, drain, and measure load on
+// common inference servers (vLLM, TGI) from their HTTP endpoints.
+package inference
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	VLLM = "vllm"
+	TGI  = "tgi"
+)
+
+// Profile describes a server's health endpoint and the Prometheus series
+// that count in-flight and completed requests.
+type Profile struct {
+	DefaultURL string
+	Health     string
+	// InFlight gauges are summed for requests running or queued; Served
+	// counters are summed for requests completed since start.
+	InFlight []string
+	Served   []string
+}
+
+var profiles = map[string]Profile{
+	VLLM: {
+		DefaultURL: "http://127.0.0.1:8000",
+		Health:     "/health",
+		InFlight:   []string{"vllm:num_requests_running", "vllm:num_requests_waiting"},
+		Served:     []string{"vllm:request_success_total"},
+	},
+	TGI: {
+		DefaultURL: "http://127.0.0.1:3000",
+		Health:     "/health",
+		InFlight:   []string{"tgi_queue_size", "tgi_batch_current_size"},
+		Served:     []string{"tgi_request_count"},
+	},
+}
+
+// Kinds lists the supported server kinds.
+func Kinds() []string {
+	var kinds []string
+	for k := range profiles {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Lookup returns the profile for kind.
+func Lookup(kind string) (Profile, bool) {
+	p, ok := profiles[kind]
+	return p, ok
+}
+
+// Load is a snapshot of a server's request counters.
+type Load struct {
+	InFlight float64
+	Served   float64
+}
+
+const scrapeTimeout = 5 * time.Second
+
+// Scrape reads baseURL/metrics and returns the current load.
+func Scrape(ctx context.Context, kind, baseURL string) (Load, error) {
+	prof, ok := profiles[kind]
+	if !ok {
+		return Load{}, fmt.Errorf("unknown inference server %q", kind)
+	}
+	ctx, cancel := context.WithTimeout(ctx, scrapeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/metrics", nil)
+	if err != nil {
+		return Load{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Load{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Load{}, fmt.Errorf("GET %s: %s", req.URL, resp.Status)
+	}
+	return parseLoad(resp.Body, prof)
+}
+
+// parseLoad sums the profile's series from Prometheus text exposition.
+func parseLoad(r io.Reader, prof Profile) (Load, error) {
+	sums := make(map[string]float64)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		name, value, ok := parseSample(line)
+		if !ok {
+			continue
+		}
+		sums[name] += value
+	}
+	if err := scanner.Err(); err != nil {
+		return Load{}, err
+	}
+
+	var load Load
+	for _, m := range prof.InFlight {
+		load.InFlight += sums[m]
+	}
+	for _, m := range prof.Served {
+		load.Served += sums[m]
+	}
+	return load, nil
+}
+
+// parseSample splits `name{labels} value [timestamp]` into name and value.
+func parseSample(line string) (string, float64, bool) {
+	end := strings.IndexAny(line, "{ ")
+	if end < 0 {
+		return "", 0, false
+	}
+	name := line[:end]
+	rest := line[end:]
+	if rest[0] == '{' {
+		close := strings.LastIndexByte(rest, '}')
+		if close < 0 {
+			return "", 0, false
+		}
+		rest = rest[close+1:]
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", 0, false
+	}
+	v, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return name, v, true
+}
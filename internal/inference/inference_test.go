@@ -0,0 +1,71 @@
+package inference
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const vllmMetrics = `# HELP vllm:num_requests_running Number of requests currently running.
+# TYPE vllm:num_requests_running gauge
+vllm:num_requests_running{model_name="llama"} 3.0
+vllm:num_requests_waiting{model_name="llama"} 2.0
+vllm:request_success_total{finished_reason="stop",model_name="llama"} 40.0
+vllm:request_success_total{finished_reason="length",model_name="llama"} 2.0
+vllm:gpu_cache_usage_perc{model_name="llama"} 0.5
+`
+
+func TestScrape(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, vllmMetrics)
+	}))
+	defer srv.Close()
+
+	load, err := Scrape(context.Background(), VLLM, srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if load.InFlight != 5 || load.Served != 42 {
+		t.Fatalf("got %+v, want 5 in flight and 42 served", load)
+	}
+
+	// A TGI profile finds none of the vLLM series.
+	load, err = Scrape(context.Background(), TGI, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if load != (Load{}) {
+		t.Fatalf("expected empty TGI load, got %+v", load)
+	}
+
+	if _, err := Scrape(context.Background(), "triton", srv.URL); err == nil {
+		t.Fatal("expected error for unknown server kind")
+	}
+}
+
+func TestParseSample(t *testing.T) {
+	cases := []struct {
+		line  string
+		name  string
+		value float64
+		ok    bool
+	}{
+		{"tgi_queue_size 4", "tgi_queue_size", 4, true},
+		{`tgi_request_count{method="POST"} 12 1700000000000`, "tgi_request_count", 12, true},
+		{`x{path="a b}"} 1`, "x", 1, true},
+		{"garbage", "", 0, false},
+		{"x NaNx", "", 0, false},
+	}
+	for _, c := range cases {
+		name, v, ok := parseSample(c.line)
+		if name != c.name || v != c.value || ok != c.ok {
+			t.Errorf("parseSample(%q) = %q, %v, %v", c.line, name, v, ok)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Package placement chooses a GPU for a process. Strategies score every
+// eligible GPU and the highest score wins; the scores are kept so the
+// decision can be explained.
+package placement
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+// Strategy names.
+const (
+	Binpack     = "binpack"
+	Spread      = "spread"
+	Utilization = "utilization"
+	Exec        = "exec"
+)
+
+// Names lists the built-in strategies.
+var Names = []string{Binpack, Spread, Utilization, Exec}
+
+// Request describes the process being placed. NeedMB is its expected GPU
+// memory (zero if unknown); Exclude is a GPU it must not go to, or -1.
+type Request struct {
+	Process string `json:"process"`
+	NeedMB  int64  `json:"need_mb"`
+	Exclude int    `json:"exclude"`
+}
+
+// Strategy scores candidate GPUs; higher is better. Scores may omit GPUs
+// the strategy rejects.
+type Strategy interface {
+	Name() string
+	Score(req Request, gpus []protocol.GPUInfo) (map[int]float64, error)
+}
+
+// New returns the named strategy. command is the plugin argv for Exec.
+func New(name string, command []string) (Strategy, error) {
+	switch name {
+	case "", Spread:
+		return spread{}, nil
+	case Binpack:
+		return binpack{}, nil
+	case Utilization:
+		return utilization{}, nil
+	case Exec:
+		if len(command) == 0 {
+			return nil, fmt.Errorf("exec placement needs a plugin command")
+		}
+		return execPlugin{argv: command}, nil
+	}
+	return nil, fmt.Errorf("unknown placement strategy %q", name)
+}
+
+// Choose filters out GPUs that are excluded or lack NeedMB free, scores
+// the rest with s, and returns the winner (ties go to the lower index).
+func Choose(s Strategy, req Request, gpus []protocol.GPUInfo) (protocol.Placement, error) {
+	var eligible []protocol.GPUInfo
+	for _, g := range gpus {
+		if g.Index != req.Exclude && g.MemFree >= req.NeedMB {
+			eligible = append(eligible, g)
+		}
+	}
+	if len(eligible) == 0 {
+		return protocol.Placement{}, fmt.Errorf("no GPU has %d MB free", req.NeedMB)
+	}
+	scores, err := s.Score(req, eligible)
+	if err != nil {
+		return protocol.Placement{}, fmt.Errorf("%s placement: %w", s.Name(), err)
+	}
+
+	best, found := 0, false
+	for _, g := range eligible {
+		sc, ok := scores[g.Index]
+		if !ok {
+			continue
+		}
+		if !found || sc > scores[best] || (sc == scores[best] && g.Index < best) {
+			best, found = g.Index, true
+		}
+	}
+	if !found {
+		return protocol.Placement{}, fmt.Errorf("%s placement rejected every GPU", s.Name())
+	}
+	return protocol.Placement{Strategy: s.Name(), GPU: best, Scores: scores}, nil
+}
+
+// spread prefers the GPU with the most free memory.
+type spread struct{}
+
+func (spread) Name() string { return Spread }
+
+func (spread) Score(_ Request, gpus []protocol.GPUInfo) (map[int]float64, error) {
+	scores := make(map[int]float64, len(gpus))
+	for _, g := range gpus {
+		scores[g.Index] = float64(g.MemFree)
+	}
+	return scores, nil
+}
+
+// binpack prefers the fullest GPU that still fits, keeping whole GPUs free
+// for large jobs.
+type binpack struct{}
+
+func (binpack) Name() string { return Binpack }
+
+func (binpack) Score(_ Request, gpus []protocol.GPUInfo) (map[int]float64, error) {
+	scores := make(map[int]float64, len(gpus))
+	for _, g := range gpus {
+		scores[g.Index] = -float64(g.MemFree)
+	}
+	return scores, nil
+}
+
+// utilization weighs free memory by idle compute: a half-empty GPU at 90%
+// utilization loses to a fuller one that is idle.
+type utilization struct{}
+
+func (utilization) Name() string { return Utilization }
+
+func (utilization) Score(_ Request, gpus []protocol.GPUInfo) (map[int]float64, error) {
+	scores := make(map[int]float64, len(gpus))
+	for _, g := range gpus {
+		if g.MemTotal <= 0 {
+			continue
+		}
+		free := float64(g.MemFree) / float64(g.MemTotal)
+		idle := float64(100-g.UtilPct) / 100
+		scores[g.Index] = free * idle
+	}
+	return scores, nil
+}
+
+const pluginTimeout = 5 * time.Second
+
+// execPlugin runs an external command that reads {"request":…,"gpus":[…]}
+// on stdin and writes {"scores":{"<gpu>":score,…}} on stdout.
+type execPlugin struct {
+	argv []string
+}
+
+func (execPlugin) Name() string { return Exec }
+
+func (e execPlugin) Score(req Request, gpus []protocol.GPUInfo) (map[int]float64, error) {
+	input, err := json.Marshal(struct {
+		Request Request            `json:"request"`
+		GPUs    []protocol.GPUInfo `json:"gpus"`
+	}{req, gpus})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, e.argv[0], e.argv[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", e.argv[0], err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	var resp struct {
+		Scores map[string]float64 `json:"scores"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, fmt.Errorf("%s: bad output: %w", e.argv[0], err)
+	}
+	scores := make(map[int]float64, len(resp.Scores))
+	for k, v := range resp.Scores {
+		idx, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("%s: bad GPU index %q", e.argv[0], k)
+		}
+		scores[idx] = v
+	}
+	return scores, nil
+}
+
+// Explain formats a placement's scores for event details, best first.
+func Explain(p protocol.Placement) string {
+	idx := make([]int, 0, len(p.Scores))
+	for i := range p.Scores {
+		idx = append(idx, i)
+	}
+	sort.Slice(idx, func(a, b int) bool {
+		if p.Scores[idx[a]] != p.Scores[idx[b]] {
+			return p.Scores[idx[a]] > p.Scores[idx[b]]
+		}
+		return idx[a] < idx[b]
+	})
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s:", p.Strategy)
+	for _, i := range idx {
+		fmt.Fprintf(&buf, " gpu%d=%.3g", i, p.Scores[i])
+	}
+	return buf.String()
+}
@@ -0,0 +1,73 @@
+package placement
+
+import (
+	"testing"
+
+	"gpusched/internal/protocol"
+)
+
+var gpus = []protocol.GPUInfo{
+	{Index: 0, MemTotal: 80_000, MemFree: 20_000, UtilPct: 0},
+	{Index: 1, MemTotal: 80_000, MemFree: 60_000, UtilPct: 95},
+	{Index: 2, MemTotal: 80_000, MemFree: 40_000, UtilPct: 10},
+}
+
+func TestChoose(t *testing.T) {
+	cases := []struct {
+		strategy string
+		req      Request
+		want     int
+	}{
+		{Spread, Request{Exclude: -1}, 1},
+		{Binpack, Request{Exclude: -1}, 0},
+		{Binpack, Request{NeedMB: 30_000, Exclude: -1}, 2},
+		{Utilization, Request{Exclude: -1}, 2},
+		{Spread, Request{Exclude: 1}, 2},
+	}
+	for _, c := range cases {
+		s, err := New(c.strategy, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pl, err := Choose(s, c.req, gpus)
+		if err != nil {
+			t.Fatalf("%s %+v: %v", c.strategy, c.req, err)
+		}
+		if pl.GPU != c.want || pl.Strategy != c.strategy {
+			t.Errorf("%s %+v chose %+v, want GPU %d", c.strategy, c.req, pl, c.want)
+		}
+	}
+
+	s, _ := New(Spread, nil)
+	if _, err := Choose(s, Request{NeedMB: 70_000, Exclude: -1}, gpus); err == nil {
+		t.Fatal("expected error when no GPU fits")
+	}
+	if _, err := New("random", nil); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+}
+
+func TestExecPlugin(t *testing.T) {
+	s, err := New(Exec, []string{"/bin/sh", "-c", `cat >/dev/null; echo '{"scores":{"0":1,"2":5}}'`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl, err := Choose(s, Request{Process: "train", Exclude: -1}, gpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pl.GPU != 2 || len(pl.Scores) != 2 {
+		t.Fatalf("unexpected placement: %+v", pl)
+	}
+	if got := Explain(pl); got != "exec: gpu2=5 gpu0=1" {
+		t.Fatalf("Explain = %q", got)
+	}
+
+	bad, _ := New(Exec, []string{"/bin/sh", "-c", "echo nope"})
+	if _, err := Choose(bad, Request{Exclude: -1}, gpus); err == nil {
+		t.Fatal("expected error for malformed plugin output")
+	}
+	if _, err := New(Exec, nil); err == nil {
+		t.Fatal("expected error for exec strategy without a command")
+	}
+}
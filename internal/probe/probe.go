@@ -0,0 +1,113 @@
+// Package probe runs TCP, HTTP, and exec checks against managed processes.
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+const (
+	DefaultPeriod  = time.Second
+	DefaultTimeout = 60 * time.Second
+
+	attemptTimeout = 5 * time.Second
+)
+
+// Period returns the interval between attempts.
+func Period(p protocol.Probe) time.Duration {
+	if p.PeriodMs > 0 {
+		return time.Duration(p.PeriodMs) * time.Millisecond
+	}
+	return DefaultPeriod
+}
+
+// Timeout returns how long Wait keeps retrying.
+func Timeout(p protocol.Probe) time.Duration {
+	if p.TimeoutMs > 0 {
+		return time.Duration(p.TimeoutMs) * time.Millisecond
+	}
+	return DefaultTimeout
+}
+
+// Check runs a single probe attempt. Exactly one of TCP, HTTP, or Exec
+// should be set; an empty probe always succeeds.
+func Check(ctx context.Context, p protocol.Probe) error {
+	ctx, cancel := context.WithTimeout(ctx, attemptTimeout)
+	defer cancel()
+
+	switch {
+	case p.TCP != "":
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", p.TCP)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	case p.HTTP != "":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.HTTP, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return fmt.Errorf("GET %s: %s", p.HTTP, resp.Status)
+		}
+		return nil
+
+	case len(p.Exec) > 0:
+		out, err := exec.CommandContext(ctx, p.Exec[0], p.Exec[1:]...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %s (%w)", p.Exec[0], strings.TrimSpace(string(out)), err)
+		}
+		return nil
+	}
+	return nil
+}
+
+// Wait retries Check every period until it succeeds or the probe timeout
+// expires, returning the time it took to succeed.
+func Wait(ctx context.Context, p protocol.Probe) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout(p))
+	defer cancel()
+
+	start := time.Now()
+	ticker := time.NewTicker(Period(p))
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		if lastErr = Check(ctx, p); lastErr == nil {
+			return time.Since(start), nil
+		}
+		select {
+		case <-ctx.Done():
+			return time.Since(start), fmt.Errorf("not ready after %s: %w", Timeout(p), lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Describe renders the probe target for logs and events.
+func Describe(p protocol.Probe) string {
+	switch {
+	case p.TCP != "":
+		return "tcp " + p.TCP
+	case p.HTTP != "":
+		return "http " + p.HTTP
+	case len(p.Exec) > 0:
+		return "exec " + strings.Join(p.Exec, " ")
+	}
+	return "none"
+}
@@ -0,0 +1,50 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gpusched/internal/protocol"
+)
+
+func TestCheckTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	if err := Check(context.Background(), protocol.Probe{TCP: addr}); err != nil {
+		t.Fatalf("expected tcp probe to pass: %v", err)
+	}
+	ln.Close()
+	if err := Check(context.Background(), protocol.Probe{TCP: addr}); err == nil {
+		t.Fatal("expected tcp probe to fail after close")
+	}
+}
+
+func TestCheckHTTPStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	if err := Check(context.Background(), protocol.Probe{HTTP: srv.URL + "/health"}); err != nil {
+		t.Fatalf("expected 200 to pass: %v", err)
+	}
+	if err := Check(context.Background(), protocol.Probe{HTTP: srv.URL + "/busy"}); err == nil {
+		t.Fatal("expected 503 to fail")
+	}
+}
+
+func TestWaitTimesOut(t *testing.T) {
+	p := protocol.Probe{Exec: []string{"false"}, PeriodMs: 10, TimeoutMs: 50}
+	if _, err := Wait(context.Background(), p); err == nil {
+		t.Fatal("expected Wait to time out")
+	}
+}
@@ -0,0 +1,265 @@
+// Package procfs samples per-process CPU and memory from /proc.
+package procfs
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockTicks is USER_HZ, which is 100 on every Linux platform we support.
+const clockTicks = 100
+
+// CPUTicks returns utime+stime for pid from /proc/PID/stat.
+func CPUTicks(pid int) (uint64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// comm (field 2) may contain spaces; fields resume after the last ')'.
+	s := string(data)
+	end := strings.LastIndexByte(s, ')')
+	if end < 0 {
+		return 0, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	fields := strings.Fields(s[end+1:])
+	// fields[0] is state (field 3); utime and stime are fields 14 and 15.
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("short stat for pid %d", pid)
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return utime + stime, nil
+}
+
+// Descendants returns the PIDs of all live descendants of pid, parents
+// before children.
+func Descendants(pid int) []int {
+	children := make(map[int][]int)
+	for _, child := range PIDs() {
+		if ppid, err := ParentPID(child); err == nil {
+			children[ppid] = append(children[ppid], child)
+		}
+	}
+	var out []int
+	queue := children[pid]
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		out = append(out, next)
+		queue = append(queue, children[next]...)
+	}
+	return out
+}
+
+// Stopped reports whether pid is stopped by a signal (state T in
+// /proc/PID/stat).
+func Stopped(pid int) bool {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	s := string(data)
+	end := strings.LastIndexByte(s, ')')
+	if end < 0 {
+		return false
+	}
+	fields := strings.Fields(s[end+1:])
+	return len(fields) > 0 && fields[0] == "T"
+}
+
+// PIDs returns every process ID currently in /proc.
+func PIDs() []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, e := range entries {
+		if pid, err := strconv.Atoi(e.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// Environ returns the environment pid was started with.
+func Environ(pid int) (map[string]string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	for _, kv := range strings.Split(string(data), "\x00") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env, nil
+}
+
+// Cmdline returns pid's argv.
+func Cmdline(pid int) ([]string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(data), "\x00"), "\x00"), nil
+}
+
+// ParentPID returns the ppid field of /proc/PID/stat.
+func ParentPID(pid int) (int, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	s := string(data)
+	end := strings.LastIndexByte(s, ')')
+	if end < 0 {
+		return 0, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	fields := strings.Fields(s[end+1:])
+	// fields[0] is state (field 3); ppid is field 4.
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("short stat for pid %d", pid)
+	}
+	return strconv.Atoi(fields[1])
+}
+
+// UID returns the real user ID pid runs as, from /proc/PID/status.
+func UID(pid int) (int, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "Uid:"); ok {
+			fields := strings.Fields(rest)
+			if len(fields) == 0 {
+				break
+			}
+			return strconv.Atoi(fields[0])
+		}
+	}
+	return 0, fmt.Errorf("no Uid line for pid %d", pid)
+}
+
+// RSSMB returns the resident set size of pid in MB from /proc/PID/statm.
+func RSSMB(pid int) int64 {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0
+	}
+	pages, _ := strconv.ParseInt(fields[1], 10, 64)
+	return pages * int64(os.Getpagesize()) / (1024 * 1024)
+}
+
+// SwapMB returns the swapped-out memory of pid in MB (VmSwap in
+// /proc/PID/status).
+func SwapMB(pid int) int64 {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "VmSwap:"); ok {
+			fields := strings.Fields(rest)
+			if len(fields) == 0 {
+				return 0
+			}
+			kb, _ := strconv.ParseInt(fields[0], 10, 64)
+			return kb / 1024
+		}
+	}
+	return 0
+}
+
+// OOMScoreAdj returns /proc/PID/oom_score_adj.
+func OOMScoreAdj(pid int) (int, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/oom_score_adj", pid))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// SetOOMScoreAdj writes /proc/PID/oom_score_adj. Lowering it below the
+// current value needs CAP_SYS_RESOURCE.
+func SetOOMScoreAdj(pid, adj int) error {
+	return os.WriteFile(fmt.Sprintf("/proc/%d/oom_score_adj", pid), []byte(strconv.Itoa(adj)), 0o644)
+}
+
+// OOMKills returns the kernel's count of OOM killer invocations since boot
+// (oom_kill in /proc/vmstat, Linux 4.13+).
+func OOMKills() (int64, error) {
+	data, err := os.ReadFile("/proc/vmstat")
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "oom_kill "); ok {
+			return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("oom_kill not in /proc/vmstat")
+}
+
+type cpuSample struct {
+	ticks uint64
+	at    time.Time
+}
+
+// CPUSampler computes CPU utilisation between successive calls per PID.
+type CPUSampler struct {
+	mu   sync.Mutex
+	last map[int]cpuSample
+}
+
+func NewCPUSampler() *CPUSampler {
+	return &CPUSampler{last: make(map[int]cpuSample)}
+}
+
+// Percent returns CPU usage of pid since the previous call, where 100 is one
+// full core. The first call for a pid returns 0.
+func (s *CPUSampler) Percent(pid int) float64 {
+	ticks, err := CPUTicks(pid)
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		delete(s.last, pid)
+		return 0
+	}
+	prev, ok := s.last[pid]
+	s.last[pid] = cpuSample{ticks: ticks, at: now}
+	if !ok || ticks < prev.ticks {
+		return 0
+	}
+	elapsed := now.Sub(prev.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(ticks-prev.ticks) / clockTicks / elapsed * 100
+}
+
+// Forget drops sampling state for pid.
+func (s *CPUSampler) Forget(pid int) {
+	s.mu.Lock()
+	delete(s.last, pid)
+	s.mu.Unlock()
+}
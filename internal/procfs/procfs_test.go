@@ -0,0 +1,70 @@
+package procfs
+
+import (
+	"os"
+	"os/exec"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSelf(t *testing.T) {
+	pid := os.Getpid()
+	if _, err := CPUTicks(pid); err != nil {
+		t.Fatalf("CPUTicks(self): %v", err)
+	}
+	if rss := RSSMB(pid); rss <= 0 {
+		t.Fatalf("expected positive RSS for self, got %d", rss)
+	}
+	if swap := SwapMB(pid); swap < 0 {
+		t.Fatalf("expected non-negative swap for self, got %d", swap)
+	}
+	if uid, err := UID(pid); err != nil || uid != os.Getuid() {
+		t.Fatalf("UID(self) = %d, %v; want %d", uid, err, os.Getuid())
+	}
+	adj, err := OOMScoreAdj(pid)
+	if err != nil {
+		t.Fatalf("OOMScoreAdj(self): %v", err)
+	}
+	// Raising our own score never needs privileges.
+	if err := SetOOMScoreAdj(pid, adj); err != nil {
+		t.Fatalf("SetOOMScoreAdj(self, %d): %v", adj, err)
+	}
+}
+
+func TestNonexistent(t *testing.T) {
+	if _, err := CPUTicks(999999999); err == nil {
+		t.Fatal("expected error for nonexistent pid")
+	}
+	s := NewCPUSampler()
+	if pct := s.Percent(999999999); pct != 0 {
+		t.Fatalf("expected 0%% for nonexistent pid, got %f", pct)
+	}
+}
+
+func TestDescendants(t *testing.T) {
+	cmd := exec.Command("/bin/sh", "-c", "sleep 30 & wait")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start shell: %v", err)
+	}
+	defer func() {
+		for _, pid := range Descendants(cmd.Process.Pid) {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	// The shell's sleep shows up under the shell, and both under us.
+	for i := 0; i < 100; i++ {
+		if len(Descendants(cmd.Process.Pid)) == 1 {
+			if !slices.Contains(Descendants(os.Getpid()), cmd.Process.Pid) {
+				t.Fatalf("expected shell %d among our descendants", cmd.Process.Pid)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected one descendant of the shell, got %v", Descendants(cmd.Process.Pid))
+}
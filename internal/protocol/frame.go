@@ -0,0 +1,116 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// FramingLength is set in Request.Framing by clients that accept
+// length-prefixed replies. Requests are always sent as JSON lines so old
+// daemons keep working; they ignore the field and reply with lines, which
+// Decoder also reads.
+const FramingLength = "length"
+
+// MaxFrame bounds a single length-prefixed message. It also keeps the
+// first header byte at or below 0x08, so it can't be mistaken for the
+// whitespace or '{' that starts a JSON line.
+const MaxFrame = 128 << 20
+
+// MaxRequest bounds a request read by the daemon, in either format. It is
+// far below MaxFrame because requests are read before the caller has
+// shown a token, and only replies (status, logs) get large.
+const MaxRequest = 4 << 20
+
+// WriteMessage marshals v and writes it either as a length-prefixed frame
+// (4-byte big-endian length, then the JSON) or as a newline-terminated
+// JSON line.
+func WriteMessage(w io.Writer, v interface{}, framed bool) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if !framed {
+		_, err = w.Write(append(data, '\n'))
+		return err
+	}
+	if len(data) > MaxFrame {
+		return fmt.Errorf("message of %d bytes exceeds max frame size", len(data))
+	}
+	buf := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	copy(buf[4:], data)
+	_, err = w.Write(buf)
+	return err
+}
+
+// Decoder reads messages written by WriteMessage in either format,
+// detecting the format per message from its first byte.
+type Decoder struct {
+	r   *bufio.Reader
+	max int
+}
+
+// NewDecoder reads replies, of up to MaxFrame bytes.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), max: MaxFrame}
+}
+
+// NewRequestDecoder reads requests, of up to MaxRequest bytes.
+func NewRequestDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), max: MaxRequest}
+}
+
+// Next returns the next message's JSON bytes. A message over the
+// decoder's limit is an error, after which the stream can't be read.
+func (d *Decoder) Next() ([]byte, error) {
+	for {
+		b, err := d.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		switch b[0] {
+		case '\n', '\r', ' ', '\t':
+			d.r.ReadByte()
+			continue
+		case '{':
+			return d.line()
+		}
+
+		var hdr [4]byte
+		if _, err := io.ReadFull(d.r, hdr[:]); err != nil {
+			return nil, err
+		}
+		n := binary.BigEndian.Uint32(hdr[:])
+		if n > uint32(d.max) {
+			return nil, fmt.Errorf("frame of %d bytes exceeds max size of %d", n, d.max)
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(d.r, data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+}
+
+// line reads a JSON line of up to d.max bytes without buffering more.
+func (d *Decoder) line() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := d.r.ReadSlice('\n')
+		if len(line)+len(chunk) > d.max {
+			return nil, fmt.Errorf("line exceeds max size of %d bytes", d.max)
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return nil, err
+		}
+		return bytes.TrimSpace(line), nil
+	}
+}
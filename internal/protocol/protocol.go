@@ -2,16 +2,34 @@
 package protocol
 
 import (
+	"bytes"
 	"encoding/json"
+	"sort"
+	"strings"
 	"time"
 )
 
+// DefaultSocket is where the daemon listens and clients connect unless
+// told otherwise.
+const DefaultSocket = "/tmp/gpusched.sock"
+
+// TCPPrefix marks a client address as host:port rather than a socket
+// path, e.g. tcp://gpu-box:9465.
+const TCPPrefix = "tcp://"
+
+// TLSPrefix is TCPPrefix for a daemon listening with TLS, e.g.
+// tls://gpu-box:9465.
+const TLSPrefix = "tls://"
+
 type ProcessState string
 
 const (
 	StateActive ProcessState = "active"
 	StateFrozen ProcessState = "frozen"
 	StateDead   ProcessState = "dead"
+	// StateDegraded is a process a failed freeze or thaw left in a CUDA
+	// state the daemon could not roll back; see the recover command.
+	StateDegraded ProcessState = "degraded"
 )
 
 type Tier string
@@ -19,17 +37,49 @@ type Tier string
 const (
 	TierGPU Tier = "gpu"
 	TierRAM Tier = "ram"
+	// TierRAMSwapped is the effective tier of a frozen process whose host
+	// memory the kernel has largely pushed out to swap.
+	TierRAMSwapped Tier = "ram(swapped)"
 )
 
 type Request struct {
+	// ID is an optional caller-chosen request ID, echoed in the response
+	// and attached to daemon logs and events for correlation.
+	ID     string          `json:"id,omitempty"`
 	Method string          `json:"method"`
 	Params json.RawMessage `json:"params,omitempty"`
+	// Framing asks for replies in the given framing (FramingLength);
+	// empty means JSON lines.
+	Framing string `json:"framing,omitempty"`
+	// Node runs the request on the named cluster peer instead; the daemon
+	// relays it and returns the peer's response.
+	Node string `json:"node,omitempty"`
+	// Token authenticates the request to a TCP listener started with a
+	// token; the Unix socket ignores it.
+	Token string `json:"token,omitempty"`
 }
 
+// Error kinds (Response.Code).
+const (
+	CodeNotFound         = "not_found"
+	CodeAlreadyExists    = "already_exists"
+	CodeInvalid          = "invalid"           // bad params, unknown method
+	CodePermissionDenied = "permission_denied" // the caller's role forbids it
+	CodeUnauthenticated  = "unauthenticated"   // missing or wrong token
+)
+
 type Response struct {
+	ID     string          `json:"id,omitempty"`
 	OK     bool            `json:"ok"`
 	Result json.RawMessage `json:"result,omitempty"`
 	Error  string          `json:"error,omitempty"`
+	// Code is the kind of error, one of the Code constants, when the
+	// daemon knows it; REST and gRPC map it to a status.
+	Code string `json:"code,omitempty"`
+	// Notification is set on unsolicited messages pushed to a command
+	// connection that opted in with "notify". They carry no ID and are
+	// not replies to any request.
+	Notification *Event `json:"notification,omitempty"`
 }
 
 type Event struct {
@@ -38,28 +88,575 @@ type Event struct {
 	Process  string    `json:"process,omitempty"`
 	Detail   string    `json:"detail,omitempty"`
 	Duration int64     `json:"duration_ms,omitempty"`
+	// RequestID is the ID of the request that caused the event, if any.
+	RequestID string `json:"request_id,omitempty"`
+	// User made that request, when the daemon knows who (callers on the
+	// Unix socket).
+	User string `json:"user,omitempty"`
+	// Cause is what triggered a state transition (see CauseUser etc.).
+	Cause string `json:"cause,omitempty"`
+	// Placement explains the GPU choice behind a run or migrate event.
+	Placement *Placement `json:"placement,omitempty"`
+	// Delta is what changed in status, on "status-delta" events.
+	Delta *StatusDelta `json:"status_delta,omitempty"`
+}
+
+// Placement is a GPU placement decision: the strategy, the GPU it chose,
+// and the score it gave each eligible GPU (higher is better).
+type Placement struct {
+	Strategy string          `json:"strategy"`
+	GPU      int             `json:"gpu"`
+	Scores   map[int]float64 `json:"scores"`
+}
+
+// Causes of freezes, thaws, and migrations.
+const (
+	CauseUser        = "user"          // a request from the CLI, SDK, or dashboard
+	CauseIdle        = "idle"          // idle freezing
+	CauseLiveness    = "liveness"      // a liveness probe's freeze action
+	CauseWake        = "wake"          // activity on a process's wake triggers
+	CauseGPUMemLimit = "gpu-mem-limit" // going over a GPU memory limit
+	CauseTimeSlice   = "time-slice"    // the time-slicing scheduler
+	CauseDeadline    = "deadline"      // reaching a maximum runtime
+)
+
+// Transition is a process's most recent state change and what caused it.
+type Transition struct {
+	Time      time.Time    `json:"time"`
+	State     ProcessState `json:"state"`
+	Cause     string       `json:"cause"`
+	Detail    string       `json:"detail,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
 }
 
 type RunParams struct {
-	Name string   `json:"name"`
-	Cmd  []string `json:"cmd"`
-	Dir  string   `json:"dir,omitempty"`
-	GPU  int      `json:"gpu"`
+	Namespace string   `json:"namespace,omitempty"`
+	Name      string   `json:"name"`
+	Cmd       []string `json:"cmd"`
+	Dir       string   `json:"dir,omitempty"`
+	GPU       int      `json:"gpu"`
+	Shell     bool     `json:"shell,omitempty"`
+	ExpandEnv bool     `json:"expand_env,omitempty"`
+
+	// AutoGPU lets the daemon's placement strategy pick the GPU instead
+	// of using GPU.
+	AutoGPU bool `json:"auto_gpu,omitempty"`
+
+	// Group puts the process in a group with the other processes of its
+	// namespace given the same group, which can be frozen, thawed,
+	// migrated, and killed together.
+	Group string `json:"group,omitempty"`
+
+	// CheckpointArgs and CheckpointTimeouts override the daemon's
+	// cuda-checkpoint settings for this process. Timeouts are keyed by
+	// action (lock, checkpoint, restore, unlock). Only admins may set them.
+	CheckpointArgs     []string         `json:"checkpoint_args,omitempty"`
+	CheckpointTimeouts map[string]int64 `json:"checkpoint_timeouts_ms,omitempty"`
+
+	// Readiness is checked after thaw; the thaw result reports when the
+	// process became ready in addition to when it was restored.
+	Readiness *Probe `json:"readiness,omitempty"`
+
+	// Liveness is checked periodically while the process is active. After
+	// FailureThreshold consecutive failures LivenessAction runs.
+	Liveness       *Probe `json:"liveness,omitempty"`
+	LivenessAction string `json:"liveness_action,omitempty"`
+
+	// OnShutdown overrides the daemon's shutdown policy for this process.
+	OnShutdown string `json:"on_shutdown,omitempty"`
+
+	// GPUMemLimitMB is the most GPU memory the process may use. Going over
+	// it runs GPUMemLimitAction: LimitKill (the default), LimitFreeze, or
+	// LimitSignal, which sends GPUMemLimitSignal (default SIGTERM).
+	GPUMemLimitMB     int64  `json:"gpu_mem_limit_mb,omitempty"`
+	GPUMemLimitAction string `json:"gpu_mem_limit_action,omitempty"`
+	GPUMemLimitSignal string `json:"gpu_mem_limit_signal,omitempty"`
+
+	// MaxRuntimeMs is how long the process may run, not counting time
+	// frozen, before MaxRuntimeAction: DeadlineKill (the default) or
+	// DeadlineFreeze. TTLAfterExitMs removes the process from the table
+	// this long after it exits. Each restart starts the clock over.
+	MaxRuntimeMs     int64  `json:"max_runtime_ms,omitempty"`
+	MaxRuntimeAction string `json:"max_runtime_action,omitempty"`
+	TTLAfterExitMs   int64  `json:"ttl_after_exit_ms,omitempty"`
+
+	// TimeSlice shares the process's GPU with the other time-sliced
+	// processes on it, each running for the daemon's time slice quantum
+	// in turn while the rest wait frozen.
+	TimeSlice bool `json:"time_slice,omitempty"`
+
+	// MPS runs the process as a client of the daemon's MPS control
+	// daemon, sharing its GPU's compute with the other clients instead of
+	// taking turns. MPS clients can't be frozen or migrated.
+	MPS bool `json:"mps,omitempty"`
+
+	// Nice, IOClass, and OOMScoreAdj are applied to the process once it
+	// has started. Zero values leave the daemon's own.
+	Nice        int    `json:"nice,omitempty"`
+	IOClass     string `json:"io_class,omitempty"`
+	OOMScoreAdj int    `json:"oom_score_adj,omitempty"`
+
+	// CPUs and HostMemMB limit the process's CPU time, in CPUs (1.5 is a
+	// core and a half), and host memory, in its own cgroup. 0 is no limit.
+	CPUs      float64 `json:"cpus,omitempty"`
+	HostMemMB int64   `json:"host_mem_mb,omitempty"`
+
+	// Wake thaws the process, while it is frozen, on activity outside it.
+	Wake *WakeTriggers `json:"wake,omitempty"`
+
+	// Restart relaunches the process when it exits on its own, after a
+	// backoff. RestartMax caps how many times it is relaunched; 0 means
+	// no limit.
+	Restart    string `json:"restart,omitempty"`
+	RestartMax int    `json:"restart_max,omitempty"`
+
+	// MaxAutoFreezesPerHour caps daemon-initiated freezes (idle, liveness)
+	// of this process, overriding the daemon default.
+	MaxAutoFreezesPerHour int `json:"max_auto_freezes_per_hour,omitempty"`
+
+	// Inference marks the process as an inference server, which gets
+	// default health probes, a drain before freeze, and request-aware
+	// idle detection.
+	Inference *InferenceServer `json:"inference,omitempty"`
+
+	// DrainHooks are run around a draining freeze (FreezeParams.Drain).
+	DrainHooks *DrainHooks `json:"drain_hooks,omitempty"`
+
+	// Exclusive reserves the GPU for this process while it is active.
+	Exclusive bool `json:"exclusive,omitempty"`
+
+	// Env adjusts the daemon's environment policy for this process.
+	Env *EnvPolicy `json:"env,omitempty"`
+
+	// Input is a file or named pipe fed to stdin, which is otherwise
+	// /dev/null. Output receives stdout in place of the log, which keeps
+	// stderr. Relative paths are resolved against Dir. The daemon opens
+	// them as its own user, so only admins may set them.
+	Input  string `json:"input,omitempty"`
+	Output string `json:"output,omitempty"`
+	// TTY runs the process on a pseudo-terminal, for REPLs and debuggers
+	// that need one. Its output still goes to the log, and clients can
+	// attach to it; it can't be combined with Input or Output.
+	TTY bool `json:"tty,omitempty"`
+
+	// Queue holds the run until its GPU (any GPU with AutoGPU) has MemMB
+	// free, instead of starting it at once. Queued runs start in order.
+	Queue bool  `json:"queue,omitempty"`
+	MemMB int64 `json:"mem_mb,omitempty"`
+
+	// UID is the user who asked for the run, taken by the daemon from the
+	// socket's peer credentials; whatever a client sends is replaced. It
+	// is nil for runs requested over TCP, HTTP, or gRPC.
+	UID *int `json:"uid,omitempty"`
+	// RunAs is the user the process runs as: UID, for callers who aren't
+	// admins. It too is set by the daemon; nil runs the process as the
+	// daemon's user.
+	RunAs *int `json:"run_as,omitempty"`
+}
+
+// EnvPolicy decides what a managed process inherits from the daemon's
+// environment. Inherit and Deny are glob patterns over variable names;
+// with no Inherit patterns everything not denied is passed. Set adds
+// variables; in the daemon's policy their values are Go templates over
+// .User, .Home, .Namespace, .Name, and .GPU, while a run's are literal.
+// Only admins may give a run Inherit patterns.
+type EnvPolicy struct {
+	Inherit []string          `json:"inherit,omitempty"`
+	Deny    []string          `json:"deny,omitempty"`
+	Set     map[string]string `json:"set,omitempty"`
+}
+
+// InferenceServer identifies a vLLM or TGI server by Kind and base URL
+// (default: the server's usual local port). Freezes wait up to
+// DrainTimeoutMs for in-flight requests to finish.
+type InferenceServer struct {
+	Kind           string `json:"kind"`
+	URL            string `json:"url,omitempty"`
+	DrainTimeoutMs int64  `json:"drain_timeout_ms,omitempty"`
+}
+
+// Probe checks a process over TCP, HTTP, by running a command, or (GPUMem)
+// by confirming it still holds GPU memory. Exactly one check is set. Exec
+// runs as the daemon's user, so only admins may set it.
+type Probe struct {
+	TCP    string   `json:"tcp,omitempty"`
+	HTTP   string   `json:"http,omitempty"`
+	Exec   []string `json:"exec,omitempty"`
+	GPUMem bool     `json:"gpu_mem,omitempty"`
+
+	PeriodMs         int64 `json:"period_ms,omitempty"`
+	TimeoutMs        int64 `json:"timeout_ms,omitempty"`
+	FailureThreshold int   `json:"failure_threshold,omitempty"`
+}
+
+// WakeTriggers are what thaws a frozen process besides a thaw request.
+// Relative paths are taken from the run's Dir. The daemon creates and
+// watches them as its own user, so only admins may set them.
+type WakeTriggers struct {
+	// Paths wake the process when a file is written, created, moved, or
+	// deleted: the path itself or, for a directory, anything directly in
+	// it. They must exist.
+	Paths []string `json:"paths,omitempty"`
+	// FIFOs wake the process when data is written to them, leaving the
+	// data for it to read. The daemon creates any that don't exist. The
+	// process should hold them open, or writers block until it opens them.
+	FIFOs []string `json:"fifos,omitempty"`
+}
+
+// Max runtime actions.
+const (
+	DeadlineKill   = "kill"
+	DeadlineFreeze = "freeze"
+)
+
+// GPU memory limit actions.
+const (
+	LimitKill   = "kill"
+	LimitFreeze = "freeze"
+	LimitSignal = "signal"
+)
+
+// I/O scheduling classes (ionice).
+const (
+	IOClassRealtime   = "realtime"
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle" // only gets disk time no one else wants
+)
+
+// Liveness failure actions.
+const (
+	LivenessAlert   = "alert"
+	LivenessFreeze  = "freeze"
+	LivenessRestart = "restart"
+)
+
+// Shutdown policies: what the daemon does with a process when it exits.
+const (
+	ShutdownKill  = "kill"  // SIGTERM, then SIGKILL after the drain timeout
+	ShutdownLeave = "leave" // keep running; the next daemon reattaches it
+)
+
+// Restart policies: when the daemon relaunches a process that exited.
+const (
+	RestartNo        = "no"
+	RestartAlways    = "always"
+	RestartOnFailure = "on-failure" // non-zero status or killed by a signal
+)
+
+// ProbeStatus is the most recent liveness result for a process.
+type ProbeStatus struct {
+	LastCheck           time.Time `json:"last_check"`
+	OK                  bool      `json:"ok"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Error               string    `json:"error,omitempty"`
 }
 
 type NameParams struct {
-	Name string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// FreezeParams names the process to freeze. Drain first stops it taking
+// new work (DrainHooks.Stop) and waits up to DrainTimeoutMs for its
+// in-flight requests (for inference servers), and refuses to freeze if
+// they don't finish.
+type FreezeParams struct {
+	Namespace      string `json:"namespace,omitempty"`
+	Name           string `json:"name"`
+	Drain          bool   `json:"drain,omitempty"`
+	DrainTimeoutMs int64  `json:"drain_timeout_ms,omitempty"`
+}
+
+// DrainHooks are commands run for a draining freeze: Stop before it, to
+// make the process take no new work (e.g. leave a load balancer), and
+// Resume once it is thawed. They get GPUSCHED_NAME and GPUSCHED_PID, and
+// run as the daemon's user, so only admins may set them.
+type DrainHooks struct {
+	Stop   []string `json:"stop,omitempty"`
+	Resume []string `json:"resume,omitempty"`
+}
+
+type AnnotateParams struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Note      string `json:"note,omitempty"`
+	Clear     bool   `json:"clear,omitempty"`
+}
+
+// AppCheckpointParams reports that a process saved a checkpoint of its
+// own, such as a training step. Processes report for themselves under the
+// GPUSCHED_NAME they were started with.
+type AppCheckpointParams struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Step      int64  `json:"step,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// AppCheckpoint is the last checkpoint a process reported saving: work
+// since Time is what killing it would lose.
+type AppCheckpoint struct {
+	Time   time.Time `json:"time"`
+	Step   int64     `json:"step,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// AdoptParams takes over processes gpusched didn't start: every CUDA
+// compute process on the host with All, else just PIDs. Each is named from
+// its command line and placed in the namespace of its owner's user name,
+// or OwnerMap[user name or uid] when that is set.
+type AdoptParams struct {
+	All      bool              `json:"all,omitempty"`
+	PIDs     []int             `json:"pids,omitempty"`
+	OwnerMap map[string]string `json:"owner_map,omitempty"`
+}
+
+type AdoptResult struct {
+	Adopted []ProcessInfo `json:"adopted"`
+	Skipped []AdoptSkip   `json:"skipped,omitempty"`
+}
+
+// AdoptSkip is a process adopt left alone, and why.
+type AdoptSkip struct {
+	PID    int    `json:"pid"`
+	Reason string `json:"reason"`
 }
 
 type MigrateParams struct {
-	Name string `json:"name"`
-	GPU  int    `json:"gpu"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	GPU       int    `json:"gpu"`
+	AutoGPU   bool   `json:"auto_gpu,omitempty"`
+
+	// DryRun only reports the target and estimated downtime. A real
+	// migration whose estimate exceeds MaxDowntimeMs is refused.
+	DryRun        bool  `json:"dry_run,omitempty"`
+	MaxDowntimeMs int64 `json:"max_downtime_ms,omitempty"`
+}
+
+// Estimate is an expected operation time derived from past throughput.
+// Ms is zero when there is no history to go on; Basis says what was used.
+type Estimate struct {
+	Ms    int64  `json:"ms"`
+	Basis string `json:"basis"`
+}
+
+// PlanParams asks what it would take to fit AddMB more on GPU.
+type PlanParams struct {
+	GPU   int   `json:"gpu"`
+	AddMB int64 `json:"add_mb"`
 }
 
 type LogsParams struct {
-	Name   string `json:"name"`
-	Lines  int    `json:"lines"`
-	Follow bool   `json:"follow"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Lines     int    `json:"lines"`
+	Follow    bool   `json:"follow"`
+	// Grep keeps only lines matching this regular expression (RE2 syntax),
+	// or only lines not matching it when Invert is set. Lines counts the
+	// last N lines that pass the filter.
+	Grep   string `json:"grep,omitempty"`
+	Invert bool   `json:"invert,omitempty"`
+	// Highlight is a regular expression whose matches are reported in
+	// LogsResult.Matches. It defaults to Grep unless Invert is set.
+	Highlight string `json:"highlight,omitempty"`
+}
+
+// AttachParams opens a terminal session with a process run with TTY,
+// sized Rows by Cols if they are set. After the daemon's reply the
+// connection carries AttachInput from the client and AttachOutput from
+// the daemon until either side hangs up or the process exits.
+type AttachParams struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Rows      int    `json:"rows,omitempty"`
+	Cols      int    `json:"cols,omitempty"`
+}
+
+// AttachInput is keyboard input for an attached process, or a new
+// terminal size when Rows and Cols are set.
+type AttachInput struct {
+	Data []byte `json:"data,omitempty"`
+	Rows int    `json:"rows,omitempty"`
+	Cols int    `json:"cols,omitempty"`
+}
+
+// AttachOutput is terminal output from an attached process. The last
+// message has Closed set, with the reason in Detail.
+type AttachOutput struct {
+	Data   []byte `json:"data,omitempty"`
+	Closed bool   `json:"closed,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// StatusStreamParams configures a "status_stream" subscription, which
+// pushes a full StatusResult every IntervalMs until the client disconnects.
+type StatusStreamParams struct {
+	IntervalMs int64  `json:"interval_ms,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+}
+
+// SubscribeParams configures a "subscribe" stream. With StatusDeltas set,
+// the daemon also pushes a "status-delta" event, at most every IntervalMs,
+// whenever status has changed since the initial status or the last delta.
+// These events are only sent to the subscriber that asked for them.
+type SubscribeParams struct {
+	StatusDeltas bool  `json:"status_deltas,omitempty"`
+	IntervalMs   int64 `json:"interval_ms,omitempty"`
+}
+
+// StatusDelta is what changed from one StatusResult to the next:
+// processes added, changed, or removed (by qualified name), and the GPUs,
+// memory, and metrics when they differ.
+type StatusDelta struct {
+	Added   []ProcessInfo `json:"added,omitempty"`
+	Changed []ProcessInfo `json:"changed,omitempty"`
+	Removed []string      `json:"removed,omitempty"`
+	GPUs    []GPUInfo     `json:"gpus,omitempty"`
+	Memory  *MemoryInfo   `json:"memory,omitempty"`
+	Metrics *Metrics      `json:"metrics,omitempty"`
+}
+
+// DiffStatus returns what changed from prev to next.
+func DiffStatus(prev, next StatusResult) StatusDelta {
+	var d StatusDelta
+	old := make(map[string]ProcessInfo, len(prev.Processes))
+	for _, p := range prev.Processes {
+		old[QualifiedName(p.Namespace, p.Name)] = p
+	}
+	for _, p := range next.Processes {
+		name := QualifiedName(p.Namespace, p.Name)
+		o, ok := old[name]
+		delete(old, name)
+		if !ok {
+			d.Added = append(d.Added, p)
+		} else if !sameJSON(o, p) {
+			d.Changed = append(d.Changed, p)
+		}
+	}
+	for name := range old {
+		d.Removed = append(d.Removed, name)
+	}
+	sort.Strings(d.Removed)
+	if !sameJSON(prev.GPUs, next.GPUs) {
+		d.GPUs = next.GPUs
+	}
+	if !sameJSON(prev.Memory, next.Memory) {
+		mem := next.Memory
+		d.Memory = &mem
+	}
+	if !sameJSON(prev.Metrics, next.Metrics) {
+		m := next.Metrics
+		d.Metrics = &m
+	}
+	return d
+}
+
+// sameJSON reports whether a and b encode the same, which is what a
+// client would see of them.
+func sameJSON(a, b interface{}) bool {
+	x, errX := json.Marshal(a)
+	y, errY := json.Marshal(b)
+	return errX == nil && errY == nil && bytes.Equal(x, y)
+}
+
+// Empty reports whether nothing changed.
+func (d StatusDelta) Empty() bool {
+	return len(d.Added)+len(d.Changed)+len(d.Removed) == 0 && d.GPUs == nil && d.Memory == nil && d.Metrics == nil
+}
+
+// Apply brings s up to date with d. Processes keep their places, with
+// added ones at the end, and are ordered active, frozen, dead as in a
+// full status.
+func (d StatusDelta) Apply(s *StatusResult) {
+	changed := make(map[string]ProcessInfo, len(d.Changed))
+	for _, p := range d.Changed {
+		changed[QualifiedName(p.Namespace, p.Name)] = p
+	}
+	removed := make(map[string]bool, len(d.Removed))
+	for _, name := range d.Removed {
+		removed[name] = true
+	}
+	procs := make([]ProcessInfo, 0, len(s.Processes)+len(d.Added))
+	for _, p := range s.Processes {
+		name := QualifiedName(p.Namespace, p.Name)
+		if removed[name] {
+			continue
+		}
+		if c, ok := changed[name]; ok {
+			p = c
+		}
+		procs = append(procs, p)
+	}
+	procs = append(procs, d.Added...)
+	order := map[ProcessState]int{StateActive: 0, StateFrozen: 1, StateDead: 2}
+	sort.SliceStable(procs, func(i, j int) bool {
+		return order[procs[i].State] < order[procs[j].State]
+	})
+	s.Processes = procs
+
+	if d.GPUs != nil {
+		s.GPUs = d.GPUs
+	}
+	if d.Memory != nil {
+		s.Memory = *d.Memory
+	}
+	if d.Metrics != nil {
+		s.Metrics = *d.Metrics
+	}
+}
+
+// NotifyParams opts a command connection in to notifications. Types
+// selects which events are pushed; empty means NotifyEventTypes.
+// Namespace limits them to one namespace; empty means all.
+type NotifyParams struct {
+	Types     []string `json:"types,omitempty"`
+	Namespace string   `json:"namespace,omitempty"`
+}
+
+// NotifyEventTypes are the events pushed to a notifying connection by
+// default: the ones that mean something happened to a process that its
+// owner didn't ask for.
+var NotifyEventTypes = []string{
+	"idle",
+	"oom-killed",
+	"freeze-capped",
+	"liveness-failed",
+	"restart-failed",
+	"restart-gave-up",
+	"wake-failed",
+	"gpu-mem-limit",
+	"deadline",
+	"drain-failed",
+	"rendezvous-warning",
+	"desired-failed",
+}
+
+// StatusParams scopes status to one namespace. An empty Namespace lists
+// processes in all namespaces.
+type StatusParams struct {
+	Namespace string `json:"namespace,omitempty"`
+	// Cluster adds the GPUs and processes of every cluster peer, each
+	// tagged with its node.
+	Cluster bool `json:"cluster,omitempty"`
+}
+
+// QualifiedName is the daemon-wide key for name within namespace. Process
+// names are only unique per namespace; names outside any namespace are
+// used as-is.
+func QualifiedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// SplitQualifiedName is the inverse of QualifiedName.
+func SplitQualifiedName(qualified string) (namespace, name string) {
+	if i := strings.IndexByte(qualified, '/'); i >= 0 {
+		return qualified[:i], qualified[i+1:]
+	}
+	return "", qualified
 }
 
 type StatusResult struct {
@@ -69,6 +666,45 @@ type StatusResult struct {
 	Metrics   Metrics       `json:"metrics"`
 	Events    []Event       `json:"recent_events"`
 	Caps      Capabilities  `json:"capabilities"`
+	// GPUCaps is what each GPU supports, keyed by GPU index. It is empty
+	// when nvidia-smi can't report it.
+	GPUCaps map[int]GPUCapabilities `json:"gpu_capabilities,omitempty"`
+	// Nodes lists the cluster this daemon knows, itself first, for a
+	// cluster status. Memory, metrics, events, and capabilities remain
+	// the local daemon's.
+	Nodes []NodeStatus `json:"nodes,omitempty"`
+	// Queued are runs waiting for GPU memory, in queue order.
+	Queued []QueuedRun `json:"queued,omitempty"`
+	// Users is what each user's processes hold, by UID.
+	Users []UserUsage `json:"users,omitempty"`
+}
+
+// Quota limits what one user's processes may hold: GPUs and GPU memory
+// while active, and host RAM as snapshots while frozen. Zero is unlimited.
+type Quota struct {
+	GPUs       int   `json:"gpus,omitempty"`
+	GPUMemMB   int64 `json:"gpu_mem_mb,omitempty"`
+	SnapshotMB int64 `json:"snapshot_mb,omitempty"`
+}
+
+// UserUsage is what one user's processes hold, and their quota if any.
+// An active process counts the larger of its GPU memory and the MemMB
+// it was run with.
+type UserUsage struct {
+	UID        int    `json:"uid"`
+	User       string `json:"user,omitempty"`
+	GPUs       []int  `json:"gpus"`
+	GPUMemMB   int64  `json:"gpu_mem_mb"`
+	SnapshotMB int64  `json:"snapshot_mb"`
+	Quota      *Quota `json:"quota,omitempty"`
+}
+
+// NodeStatus is one daemon of a cluster status. Error is set when the
+// peer could not be reached; its GPUs and processes are then missing.
+type NodeStatus struct {
+	Name  string `json:"name"`
+	Addr  string `json:"addr,omitempty"`
+	Error string `json:"error,omitempty"`
 }
 
 type GPUInfo struct {
@@ -77,9 +713,37 @@ type GPUInfo struct {
 	MemTotal int64  `json:"mem_total_mb"`
 	MemUsed  int64  `json:"mem_used_mb"`
 	MemFree  int64  `json:"mem_free_mb"`
+	UtilPct  int    `json:"util_pct"`
+	UUID     string `json:"uuid,omitempty"`
+
+	SMClockMHz  int `json:"sm_clock_mhz,omitempty"`
+	MemClockMHz int `json:"mem_clock_mhz,omitempty"`
+	// ThrottleReasons lists active clock throttle reasons, e.g.
+	// "sw_power_cap" or "hw_thermal". "idle" is normal for an unused GPU.
+	ThrottleReasons []string `json:"throttle_reasons,omitempty"`
+
+	// ReservedBy names the active exclusive process holding this GPU.
+	ReservedBy string `json:"reserved_by,omitempty"`
+	// MPS means an NVIDIA MPS server is running on the GPU, whether the
+	// daemon's or another.
+	MPS bool `json:"mps,omitempty"`
+
+	// ReserveMB is memory the daemon keeps free on this GPU. BudgetMB is
+	// its overcommit limit on the memory of active and frozen processes,
+	// CommittedMB what they hold now; both are 0 without a limit.
+	ReserveMB   int64 `json:"reserve_mb,omitempty"`
+	BudgetMB    int64 `json:"budget_mb,omitempty"`
+	CommittedMB int64 `json:"committed_mb,omitempty"`
+
+	// Node is the cluster node the GPU belongs to, in cluster status.
+	Node string `json:"node,omitempty"`
 }
 
 type ProcessInfo struct {
+	// Node is the cluster node running the process, in cluster status.
+	Node      string `json:"node,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+
 	Name    string       `json:"name"`
 	PID     int          `json:"pid"`
 	State   ProcessState `json:"state"`
@@ -88,6 +752,109 @@ type ProcessInfo struct {
 	Age     string       `json:"age"`
 	Started time.Time    `json:"started"`
 	Tier    Tier         `json:"tier"`
+	// Parked means the process is frozen but still running on the CPU,
+	// its GPU state in host RAM until it is unparked.
+	Parked  bool   `json:"parked,omitempty"`
+	Command string `json:"command,omitempty"`
+	Shell   bool   `json:"shell,omitempty"`
+	Group   string `json:"group,omitempty"`
+
+	// CPUPercent is usage since the previous status call (100 = one core).
+	CPUPercent float64 `json:"cpu_pct"`
+	// RSSMB is host resident memory, which for frozen processes includes
+	// the parked GPU snapshot.
+	RSSMB int64 `json:"rss_mb"`
+	// SwapMB is host memory swapped out. ThawPenaltyMs estimates the extra
+	// time a thaw spends faulting it back in.
+	SwapMB        int64 `json:"swap_mb,omitempty"`
+	ThawPenaltyMs int64 `json:"thaw_penalty_ms,omitempty"`
+	// RecentThawMs are the process's last few thaw times, oldest first.
+	// ThawEstimate is how long thawing it now should take (frozen only).
+	RecentThawMs []int64   `json:"recent_thaw_ms,omitempty"`
+	ThawEstimate *Estimate `json:"thaw_estimate,omitempty"`
+
+	Restarts int `json:"restarts,omitempty"`
+	// Restart is the restart policy, with its cap if any, such as
+	// "on-failure:5". NextRestart is when an exited process is due to be
+	// relaunched.
+	Restart     string     `json:"restart,omitempty"`
+	NextRestart *time.Time `json:"next_restart,omitempty"`
+	// Freezes counts freezes over the process's lifetime; SuspendedMs is
+	// the cumulative time spent frozen.
+	Freezes     int          `json:"freezes,omitempty"`
+	SuspendedMs int64        `json:"suspended_ms,omitempty"`
+	LastChange  *Transition  `json:"last_change,omitempty"`
+	Liveness    *ProbeStatus `json:"liveness,omitempty"`
+	Notes       []Note       `json:"notes,omitempty"`
+	// AppCheckpoint is the last checkpoint the process itself reported.
+	AppCheckpoint *AppCheckpoint `json:"app_checkpoint,omitempty"`
+
+	OnShutdown string           `json:"on_shutdown,omitempty"`
+	Inference  *InferenceServer `json:"inference,omitempty"`
+	Rendezvous *Rendezvous      `json:"rendezvous,omitempty"`
+	Exclusive  bool             `json:"exclusive,omitempty"`
+	// TTY is set for processes run on a terminal, which attach connects to.
+	TTY         bool          `json:"tty,omitempty"`
+	Wake        *WakeTriggers `json:"wake,omitempty"`
+	Nice        int           `json:"nice,omitempty"`
+	IOClass     string        `json:"io_class,omitempty"`
+	OOMScoreAdj int           `json:"oom_score_adj,omitempty"`
+	// Cgroup is the process's own cgroup, where its CPUs and HostMemMB
+	// limits apply and which RSSMB is read from.
+	Cgroup    string  `json:"cgroup,omitempty"`
+	CPUs      float64 `json:"cpus,omitempty"`
+	HostMemMB int64   `json:"host_mem_mb,omitempty"`
+	// GPUMemLimitMB is the process's GPU memory limit and
+	// GPUMemLimitAction what going over it does.
+	GPUMemLimitMB     int64  `json:"gpu_mem_limit_mb,omitempty"`
+	GPUMemLimitAction string `json:"gpu_mem_limit_action,omitempty"`
+	TimeSlice         bool   `json:"time_slice,omitempty"`
+	MPS               bool   `json:"mps,omitempty"`
+	// Deadline is when the process reaches MaxRuntimeMs if it stays
+	// active, or, once it has exited, when TTLAfterExitMs removes it.
+	MaxRuntimeMs     int64      `json:"max_runtime_ms,omitempty"`
+	MaxRuntimeAction string     `json:"max_runtime_action,omitempty"`
+	TTLAfterExitMs   int64      `json:"ttl_after_exit_ms,omitempty"`
+	Deadline         *time.Time `json:"deadline,omitempty"`
+}
+
+// Unhealthy reports whether the process is active but failing its
+// liveness probe.
+func (p ProcessInfo) Unhealthy() bool {
+	return p.State == StateActive && p.Liveness != nil && !p.Liveness.OK
+}
+
+// WaitingForSlice reports whether the process is frozen until its next
+// turn on a time-sliced GPU.
+func (p ProcessInfo) WaitingForSlice() bool {
+	return p.TimeSlice && p.State == StateFrozen && p.LastChange != nil && p.LastChange.Cause == CauseTimeSlice
+}
+
+// Rendezvous is the torchrun (torch.distributed.run) configuration found
+// on a process's command line. Such processes are frozen worker by worker
+// with the elastic agent paused around the checkpoint.
+type Rendezvous struct {
+	Backend      string `json:"backend,omitempty"`
+	Endpoint     string `json:"endpoint,omitempty"`
+	ID           string `json:"id,omitempty"`
+	Nnodes       string `json:"nnodes,omitempty"`
+	NprocPerNode string `json:"nproc_per_node,omitempty"`
+	Standalone   bool   `json:"standalone,omitempty"`
+}
+
+// MultiNode reports whether the job may span more than one node.
+func (r *Rendezvous) MultiNode() bool {
+	switch r.Nnodes {
+	case "", "1", "1:1":
+		return false
+	}
+	return !r.Standalone
+}
+
+// Note is a free-form annotation attached to a process.
+type Note struct {
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
 }
 
 type MemoryInfo struct {
@@ -95,6 +862,12 @@ type MemoryInfo struct {
 	HostRAMFreeMB   int64 `json:"host_ram_free_mb"`
 	HostRAMBudgetMB int64 `json:"host_ram_budget_mb"`
 	SnapshotsMB     int64 `json:"snapshots_mb"`
+	// RAMMarginMB is the free host RAM freezes leave. HeadroomMB is how
+	// much more can be frozen: the rest of the budget, or of free RAM
+	// above the margin when that is smaller (MarginBound).
+	RAMMarginMB int64 `json:"ram_margin_mb"`
+	HeadroomMB  int64 `json:"headroom_mb"`
+	MarginBound bool  `json:"margin_bound,omitempty"`
 }
 
 type Metrics struct {
@@ -106,38 +879,410 @@ type Metrics struct {
 	ColdStarts  int   `json:"cold_starts"`
 	AvgFreezeMs int64 `json:"avg_freeze_ms"`
 	AvgThawMs   int64 `json:"avg_thaw_ms"`
+
+	ReconcileWarnings int `json:"reconcile_warnings"`
+
+	// Evictions counts freezes the daemon initiated itself (idle,
+	// liveness, or a GPU memory limit) rather than a user.
+	Evictions int `json:"evictions"`
+	// GPUMemLimitViolations counts processes found over their GPU memory
+	// limit.
+	GPUMemLimitViolations int `json:"gpu_mem_limit_violations"`
+	// Latency histograms for completed operations.
+	FreezeLatency  Histogram `json:"freeze_latency"`
+	ThawLatency    Histogram `json:"thaw_latency"`
+	MigrateLatency Histogram `json:"migrate_latency"`
+
+	// EventsDropped counts events not delivered to slow subscribers.
+	EventsDropped int               `json:"events_dropped"`
+	Subscribers   []SubscriberStats `json:"subscribers,omitempty"`
+}
+
+// LatencyBucketsMs are the upper bounds of Histogram buckets.
+var LatencyBucketsMs = []int64{100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000, 120000}
+
+// Histogram counts operation durations into LatencyBucketsMs. Counts[i] is
+// the number of observations at or below LatencyBucketsMs[i] and above the
+// previous bound; anything slower is only in Count.
+type Histogram struct {
+	Counts []int `json:"counts,omitempty"`
+	SumMs  int64 `json:"sum_ms"`
+	Count  int   `json:"count"`
+}
+
+// Observe adds one duration.
+func (h *Histogram) Observe(ms int64) {
+	if h.Counts == nil {
+		h.Counts = make([]int, len(LatencyBucketsMs))
+	}
+	for i, b := range LatencyBucketsMs {
+		if ms <= b {
+			h.Counts[i]++
+			break
+		}
+	}
+	h.SumMs += ms
+	h.Count++
+}
+
+// SubscriberStats shows how far behind an event subscriber is.
+type SubscriberStats struct {
+	ID      int `json:"id"`
+	Queued  int `json:"queued"`
+	Dropped int `json:"dropped"`
 }
 
 type Capabilities struct {
-	CUDACheckpoint bool   `json:"cuda_checkpoint"`
-	DriverVersion  string `json:"driver_version,omitempty"`
+	CUDACheckpoint       bool   `json:"cuda_checkpoint"`
+	CUDACheckpointBinary string `json:"cuda_checkpoint_binary,omitempty"`
+	DriverVersion        string `json:"driver_version,omitempty"`
+}
+
+// GPUCapabilities is what one GPU supports. Features vary between devices
+// on one host and between driver branches, so clients check these before
+// offering an action on a device.
+type GPUCapabilities struct {
+	DriverVersion string `json:"driver_version,omitempty"`
+	ComputeCap    string `json:"compute_cap,omitempty"`
+	// MIGSupported is false on GPUs without MIG; MIG reports whether it is
+	// currently enabled.
+	MIGSupported bool `json:"mig_supported"`
+	MIG          bool `json:"mig"`
+	// Checkpoint means processes on this GPU can be frozen and thawed.
+	Checkpoint bool `json:"checkpoint"`
+	// Reset means nvidia-smi can reset the device.
+	Reset bool `json:"reset"`
+	// Notes explain why a feature is unavailable.
+	Notes []string `json:"notes,omitempty"`
+}
+
+// Beacon advertises a daemon on the local network.
+type Beacon struct {
+	Host   string    `json:"host"`
+	Socket string    `json:"socket"`
+	Time   time.Time `json:"time"`
+	GPUs   []GPUInfo `json:"gpus"`
+	// Processes is the number of managed processes.
+	Processes int `json:"processes"`
+	// From is the sender's IP, filled in by the listener.
+	From string `json:"from,omitempty"`
 }
 
 type RunResult struct {
 	Name string `json:"name"`
 	PID  int    `json:"pid"`
+	GPU  int    `json:"gpu"`
+	// Queued is set when the run is waiting for GPU memory; Position is
+	// its place in the queue, from 1. PID and GPU are then unset.
+	Queued   bool `json:"queued,omitempty"`
+	Position int  `json:"position,omitempty"`
 }
 
-type FreezeResult struct {
+// QueuedRun is a run waiting for GPU memory.
+type QueuedRun struct {
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	Cmd       []string  `json:"cmd"`
+	GPU       int       `json:"gpu"`
+	AutoGPU   bool      `json:"auto_gpu,omitempty"`
+	MemMB     int64     `json:"mem_mb"`
+	Position  int       `json:"position"`
+	Enqueued  time.Time `json:"enqueued"`
+}
+
+// QueueParams lists the runs queued in Namespace, or all if empty.
+type QueueParams struct {
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type QueueResult struct {
+	Runs []QueuedRun `json:"runs"`
+}
+
+// QueueMoveParams moves a queued run to Position (from 1; past the end
+// means last).
+type QueueMoveParams struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Position  int    `json:"position"`
+}
+
+// GroupParams names a group of processes (RunParams.Group) for the group_
+// methods, which act on every member or, if one fails, none. GPU is where
+// group_migrate moves them.
+type GroupParams struct {
+	Namespace string `json:"namespace,omitempty"`
+	Group     string `json:"group"`
+	GPU       int    `json:"gpu,omitempty"`
+}
+
+// GroupResult lists the members a group method acted on, and those it
+// skipped for already being in the state asked for. A group_kill that
+// fails for some members still returns it, with the error, for the rest.
+type GroupResult struct {
+	Group     string   `json:"group"`
+	Processes []string `json:"processes"`
+	Skipped   []string `json:"skipped,omitempty"`
+}
+
+// Phase is the time spent in one step of a freeze or thaw, in order.
+type Phase struct {
 	Name       string `json:"name"`
 	DurationMs int64  `json:"duration_ms"`
-	MemMB      int64  `json:"mem_mb"`
 }
 
-type ThawResult struct {
+type FreezeResult struct {
+	Name       string  `json:"name"`
+	DurationMs int64   `json:"duration_ms"`
+	MemMB      int64   `json:"mem_mb"`
+	Phases     []Phase `json:"phases,omitempty"`
+	// GPUFree is the process's GPU's free memory around the freeze, when
+	// nvidia-smi could report it.
+	GPUFree *GPUMemDelta `json:"gpu_free,omitempty"`
+	// DrainMs is how long a draining freeze spent draining.
+	DrainMs int64 `json:"drain_ms,omitempty"`
+}
+
+// GPUMemDelta is a GPU's free memory before and after a freeze or thaw.
+type GPUMemDelta struct {
+	GPU      int   `json:"gpu"`
+	BeforeMB int64 `json:"before_mb"`
+	AfterMB  int64 `json:"after_mb"`
+}
+
+// DeltaMB is the change in free memory: positive when memory was released.
+func (g GPUMemDelta) DeltaMB() int64 {
+	return g.AfterMB - g.BeforeMB
+}
+
+// RecoverResult reports a degraded process brought back to active.
+type RecoverResult struct {
 	Name       string `json:"name"`
 	DurationMs int64  `json:"duration_ms"`
-	MemMB      int64  `json:"mem_mb"`
+}
+
+type ThawResult struct {
+	Name       string  `json:"name"`
+	DurationMs int64   `json:"duration_ms"`
+	MemMB      int64   `json:"mem_mb"`
+	Phases     []Phase `json:"phases,omitempty"`
+	// GPUFree is as in FreezeResult; it drops as the snapshot is restored.
+	GPUFree *GPUMemDelta `json:"gpu_free,omitempty"`
+
+	// Ready is set when the process has a readiness probe. ReadyMs is the
+	// time from restore completing until the probe first passed.
+	Ready      *bool  `json:"ready,omitempty"`
+	ReadyMs    int64  `json:"ready_ms,omitempty"`
+	ReadyError string `json:"ready_error,omitempty"`
 }
 
 type MigrateResult struct {
-	Name    string `json:"name"`
-	FromGPU int    `json:"from_gpu"`
-	ToGPU   int    `json:"to_gpu"`
+	Name     string   `json:"name"`
+	FromGPU  int      `json:"from_gpu"`
+	ToGPU    int      `json:"to_gpu"`
+	Estimate Estimate `json:"estimate"`
+	DryRun   bool     `json:"dry_run,omitempty"`
+	Warning  string   `json:"warning,omitempty"`
+}
+
+// PlanStep is one action in a dry-run placement plan: "migrate" a process
+// to ToGPU or "freeze" it to host RAM.
+type PlanStep struct {
+	Action  string `json:"action"`
+	Process string `json:"process"`
+	MemMB   int64  `json:"mem_mb"`
+	ToGPU   int    `json:"to_gpu,omitempty"`
+	// EstimatedMs is the expected time the step takes, from history.
+	EstimatedMs int64 `json:"estimated_ms,omitempty"`
+}
+
+type PlanResult struct {
+	GPU    int   `json:"gpu"`
+	AddMB  int64 `json:"add_mb"`
+	FreeMB int64 `json:"free_mb"`
+	// Steps are the actions that would run, in order. Fits reports whether
+	// they free enough memory; ShortMB is what is still missing if not.
+	Steps   []PlanStep `json:"steps,omitempty"`
+	Fits    bool       `json:"fits"`
+	ShortMB int64      `json:"short_mb,omitempty"`
 }
 
 type LogsResult struct {
 	Lines []string `json:"lines"`
+	// Matches holds, for each of Lines, the [start, end) byte offsets of
+	// highlight matches. It is omitted when no highlight was requested.
+	Matches [][][2]int `json:"matches,omitempty"`
+}
+
+// OpRecord is one completed freeze/thaw/migrate, kept in the persistent
+// operation history.
+type OpRecord struct {
+	Time       time.Time `json:"time"`
+	Op         string    `json:"op"`
+	Process    string    `json:"process"`
+	GPU        int       `json:"gpu"`
+	Tier       Tier      `json:"tier"`
+	MemMB      int64     `json:"mem_mb"`
+	DurationMs int64     `json:"duration_ms"`
+	MBps       float64   `json:"mb_per_s"`
+	Cause      string    `json:"cause,omitempty"`
+	// RequestID and User identify the request behind a user-caused
+	// operation, as they do for events.
+	RequestID string `json:"request_id,omitempty"`
+	User      string `json:"user,omitempty"`
+}
+
+type OpsHistoryParams struct {
+	Process string    `json:"process,omitempty"`
+	Op      string    `json:"op,omitempty"`
+	Limit   int       `json:"limit,omitempty"`
+	Since   time.Time `json:"since,omitempty"`
+}
+
+// EventsParams queries the persistent event log. Empty fields match
+// everything; Limit keeps the last N matches.
+type EventsParams struct {
+	Namespace string    `json:"namespace,omitempty"`
+	Process   string    `json:"process,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	Since     time.Time `json:"since,omitempty"`
+	Limit     int       `json:"limit,omitempty"`
+}
+
+type EventsResult struct {
+	Events []Event `json:"events"`
+}
+
+// OpStats aggregates history records sharing an op and tier.
+type OpStats struct {
+	Op       string  `json:"op"`
+	Tier     Tier    `json:"tier"`
+	Count    int     `json:"count"`
+	TotalMB  int64   `json:"total_mb"`
+	AvgMs    int64   `json:"avg_ms"`
+	AvgMBps  float64 `json:"avg_mb_per_s"`
+	PeakMBps float64 `json:"peak_mb_per_s"`
+}
+
+type OpsHistoryResult struct {
+	Records []OpRecord `json:"records"`
+	Stats   []OpStats  `json:"stats"`
+}
+
+// UsageRecord is one interval a process spent active on a GPU.
+type UsageRecord struct {
+	Process string    `json:"process"`
+	GPU     int       `json:"gpu"`
+	Model   string    `json:"model,omitempty"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+}
+
+type UsageParams struct {
+	// By groups usage: process, owner (namespace), gpu, or model.
+	By    string    `json:"by,omitempty"`
+	Since time.Time `json:"since,omitempty"`
+}
+
+type UsageRow struct {
+	Key      string  `json:"key"`
+	GPUHours float64 `json:"gpu_hours"`
+	Cost     float64 `json:"cost,omitempty"`
+}
+
+type UsageResult struct {
+	Since    time.Time  `json:"since"`
+	By       string     `json:"by"`
+	Rows     []UsageRow `json:"rows"`
+	GPUHours float64    `json:"gpu_hours"`
+	Cost     float64    `json:"cost,omitempty"`
+}
+
+// GCParams asks what "gc" would clean up: logs of processes the daemon no
+// longer has, untouched for RetentionMs (default a day), and processes
+// that exited longer ago than that. Apply removes them.
+type GCParams struct {
+	Apply       bool  `json:"apply,omitempty"`
+	RetentionMs int64 `json:"retention_ms,omitempty"`
+}
+
+// GCItem is one thing gc found: a log file by path or a process by
+// qualified name, and the disk space it takes. Error is why applying
+// failed to remove it.
+type GCItem struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// GCCategory is what gc found of one kind, with the space it takes.
+type GCCategory struct {
+	Name  string   `json:"name"`
+	Items []GCItem `json:"items"`
+	Bytes int64    `json:"bytes"`
+}
+
+// GCResult is what gc found, by category, and whether it removed it.
+type GCResult struct {
+	Applied    bool         `json:"applied"`
+	Categories []GCCategory `json:"categories"`
+}
+
+// GC categories.
+const (
+	GCLogs      = "logs"
+	GCProcesses = "exited processes"
+)
+
+// ApplyParams pushes specs to the daemon's desired state: processes it
+// keeps running, relaunching them if they go missing and replacing them
+// when their spec changes. A spec is named by its Name, and its
+// Namespace defaults to Namespace. Delete drops pushed specs by name,
+// qualified by Namespace, and kills their processes. Pushed specs take
+// the place of spec directory ones of the same name and last until the
+// daemon restarts.
+type ApplyParams struct {
+	Namespace string      `json:"namespace,omitempty"`
+	Specs     []RunParams `json:"specs,omitempty"`
+	Delete    []string    `json:"delete,omitempty"`
+}
+
+// ApplyAction is one step taken to bring processes in line with the
+// desired state, by qualified name: DesiredStarted, DesiredReplaced, or
+// DesiredRemoved. Error is why it failed.
+type ApplyAction struct {
+	Name   string `json:"name"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ApplyResult is what reconciling after an apply did. It may include
+// steps for spec directory changes made since the last pass.
+type ApplyResult struct {
+	Actions []ApplyAction `json:"actions"`
+}
+
+// Desired-state reconcile actions.
+const (
+	DesiredStarted  = "started"
+	DesiredReplaced = "replaced"
+	DesiredRemoved  = "removed"
+)
+
+// DebugInfo exposes daemon internals via the "debug" method.
+type DebugInfo struct {
+	EventRing   EventRingInfo     `json:"event_ring"`
+	Subscribers []SubscriberStats `json:"subscribers"`
+	Processes   int               `json:"processes"`
+	Goroutines  int               `json:"goroutines"`
+}
+
+// EventRingInfo describes occupancy of the in-memory event ring.
+type EventRingInfo struct {
+	Capacity   int            `json:"capacity"`
+	Len        int            `json:"len"`
+	ByType     map[string]int `json:"by_type"`
+	TypeLimits map[string]int `json:"type_limits,omitempty"`
 }
 
 func OkResponse(result interface{}) Response {
@@ -148,3 +1293,8 @@ func OkResponse(result interface{}) Response {
 func ErrResponse(msg string) Response {
 	return Response{OK: false, Error: msg}
 }
+
+// ErrCodeResponse is an error response of a known kind.
+func ErrCodeResponse(code, msg string) Response {
+	return Response{OK: false, Error: msg, Code: code}
+}
@@ -1,7 +1,11 @@
 package protocol
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -112,3 +116,129 @@ func TestRunParamsMarshal(t *testing.T) {
 		t.Fatalf("runparams roundtrip: %+v", p2)
 	}
 }
+
+func TestQualifiedName(t *testing.T) {
+	if got := QualifiedName("", "train"); got != "train" {
+		t.Fatalf("QualifiedName without namespace = %q", got)
+	}
+	q := QualifiedName("team-a", "train")
+	if q != "team-a/train" {
+		t.Fatalf("QualifiedName = %q", q)
+	}
+	if ns, name := SplitQualifiedName(q); ns != "team-a" || name != "train" {
+		t.Fatalf("SplitQualifiedName(%q) = %q, %q", q, ns, name)
+	}
+	if ns, name := SplitQualifiedName("train"); ns != "" || name != "train" {
+		t.Fatalf("SplitQualifiedName(train) = %q, %q", ns, name)
+	}
+}
+
+func TestUnhealthy(t *testing.T) {
+	failing := &ProbeStatus{OK: false, Error: "connection refused"}
+	if !(ProcessInfo{State: StateActive, Liveness: failing}).Unhealthy() {
+		t.Fatal("active process failing liveness should be unhealthy")
+	}
+	if (ProcessInfo{State: StateFrozen, Liveness: failing}).Unhealthy() {
+		t.Fatal("frozen process should not be unhealthy")
+	}
+	if (ProcessInfo{State: StateActive}).Unhealthy() {
+		t.Fatal("process without a liveness result should not be unhealthy")
+	}
+}
+
+func TestStatusDelta(t *testing.T) {
+	prev := StatusResult{
+		GPUs: []GPUInfo{{Index: 0, MemUsed: 1000}},
+		Processes: []ProcessInfo{
+			{Name: "a", State: StateActive, MemMB: 100},
+			{Name: "b", State: StateActive, MemMB: 200},
+			{Namespace: "alice", Name: "c", State: StateFrozen},
+		},
+	}
+	next := StatusResult{
+		GPUs: []GPUInfo{{Index: 0, MemUsed: 1300}},
+		Processes: []ProcessInfo{
+			{Name: "d", State: StateActive, MemMB: 300},
+			{Name: "b", State: StateActive, MemMB: 200},
+			{Name: "a", State: StateFrozen, MemMB: 100},
+		},
+	}
+
+	d := DiffStatus(prev, next)
+	if len(d.Added) != 1 || d.Added[0].Name != "d" || len(d.Changed) != 1 || d.Changed[0].Name != "a" {
+		t.Fatalf("added %+v, changed %+v", d.Added, d.Changed)
+	}
+	if !slices.Equal(d.Removed, []string{"alice/c"}) || d.GPUs == nil || d.Memory != nil || d.Metrics != nil {
+		t.Fatalf("unexpected delta %+v", d)
+	}
+
+	got := prev
+	d.Apply(&got)
+	var names []string
+	for _, p := range got.Processes {
+		names = append(names, p.Name)
+	}
+	if !slices.Equal(names, []string{"b", "d", "a"}) {
+		t.Fatalf("processes after apply: %v", names)
+	}
+	if got.GPUs[0].MemUsed != 1300 || prev.Processes[0].State != StateActive {
+		t.Fatalf("gpus %+v, original modified: %+v", got.GPUs, prev.Processes)
+	}
+	if !DiffStatus(next, next).Empty() {
+		t.Fatal("delta between equal statuses is not empty")
+	}
+}
+
+func TestDecoderMixedFraming(t *testing.T) {
+	var buf bytes.Buffer
+	big := Event{Type: "run", Detail: strings.Repeat("x", 2<<20)}
+	if err := WriteMessage(&buf, big, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMessage(&buf, Event{Type: "freeze"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMessage(&buf, big, false); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := NewDecoder(&buf)
+	for i, want := range []Event{big, {Type: "freeze"}, big} {
+		data, err := dec.Next()
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		var got Event
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if got.Type != want.Type || len(got.Detail) != len(want.Detail) {
+			t.Fatalf("message %d: got type=%s detail=%d bytes", i, got.Type, len(got.Detail))
+		}
+	}
+	if _, err := dec.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestRequestDecoderLimit(t *testing.T) {
+	line := append([]byte(`{"method":"`+strings.Repeat("x", MaxRequest)), '\n')
+	if _, err := NewRequestDecoder(bytes.NewReader(line)).Next(); err == nil || !strings.Contains(err.Error(), "max size") {
+		t.Fatalf("expected an oversized line to be refused, got %v", err)
+	}
+	hdr := []byte{0x01, 0, 0, 0}
+	if _, err := NewRequestDecoder(bytes.NewReader(hdr)).Next(); err == nil || !strings.Contains(err.Error(), "max size") {
+		t.Fatalf("expected an oversized frame to be refused, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	small := Request{Method: "status"}
+	WriteMessage(&buf, small, false)
+	WriteMessage(&buf, small, true)
+	dec := NewRequestDecoder(&buf)
+	for i := 0; i < 2; i++ {
+		if data, err := dec.Next(); err != nil || !strings.Contains(string(data), `"status"`) {
+			t.Fatalf("message %d: %s, %v", i, data, err)
+		}
+	}
+}
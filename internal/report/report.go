@@ -0,0 +1,134 @@
+// Package report renders periodic usage digests from daemon status and
+// operation history.
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"gpusched/internal/bytesize"
+	"gpusched/internal/protocol"
+)
+
+// TopN is how many processes the memory consumer table lists.
+const TopN = 5
+
+// Input is everything a digest covers.
+type Input struct {
+	Host    string
+	Since   time.Time
+	Until   time.Time
+	Status  protocol.StatusResult
+	History protocol.OpsHistoryResult
+}
+
+// Subject is a one-line summary suitable for an email subject.
+func Subject(in Input) string {
+	return fmt.Sprintf("gpusched report for %s: %s – %s", in.Host,
+		in.Since.Format("Jan 2 15:04"), in.Until.Format("Jan 2 15:04"))
+}
+
+// Write renders the digest as plain text.
+func Write(w io.Writer, in Input) {
+	fmt.Fprintln(w, Subject(in))
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "GPUs (now):")
+	if len(in.Status.GPUs) == 0 {
+		fmt.Fprintln(w, "  (none detected)")
+	}
+	for _, g := range in.Status.GPUs {
+		pct := 0.0
+		if g.MemTotal > 0 {
+			pct = float64(g.MemUsed) / float64(g.MemTotal) * 100
+		}
+		fmt.Fprintf(w, "  GPU %d  %-24s %s / %s (%.0f%%)\n", g.Index, g.Name,
+			bytesize.FormatMB(g.MemUsed), bytesize.FormatMB(g.MemTotal), pct)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Operations:")
+	if len(in.History.Stats) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	for _, st := range in.History.Stats {
+		fmt.Fprintf(w, "  %-8s %-4s %5d ops  %10s moved  avg %6d ms\n",
+			st.Op, st.Tier, st.Count, bytesize.FormatMB(st.TotalMB), st.AvgMs)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "Top memory consumers (peak):\n")
+	top := topConsumers(in)
+	if len(top) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	for _, c := range top {
+		fmt.Fprintf(w, "  %-24s %10s  %d freezes\n", c.name, bytesize.FormatMB(c.peakMB), c.freezes)
+	}
+
+	active, frozen := 0, 0
+	for _, p := range in.Status.Processes {
+		switch p.State {
+		case protocol.StateActive:
+			active++
+		case protocol.StateFrozen:
+			frozen++
+		}
+	}
+	fmt.Fprintf(w, "\nNow: %d active, %d frozen, %s of snapshots in host RAM\n",
+		active, frozen, bytesize.FormatMB(in.Status.Memory.SnapshotsMB))
+}
+
+type consumer struct {
+	name    string
+	peakMB  int64
+	freezes int
+}
+
+// topConsumers ranks processes by the most GPU memory seen either in the
+// period's operations or in the current status.
+func topConsumers(in Input) []consumer {
+	byName := make(map[string]*consumer)
+	get := func(name string) *consumer {
+		c, ok := byName[name]
+		if !ok {
+			c = &consumer{name: name}
+			byName[name] = c
+		}
+		return c
+	}
+	for _, r := range in.History.Records {
+		c := get(r.Process)
+		if r.MemMB > c.peakMB {
+			c.peakMB = r.MemMB
+		}
+		if r.Op == "freeze" {
+			c.freezes++
+		}
+	}
+	for _, p := range in.Status.Processes {
+		c := get(protocol.QualifiedName(p.Namespace, p.Name))
+		if p.MemMB > c.peakMB {
+			c.peakMB = p.MemMB
+		}
+	}
+
+	out := make([]consumer, 0, len(byName))
+	for _, c := range byName {
+		if c.peakMB > 0 {
+			out = append(out, *c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].peakMB != out[j].peakMB {
+			return out[i].peakMB > out[j].peakMB
+		}
+		return out[i].name < out[j].name
+	})
+	if len(out) > TopN {
+		out = out[:TopN]
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gpusched/internal/protocol"
+)
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	in := Input{
+		Host:  "gpu-box",
+		Since: now.Add(-24 * time.Hour),
+		Until: now,
+		Status: protocol.StatusResult{
+			GPUs: []protocol.GPUInfo{{Index: 0, Name: "H100", MemTotal: 81920, MemUsed: 40960}},
+			Processes: []protocol.ProcessInfo{
+				{Namespace: "alice", Name: "serve", State: protocol.StateActive, MemMB: 30000},
+			},
+		},
+		History: protocol.OpsHistoryResult{
+			Records: []protocol.OpRecord{
+				{Op: "freeze", Process: "bob/train", MemMB: 60000},
+				{Op: "thaw", Process: "bob/train", MemMB: 60000},
+				{Op: "freeze", Process: "bob/train", MemMB: 58000},
+			},
+			Stats: []protocol.OpStats{{Op: "freeze", Tier: protocol.TierRAM, Count: 2, TotalMB: 118000, AvgMs: 900}},
+		},
+	}
+
+	var b strings.Builder
+	Write(&b, in)
+	out := b.String()
+
+	for _, want := range []string{"gpu-box", "H100", "freeze", "bob/train", "2 freezes", "alice/serve", "1 active, 0 frozen"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "bob/train") > strings.Index(out, "alice/serve") {
+		t.Errorf("expected bob/train ranked above alice/serve:\n%s", out)
+	}
+}
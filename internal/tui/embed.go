@@ -0,0 +1,127 @@
+package tui
+
+import (
+	"slices"
+	"strings"
+
+	"gpusched/internal/protocol"
+)
+
+// KeyMap lists the keys bound to each dashboard action. An action with no
+// keys can't be triggered from the keyboard.
+type KeyMap struct {
+	Up     []string
+	Down   []string
+	Freeze []string
+	Thaw   []string
+	Kill   []string
+	New    []string
+	// Quit closes the dashboard's connections and quits the program. A
+	// host application embedding the dashboard usually leaves it empty
+	// and calls Close itself.
+	Quit []string
+}
+
+// DefaultKeyMap is the key map of the standalone dashboard.
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Up:     []string{"up", "k"},
+		Down:   []string{"down", "j"},
+		Freeze: []string{"f"},
+		Thaw:   []string{"t"},
+		Kill:   []string{"x"},
+		New:    []string{"n"},
+		Quit:   []string{"q", "ctrl+c"},
+	}
+}
+
+// Panel is an extra section shown below the dashboard's events. Render
+// gets the dashboard as it is about to be drawn and returns the section's
+// body; Title is drawn above it as a header.
+type Panel struct {
+	Title  string
+	Render func(m Model) string
+}
+
+// WithKeys replaces the dashboard's key bindings.
+func (m Model) WithKeys(keys KeyMap) Model {
+	m.keys = keys
+	return m
+}
+
+// WithoutActions disables the named actions, "freeze", "thaw", "kill",
+// and "run", hiding them from the help line and ignoring their keys.
+func (m Model) WithoutActions(actions ...string) Model {
+	m.disabled = append(slices.Clone(m.disabled), actions...)
+	return m
+}
+
+// WithPanels adds panels below the dashboard's events, in order.
+func (m Model) WithPanels(panels ...Panel) Model {
+	m.panels = append(slices.Clone(m.panels), panels...)
+	return m
+}
+
+// Status returns the daemon status the dashboard last received.
+func (m Model) Status() protocol.StatusResult {
+	return m.status
+}
+
+// Selected returns the process under the cursor, or false if there are
+// no processes.
+func (m Model) Selected() (protocol.ProcessInfo, bool) {
+	if m.cursor >= len(m.status.Processes) {
+		return protocol.ProcessInfo{}, false
+	}
+	return m.status.Processes[m.cursor], true
+}
+
+// Close stops the dashboard's event stream and closes its
+// command connection. Quit does this itself; a host application that
+// unbinds Quit calls Close when it is done with the dashboard.
+func (m Model) Close() {
+	if m.cancelFn != nil {
+		m.cancelFn()
+	}
+	if m.cmdConn != nil {
+		m.cmdConn.Close()
+	}
+}
+
+func (m Model) enabled(action string) bool {
+	return !slices.Contains(m.disabled, action)
+}
+
+// helpLine lists the enabled actions under their first key.
+func (m Model) helpLine() string {
+	var parts []string
+	if len(m.keys.Up) > 0 && len(m.keys.Down) > 0 {
+		parts = append(parts, keyLabel(m.keys.Up[0])+keyLabel(m.keys.Down[0])+":select")
+	}
+	for _, a := range []struct {
+		keys   []string
+		action string
+		label  string
+	}{
+		{m.keys.Freeze, "freeze", "freeze"},
+		{m.keys.Thaw, "thaw", "thaw"},
+		{m.keys.Kill, "kill", "kill"},
+		{m.keys.New, "run", "new"},
+		{m.keys.Quit, "", "quit"},
+	} {
+		if len(a.keys) > 0 && (a.action == "" || m.enabled(a.action)) {
+			parts = append(parts, keyLabel(a.keys[0])+":"+a.label)
+		}
+	}
+	return "  " + strings.Join(parts, "  ")
+}
+
+func keyLabel(key string) string {
+	switch key {
+	case "up":
+		return "↑"
+	case "down":
+		return "↓"
+	}
+	return key
+}
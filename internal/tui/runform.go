@@ -0,0 +1,190 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"gpusched/internal/bytesize"
+	"gpusched/internal/protocol"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	fieldName = iota
+	fieldCmd
+	fieldGPU
+	numFields
+)
+
+// runForm is the quick-run dialog opened with 'n'.
+type runForm struct {
+	name   string
+	cmd    string
+	gpuIdx int // index into the status GPU list
+	focus  int
+	err    string
+}
+
+// update applies a key to the form. It returns submit=true when the user
+// confirms and cancel=true when the dialog should close without running.
+func (f *runForm) update(msg tea.KeyMsg, gpus []protocol.GPUInfo) (submit, cancel bool) {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		return false, true
+	case tea.KeyEnter:
+		if f.focus < fieldGPU {
+			f.focus++
+			return false, false
+		}
+		return true, false
+	case tea.KeyTab, tea.KeyDown:
+		f.focus = (f.focus + 1) % numFields
+	case tea.KeyShiftTab, tea.KeyUp:
+		f.focus = (f.focus + numFields - 1) % numFields
+	case tea.KeyLeft:
+		if f.focus == fieldGPU && f.gpuIdx > 0 {
+			f.gpuIdx--
+		}
+	case tea.KeyRight:
+		if f.focus == fieldGPU && f.gpuIdx < len(gpus)-1 {
+			f.gpuIdx++
+		}
+	case tea.KeyBackspace:
+		if s := f.field(); s != nil && len(*s) > 0 {
+			r := []rune(*s)
+			*s = string(r[:len(r)-1])
+		}
+	case tea.KeySpace:
+		if s := f.field(); s != nil {
+			*s += " "
+		}
+	case tea.KeyRunes:
+		if s := f.field(); s != nil {
+			*s += string(msg.Runes)
+		}
+	}
+	return false, false
+}
+
+func (f *runForm) field() *string {
+	switch f.focus {
+	case fieldName:
+		return &f.name
+	case fieldCmd:
+		return &f.cmd
+	}
+	return nil
+}
+
+// params validates the form and builds the run request.
+func (f *runForm) params(gpus []protocol.GPUInfo) (protocol.RunParams, error) {
+	args, err := splitArgs(f.cmd)
+	if err != nil {
+		return protocol.RunParams{}, err
+	}
+	if len(args) == 0 {
+		return protocol.RunParams{}, fmt.Errorf("command is required")
+	}
+	name := strings.TrimSpace(f.name)
+	if name == "" {
+		name = args[0]
+	}
+	gpuID := 0
+	if f.gpuIdx < len(gpus) {
+		gpuID = gpus[f.gpuIdx].Index
+	}
+	return protocol.RunParams{Name: name, Cmd: args, GPU: gpuID}, nil
+}
+
+func (f *runForm) view(gpus []protocol.GPUInfo) string {
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("  NEW PROCESS") + "\n\n")
+
+	label := func(i int, text string) string {
+		if i == f.focus {
+			return boldStyle.Render("▸ " + text)
+		}
+		return dimStyle.Render("  " + text)
+	}
+	caret := func(i int) string {
+		if i == f.focus {
+			return "█"
+		}
+		return ""
+	}
+
+	b.WriteString(fmt.Sprintf("  %s %s%s\n", label(fieldName, "Name:   "), f.name, caret(fieldName)))
+	b.WriteString(fmt.Sprintf("  %s %s%s\n", label(fieldCmd, "Command:"), f.cmd, caret(fieldCmd)))
+
+	gpuLine := dimStyle.Render("GPU 0")
+	if len(gpus) > 0 {
+		var opts []string
+		for i, g := range gpus {
+			opt := fmt.Sprintf("GPU %d (%s free)", g.Index, bytesize.FormatMB(g.MemFree))
+			if i == f.gpuIdx {
+				opt = activeStyle.Render("[" + opt + "]")
+			} else {
+				opt = dimStyle.Render(" " + opt + " ")
+			}
+			opts = append(opts, opt)
+		}
+		gpuLine = strings.Join(opts, " ")
+	}
+	b.WriteString(fmt.Sprintf("  %s %s\n\n", label(fieldGPU, "GPU:    "), gpuLine))
+
+	if f.err != "" {
+		b.WriteString(warnStyle.Render("  "+f.err) + "\n\n")
+	}
+	b.WriteString(helpStyle.Render("  tab:next field  ←→:GPU  enter:run  esc:cancel") + "\n")
+	return b.String()
+}
+
+// splitArgs splits a command line into words, honouring single quotes,
+// double quotes, and backslash escapes. It does not expand anything.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' && i+1 < len(runes) {
+				i++
+				cur.WriteRune(runes[i])
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\' && i+1 < len(runes):
+			i++
+			cur.WriteRune(runes[i])
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
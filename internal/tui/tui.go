@@ -4,9 +4,11 @@ package tui
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
+	"gpusched/internal/bytesize"
 	"gpusched/internal/client"
 	"gpusched/internal/protocol"
 
@@ -46,6 +48,7 @@ var (
 	logoLine3 = lipgloss.NewStyle().Foreground(lipgloss.Color("#7D56F4"))
 )
 
+// Model is the dashboard's bubbletea model.
 type Model struct {
 	client   *client.Client
 	status   protocol.StatusResult
@@ -57,29 +60,77 @@ type Model struct {
 	height   int
 	err      error
 	cmdConn  *client.Command
+	form     *runForm
+
+	// retry is how long to wait before resubscribing after the event
+	// stream is lost; 0 while it is up.
+	retry time.Duration
+
+	// notice is the latest notification pushed on cmdConn: something
+	// happened to a process that nobody in the dashboard asked for.
+	notice   *protocol.Event
+	cmdNotes <-chan protocol.Event
+
+	// namespace is where the run form starts processes. The dashboard
+	// itself shows every namespace.
+	namespace string
+
+	// keys, disabled, and panels are set by WithKeys, WithoutActions, and
+	// WithPanels for applications embedding the dashboard.
+	keys     KeyMap
+	disabled []string
+	panels   []Panel
 }
 
-func NewModel(c *client.Client) Model {
-	return Model{client: c, width: 80, height: 24}
+// statusInterval is how often the daemon pushes what changed in status.
+const statusInterval = 2 * time.Second
+
+// After losing the event stream, such as when the daemon restarts or
+// drops a slow subscriber, the dashboard resubscribes after a backoff
+// from resubscribeMin, doubling up to resubscribeMax.
+const (
+	resubscribeMin = time.Second
+	resubscribeMax = 30 * time.Second
+)
+
+// NewModel returns the dashboard for the daemon c talks to. The run form
+// starts processes in namespace. Model is a tea.Model, so an application
+// can embed it by forwarding messages to Update and drawing View.
+func NewModel(c *client.Client, namespace string) Model {
+	return Model{client: c, width: 80, height: 24, namespace: namespace, keys: DefaultKeyMap()}
 }
 
 type eventMsg protocol.Event
+type noticeMsg protocol.Event
 type statusMsg protocol.StatusResult
 type errMsg error
-type tickMsg time.Time
+
+// streamLostMsg reports that the event stream ended or couldn't be opened.
+type streamLostMsg struct{ err error }
+type resubscribeMsg struct{}
 
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(
-		m.subscribe(),
-		m.tick(),
-	)
+	return m.subscribe()
+}
+
+// waitForNotice reads the next notification from the command connection.
+// When the connection closes there is nothing more to wait for; actions
+// report the failure themselves.
+func waitForNotice(ch <-chan protocol.Event) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-ch
+		if !ok {
+			return nil
+		}
+		return noticeMsg(event)
+	}
 }
 
 func (m Model) subscribe() tea.Cmd {
 	return func() tea.Msg {
-		status, ch, cancel, err := m.client.Subscribe()
+		status, ch, cancel, err := m.client.SubscribeDeltas(statusInterval)
 		if err != nil {
-			return errMsg(err)
+			return streamLostMsg{err}
 		}
 		m.eventCh = ch
 		m.cancelFn = cancel
@@ -93,17 +144,14 @@ type initMsg struct {
 	cancel func()
 }
 
-func (m Model) tick() tea.Cmd {
-	return tea.Tick(2*time.Second, func(t time.Time) tea.Msg {
-		return tickMsg(t)
-	})
-}
-
 func waitForEvent(ch <-chan protocol.Event) tea.Cmd {
 	return func() tea.Msg {
 		event, ok := <-ch
 		if !ok {
-			return errMsg(fmt.Errorf("event stream closed"))
+			return streamLostMsg{fmt.Errorf("event stream closed")}
+		}
+		if event.Type == "disconnect" {
+			return streamLostMsg{fmt.Errorf("disconnected by daemon: %s", event.Detail)}
 		}
 		return eventMsg(event)
 	}
@@ -117,29 +165,48 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case initMsg:
+		if m.retry > 0 {
+			// Back after losing the stream: the old connections are done
+			// with, and so is the error.
+			m.Close()
+			m.cmdConn, m.cmdNotes = nil, nil
+			m.err = nil
+			m.retry = 0
+		}
 		m.status = msg.status
 		m.eventCh = msg.ch
 		m.cancelFn = msg.cancel
 		m.events = append(m.events, m.status.Events...)
 
 		conn, err := m.client.OpenCommand()
-		if err == nil {
-			m.cmdConn = conn
+		if err != nil {
+			return m, waitForEvent(m.eventCh)
+		}
+		m.cmdConn = conn
+		notes, err := conn.Notify("")
+		if err != nil {
+			return m, waitForEvent(m.eventCh)
 		}
+		m.cmdNotes = notes
+		return m, tea.Batch(waitForEvent(m.eventCh), waitForNotice(notes))
 
-		return m, waitForEvent(m.eventCh)
+	case noticeMsg:
+		event := protocol.Event(msg)
+		m.notice = &event
+		return m, waitForNotice(m.cmdNotes)
 
 	case eventMsg:
 		event := protocol.Event(msg)
+		if event.Delta != nil {
+			event.Delta.Apply(&m.status)
+			return m, waitForEvent(m.eventCh)
+		}
 		m.events = append(m.events, event)
 		if len(m.events) > 100 {
 			m.events = m.events[len(m.events)-50:]
 		}
 		return m, waitForEvent(m.eventCh)
 
-	case tickMsg:
-		return m, tea.Batch(m.refreshStatus(), m.tick())
-
 	case statusMsg:
 		m.status = protocol.StatusResult(msg)
 		return m, nil
@@ -148,64 +215,136 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.err = msg
 		return m, nil
 
+	case streamLostMsg:
+		m.err = msg.err
+		if m.retry == 0 {
+			m.retry = resubscribeMin
+		} else {
+			m.retry = min(2*m.retry, resubscribeMax)
+		}
+		return m, tea.Tick(m.retry, func(time.Time) tea.Msg { return resubscribeMsg{} })
+
+	case resubscribeMsg:
+		return m, m.subscribe()
+
 	case tea.KeyMsg:
 		return m.handleKey(msg)
 	}
 	return m, nil
 }
 
-func (m Model) refreshStatus() tea.Cmd {
-	return func() tea.Msg {
-		resp, err := m.client.Call("status", nil)
-		if err != nil {
-			return errMsg(err)
-		}
-		if !resp.OK {
-			return errMsg(fmt.Errorf("%s", resp.Error))
-		}
-		var s protocol.StatusResult
-		json.Unmarshal(resp.Result, &s)
-		return statusMsg(s)
+func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.form != nil {
+		return m.handleFormKey(msg)
 	}
-}
 
-func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "q", "ctrl+c":
-		if m.cancelFn != nil {
-			m.cancelFn()
-		}
-		if m.cmdConn != nil {
-			m.cmdConn.Close()
-		}
+	key := msg.String()
+	switch {
+	case slices.Contains(m.keys.Quit, key):
+		m.Close()
 		return m, tea.Quit
 
-	case "up", "k":
+	case slices.Contains(m.keys.Up, key):
 		if m.cursor > 0 {
 			m.cursor--
 		}
-	case "down", "j":
+	case slices.Contains(m.keys.Down, key):
 		if m.cursor < len(m.status.Processes)-1 {
 			m.cursor++
 		}
 
-	case "f":
+	case slices.Contains(m.keys.Freeze, key) && m.enabled("freeze"):
+		if err := m.checkpointable(); err != nil {
+			m.err = err
+			return m, nil
+		}
 		return m, m.doAction("freeze")
-	case "t":
+	case slices.Contains(m.keys.Thaw, key) && m.enabled("thaw"):
+		if err := m.checkpointable(); err != nil {
+			m.err = err
+			return m, nil
+		}
 		return m, m.doAction("thaw")
-	case "x":
+	case slices.Contains(m.keys.Kill, key) && m.enabled("kill"):
 		return m, m.doAction("kill")
+	case slices.Contains(m.keys.New, key) && m.enabled("run"):
+		m.form = &runForm{gpuIdx: mostFreeGPU(m.status.GPUs)}
 	}
 	return m, nil
 }
 
+func (m Model) handleFormKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	submit, cancel := m.form.update(msg, m.status.GPUs)
+	if cancel {
+		m.form = nil
+		return m, nil
+	}
+	if !submit {
+		return m, nil
+	}
+	params, err := m.form.params(m.status.GPUs)
+	if err != nil {
+		m.form.err = err.Error()
+		return m, nil
+	}
+	m.form = nil
+	params.Namespace = m.namespace
+	return m, m.doRun(params)
+}
+
+func (m Model) doRun(params protocol.RunParams) tea.Cmd {
+	if m.cmdConn == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		resp, err := m.cmdConn.Call("run", params)
+		if err != nil {
+			return errMsg(err)
+		}
+		if !resp.OK {
+			return errMsg(fmt.Errorf("%s", resp.Error))
+		}
+		resp2, _ := m.cmdConn.Call("status", nil)
+		var s protocol.StatusResult
+		if resp2.OK {
+			json.Unmarshal(resp2.Result, &s)
+		}
+		return statusMsg(s)
+	}
+}
+
+// mostFreeGPU returns the index in gpus with the most free memory.
+func mostFreeGPU(gpus []protocol.GPUInfo) int {
+	best := 0
+	for i, g := range gpus {
+		if g.MemFree > gpus[best].MemFree {
+			best = i
+		}
+	}
+	return best
+}
+
+// checkpointable reports why the selected process's GPU can't be frozen or
+// thawed, or nil if it can (or the daemon didn't say).
+func (m Model) checkpointable() error {
+	if len(m.status.Processes) == 0 {
+		return nil
+	}
+	gpu := m.status.Processes[m.cursor].GPU
+	c, ok := m.status.GPUCaps[gpu]
+	if !ok || c.Checkpoint {
+		return nil
+	}
+	return fmt.Errorf("GPU %d does not support freeze/thaw: %s", gpu, strings.Join(c.Notes, "; "))
+}
+
 func (m Model) doAction(method string) tea.Cmd {
 	if m.cmdConn == nil || len(m.status.Processes) == 0 {
 		return nil
 	}
 	proc := m.status.Processes[m.cursor]
 	return func() tea.Msg {
-		resp, err := m.cmdConn.Call(method, protocol.NameParams{Name: proc.Name})
+		resp, err := m.cmdConn.Call(method, protocol.NameParams{Namespace: proc.Namespace, Name: proc.Name})
 		if err != nil {
 			return errMsg(err)
 		}
@@ -229,7 +368,7 @@ func (m Model) View() string {
 	if len(m.status.GPUs) > 0 {
 		g := m.status.GPUs[0]
 		gpuName = g.Name
-		gpuMem = fmt.Sprintf("%d GB", g.MemTotal/1024)
+		gpuMem = bytesize.FormatMB(g.MemTotal)
 	}
 
 	logo := logoLine1.Render("  ╔═╗╔═╗╦ ╦╔═╗╔═╗╦ ╦╔═╗╔╦╗") + "\n" +
@@ -241,38 +380,73 @@ func (m Model) View() string {
 	for _, g := range m.status.GPUs {
 		label := fmt.Sprintf("GPU %d", g.Index)
 		bar := renderBar(g.MemUsed, g.MemTotal, 30)
-		info := fmt.Sprintf("%d / %d MB", g.MemUsed, g.MemTotal)
-		b.WriteString(fmt.Sprintf("  %-6s %s  %s\n", label, bar, dimStyle.Render(info)))
+		info := fmt.Sprintf("%s / %s", bytesize.FormatMB(g.MemUsed), bytesize.FormatMB(g.MemTotal))
+		clocks := ""
+		if g.SMClockMHz > 0 {
+			clocks = dimStyle.Render(fmt.Sprintf("  %d/%d MHz", g.SMClockMHz, g.MemClockMHz))
+		}
+		var throttles []string
+		for _, r := range g.ThrottleReasons {
+			if r != "idle" {
+				throttles = append(throttles, r)
+			}
+		}
+		if len(throttles) > 0 {
+			clocks += warnStyle.Render("  ⚠ " + strings.Join(throttles, ","))
+		}
+		b.WriteString(fmt.Sprintf("  %-6s %s  %s%s\n", label, bar, dimStyle.Render(info), clocks))
 	}
 
 	mem := m.status.Memory
 	if mem.HostRAMTotalMB > 0 {
 		used := mem.HostRAMTotalMB - mem.HostRAMFreeMB
 		bar := renderBar(used, mem.HostRAMTotalMB, 30)
-		info := fmt.Sprintf("%d / %d MB", used, mem.HostRAMTotalMB)
+		info := fmt.Sprintf("%s / %s", bytesize.FormatMB(used), bytesize.FormatMB(mem.HostRAMTotalMB))
 		snapInfo := ""
 		if mem.SnapshotsMB > 0 {
-			snapInfo = fmt.Sprintf("  snapshots: %d MB", mem.SnapshotsMB)
+			snapInfo = "  snapshots: " + bytesize.FormatMB(mem.SnapshotsMB)
 		}
 		b.WriteString(fmt.Sprintf("  %-6s %s  %s%s\n", "RAM", bar, dimStyle.Render(info), dimStyle.Render(snapInfo)))
 	}
 	b.WriteString("\n")
 
+	if m.form != nil {
+		b.WriteString(m.form.view(m.status.GPUs))
+		return b.String()
+	}
+
 	b.WriteString(headerStyle.Render("  PROCESSES") + "\n\n")
 	if len(m.status.Processes) == 0 {
 		b.WriteString(dimStyle.Render("  (no processes — use 'gpusched run' to start one)") + "\n")
 	} else {
-		b.WriteString(dimStyle.Render("  NAME              STATE         MEM        AGE") + "\n")
+		b.WriteString(dimStyle.Render("  NAME              STATE         MEM        CPU     RSS        AGE") + "\n")
 		for i, p := range m.status.Processes {
 			cursor := "  "
 			if i == m.cursor {
 				cursor = boldStyle.Render("▸ ")
 			}
 
-			icon, nameStyled := stateStyle(p.State, p.Name)
+			icon, nameStyled := stateStyle(p.State, protocol.QualifiedName(p.Namespace, p.Name))
 			state := stateLabel(p.State)
-			mem := fmt.Sprintf("%d MB", p.MemMB)
-			line := fmt.Sprintf("%s%-18s%-14s%-11s%s", cursor, icon+" "+nameStyled, state, mem, dimStyle.Render(p.Age))
+			if p.Tier == protocol.TierRAMSwapped {
+				state = frozenStyle.Render("frozen/swap")
+			}
+			if p.Parked {
+				state = frozenStyle.Render("parked")
+			}
+			if p.WaitingForSlice() {
+				state = frozenStyle.Render("waiting")
+			}
+			if p.Unhealthy() {
+				icon, state = warnStyle.Render("!"), warnStyle.Render("unhealthy")
+			}
+			mem := bytesize.FormatMB(p.MemMB)
+			cpu := fmt.Sprintf("%.0f%%", p.CPUPercent)
+			rss := bytesize.FormatMB(p.RSSMB)
+			line := fmt.Sprintf("%s%-18s%-14s%-11s%-8s%-11s%s", cursor, icon+" "+nameStyled, state, mem, cpu, rss, dimStyle.Render(p.Age))
+			if e := p.ThawEstimate; e != nil && e.Ms > 0 {
+				line += dimStyle.Render(fmt.Sprintf("  thaw ~%s", (time.Duration(e.Ms) * time.Millisecond).Round(100*time.Millisecond)))
+			}
 			b.WriteString(line + "\n")
 		}
 	}
@@ -313,6 +487,9 @@ func (m Model) View() string {
 		if e.Detail != "" {
 			detail = " " + e.Detail
 		}
+		if e.Cause != "" && e.Cause != protocol.CauseUser {
+			detail += dimStyle.Render(" [" + e.Cause + "]")
+		}
 		proc := ""
 		if e.Process != "" {
 			proc = " " + boldStyle.Render(e.Process)
@@ -321,16 +498,26 @@ func (m Model) View() string {
 	}
 	b.WriteString("\n")
 
+	for _, panel := range m.panels {
+		b.WriteString(headerStyle.Render("  "+strings.ToUpper(panel.Title)) + "\n\n")
+		b.WriteString(strings.TrimRight(panel.Render(m), "\n") + "\n\n")
+	}
+
 	caps := m.status.Caps
 	capStr := dimStyle.Render(fmt.Sprintf("  cuda-checkpoint: %s  driver: %s",
 		boolStr(caps.CUDACheckpoint), caps.DriverVersion))
 	b.WriteString(capStr + "\n\n")
 
+	if m.notice != nil {
+		b.WriteString(warnStyle.Render(fmt.Sprintf("  %s  %s %s: %s",
+			m.notice.Time.Format("15:04:05"), m.notice.Type, m.notice.Process, m.notice.Detail)) + "\n\n")
+	}
+
 	if m.err != nil {
 		b.WriteString(warnStyle.Render(fmt.Sprintf("  ERROR: %v", m.err)) + "\n\n")
 	}
 
-	b.WriteString(helpStyle.Render("  ↑↓:select  f:freeze  t:thaw  x:kill  q:quit"))
+	b.WriteString(helpStyle.Render(m.helpLine()))
 	b.WriteString("\n")
 
 	return b.String()
@@ -364,6 +551,8 @@ func stateStyle(state protocol.ProcessState, name string) (string, string) {
 		return activeStyle.Render("●"), activeStyle.Render(name)
 	case protocol.StateFrozen:
 		return frozenStyle.Render("○"), frozenStyle.Render(name)
+	case protocol.StateDegraded:
+		return warnStyle.Render("!"), warnStyle.Render(name)
 	default:
 		return deadStyle.Render("✕"), deadStyle.Render(name)
 	}
@@ -375,6 +564,8 @@ func stateLabel(state protocol.ProcessState) string {
 		return activeStyle.Render("active")
 	case protocol.StateFrozen:
 		return frozenStyle.Render("frozen")
+	case protocol.StateDegraded:
+		return warnStyle.Render("degraded")
 	default:
 		return deadStyle.Render("dead")
 	}
@@ -384,14 +575,22 @@ func eventTypeLabel(typ string) string {
 	switch typ {
 	case "freeze":
 		return frozenStyle.Render("FREEZE")
+	case "idle":
+		return frozenStyle.Render("IDLE")
 	case "thaw":
 		return activeStyle.Render("THAW")
+	case "ready":
+		return activeStyle.Render("READY")
+	case "not-ready":
+		return warnStyle.Render("NOT READY")
 	case "run":
 		return activeStyle.Render("RUN")
 	case "kill":
 		return deadStyle.Render("KILL")
 	case "exit":
 		return deadStyle.Render("EXIT")
+	case "reconcile", "liveness-failed", "freeze-capped", "oom-killed", "drain-failed", "rendezvous-warning", "ram-budget", "ram-margin", "gpu-removed", "gpu-mem-limit", "deadline":
+		return warnStyle.Render(strings.ToUpper(typ))
 	case "migrate":
 		return lipgloss.NewStyle().Foreground(lipgloss.Color("#E5C07B")).Render("MIGRATE")
 	default:
@@ -406,8 +605,8 @@ func boolStr(b bool) string {
 	return deadStyle.Render("✗")
 }
 
-func Run(c *client.Client) error {
-	p := tea.NewProgram(NewModel(c), tea.WithAltScreen())
+func Run(c *client.Client, namespace string) error {
+	p := tea.NewProgram(NewModel(c, namespace), tea.WithAltScreen())
 	_, err := p.Run()
 	return err
 }
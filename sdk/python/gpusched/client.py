@@ -3,6 +3,7 @@
 from __future__ import annotations
 
 import json
+import os
 import socket
 import time
 from typing import Any
@@ -61,29 +62,78 @@ class GpuSched:
         finally:
             sock.close()
 
-    def run(self, name: str, cmd: list[str], gpu: int = 0) -> dict:
-        """Spawn a managed GPU process."""
+    def run(self, name: str, cmd: list[str], gpu: int | str = "auto") -> dict:
+        """Spawn a managed GPU process on *gpu*, or wherever the daemon's
+        placement strategy picks if it is ``"auto"``."""
+        if gpu == "auto":
+            return self._call("run", {"name": name, "cmd": cmd, "auto_gpu": True})
         return self._call("run", {"name": name, "cmd": cmd, "gpu": gpu})
 
-    def freeze(self, name: str) -> dict:
-        """Checkpoint a process from GPU to host RAM."""
-        return self._call("freeze", {"name": name})
+    def freeze(
+        self, name: str, drain: bool = False, drain_timeout: float = 0
+    ) -> dict:
+        """Checkpoint a process from GPU to host RAM.
+
+        With *drain*, the process first stops taking new work and finishes
+        its in-flight requests (waiting up to *drain_timeout* seconds); the
+        freeze is refused if they don't finish.
+        """
+        params: dict = {"name": name}
+        if drain:
+            params["drain"] = True
+        if drain_timeout:
+            params["drain_timeout_ms"] = int(drain_timeout * 1000)
+        return self._call("freeze", params)
 
     def thaw(self, name: str) -> dict:
         """Restore a frozen process back to the GPU."""
         return self._call("thaw", {"name": name})
 
+    def recover(self, name: str) -> dict:
+        """Return a process degraded by a failed freeze or thaw to active."""
+        return self._call("recover", {"name": name})
+
     def kill(self, name: str) -> dict:
         """Terminate a managed process."""
         return self._call("kill", {"name": name})
 
-    def status(self) -> dict:
-        """Return full system state."""
-        return self._call("status")
+    def status(self, cluster: bool = False) -> dict:
+        """Return full system state, with the daemon's peers if *cluster*."""
+        return self._call("status", {"cluster": True} if cluster else None)
 
-    def logs(self, name: str, lines: int = 50) -> dict:
-        """Return recent stdout/stderr for a process."""
-        return self._call("logs", {"name": name, "lines": lines})
+    def logs(
+        self, name: str, lines: int = 50, grep: str = "", invert: bool = False
+    ) -> dict:
+        """Return recent stdout/stderr for a process.
+
+        *grep* is a regular expression applied by the daemon; with *invert*
+        only non-matching lines are returned.
+        """
+        params: dict = {"name": name, "lines": lines}
+        if grep:
+            params["grep"] = grep
+        if invert:
+            params["invert"] = True
+        return self._call("logs", params)
+
+    def report_checkpoint(
+        self, step: int = 0, detail: str = "", name: str | None = None
+    ) -> dict:
+        """Record that a process saved a checkpoint of its own.
+
+        *name* defaults to ``$GPUSCHED_NAME``, set for every process the
+        daemon runs, so training code can call this after each save.
+        ``status`` and ``describe`` show the last one reported.
+        """
+        name = name or os.environ.get("GPUSCHED_NAME", "")
+        if not name:
+            raise ValueError("name is required outside a managed process")
+        params: dict = {"name": name}
+        if step:
+            params["step"] = step
+        if detail:
+            params["detail"] = detail
+        return self._call("app_checkpoint", params)
 
     def swap(self, off: str, on: str) -> tuple[dict, dict]:
         """Freeze *off*, then thaw *on*."""